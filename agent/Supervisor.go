@@ -1 +1,476 @@
 package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"eino_test/components/models"
+	"eino_test/components/state"
+	"eino_test/config"
+	"eino_test/history"
+	"eino_test/outline"
+	"eino_test/persona"
+	"eino_test/prompts"
+	"eino_test/review"
+	"eino_test/tools"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/adk/prebuilt/supervisor"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Config 文档改写流程的可调参数
+type Config struct {
+	// Supervisor MainAgent 使用的模型
+	Supervisor config.ModelConfig
+	// Summary SummaryAgent 使用的模型
+	Summary config.ModelConfig
+	// Reviewer ReviewerAgent 使用的模型
+	Reviewer config.ModelConfig
+	// MaxIterations 改写-评审循环的最大迭代次数
+	MaxIterations int
+	// Persona 读者画像，会被注入到 SummaryAgent 和 ReviewerAgent 的指令中
+	Persona *persona.Persona
+	// PersonasDir 自定义读者画像目录，按名称切换画像时使用
+	PersonasDir string
+	// Language 改写后文档使用的语言
+	Language string
+	// Prompts 各 Agent 使用的提示词模板
+	Prompts *prompts.Library
+	// OutputDir 改写后文档的保存目录
+	OutputDir string
+	// SkipSave 为 true 时 ReviewerAgent 只给出评审意见，不挂载保存工具
+	SkipSave bool
+	// Feishu 飞书配置，未配置应用凭证时不挂载 save_to_feishu
+	Feishu config.FeishuConfig
+	// Review 评审通过的分数阈值和评分记录文件
+	Review config.ReviewConfig
+	// Rubric 评审标准，ReviewerAgent 对每一项打分，SummaryAgent 的改写原则也由它生成
+	Rubric *review.Rubric
+	// Lint 评审前对改写稿做的确定性格式检查，SummaryAgent 的 lint_markdown 工具也使用这份配置
+	Lint config.LintConfig
+	// CodeCheck 评审前对改写稿中 Go 代码示例的编译检查
+	CodeCheck config.CodeCheckConfig
+	// Coverage 评审前和保存时对照源文档检查改写稿是否遗漏了内容
+	Coverage config.CoverageConfig
+	// Mermaid save_document 把图表转换为图片时使用的渲染器
+	Mermaid config.MermaidConfig
+	// PostProcess save_document 写入文件前的后处理步骤
+	PostProcess config.PostProcessConfig
+	// Source 本次改写的源文件路径，由 RunRewrite 设置，用于把源文件和飞书文档对应起来，
+	// 以及对照源文档做内容覆盖检查
+	Source string
+	// Sections 长文档按章节并行改写的配置
+	Sections config.SectionsConfig
+	// HistoryDir 修订历史目录，为空时不记录
+	HistoryDir string
+	// Plan 整篇改写前的教学大纲阶段
+	Plan config.PlanConfig
+	// EditPlan Plan.Mode 为 edit 时，大纲保存到 path 后调用，返回后重新读取并校验大纲文件，
+	// 返回错误时取消改写。为 nil 时不暂停
+	EditPlan func(ctx context.Context, path string) error
+
+	// plan 教学计划：按章节改写时是全局教学计划，整篇改写时是教学大纲渲染的 Markdown
+	plan string
+	// section 按章节改写时每一节的 Agent 使用，整篇改写时为空
+	section *sectionInfo
+	// checkpoints 记录修订历史时保存改写-评审循环 checkpoint 的存储，为 nil 时不保存
+	checkpoints *history.CheckPointStore
+	// newModel 创建各个 Agent 使用的模型，为 nil 时使用 models.NewChatModel。测试时替换为不访问网络的模型
+	newModel func(ctx context.Context, mc config.ModelConfig) model.ToolCallingChatModel
+}
+
+// NewConfig 根据应用配置创建改写流程配置，rewrite.persona 指定的画像不存在、
+// rewrite.prompts_dir 中的模板或 review.rubric 指定的评审标准有误时返回错误
+func NewConfig(app *config.Config) (*Config, error) {
+	p, err := persona.Load(app.Rewrite.Persona, app.Rewrite.PersonasDir)
+	if err != nil {
+		return nil, err
+	}
+	lib, err := prompts.Load(app.Rewrite.PromptsDir)
+	if err != nil {
+		return nil, err
+	}
+	rubric, err := review.LoadRubric(app.Review.Rubric)
+	if err != nil {
+		return nil, err
+	}
+	return newConfig(app, p, lib, rubric), nil
+}
+
+func newConfig(app *config.Config, p *persona.Persona, lib *prompts.Library, rubric *review.Rubric) *Config {
+	return &Config{
+		Supervisor:    app.Agents.Supervisor,
+		Summary:       app.Agents.Summary,
+		Reviewer:      app.Agents.Reviewer,
+		MaxIterations: app.Rewrite.MaxIterations,
+		Persona:       p,
+		PersonasDir:   app.Rewrite.PersonasDir,
+		Language:      app.Rewrite.Language,
+		Prompts:       lib,
+		OutputDir:     app.Rewrite.OutputDir,
+		Review:        app.Review,
+		Rubric:        rubric,
+		Lint:          app.Lint,
+		CodeCheck:     app.CodeCheck,
+		Coverage:      app.Coverage,
+		Feishu:        app.Feishu,
+		Mermaid:       app.Mermaid,
+		PostProcess:   app.PostProcess,
+		Sections:      app.Rewrite.Sections,
+		HistoryDir:    app.Rewrite.HistoryDir,
+		Plan:          app.Rewrite.Plan,
+	}
+}
+
+// models 各个 Agent 使用的模型，记录到文档元数据和修订历史中
+func (c *Config) models() map[string]string {
+	return map[string]string{
+		"supervisor": c.Supervisor.Model,
+		"summary":    c.Summary.Model,
+		"reviewer":   c.Reviewer.Model,
+	}
+}
+
+// chatModel 按模型配置创建 Agent 使用的模型
+func (c *Config) chatModel(ctx context.Context, mc config.ModelConfig) model.ToolCallingChatModel {
+	if c.newModel != nil {
+		return c.newModel(ctx, mc)
+	}
+	return models.NewChatModel(ctx, mc)
+}
+
+// DefaultConfig 返回与原先硬编码一致的默认配置，使用内置的后端初学者画像、内置提示词和内置评审标准
+func DefaultConfig() *Config {
+	return newConfig(config.Default(), persona.Default(), prompts.Default(), review.DefaultRubric())
+}
+
+// withDefaults 用默认值补齐未设置的字段，cfg 为 nil 时返回默认配置
+func (c *Config) withDefaults() *Config {
+	def := DefaultConfig()
+	if c == nil {
+		return def
+	}
+	out := *c
+	if out.Supervisor.Model == "" {
+		out.Supervisor.Model = def.Supervisor.Model
+	}
+	if out.Summary.Model == "" {
+		out.Summary.Model = def.Summary.Model
+	}
+	if out.Reviewer.Model == "" {
+		out.Reviewer.Model = def.Reviewer.Model
+	}
+	if out.MaxIterations <= 0 {
+		out.MaxIterations = def.MaxIterations
+	}
+	if out.Persona == nil {
+		out.Persona = def.Persona
+	}
+	if out.Language == "" {
+		out.Language = def.Language
+	}
+	if out.Prompts == nil {
+		out.Prompts = def.Prompts
+	}
+	if out.OutputDir == "" {
+		out.OutputDir = def.OutputDir
+	}
+	if out.Review == (config.ReviewConfig{}) {
+		out.Review = def.Review
+	}
+	if out.Rubric == nil {
+		out.Rubric = def.Rubric
+	}
+	if out.Lint.Rules == nil {
+		out.Lint = def.Lint
+	}
+	if out.CodeCheck.Mode == "" {
+		out.CodeCheck = def.CodeCheck
+	}
+	if out.Coverage.Checks == nil {
+		out.Coverage = def.Coverage
+	}
+	if out.Mermaid.Renderer == "" {
+		out.Mermaid = def.Mermaid
+	}
+	if out.PostProcess.Stages == nil {
+		out.PostProcess = def.PostProcess
+	}
+	if out.Sections.Mode == "" {
+		out.Sections = def.Sections
+	}
+	if out.Plan.Mode == "" {
+		out.Plan = def.Plan
+	}
+	return &out
+}
+
+// NewRewriteSupervisor 使用 Supervisor 编排 MainAgent 和 SummaryAgent（内含改写-评审循环）
+func NewRewriteSupervisor(ctx context.Context, cfg *Config) (adk.Agent, error) {
+	cfg = cfg.withDefaults()
+
+	// 创建 MainAgent 作为 Supervisor
+	mainAgent := NewMainAgent(ctx, cfg)
+
+	// 创建 SummaryAgent 作为子 Agent
+	summaryAgent := NewSummaryAgent(ctx, cfg)
+
+	return supervisor.New(ctx, &supervisor.Config{
+		Supervisor: mainAgent,
+		SubAgents:  []adk.Agent{summaryAgent},
+	})
+}
+
+// NewRewriteMessages 构造发给 Supervisor 的改写请求消息
+// 注意：详细的改写原则已经在 SummaryAgent 的 Instruction 中定义，这里只传递文件路径而不是文件内容
+func NewRewriteMessages(cfg *Config, documentPath string) ([]adk.Message, error) {
+	cfg = cfg.withDefaults()
+	data := cfg.promptData()
+	data.Filepath = documentPath
+
+	system, err := cfg.Prompts.Render(prompts.RewriteSystem, data)
+	if err != nil {
+		return nil, err
+	}
+	request, err := cfg.Prompts.Render(prompts.RewriteRequest, data)
+	if err != nil {
+		return nil, err
+	}
+	return []adk.Message{schema.SystemMessage(system), schema.UserMessage(request)}, nil
+}
+
+// RewriteResult 一次改写流程的执行结果
+type RewriteResult struct {
+	// Iterations SummaryAgent 产出改写稿的次数，即实际执行的循环轮数
+	Iterations int
+	// Approved 是否有一轮评审达到了通过阈值
+	Approved bool
+	// Reviews 每一轮评审的结构化评分
+	Reviews []*review.Result
+	// OutputPath save_document 最后一次写入的文件路径，未保存时为空
+	OutputPath string
+	// Prompts 本次使用的提示词模板版本，与 OutputPath 旁的 .meta.json 中记录的一致
+	Prompts []string
+	// Sections 按章节改写时的章节数，整篇改写时为 0。按章节改写时 Iterations 是各节中最多的轮数，
+	// Approved 表示所有章节都通过了评审
+	Sections int
+	// Best 加权平均分最高的一轮评审。按章节改写时是各节最高分中最低的一个
+	Best *review.Result
+	// Fallback 达到最大迭代次数仍未通过评审，OutputPath 是程序保存的得分最高的改写稿，
+	// 文档开头带有未通过评审的标记，文末附有未解决的评审问题
+	Fallback bool
+
+	// RunID 修订历史的 run ID，未记录修订历史时为空
+	RunID string
+
+	// bestDraft Best 那一轮评审的改写稿
+	bestDraft string
+	// approvedDraft 通过评审的那一版改写稿，没有通过时为空
+	approvedDraft string
+}
+
+// Observe 根据事件更新执行结果，也可用于在运行过程中跟踪进度
+func (r *RewriteResult) Observe(event *adk.AgentEvent) {
+	if _, ok := draftOf(event); ok {
+		r.Iterations++
+	}
+	if event.Action != nil {
+		if event.Action.BreakLoop != nil {
+			r.Approved = true
+		}
+		switch action := event.Action.CustomizedAction.(type) {
+		case *tools.SavedDocumentAction:
+			r.OutputPath = action.Path
+		case *ReviewAction:
+			r.Reviews = append(r.Reviews, action.Result)
+			if r.Best == nil || action.Result.Average > r.Best.Average {
+				r.Best, r.bestDraft = action.Result, action.Draft
+			}
+			if action.Result.Passed {
+				r.approvedDraft = action.Draft
+			}
+		}
+	}
+}
+
+// RunRewrite 对指定文档执行一次完整的改写流程，每个 AgentEvent 都会交给 onEvent 处理。
+// 评审通过但 ReviewerAgent 没有保存时，由程序保存通过评审的改写稿；
+// 达到最大迭代次数仍未通过评审时，保存得分最高的改写稿并把 result.Fallback 设为 true
+func RunRewrite(ctx context.Context, cfg *Config, documentPath string, onEvent func(*adk.AgentEvent)) (*RewriteResult, error) {
+	cfg = cfg.withDefaults()
+	cfg.Source = documentPath
+
+	data, err := os.ReadFile(documentPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取源文档失败: %w", err)
+	}
+	// 先确定改写方式再记录修订历史，从 checkpoint 恢复时沿用同一种方式
+	if cfg.useSections(string(data)) {
+		cfg.Sections.Mode = "sections"
+	} else {
+		cfg.Sections.Mode = "whole"
+	}
+	return rewriteWithHistory(ctx, cfg, string(data), startHistory(cfg, documentPath), onEvent)
+}
+
+// ResumeRewrite 从 checkpoint 继续一次中断的改写，id 可以是 run ID 的前缀。
+// 沿用原来的源文件和改写方式，改写-评审循环从最后完成的一步之后继续，按章节改写时已经完成的章节不再改写
+func ResumeRewrite(ctx context.Context, cfg *Config, id string, onEvent func(*adk.AgentEvent)) (*RewriteResult, error) {
+	cfg = cfg.withDefaults()
+	if cfg.HistoryDir == "" {
+		return nil, errors.New("没有配置修订历史目录（rewrite.history_dir），无法恢复")
+	}
+	run, err := history.Open(cfg.HistoryDir, id)
+	if err != nil {
+		return nil, err
+	}
+	info := run.Info()
+	if info.Finished() {
+		return nil, fmt.Errorf("改写 %s 已经结束，不需要恢复", info.ID)
+	}
+	cfg.Source = info.Source
+	if info.Mode != "" {
+		cfg.Sections.Mode = info.Mode
+	}
+
+	data, err := os.ReadFile(info.Source)
+	if err != nil {
+		return nil, fmt.Errorf("读取源文档失败: %w", err)
+	}
+	if err := run.Resume(); err != nil {
+		return nil, err
+	}
+	return rewriteWithHistory(ctx, cfg, string(data), run, onEvent)
+}
+
+// rewriteWithHistory 运行改写流程，结束后在修订历史中记录结果
+func rewriteWithHistory(ctx context.Context, cfg *Config, source string, run *history.Run, onEvent func(*adk.AgentEvent)) (*RewriteResult, error) {
+	result, err := runRewrite(ctx, cfg, source, run, onEvent)
+	if run != nil {
+		var approved bool
+		var output string
+		if result != nil {
+			result.RunID = run.ID()
+			approved, output = result.Approved, result.OutputPath
+		}
+		if err := run.Finish(approved, output, err); err != nil {
+			log.Printf("记录修订历史失败: %v", err)
+		}
+		if err != nil && cfg.checkpoints != nil {
+			err = fmt.Errorf("%w（可以用 resume %s 从中断处继续）", err, run.ID())
+		}
+	}
+	return result, err
+}
+
+// runRewrite 按 cfg.Sections.Mode 整篇或按章节改写，run 不为 nil 时记录每一轮的修订并保存 checkpoint
+func runRewrite(ctx context.Context, cfg *Config, source string, run *history.Run, onEvent func(*adk.AgentEvent)) (*RewriteResult, error) {
+	documentPath := cfg.Source
+	if run != nil {
+		cfg.checkpoints = run.CheckPoints()
+	}
+	// 先确定教学大纲：整篇改写时 SummaryAgent 的指令和评审前的一致性检查使用它，
+	// 按章节改写时全局教学计划按它安排，拼接后的全文对照它检查
+	var o *outline.Outline
+	if cfg.Plan.Mode != "off" {
+		var err error
+		if o, err = planOutline(ctx, cfg, source, run, onEvent); err != nil {
+			return nil, fmt.Errorf("生成教学大纲失败: %w", err)
+		}
+	}
+
+	// 长文档按章节并行改写，章节不足两个时仍然整篇改写
+	if cfg.Sections.Mode == "sections" {
+		result, err := runSectionRewrite(ctx, cfg, documentPath, source, o, run, onEvent)
+		if !errors.Is(err, errTooFewSections) {
+			return result, err
+		}
+	}
+
+	initial := &state.State{Source: &state.Source{Path: documentPath, Content: source}}
+	if o != nil {
+		cfg.plan = o.Markdown()
+		initial.Outline, initial.Plan = o, cfg.plan
+	}
+
+	supervisorAgent, err := NewRewriteSupervisor(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Supervisor 失败: %w", err)
+	}
+
+	messages, err := NewRewriteMessages(cfg, documentPath)
+	if err != nil {
+		return nil, fmt.Errorf("格式化 prompt 模板失败: %w", err)
+	}
+
+	runner := adk.NewRunner(ctx, adk.RunnerConfig{Agent: supervisorAgent})
+	iter := runner.Run(ctx, messages, adk.WithSessionValues(initial.Values()))
+
+	result := &RewriteResult{Prompts: cfg.Prompts.Refs()}
+	result.restore(run, 0)
+	recorder := newRevisionRecorder(run, 0)
+	err = drain(iter, func(event *adk.AgentEvent) {
+		result.Observe(event)
+		recorder.Observe(event)
+		if onEvent != nil {
+			onEvent(event)
+		}
+	})
+	recorder.Close()
+	if err != nil {
+		return result, err
+	}
+	// 保存什么不依赖模型是否调用了保存工具：通过评审时保存通过的那一版，
+	// 达到最大迭代次数仍未通过时保存得分最高的改写稿
+	if result.Approved {
+		return result, saveApproved(ctx, cfg, documentPath, result, onEvent)
+	}
+	return result, saveBest(ctx, cfg, documentPath, result, onEvent)
+}
+
+// RunReview 只运行 ReviewerAgent，对已有文档给出评审意见
+func RunReview(ctx context.Context, cfg *Config, content string, onEvent func(*adk.AgentEvent)) error {
+	cfg = cfg.withDefaults()
+	cfg.SkipSave = true
+
+	data := cfg.promptData()
+	data.Content = content
+	query, err := cfg.Prompts.Render(prompts.ReviewRequest, data)
+	if err != nil {
+		return err
+	}
+
+	// 评审前的检查从 session 读取改写稿，单独评审时没有 SummaryAgent，直接放入待评审的内容
+	initial := &state.State{Draft: content}
+	if cfg.Source != "" {
+		data, err := os.ReadFile(cfg.Source)
+		if err != nil {
+			return fmt.Errorf("读取源文档失败: %w", err)
+		}
+		initial.Source = &state.Source{Path: cfg.Source, Content: string(data)}
+	}
+	runner := adk.NewRunner(ctx, adk.RunnerConfig{Agent: NewReviewerAgent(ctx, cfg)})
+	iter := runner.Query(ctx, query, adk.WithSessionValues(initial.Values()))
+	return drain(iter, onEvent)
+}
+
+// drain 消费事件迭代器直到结束，遇到错误事件时返回该错误
+func drain(iter *adk.AsyncIterator[*adk.AgentEvent], onEvent func(*adk.AgentEvent)) error {
+	for {
+		event, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		if event.Err != nil {
+			return event.Err
+		}
+		if onEvent != nil {
+			onEvent(event)
+		}
+	}
+}
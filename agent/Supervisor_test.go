@@ -0,0 +1,210 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"eino_test/config"
+	"eino_test/history"
+	"eino_test/review"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeModel 按 reply 给出回复的模型，不访问网络，并记录每次调用的输入。每次回复的 token 用量为 15
+type fakeModel struct {
+	mu     sync.Mutex
+	inputs [][]*schema.Message
+	reply  func(call int, input []*schema.Message) *schema.Message
+}
+
+func (m *fakeModel) Generate(_ context.Context, input []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs = append(m.inputs, input)
+	msg := m.reply(len(m.inputs), input)
+	msg.ResponseMeta = &schema.ResponseMeta{Usage: &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}}
+	return msg, nil
+}
+
+func (m *fakeModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m *fakeModel) WithTools([]*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// toolCall 构造一条调用 name 工具的模型回复
+func toolCall(t *testing.T, name string, args any) *schema.Message {
+	data, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema.AssistantMessage("", []schema.ToolCall{{
+		ID:       name + "-call",
+		Function: schema.FunctionCall{Name: name, Arguments: string(data)},
+	}})
+}
+
+// lastIsTool 模型的上一步是否调用了工具，是时 Agent 应给出最终回复
+func lastIsTool(input []*schema.Message) bool {
+	return len(input) > 0 && input[len(input)-1].Role == schema.Tool
+}
+
+// rewriteFixture 整篇改写的测试环境：MainAgent 转交给改写-评审循环，SummaryAgent 每次返回 draft，
+// ReviewerAgent 按 scores 依次提交评审，分数用完后重复最后一个
+type rewriteFixture struct {
+	cfg      *Config
+	source   string
+	reviewer *fakeModel
+}
+
+func newRewriteFixture(t *testing.T, content, draft string, scores ...int) *rewriteFixture {
+	t.Helper()
+	dir := t.TempDir()
+	source := filepath.Join(dir, "kafka.md")
+	if err := os.WriteFile(source, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Supervisor.Model, cfg.Summary.Model, cfg.Reviewer.Model = "main", "summary", "reviewer"
+	cfg.MaxIterations = 2
+	cfg.OutputDir = filepath.Join(dir, "out")
+	cfg.HistoryDir = ""
+	cfg.Plan.Mode = "off"
+	cfg.CodeCheck.Mode = "off"
+	cfg.PostProcess.Stages = []string{}
+	cfg.Review.ScoresFile = filepath.Join(dir, "scores.jsonl")
+
+	reviews := 0
+	main := &fakeModel{reply: func(call int, _ []*schema.Message) *schema.Message {
+		if call == 1 {
+			return toolCall(t, adk.TransferToAgentToolName, map[string]string{"agent_name": "文档改写Agent"})
+		}
+		return schema.AssistantMessage("改写完成", nil)
+	}}
+	summary := &fakeModel{reply: func(int, []*schema.Message) *schema.Message {
+		return schema.AssistantMessage(draft, nil)
+	}}
+	reviewer := &fakeModel{reply: func(_ int, input []*schema.Message) *schema.Message {
+		if lastIsTool(input) {
+			return schema.AssistantMessage("评审结束", nil)
+		}
+		score := scores[min(reviews, len(scores)-1)]
+		reviews++
+		sub := review.Submission{Summary: "整体评价"}
+		for _, c := range cfg.Rubric.Criteria {
+			sub.Scores = append(sub.Scores, review.Score{Criterion: c.ID, Score: score})
+			if score < cfg.Review.MinScore {
+				sub.Issues = append(sub.Issues, review.Issue{Criterion: c.ID, Anchor: "# Kafka", Problem: "问题", Suggestion: "建议"})
+			}
+		}
+		return toolCall(t, "submit_review", &sub)
+	}}
+	cfg.newModel = func(_ context.Context, mc config.ModelConfig) model.ToolCallingChatModel {
+		switch mc.Model {
+		case "main":
+			return main
+		case "summary":
+			return summary
+		default:
+			return reviewer
+		}
+	}
+	return &rewriteFixture{cfg: cfg, source: source, reviewer: reviewer}
+}
+
+// TestRunRewriteWhole 用假模型运行完整的 Supervisor，检查循环中的事件改名后仍能统计出改写轮数和评审结果
+func TestRunRewriteWhole(t *testing.T) {
+	f := newRewriteFixture(t, "# Kafka\n\n正文\n", "# Kafka\n\n改写后的正文\n", 5, 9)
+	result, err := RunRewrite(context.Background(), f.cfg, f.source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Iterations != 2 || len(result.Reviews) != 2 || !result.Approved {
+		t.Fatalf("执行结果不符合预期: iterations=%d reviews=%d approved=%v", result.Iterations, len(result.Reviews), result.Approved)
+	}
+	if result.Reviews[0].Passed || !result.Reviews[1].Passed || result.Reviews[1].Iteration != 2 {
+		t.Errorf("评审结果不符合预期: %+v %+v", result.Reviews[0], result.Reviews[1])
+	}
+
+	// ReviewerAgent 没有调用保存工具，由程序保存通过评审的改写稿
+	if result.Fallback || filepath.Base(result.OutputPath) != "kafka_改写.md" {
+		t.Fatalf("通过评审的改写稿应保存为 kafka_改写.md: %q fallback=%v", result.OutputPath, result.Fallback)
+	}
+	data, err := os.ReadFile(result.OutputPath)
+	if err != nil || !strings.Contains(string(data), "改写后的正文") {
+		t.Errorf("保存的内容不对: %q %v", data, err)
+	}
+}
+
+// TestRunRewriteHistory 测试整篇改写时每一版改写稿、评审结果、ReviewerAgent 的回复和 token 用量都记入修订历史
+func TestRunRewriteHistory(t *testing.T) {
+	f := newRewriteFixture(t, "# Kafka\n\n正文\n", "# Kafka\n\n改写后的正文\n", 5, 9)
+	f.cfg.HistoryDir = filepath.Join(t.TempDir(), "runs")
+	result, err := RunRewrite(context.Background(), f.cfg, f.source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := history.Open(f.cfg.HistoryDir, result.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revs, drafts, err := run.Revisions(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || drafts[1] != "# Kafka\n\n改写后的正文\n" {
+		t.Fatalf("修订记录不符合预期: %d 次修订", len(revs))
+	}
+	for i, rev := range revs {
+		if rev.Iteration != i+1 || rev.Review == nil || rev.Review.Iteration != i+1 {
+			t.Errorf("第 %d 次修订的评审结果不对: %+v", i+1, rev)
+		}
+		if !strings.Contains(rev.Feedback, "评审结束") {
+			t.Errorf("第 %d 次修订缺少 ReviewerAgent 的回复: %q", i+1, rev.Feedback)
+		}
+		// SummaryAgent 调用一次模型，ReviewerAgent 提交评审和最终回复各调用一次
+		if u := rev.Usage[RewriterName]; u == nil || u.Total != 15 {
+			t.Errorf("第 %d 次修订的 SummaryAgent 用量不对: %+v", i+1, u)
+		}
+		if u := rev.Usage[ReviewerName]; u == nil || u.Total != 30 {
+			t.Errorf("第 %d 次修订的 ReviewerAgent 用量不对: %+v", i+1, u)
+		}
+	}
+}
+
+// TestRunRewriteReviewerSeesSource 测试改写-评审循环运行在 Supervisor 之外的 session 中时，
+// 评审前的内容覆盖检查仍能读到 session 中的源文档：改写稿删掉了一个章节，ReviewerAgent 应收到检查报告
+func TestRunRewriteReviewerSeesSource(t *testing.T) {
+	content := "# Kafka\n\n## 安装\n\n下载安装包。\n\n## 配置\n\n修改 server.properties。\n"
+	f := newRewriteFixture(t, content, "# Kafka\n\n## 安装\n\n下载并解压安装包。\n", 9)
+	if _, err := RunRewrite(context.Background(), f.cfg, f.source, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.reviewer.inputs) == 0 {
+		t.Fatal("ReviewerAgent 没有被调用")
+	}
+	var seen bool
+	for _, msg := range f.reviewer.inputs[0] {
+		if msg.Role == schema.User && strings.Contains(msg.Content, "【内容覆盖检查】程序对照源文档") && strings.Contains(msg.Content, "配置") {
+			seen = true
+		}
+	}
+	if !seen {
+		t.Error("ReviewerAgent 没有收到对照源文档的内容覆盖检查报告")
+	}
+}
@@ -0,0 +1,288 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"eino_test/components/state"
+	"eino_test/history"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
+)
+
+// wholeCheckpointID 整篇改写时改写-评审循环的 checkpoint ID，按章节改写时每一节使用 sectionCheckpointID
+const wholeCheckpointID = "rewrite"
+
+func init() {
+	// checkpoint 用 gob 序列化 session 中的事件，事件携带的自定义 Action 需要注册
+	schema.RegisterName[*ReviewAction]("eino_test_review_action")
+	schema.RegisterName[*StepAction]("eino_test_step_action")
+}
+
+// StepAction 改写-评审循环中的一步完成后由 stepGate 发出。循环转发的事件 AgentName 都是循环自己的名称，
+// 调用方据此判断这一步由哪个 Agent 完成、产出了哪一版改写稿，不依赖 AgentName
+type StepAction struct {
+	// Agent 完成这一步的 Agent：RewriterName 或 ReviewerName
+	Agent string
+	// Draft SummaryAgent 这一步产出的改写稿（最后一条不带工具调用的回复），其他 Agent 为空
+	Draft string
+	// Feedback 其他 Agent 这一步不带工具调用的回复，ReviewerAgent 的包括评审前的自动检查报告
+	Feedback string
+	// Usage 这一步模型回复的 token 用量
+	Usage *history.Usage
+}
+
+// stepOf 返回 stepGate 在一步完成后发出的 StepAction，其他事件返回 nil
+func stepOf(event *adk.AgentEvent) *StepAction {
+	if event.Action == nil {
+		return nil
+	}
+	step, _ := event.Action.CustomizedAction.(*StepAction)
+	return step
+}
+
+// draftOf 判断事件是否是 SummaryAgent 完成一步时发出的 StepAction，返回这一步产出的改写稿
+func draftOf(event *adk.AgentEvent) (string, bool) {
+	step := stepOf(event)
+	if step == nil || step.Agent != RewriterName {
+		return "", false
+	}
+	return step.Draft, true
+}
+
+// checkpointingKey 标记 ctx 中的改写-评审循环启用了 checkpoint
+type checkpointingKey struct{}
+
+// loopStateKey ctx 中记录改写-评审循环最新状态的 *loopState
+type loopStateKey struct{}
+
+// loopState 改写-评审循环每完成一步后 session 中的状态。循环在单独的 Runner 和 session 中运行，
+// checkpointedLoop 在循环结束后用它把改写稿、评审结果等带回外层 session
+type loopState struct {
+	mu sync.Mutex
+	s  *state.State
+}
+
+func (l *loopState) set(s *state.State) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.s = s
+}
+
+func (l *loopState) get() *state.State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.s
+}
+
+// stepGate 包装改写-评审循环中的一步（SummaryAgent 或 ReviewerAgent）。这一步正常完成后发出 StepAction；
+// 启用 checkpoint 时随后再发出中断，Runner 借此把循环进行到哪一步和 session 保存为 checkpoint，runSteps 随后立即从这里继续
+type stepGate struct {
+	adk.Agent
+}
+
+func (g *stepGate) Run(ctx context.Context, input *adk.AgentInput, opts ...adk.AgentRunOption) *adk.AsyncIterator[*adk.AgentEvent] {
+	inner := g.Agent.Run(ctx, input, opts...)
+
+	iter, gen := adk.NewAsyncIteratorPair[*adk.AgentEvent]()
+	go func() {
+		defer gen.Close()
+		name := g.Name(ctx)
+		step := &StepAction{Agent: name, Usage: &history.Usage{}}
+		var replies []string
+		// held 最近一个带 Action 的事件。循环只根据一步中最后一个 Action 判断是否退出，
+		// 这一步以 BreakLoop 等 Action 结束时，StepAction 要在它之前发出
+		var held *adk.AgentEvent
+		received := false
+		for {
+			event, ok := inner.Next()
+			if !ok {
+				break
+			}
+			received = true
+			if held != nil {
+				gen.Send(held)
+				held = nil
+			}
+			if event.Err != nil {
+				gen.Send(event)
+				return
+			}
+			if reply, ok := replyOf(event, step.Usage); ok {
+				replies = append(replies, reply)
+			}
+			if event.Action != nil {
+				held = event
+				continue
+			}
+			gen.Send(event)
+		}
+		if !received {
+			return
+		}
+
+		if name == RewriterName {
+			if n := len(replies); n > 0 {
+				step.Draft = replies[n-1]
+			}
+		} else {
+			step.Feedback = strings.Join(replies, "\n\n")
+		}
+		if l, ok := ctx.Value(loopStateKey{}).(*loopState); ok {
+			if s, err := state.Load(ctx); err == nil {
+				l.set(s)
+			}
+		}
+		gen.Send(&adk.AgentEvent{Action: &adk.AgentAction{CustomizedAction: step}})
+		if held != nil {
+			// 以 BreakLoop 等 Action 结束时循环即将退出，不需要保存 checkpoint
+			gen.Send(held)
+			return
+		}
+		if ctx.Value(checkpointingKey{}) != nil {
+			gen.Send(adk.Interrupt(ctx, name+" 已完成"))
+		}
+	}()
+	return iter
+}
+
+// replyOf 把事件中模型回复的 token 用量累加到 usage，事件是不带工具调用的回复时返回回复内容
+func replyOf(event *adk.AgentEvent, usage *history.Usage) (string, bool) {
+	if event.Output == nil || event.Output.MessageOutput == nil {
+		return "", false
+	}
+	mo := event.Output.MessageOutput
+	msg := mo.Message
+	if msg == nil {
+		return "", false
+	}
+	if msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil {
+		u := msg.ResponseMeta.Usage
+		usage.Add(u.PromptTokens, u.CompletionTokens, u.TotalTokens)
+	}
+	if mo.Role != schema.Assistant || len(msg.ToolCalls) > 0 || msg.Content == "" {
+		return "", false
+	}
+	return msg.Content, true
+}
+
+// Resume 中断发生在这一步完成之后，恢复时这一步不需要再做任何事，循环直接进入下一步
+func (g *stepGate) Resume(context.Context, *adk.ResumeInfo, ...adk.AgentRunOption) *adk.AsyncIterator[*adk.AgentEvent] {
+	iter, gen := adk.NewAsyncIteratorPair[*adk.AgentEvent]()
+	gen.Close()
+	return iter
+}
+
+// newRewriteLoop 创建改写-评审循环，每一步都用 stepGate 包装，启用 checkpoint 时可以从任意一步之后继续
+func newRewriteLoop(ctx context.Context, name, description string, maxIterations int, rewriter, reviewer adk.Agent) (adk.Agent, error) {
+	return adk.NewLoopAgent(ctx, &adk.LoopAgentConfig{
+		Name:          name,
+		Description:   description,
+		SubAgents:     []adk.Agent{&stepGate{rewriter}, &stepGate{reviewer}},
+		MaxIterations: maxIterations,
+	})
+}
+
+// runSteps 在单独的 Runner 中运行改写-评审循环，values 为注入 session 的初始状态。
+// store 为 nil 时直接运行；否则每完成一步保存一次 checkpoint，store 中已有 id 的 checkpoint 时从中断的那一步继续，
+// 循环正常结束后删除 checkpoint。保存 checkpoint 产生的中断事件不会交给调用方
+func runSteps(ctx context.Context, loop adk.Agent, store *history.CheckPointStore, id string, messages []adk.Message, values map[string]any) *adk.AsyncIterator[*adk.AgentEvent] {
+	if store == nil {
+		return adk.NewRunner(ctx, adk.RunnerConfig{Agent: loop}).Run(ctx, messages, adk.WithSessionValues(values))
+	}
+	ctx = context.WithValue(ctx, checkpointingKey{}, true)
+	runner := adk.NewRunner(ctx, adk.RunnerConfig{Agent: loop, CheckPointStore: store})
+
+	iter, gen := adk.NewAsyncIteratorPair[*adk.AgentEvent]()
+	go func() {
+		defer gen.Close()
+		var inner *adk.AsyncIterator[*adk.AgentEvent]
+		if _, ok, _ := store.Get(ctx, id); ok {
+			var err error
+			if inner, err = runner.Resume(ctx, id); err != nil {
+				gen.Send(&adk.AgentEvent{Err: err})
+				return
+			}
+		} else {
+			inner = runner.Run(ctx, messages, adk.WithSessionValues(values), adk.WithCheckPointID(id))
+		}
+
+		for {
+			interrupted := false
+			for {
+				event, ok := inner.Next()
+				if !ok {
+					break
+				}
+				if event.Err != nil {
+					// 保留上一步的 checkpoint，之后可以从这里恢复
+					gen.Send(event)
+					return
+				}
+				if event.Action != nil && event.Action.Interrupted != nil {
+					interrupted = true
+					continue
+				}
+				gen.Send(event)
+			}
+			if !interrupted {
+				break
+			}
+			var err error
+			if inner, err = runner.Resume(ctx, id); err != nil {
+				gen.Send(&adk.AgentEvent{Err: err})
+				return
+			}
+		}
+		if err := store.Delete(id); err != nil {
+			log.Printf("删除 checkpoint 失败: %v", err)
+		}
+	}()
+	return iter
+}
+
+// checkpointedLoop 整篇改写时作为 Supervisor 的子 Agent，用 runSteps 运行改写-评审循环。
+// 循环在单独的 session 中运行，这里把外层 session 中的源文档等状态带进循环，
+// 循环正常结束后再把最后一步完成时的改写稿、评审结果等状态写回外层 session，交回 MainAgent 后仍然可以读取
+type checkpointedLoop struct {
+	adk.Agent
+	store *history.CheckPointStore
+}
+
+func (l *checkpointedLoop) Run(ctx context.Context, input *adk.AgentInput, _ ...adk.AgentRunOption) *adk.AsyncIterator[*adk.AgentEvent] {
+	s, err := state.Load(ctx)
+	if err != nil {
+		iter, gen := adk.NewAsyncIteratorPair[*adk.AgentEvent]()
+		gen.Send(&adk.AgentEvent{Err: err})
+		gen.Close()
+		return iter
+	}
+	var messages []adk.Message
+	if input != nil {
+		messages = input.Messages
+	}
+	last := &loopState{}
+	inner := runSteps(context.WithValue(ctx, loopStateKey{}, last), l.Agent, l.store, wholeCheckpointID, messages, s.Values())
+
+	iter, gen := adk.NewAsyncIteratorPair[*adk.AgentEvent]()
+	go func() {
+		defer gen.Close()
+		for {
+			event, ok := inner.Next()
+			if !ok {
+				break
+			}
+			gen.Send(event)
+			if event.Err != nil {
+				return
+			}
+		}
+		if s := last.get(); s != nil {
+			adk.AddSessionValues(ctx, s.Values())
+		}
+	}()
+	return iter
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"eino_test/components/state"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
+)
+
+// stateProbe 运行时读取 session 中的状态，用来检查前面的 Agent 留下了什么
+type stateProbe struct {
+	state *state.State
+}
+
+func (p *stateProbe) Name(context.Context) string        { return "stateProbe" }
+func (p *stateProbe) Description(context.Context) string { return "读取 session 中的状态" }
+
+func (p *stateProbe) Run(ctx context.Context, _ *adk.AgentInput, _ ...adk.AgentRunOption) *adk.AsyncIterator[*adk.AgentEvent] {
+	iter, gen := adk.NewAsyncIteratorPair[*adk.AgentEvent]()
+	p.state, _ = state.Load(ctx)
+	gen.Close()
+	return iter
+}
+
+// TestCheckpointedLoopState 测试改写-评审循环在单独的 session 中结束后，改写稿和评审结果被写回外层 session
+func TestCheckpointedLoopState(t *testing.T) {
+	for _, checkpoints := range []bool{false, true} {
+		f := newRewriteFixture(t, "# Kafka\n\n正文\n", "# Kafka\n\n改写后的正文\n", 5, 9)
+		if checkpoints {
+			f.cfg.HistoryDir = t.TempDir()
+			run := startHistory(f.cfg, f.source)
+			f.cfg.checkpoints = run.CheckPoints()
+		}
+		ctx := context.Background()
+		probe := &stateProbe{}
+		seq, err := adk.NewSequentialAgent(ctx, &adk.SequentialAgentConfig{
+			Name:      "seq",
+			SubAgents: []adk.Agent{NewSummaryAgent(ctx, f.cfg), probe},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		initial := &state.State{Source: &state.Source{Path: f.source, Content: "# Kafka\n\n正文\n"}}
+		iter := adk.NewRunner(ctx, adk.RunnerConfig{Agent: seq}).
+			Run(ctx, []adk.Message{schema.UserMessage("改写")}, adk.WithSessionValues(initial.Values()))
+		if err := drain(iter, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		s := probe.state
+		if s == nil || s.Draft != "# Kafka\n\n改写后的正文\n" || len(s.Reviews) != 2 || s.Iteration != 2 || s.Source == nil {
+			t.Fatalf("checkpoint=%v: 外层 session 中的状态不符合预期: %+v", checkpoints, s)
+		}
+		if !s.Reviews[1].Passed {
+			t.Errorf("checkpoint=%v: 最后一轮评审应通过", checkpoints)
+		}
+	}
+}
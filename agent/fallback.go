@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"eino_test/markdown"
+	"eino_test/review"
+	"eino_test/tools"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// unapprovedSuffix 未通过评审时保存的文件名后缀
+const unapprovedSuffix = "_未通过评审"
+
+// fallbackAgentName 流程结束后由程序保存改写稿时，发出的事件使用的 AgentName
+const fallbackAgentName = "fallback"
+
+// unapprovedPart 一份未通过评审的改写稿对应的得分最高的评审结果
+type unapprovedPart struct {
+	// Title 按章节改写时的章节名称，整篇改写时为空
+	Title  string
+	Result *review.Result
+}
+
+// markUnapproved 在改写稿开头加上未通过评审的标记，在文末附上仍未解决的评审问题
+func markUnapproved(cfg *Config, content string, parts []unapprovedPart) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "> ⚠️ **未通过评审**：改写-评审循环达到最大迭代次数（%d 轮）仍未达到通过阈值（必须满足的标准不低于 %d 分，加权平均分不低于 %g 分）",
+		cfg.MaxIterations, cfg.Review.MinScore, cfg.Review.PassScore)
+	if len(parts) == 1 && parts[0].Title == "" {
+		fmt.Fprintf(&b, "，这里保存的是得分最高的第 %d 轮改写稿（加权平均分 %.1f）", parts[0].Result.Iteration, parts[0].Result.Average)
+	} else {
+		fmt.Fprintf(&b, "，其中 %d 节未通过，使用的是这些章节得分最高的改写稿", len(parts))
+	}
+	b.WriteString("。未解决的评审问题见文末。\n\n")
+	b.WriteString(strings.TrimSpace(content))
+	b.WriteString("\n\n---\n\n## 附录：未解决的评审问题\n")
+	for _, p := range parts {
+		if p.Title != "" {
+			fmt.Fprintf(&b, "\n### %s\n", p.Title)
+		}
+		b.WriteString("\n" + strings.TrimSpace(p.Result.Feedback(cfg.Rubric.Criteria)) + "\n")
+	}
+	return b.String()
+}
+
+// saveBest 整篇改写达到最大迭代次数仍未通过评审时，保存得分最高的改写稿并标记为未通过。
+// 一轮评审都没有完成时没有可以保存的改写稿，不做任何处理
+func saveBest(ctx context.Context, cfg *Config, documentPath string, result *RewriteResult, onEvent func(*adk.AgentEvent)) error {
+	if result.Best == nil || result.bestDraft == "" {
+		return nil
+	}
+	content := markUnapproved(cfg, result.bestDraft, []unapprovedPart{{Result: result.Best}})
+	path, out, err := saveContent(ctx, cfg, outputName(documentPath, false), content)
+	if onEvent != nil {
+		onEvent(progressEvent(fallbackAgentName, out))
+	}
+	if err != nil {
+		return fmt.Errorf("保存得分最高的改写稿失败: %w", err)
+	}
+	result.OutputPath, result.Fallback = path, true
+	return nil
+}
+
+// saveApproved 整篇改写通过评审但 ReviewerAgent 没有调用 save_document 时，保存通过评审的那一版改写稿。
+// 已经保存过时不做任何处理
+func saveApproved(ctx context.Context, cfg *Config, documentPath string, result *RewriteResult, onEvent func(*adk.AgentEvent)) error {
+	if result.OutputPath != "" || result.approvedDraft == "" {
+		return nil
+	}
+	path, out, err := saveContent(ctx, cfg, outputName(documentPath, true), result.approvedDraft)
+	if onEvent != nil {
+		onEvent(progressEvent(fallbackAgentName, out))
+	}
+	if err != nil {
+		return fmt.Errorf("保存通过评审的改写稿失败: %w", err)
+	}
+	result.OutputPath = path
+	return nil
+}
+
+// outputName 返回程序直接保存改写稿时使用的文件名，未通过评审时带上 unapprovedSuffix
+func outputName(documentPath string, approved bool) string {
+	name := strings.TrimSuffix(filepath.Base(documentPath), filepath.Ext(documentPath)) + "_改写"
+	if !approved {
+		name += unapprovedSuffix
+	}
+	return name + ".md"
+}
+
+// saveContent 不经过模型，直接调用 save_document 工具保存 content，
+// 与 ReviewerAgent 保存时一样执行后处理并写入元数据。返回保存的绝对路径和工具输出
+func saveContent(ctx context.Context, cfg *Config, filename, content string) (string, string, error) {
+	saveTool, err := newSaveDocumentTool(ctx, cfg)
+	if err != nil {
+		return "", "", fmt.Errorf("创建保存文档工具失败: %w", err)
+	}
+	invokable, ok := saveTool.(tool.InvokableTool)
+	if !ok {
+		return "", "", errors.New("save_document 工具不支持直接调用")
+	}
+	args, err := json.Marshal(&tools.SaveDocumentInput{Filename: filename})
+	if err != nil {
+		return "", "", err
+	}
+	// 不在 Agent 中运行，没有 session，通过 ctx 传入要保存的内容
+	out, err := invokable.InvokableRun(tools.WithDocumentContent(ctx, content), string(args))
+	if err != nil {
+		return "", out, err
+	}
+	path := filepath.Join(cfg.OutputDir, filename)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path, out, nil
+}
+
+// saveToFeishu 不经过模型，直接调用 save_to_feishu 工具把 content 保存到飞书，标题使用改写稿的第一个标题。
+// 没有配置飞书应用时返回 feishu.ErrNotConfigured
+func saveToFeishu(ctx context.Context, cfg *Config, documentPath, content string) (string, error) {
+	feishuTool, err := tools.NewSaveToFeishuTool(cfg.Feishu, cfg.Source)
+	if err != nil {
+		return "", err
+	}
+	invokable, ok := feishuTool.(tool.InvokableTool)
+	if !ok {
+		return "", errors.New("save_to_feishu 工具不支持直接调用")
+	}
+	title := strings.TrimSuffix(filepath.Base(documentPath), filepath.Ext(documentPath))
+	if hs := markdown.Headings(strings.Split(content, "\n")); len(hs) > 0 {
+		title = hs[0].Text
+	}
+	args, err := json.Marshal(&tools.SaveToFeishuInput{Title: title})
+	if err != nil {
+		return "", err
+	}
+	return invokable.InvokableRun(tools.WithDocumentContent(ctx, content), string(args))
+}
+
+// progressEvent 构造一条由程序而不是模型发出的进度消息
+func progressEvent(agentName, text string) *adk.AgentEvent {
+	event := adk.EventFromMessage(schema.AssistantMessage(text, nil), nil, schema.Assistant, "")
+	event.AgentName = agentName
+	return event
+}
@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"eino_test/history"
+
+	"github.com/cloudwego/eino/adk"
+)
+
+// startHistory 为本次改写创建修订历史，未配置目录或创建失败时返回 nil，不影响改写
+func startHistory(cfg *Config, documentPath string) *history.Run {
+	if cfg.HistoryDir == "" {
+		return nil
+	}
+	run, err := history.Create(cfg.HistoryDir, history.RunInfo{
+		Source:  documentPath,
+		Persona: cfg.Persona.Name,
+		Mode:    cfg.Sections.Mode,
+		Prompts: cfg.Prompts.Refs(),
+		Models:  cfg.models(),
+	})
+	if err != nil {
+		log.Printf("创建修订历史失败: %v", err)
+		return nil
+	}
+	return run
+}
+
+// revisionRecorder 根据一个改写-评审循环的事件记录修订历史：SummaryAgent 每产出一版改写稿就是一次修订，
+// 随后的评审结果、ReviewerAgent 的回复和 token 用量都记到这次修订上。循环中事件的 AgentName 都是循环的名称，
+// 改写稿、回复和用量都从每一步完成时的 StepAction 中读取。run 为 nil 时不记录
+type revisionRecorder struct {
+	run *history.Run
+	// section 按章节改写时的章节序号，整篇改写时为 0
+	section   int
+	iteration int
+	current   *history.Revision
+	draft     string
+}
+
+// newRevisionRecorder 创建记录第 section 节修订的 revisionRecorder。从 checkpoint 恢复时接着已有的最后一次修订记录，
+// 中断前已经产出但还没有评审的改写稿，恢复后的评审结果仍然记到它上面
+func newRevisionRecorder(run *history.Run, section int) *revisionRecorder {
+	if run == nil {
+		return nil
+	}
+	r := &revisionRecorder{run: run, section: section}
+	revs, drafts, err := run.Revisions(section)
+	if err != nil {
+		log.Printf("读取修订历史失败: %v", err)
+	}
+	if n := len(revs); n > 0 {
+		r.current, r.draft, r.iteration = revs[n-1], drafts[n-1], revs[n-1].Iteration
+	}
+	return r
+}
+
+// Observe 根据事件更新当前修订，改写稿、评审结果和每一步的回复到达时立即写入
+func (r *revisionRecorder) Observe(event *adk.AgentEvent) {
+	if r == nil || event.Action == nil {
+		return
+	}
+	switch action := event.Action.CustomizedAction.(type) {
+	case *StepAction:
+		if action.Agent == RewriterName {
+			r.iteration++
+			r.current = &history.Revision{
+				Seq:       r.run.NextSeq(),
+				Section:   r.section,
+				Iteration: r.iteration,
+				Chars:     utf8.RuneCountInString(action.Draft),
+				DraftedAt: time.Now(),
+			}
+			r.draft = action.Draft
+			r.addUsage(action)
+			r.write()
+			return
+		}
+		if r.current == nil {
+			return
+		}
+		// 评审前的自动检查报告和 ReviewerAgent 的最终回复
+		if action.Feedback != "" {
+			if r.current.Feedback != "" {
+				r.current.Feedback += "\n\n"
+			}
+			r.current.Feedback += action.Feedback
+		}
+		r.addUsage(action)
+		r.write()
+	case *ReviewAction:
+		if r.current != nil {
+			r.current.Review, r.current.ReviewedAt = action.Result, time.Now()
+			r.write()
+		}
+	}
+}
+
+// Close 写入最后一次修订。每一步的用量在这一步完成时已经写入，这里只是保证修订文件是最新的
+func (r *revisionRecorder) Close() {
+	if r == nil || r.current == nil {
+		return
+	}
+	r.write()
+}
+
+// addUsage 把一步的 token 用量按 Agent 累加到当前修订：SummaryAgent 的用量计入它产出的改写稿，
+// ReviewerAgent 的用量计入被评审的改写稿
+func (r *revisionRecorder) addUsage(step *StepAction) {
+	if step.Usage == nil || *step.Usage == (history.Usage{}) {
+		return
+	}
+	if r.current.Usage == nil {
+		r.current.Usage = map[string]*history.Usage{}
+	}
+	u := r.current.Usage[step.Agent]
+	if u == nil {
+		u = &history.Usage{}
+		r.current.Usage[step.Agent] = u
+	}
+	u.Add(step.Usage.Prompt, step.Usage.Completion, step.Usage.Total)
+}
+
+func (r *revisionRecorder) write() {
+	if err := r.run.WriteRevision(r.current, r.draft); err != nil {
+		log.Printf("记录修订历史失败: %v", err)
+	}
+}
+
+// restore 从 checkpoint 恢复时，用修订历史中已有的评审结果补上中断前的轮次，
+// 保证未通过评审时仍然能在所有轮次中选出得分最高的改写稿。run 为 nil 或没有修订时不做任何处理
+func (r *RewriteResult) restore(run *history.Run, section int) {
+	if run == nil {
+		return
+	}
+	revs, drafts, err := run.Revisions(section)
+	if err != nil {
+		log.Printf("读取修订历史失败: %v", err)
+		return
+	}
+	r.Iterations = len(revs)
+	for i, rev := range revs {
+		if rev.Review == nil {
+			continue
+		}
+		r.Reviews = append(r.Reviews, rev.Review)
+		if r.Best == nil || rev.Review.Average > r.Best.Average {
+			r.Best, r.bestDraft = rev.Review, drafts[i]
+		}
+	}
+}
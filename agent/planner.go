@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"eino_test/history"
+	"eino_test/outline"
+	"eino_test/prompts"
+	"eino_test/tools"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+)
+
+const (
+	// outlineFile 修订历史目录中的教学大纲文件
+	outlineFile = "outline.yaml"
+	// confirmedFile 大纲确定之后写入修订历史目录的 Markdown，有它才说明 outlineFile 已经确认，从 checkpoint 恢复时沿用
+	confirmedFile = "outline.md"
+)
+
+// newPlannerAgent 创建 PlannerAgent：阅读原文，通过 save_teaching_plan 提交结构化的教学大纲，使用 SummaryAgent 的模型
+func newPlannerAgent(ctx context.Context, cfg *Config) adk.Agent {
+	planTool, err := tools.NewSaveTeachingPlanTool()
+	if err != nil {
+		log.Fatalf("创建保存教学大纲工具失败: %v", err)
+	}
+	a, err := adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
+		Name:        "plannerAgent",
+		Description: "教学大纲agent，改写前生成结构化的教学大纲",
+		Instruction: renderInstruction(cfg, prompts.Planner, cfg.promptData()),
+		Model:       cfg.chatModel(ctx, cfg.Summary),
+		ToolsConfig: adk.ToolsConfig{
+			ToolsNodeConfig: compose.ToolsNodeConfig{
+				Tools: []tool.BaseTool{planTool},
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// outlinePath 教学大纲文件的路径：记录修订历史时保存在这次改写的目录中，否则保存在输出目录下的 <源文件名>.outline.yaml
+func outlinePath(cfg *Config, run *history.Run) string {
+	if run != nil {
+		return filepath.Join(run.Dir(), outlineFile)
+	}
+	name := strings.TrimSuffix(filepath.Base(cfg.Source), filepath.Ext(cfg.Source))
+	return filepath.Join(cfg.OutputDir, name+".outline.yaml")
+}
+
+// planOutline 整篇改写前的教学大纲阶段：PlannerAgent 生成大纲并保存到文件，edit 模式下等人修改并确认后重新校验。
+// 从 checkpoint 恢复时沿用修订历史中已经确认的大纲；在等待确认时中断的，恢复后沿用已经生成（可能已经改过）的大纲文件，重新等待确认
+func planOutline(ctx context.Context, cfg *Config, source string, run *history.Run, onEvent func(*adk.AgentEvent)) (*outline.Outline, error) {
+	path := outlinePath(cfg, run)
+	if run != nil {
+		if _, err := run.ReadFile(confirmedFile); err == nil {
+			return outline.Load(path)
+		}
+		if _, err := os.Stat(path); err == nil && cfg.Plan.Mode == "edit" {
+			return confirmPlan(ctx, cfg, path, run)
+		}
+	}
+
+	runner := adk.NewRunner(ctx, adk.RunnerConfig{Agent: newPlannerAgent(ctx, cfg)})
+	query := fmt.Sprintf("请为下面这篇文档制定教学大纲，文档路径为：%s\n\n%s", cfg.Source, source)
+	var o *outline.Outline
+	err := drain(runner.Query(ctx, query), func(event *adk.AgentEvent) {
+		if event.Action != nil {
+			if action, ok := event.Action.CustomizedAction.(*tools.SavedPlanAction); ok {
+				o = action.Outline
+			}
+		}
+		if onEvent != nil {
+			onEvent(event)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, errors.New("PlannerAgent 没有提交合法的教学大纲")
+	}
+	if err := o.Save(path); err != nil {
+		return nil, err
+	}
+	log.Printf("教学大纲已保存: %s（%d 个部分，%d 节）", path, len(o.Parts), len(o.Sections()))
+
+	if cfg.Plan.Mode == "edit" {
+		return confirmPlan(ctx, cfg, path, run)
+	}
+	recordPlan(run, o)
+	return o, nil
+}
+
+// confirmPlan 等人确认 path 中的大纲，确认后记录到修订历史
+func confirmPlan(ctx context.Context, cfg *Config, path string, run *history.Run) (*outline.Outline, error) {
+	o, err := confirmOutline(ctx, cfg, path)
+	if err != nil {
+		return nil, err
+	}
+	recordPlan(run, o)
+	return o, nil
+}
+
+// recordPlan 把确定的大纲写入修订历史的 confirmedFile，作为大纲已经确认的记录
+func recordPlan(run *history.Run, o *outline.Outline) {
+	if run == nil {
+		return
+	}
+	if err := run.WriteFile(confirmedFile, []byte(o.Markdown())); err != nil {
+		log.Printf("记录教学大纲失败: %v", err)
+	}
+}
+
+// confirmOutline 暂停改写，等 cfg.EditPlan 返回后重新读取并校验大纲文件，不合法时提示问题并再次等待，ctx 取消时返回
+func confirmOutline(ctx context.Context, cfg *Config, path string) (*outline.Outline, error) {
+	if cfg.EditPlan == nil {
+		log.Printf("没有确认教学大纲的方式，不暂停，直接按生成的大纲改写")
+		return outline.Load(path)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := cfg.EditPlan(ctx, path); err != nil {
+			return nil, err
+		}
+		o, err := outline.Load(path)
+		if err == nil {
+			return o, nil
+		}
+		log.Printf("%v\n请修改后重新确认", err)
+	}
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+	"eino_test/history"
+	"eino_test/outline"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+func planTestOutline() *outline.Outline {
+	return &outline.Outline{
+		Title: "Kafka 入门",
+		Parts: []outline.Part{{Title: "基础", Problem: "Kafka 是什么", Sections: []outline.Section{
+			{Title: "主题与分区", Goal: "理解分区", Questions: []string{"是什么", "为什么", "怎么用"}},
+		}}},
+		Outcomes: []string{"能创建主题", "能写消费者", "能排查重复消费"},
+	}
+}
+
+// TestPlanOutlineResume 测试恢复时只沿用已经确认的大纲：在等待确认时中断的重新等待确认，确认之后不再暂停
+func TestPlanOutlineResume(t *testing.T) {
+	f := newRewriteFixture(t, "# Kafka\n\n正文\n", "# Kafka\n")
+	f.cfg.HistoryDir = t.TempDir()
+	f.cfg.Plan.Mode = "edit"
+	f.cfg.newModel = func(context.Context, config.ModelConfig) model.ToolCallingChatModel {
+		t.Fatal("已经生成过大纲，不应再调用 PlannerAgent")
+		return nil
+	}
+	edits := 0
+	f.cfg.EditPlan = func(context.Context, string) error {
+		edits++
+		return nil
+	}
+	run := startHistory(f.cfg, f.source)
+	path := outlinePath(f.cfg, run)
+	if err := planTestOutline().Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := planOutline(context.Background(), f.cfg, "", run, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edits != 1 || o.Title != "Kafka 入门" {
+		t.Fatalf("没有确认过的大纲应重新等待确认: edits=%d", edits)
+	}
+	if _, err := run.ReadFile(confirmedFile); err != nil {
+		t.Fatalf("确认后应写入 %s: %v", confirmedFile, err)
+	}
+
+	if _, err := planOutline(context.Background(), f.cfg, "", run, nil); err != nil {
+		t.Fatal(err)
+	}
+	if edits != 1 {
+		t.Errorf("已经确认的大纲不应再次暂停: edits=%d", edits)
+	}
+}
+
+// TestConfirmOutlineCanceled 测试大纲文件不合法时等待确认的过程可以被 ctx 取消
+func TestConfirmOutlineCanceled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outline.yaml")
+	if err := os.WriteFile(path, []byte("title: ''\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	edits := 0
+	cfg := &Config{EditPlan: func(context.Context, string) error {
+		edits++
+		cancel()
+		return nil
+	}}
+	if _, err := confirmOutline(ctx, cfg, path); !errors.Is(err, context.Canceled) || edits != 1 {
+		t.Fatalf("取消后应返回 context.Canceled: err=%v edits=%d", err, edits)
+	}
+}
+
+// TestRunSectionRewriteOutline 测试按章节改写时同样先生成并确认教学大纲：全局教学计划按大纲生成，
+// 每一节的改写请求带着大纲，拼接后的全文对照大纲检查
+func TestRunSectionRewriteOutline(t *testing.T) {
+	f := newRewriteFixture(t, "# Kafka\n\n## 主题与分区\n\n正文一\n\n## 消费者\n\n正文二\n", "", 9)
+	f.cfg.HistoryDir = t.TempDir()
+	f.cfg.Sections.Mode = "sections"
+	f.cfg.Plan.Mode = "edit"
+	edits := 0
+	f.cfg.EditPlan = func(context.Context, string) error {
+		edits++
+		return nil
+	}
+
+	var planPrompt string
+	var requests []string
+	summary := &fakeModel{reply: func(_ int, input []*schema.Message) *schema.Message {
+		var all strings.Builder
+		for _, msg := range input {
+			all.WriteString(msg.Content)
+		}
+		text := all.String()
+		switch {
+		case lastIsTool(input):
+			return schema.AssistantMessage("大纲已提交", nil)
+		case strings.Contains(text, "请为下面这篇文档制定教学大纲"):
+			return toolCall(t, "save_teaching_plan", planTestOutline())
+		case strings.Contains(text, "【章节概要】"):
+			planPrompt = text
+			return schema.AssistantMessage("全局计划", nil)
+		case strings.Contains(text, "【全文摘要】"):
+			return schema.AssistantMessage("[]", nil)
+		case strings.Contains(text, "前一节《"):
+			return schema.AssistantMessage("过渡段", nil)
+		}
+		requests = append(requests, text)
+		return schema.AssistantMessage("## 其他标题\n\n改写后的正文", nil)
+	}}
+	newModel := f.cfg.newModel
+	f.cfg.newModel = func(ctx context.Context, mc config.ModelConfig) model.ToolCallingChatModel {
+		if mc.Model == "summary" {
+			return summary
+		}
+		return newModel(ctx, mc)
+	}
+
+	var progress []string
+	result, err := RunRewrite(context.Background(), f.cfg, f.source, func(event *adk.AgentEvent) {
+		if event.AgentName == sectionAgentName && event.Output != nil && event.Output.MessageOutput != nil {
+			progress = append(progress, event.Output.MessageOutput.Message.Content)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sections != 2 || edits != 1 {
+		t.Fatalf("应按章节改写并暂停确认一次大纲: sections=%d edits=%d", result.Sections, edits)
+	}
+	run, err := history.Open(f.cfg.HistoryDir, result.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(run.Dir(), outlineFile)); err != nil {
+		t.Errorf("没有保存 %s: %v", outlineFile, err)
+	}
+	if !strings.Contains(planPrompt, "【教学大纲】") || !strings.Contains(planPrompt, "主题与分区") {
+		t.Errorf("生成全局教学计划时没有带上教学大纲:\n%s", planPrompt)
+	}
+	if len(requests) == 0 || !strings.Contains(requests[0], "# 教学大纲：Kafka 入门") {
+		t.Errorf("章节的改写请求中没有教学大纲: %q", requests)
+	}
+	var checked bool
+	for _, p := range progress {
+		if strings.Contains(p, "【大纲一致性检查】") && strings.Contains(p, "主题与分区") {
+			checked = true
+		}
+	}
+	if !checked {
+		t.Errorf("拼接后的全文缺少大纲中的章节，应给出大纲一致性检查的结果: %q", progress)
+	}
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"log"
+
+	"eino_test/config"
+	"eino_test/persona"
+	"eino_test/review"
+)
+
+// promptData 提示词模板可以引用的数据，覆盖模板时也只能使用这些字段
+type promptData struct {
+	// Persona 读者画像
+	Persona *persona.Persona
+	// Language 改写后文档使用的语言
+	Language string
+	// Rubric 评审标准，reviewer 模板生成评审清单，summary 模板生成改写原则
+	Rubric *review.Rubric
+	// Review 评审通过的分数阈值
+	Review config.ReviewConfig
+	// ReviewOnly 只评审不保存（review 命令）
+	ReviewOnly bool
+	// Feishu 是否挂载了 save_to_feishu 工具
+	Feishu bool
+	// Filepath 待改写的文档路径，rewrite_request 使用
+	Filepath string
+	// Content 待评审的文档内容，review_request 使用；mermaid_repair 中是待修复的图表代码
+	Content string
+	// Errors 图表的语法错误，mermaid_repair 使用
+	Errors string
+	// Plan 按章节改写时生成的全局教学计划
+	Plan string
+	// Section 按章节改写时正在处理的章节，整篇改写时 Total 为 0
+	Section sectionInfo
+}
+
+// sectionInfo 按章节改写时一节在全文中的位置
+type sectionInfo struct {
+	// Index 第几节，从 1 开始
+	Index int
+	Total int
+	Title string
+	// Prev、Next 前一节和后一节的标题，第一节和最后一节为空
+	Prev, Next string
+}
+
+func (c *Config) promptData() promptData {
+	data := promptData{Persona: c.Persona, Language: c.Language, Rubric: c.Rubric, Review: c.Review, ReviewOnly: c.SkipSave, Plan: c.plan}
+	if c.section != nil {
+		data.Section = *c.section
+	}
+	return data
+}
+
+// renderInstruction 渲染 Agent 的指令模板，模板有误时无法创建 Agent，直接退出
+func renderInstruction(cfg *Config, name string, data promptData) string {
+	instruction, err := cfg.Prompts.Render(name, data)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return instruction
+}
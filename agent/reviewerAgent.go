@@ -2,196 +2,253 @@ package agent
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-
-	"eino_test/common/constant"
-	"eino_test/components/models"
+	"slices"
+	"strings"
+
+	"eino_test/codecheck"
+	"eino_test/components/state"
+	"eino_test/config"
+	"eino_test/coverage"
+	"eino_test/feishu"
+	"eino_test/lint"
+	"eino_test/outline"
+	"eino_test/prompts"
+	"eino_test/review"
 	"eino_test/tools"
 
 	"github.com/cloudwego/eino/adk"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
 	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
 )
 
-// SatisfiedAndExitInput 用于满意并退出循环的工具输入
-type SatisfiedAndExitInput struct {
-	Summary string `json:"summary" jsonschema_description:"对改写后文档的总结或确认信息"`
+// ReviewAction submit_review 通过校验后通过 AgentAction.CustomizedAction 发出的事件，
+// 调用方可以据此拿到每一轮的结构化评分和被评审的改写稿
+type ReviewAction struct {
+	Result *review.Result
+	// Draft 本轮评审的改写稿，未通过评审时用于保存得分最高的版本
+	Draft string
 }
 
-func NewReviewerAgent(ctx context.Context) adk.Agent {
-	// 创建 save_document 工具（保存到本地文件）
-	saveDocumentTool, err := tools.NewSaveDocumentTool()
-	if err != nil {
-		log.Fatalf("创建保存文档工具失败: %v", err)
+// newSubmitReviewTool 创建 submit_review 工具：校验评分、按阈值判定是否通过并持久化评分。
+// 是否退出循环由 reviewGate 根据判定结果决定，模型无法自行结束循环
+func newSubmitReviewTool(cfg *Config) (tool.BaseTool, error) {
+	return utils.InferTool(
+		"submit_review",
+		"提交结构化评审结果：每个评审标准一项 0～10 分的评分，以及带章节定位的问题和修改建议。是否通过由分数阈值自动判定",
+		func(ctx context.Context, input *review.Submission) (string, error) {
+			if err := input.Validate(cfg.Rubric.Criteria, cfg.Review.MinScore); err != nil {
+				// 返回给模型修正后重新提交，而不是中断流程
+				return fmt.Sprintf("评审结果不合法，请修正后重新调用 submit_review:\n%v", err), nil
+			}
+			result := review.Evaluate(*input, cfg.Rubric.Criteria, cfg.Review, state.Iteration(ctx))
+			result.Rubric = cfg.Rubric.Name
+			if err := state.AddReview(ctx, result); err != nil {
+				return "", err
+			}
+			_ = adk.SendToolGenAction(ctx, "submit_review", &adk.AgentAction{
+				CustomizedAction: &ReviewAction{Result: result, Draft: state.Draft(ctx)},
+			})
+			if err := review.Append(cfg.Review.ScoresFile, review.Record{Source: cfg.Source, Result: result}); err != nil {
+				log.Printf("记录评分失败: %v", err)
+			}
+
+			feedback := result.Feedback(cfg.Rubric.Criteria)
+			switch {
+			case !result.Passed:
+				return feedback + "\n\n结论：未达到通过阈值。请把上面的问题整理成改进建议作为最终回复，SummaryAgent 会据此修改", nil
+			case cfg.SkipSave:
+				return feedback + "\n\n结论：达到通过阈值。请给出简短的评审结论", nil
+			default:
+				return feedback + "\n\n结论：达到通过阈值，请保存文档", nil
+			}
+		},
+	)
+}
+
+// reviewGate 包装 ReviewerAgent：每轮评审结束后，如果本轮 submit_review 的结果达到通过阈值，
+// 就发出 BreakLoopAction 结束改写-评审循环。退出与否只取决于分数，与模型调用了哪些工具无关
+type reviewGate struct {
+	adk.Agent
+	// breakLoop 为 false 时只记录评分，不发出 BreakLoopAction（单独评审时没有外层循环）
+	breakLoop bool
+	// checks 每轮评审前对改写稿做的确定性检查
+	checks []precheck
+}
+
+// precheck 评审前对改写稿做的确定性检查，返回交给 ReviewerAgent 的报告，没有问题时返回空字符串
+type precheck func(ctx context.Context, content string) string
+
+// lintCheck 按配置检查改写稿的 Markdown 格式
+func lintCheck(cfg config.LintConfig) precheck {
+	return func(_ context.Context, content string) string {
+		issues := lint.Check(content, cfg)
+		if len(issues) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("【自动格式检查】程序在改写稿中发现 %d 个格式问题（行号以改写稿为准）。"+
+			"这些问题已经确定存在，评审时不需要重复检查，请在对应标准的评分中考虑，并把它们列入修改建议：\n%s",
+			len(issues), lint.Format(issues))
 	}
+}
 
-	// 创建 save_to_feishu 工具（保存到飞书）
-	saveToFeishuTool, err := tools.NewSaveToFeishuTool()
-	if err != nil {
-		log.Fatalf("创建飞书保存工具失败: %v", err)
+// codeCheck 检查改写稿中的 Go 代码示例能否编译
+func codeCheck(cfg config.CodeCheckConfig) precheck {
+	return func(ctx context.Context, content string) string {
+		report := codecheck.Check(ctx, content, cfg)
+		if len(report.Findings) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("【代码编译检查】程序检查了改写稿中的 %d 个 Go 代码块，发现 %d 个编译问题（行号以改写稿为准）。"+
+			"代码示例要求完整、可运行，请在代码示例相关标准的评分中考虑，并把它们列入修改建议：\n%s",
+			report.Blocks, len(report.Findings), report.Format())
 	}
+}
 
-	// 创建 satisfied_and_exit 工具，用于评审满意时退出循环
-	satisfiedAndExitTool, err := utils.InferTool(
-		"satisfied_and_exit",
-		"当文档改写满意时调用此工具退出循环",
-		func(ctx context.Context, req *SatisfiedAndExitInput) (string, error) {
-			// 发送 BreakLoopAction 来退出循环
-			_ = adk.SendToolGenAction(ctx, "satisfied_and_exit", adk.NewBreakLoopAction("reviewerAgent"))
-			return req.Summary, nil
-		},
-	)
+// coverageCheck 对照 session 中的源文档检查改写稿是否遗漏了标题、术语、代码块或大段内容。
+// 按章节改写时对照的是这一节的原文，没有源文档时不检查
+func coverageCheck(cfg config.CoverageConfig) precheck {
+	return func(ctx context.Context, content string) string {
+		source := state.SourceOf(ctx)
+		if len(cfg.Checks) == 0 || source == nil || source.Content == "" {
+			return ""
+		}
+		report, err := coverage.Check(ctx, source.Content, content, cfg)
+		if err != nil || report.OK() {
+			return ""
+		}
+		return fmt.Sprintf("【内容覆盖检查】程序对照源文档（%d 个标题、%d 个关键术语、%d 个代码块）检查了改写稿，发现以下内容可能被删减。"+
+			"改写原则要求保留结构、不要大幅删减，请核实后在对应标准的评分中考虑，并把确实遗漏的内容列入修改建议：\n%s",
+			report.SourceHeadings, report.Terms, report.CodeBlocks, report.Format())
+	}
+}
+
+// outlineCheck 对照 session 中的教学大纲检查改写稿的章节是否齐全、顺序是否一致，没有单独的大纲阶段时不检查
+func outlineCheck() precheck {
+	return func(ctx context.Context, content string) string {
+		o := state.Outline(ctx)
+		if o == nil {
+			return ""
+		}
+		report := outline.Check(o, content)
+		if report.OK() {
+			return ""
+		}
+		return fmt.Sprintf("【大纲一致性检查】程序对照改写前确定的教学大纲（%d 节）检查了改写稿的标题，发现以下问题。"+
+			"正文必须与大纲一致，请在教学化结构相关标准的评分中考虑，并把它们列入修改建议：\n%s",
+			report.Sections, report.Format())
+	}
+}
+
+func (g *reviewGate) Run(ctx context.Context, input *adk.AgentInput, opts ...adk.AgentRunOption) *adk.AsyncIterator[*adk.AgentEvent] {
+	state.NextIteration(ctx)
+
+	// 评审前先做确定性的检查，结果同时交给 ReviewerAgent 和记录到历史中，
+	// 下一轮 SummaryAgent 能看到原样的检查结果，不依赖 ReviewerAgent 转述
+	var reports []string
+	if draft := state.Draft(ctx); draft != "" {
+		for _, check := range g.checks {
+			if r := check(ctx, draft); r != "" {
+				reports = append(reports, r)
+			}
+		}
+	}
+	report := strings.Join(reports, "\n\n")
+	if report != "" {
+		in := *input
+		in.Messages = append(slices.Clone(input.Messages), schema.UserMessage(report))
+		input = &in
+	}
+	inner := g.Agent.Run(ctx, input, opts...)
+
+	iter, gen := adk.NewAsyncIteratorPair[*adk.AgentEvent]()
+	go func() {
+		defer gen.Close()
+		if report != "" {
+			gen.Send(adk.EventFromMessage(schema.AssistantMessage(report, nil), nil, schema.Assistant, ""))
+		}
+		for {
+			event, ok := inner.Next()
+			if !ok {
+				break
+			}
+			gen.Send(event)
+		}
+		if !g.breakLoop {
+			return
+		}
+		if result := state.CurrentReview(ctx); result != nil && result.Passed {
+			gen.Send(&adk.AgentEvent{Action: adk.NewBreakLoopAction(g.Name(ctx))})
+		}
+	}()
+	return iter
+}
+
+// ReviewerName 负责评审的 ReviewerAgent 的名称，也是它完成一步时 StepAction.Agent 的值
+const ReviewerName = "reviewerAgent"
+
+func NewReviewerAgent(ctx context.Context, cfg *Config) adk.Agent {
+	cfg = cfg.withDefaults()
+	return newReviewerAgent(ctx, cfg, !cfg.SkipSave)
+}
+
+// newReviewerAgent 创建 ReviewerAgent，breakLoop 为 true 时评审通过后结束外层的改写-评审循环。
+// 按章节改写时每一节只评审不保存（cfg.SkipSave），但仍然需要在通过后结束这一节的循环
+func newReviewerAgent(ctx context.Context, cfg *Config, breakLoop bool) adk.Agent {
+	gate := &reviewGate{breakLoop: breakLoop, checks: []precheck{
+		lintCheck(cfg.Lint), codeCheck(cfg.CodeCheck), coverageCheck(cfg.Coverage), outlineCheck(),
+	}}
+
+	submitReviewTool, err := newSubmitReviewTool(cfg)
 	if err != nil {
-		log.Fatalf("创建 satisfied_and_exit 工具失败: %v", err)
+		log.Fatalf("创建 submit_review 工具失败: %v", err)
+	}
+
+	reviewTools := []tool.BaseTool{submitReviewTool}
+	data := cfg.promptData()
+	if !cfg.SkipSave {
+		// 创建 save_document 工具（保存到本地文件）
+		saveDocumentTool, err := newSaveDocumentTool(ctx, cfg)
+		if err != nil {
+			log.Fatalf("创建保存文档工具失败: %v", err)
+		}
+		saveTools := []tool.BaseTool{saveDocumentTool}
+
+		// 创建 save_to_feishu 工具（保存到飞书），没有配置飞书应用时跳过
+		saveToFeishuTool, err := tools.NewSaveToFeishuTool(cfg.Feishu, cfg.Source)
+		switch {
+		case err == nil:
+			saveTools = append(saveTools, saveToFeishuTool)
+			data.Feishu = true
+		case errors.Is(err, feishu.ErrNotConfigured):
+		default:
+			log.Fatalf("创建飞书保存工具失败: %v", err)
+		}
+		reviewTools = append(saveTools, reviewTools...)
 	}
 
 	a, err := adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
-		Name:        "reviewerAgent",
+		Name:        ReviewerName,
 		Description: "文档评审agent，负责严格评审改写后的文档",
-		Instruction: `你是一个严格的文档评审专家，负责评审改写后的文档。你的职责是确保文档质量达到最高标准。
-
-你会获得改写后的文档内容（存储在 session 中），需要根据以下标准进行严格评审。
-
-【用户背景信息】
-- 编程语言：掌握 Java、Golang 的简单后端开发
-- 中间件经验：熟悉 MySQL、Kafka、Redis 的基本使用
-- 架构知识：了解分布式设计的基本概念
-- 学习阶段：正在从零开始学习和梳理后端开发框架
-
-【严格的评审标准】
-
-1. 【语言易懂性】（必须满足）
-   - 是否避免了生硬的学术用语？
-   - 是否使用了友好、亲切的语气？
-   - 是否有包容性语言（"我们"、"让我们"）？
-   - 是否每个句子都清晰易懂？
-   - 是否有冗长复杂的句子需要简化？
-
-2. 【内容详细度】（必须满足）
-   - 是否保留了原文的完整结构和内容？
-   - 是否有大幅删减的内容？
-   - 是否对每个关键概念都进行了充分解释？
-   - 是否解释了"是什么"、"为什么"、"怎么用"？
-   - 是否有遗漏的重要概念或细节？
-
-3. 【举例和代码示例】（必须满足）
-   - 是否对每个"大概念"至少提供了 1 个完整示例？
-   - 对容易混淆的概念对是否用对比表 + 一个对比示例？
-   - 是否优先保证覆盖面，而不是机械凑"2-3 个示例"？
-   - 是否避免了重复类似的示例？
-   - 代码示例是否完整、可运行？
-   - 是否优先使用 Java/Golang 的示例？
-   - 是否有场景示例贴近用户的实际工作？
-   - 代码示例是否正确无误？
-
-4. 【类比学习】（必须满足）
-   - 是否在适当的地方使用了生活中的类比？
-   - 类比是否恰当、易于理解？
-   - 是否有遗漏的可以用类比解释的概念？
-
-5. 【对比学习】（必须满足）
-   - 是否对相似或相对的概念进行了明确对比？
-   - 是否使用了表格或列表进行对比？
-   - 是否说明了何时选择哪个方案？
-   - 是否突出了各自的优缺点？
-   - 是否有遗漏的对比机会？
-
-6. 【图表辅助】（必须满足）
-   - 对于复杂的概念、流程或架构，是否使用了 Mermaid 图表？
-   - 图表是否清晰、有标注、易于理解？
-   - 图表是否会在 Markdown 中自动渲染为图片（而不是源代码）？
-   - 是否有应该添加图表但没有的地方？
-
-7. 【深度讲解】（必须满足）
-   - 是否讲解了原理和机制？
-   - 是否讲解了常见的坑和注意事项？
-   - 是否讲解了性能影响和优化方向？
-   - 是否讲解了与其他概念的关系？
-
-8. 【章节过渡】（必须满足）
-   - 是否在章节之间添加了过渡性语句？
-   - 过渡语句是否清晰地说明了章节之间的关系？
-   - 是否帮助读者理解内容的逻辑流程？
-
-9. 【教学化结构】（必须满足）
-   - 是否存在"教学大纲"（全局结构、每节目标、前置知识、核心问题、判定主线）？
-   - 每一节是否都包含以下结构块：
-     * 本节你会学到什么
-     * 前置知识
-     * 概念解释（是什么）
-     * 为什么需要它（动机 / 场景）
-     * 怎么用（步骤 + 示例代码）
-     * 坑点与最佳实践
-     * 小结
-   - 正文与大纲的一致性是否良好（标题、顺序、内容是否对应）？
-   - 是否避免了"机械降重 + 加例子"的改写方式？
-   - 是否真正体现了教学化的思路？
-
-10. 【Markdown 格式】（必须满足）
-   - 是否正确使用了多级标题（#、##、###、####）？
-   - 是否使用了列表、表格、代码块等格式？
-   - 是否有适当的段落空行？
-   - 是否极少使用 emoji，只在必要时使用？
-   - emoji 的使用是否过度（不应该有太多 emoji）？
-
-【评审流程】
-1. 逐一检查上述 10 个标准
-2. 对于每个标准，判断是否满足
-3. 如果有任何标准不满足，列出具体的改进建议
-4. 只有当所有 10 个标准都完全满足时，才能调用 satisfied_and_exit 工具
-
-【改进建议格式】
-如果文档不满意，请提供具体的改进建议，格式如下：
-- 【标准名称】：具体问题描述
-- 【问题位置】：指出文档中具体的章节或段落位置（例如："第二章节 - 数据库事务部分"）
-- 【改进建议】：详细的改进方案
-- 【示例】：如果适用，提供改进前后的对比
-
-【重要说明】
-- 明确指出问题所在的具体位置，帮助 SummaryAgent 进行增量改进
-- 不要要求 SummaryAgent 重新改写整个文档
-- 只指出需要改进的部分，这样可以大幅减少 token 消耗
-
-【输出要求】
-- 如果文档满意，需要执行以下步骤：
-  1. 调用 save_document 工具将改写后的文档保存到本地文件
-  2. 调用 save_to_feishu 工具将改写后的文档保存到飞书文档
-  3. 调用 "satisfied_and_exit" 工具退出循环
-- 如果文档不满意，详细列出所有不满足的标准和改进建议，这些建议会被传递给 SummaryAgent 进行改进
-- 文件名应该清晰易识别（例如：改写文档_技术文档.md）
-- 飞书文档标题应该与本地文件名保持一致
-
-【重要提示】
-- 不要轻易通过审核，要确保文档质量真正达到标准
-- 如果有任何疑虑，宁可要求改进也不要通过
-- 严格按照上述 10 个标准进行评审，不要遗漏任何一个
-- 特别注意：教学化结构是新增的关键标准，要重点检查
-- 特别注意：示例质量 > 数量，避免机械凑"2-3 个示例"
-- 特别注意：代码注释要有解释性，不是翻译式注释
-  * 检查是否存在关键步骤没有解释（重试策略、事务边界、错误处理）
-  * 检查是否有大量"翻译式注释"（如 i++ // i 加 1）→ 应当视为不合格
-- 特别注意：emoji 使用过度是常见问题，要严格检查
-- 特别注意：章节过渡语句容易被遗漏，要检查是否有
-- 特别注意：避免"机械降重 + 加例子"的改写方式，要真正体现教学化思路`,
-		Model: models.NewQwenModel(ctx, constant.QWEN3_MAX_PREVIEW),
+		Instruction: renderInstruction(cfg, prompts.Reviewer, data),
+		Model:       cfg.chatModel(ctx, cfg.Reviewer),
 		ToolsConfig: adk.ToolsConfig{
 			ToolsNodeConfig: compose.ToolsNodeConfig{
-				Tools: []tool.BaseTool{
-					saveDocumentTool,
-					saveToFeishuTool,
-					satisfiedAndExitTool,
-				},
+				Tools: reviewTools,
 			},
 			ReturnDirectly: map[string]bool{
-				"satisfied_and_exit": true,
-				"save_document":      true,
-				"save_to_feishu":     true,
+				"save_document":  true,
+				"save_to_feishu": true,
 			},
 		},
 	})
 	if err != nil {
 		panic(err)
 	}
-	return a
+	gate.Agent = a
+	return gate
 }
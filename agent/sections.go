@@ -0,0 +1,597 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"eino_test/components"
+	"eino_test/components/state"
+	"eino_test/feishu"
+	"eino_test/history"
+	"eino_test/markdown"
+	"eino_test/outline"
+	"eino_test/prompts"
+	"eino_test/review"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// sectionAgentName 按章节改写时，计划、过渡和一致性检查等步骤发出的事件使用的 AgentName
+const sectionAgentName = "sectionAgent"
+
+// sectionPlanFile 按章节改写时写入修订历史目录的全局教学计划，从 checkpoint 恢复时沿用。
+// 与整篇改写确认大纲的 confirmedFile 分开，换了改写方式恢复时不会把对方的文件当成自己的状态
+const sectionPlanFile = "section_plan.md"
+
+// excerptRunes 生成过渡段时截取前一节结尾和后一节开头的字数，也是全文摘要中每一节开头的字数
+const excerptRunes = 600
+
+var sectionTermRe = regexp.MustCompile("`([^`\n]+)`|\\*\\*([^*\n]+)\\*\\*")
+
+// docSection 源文档按标题切出的一节，Content 保留原文的缩进和空行
+type docSection struct {
+	Title   string
+	Content string
+}
+
+// consistencyFix 全局一致性检查给出的一处替换
+type consistencyFix struct {
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
+	Reason  string `json:"reason"`
+}
+
+// useSections 判断是否按章节改写：sections 总是按章节，auto 在源文档超过 AutoChars 个字符（不含空白）时按章节
+func (c *Config) useSections(source string) bool {
+	switch c.Sections.Mode {
+	case "sections":
+		return true
+	case "auto":
+		n := 0
+		for _, r := range source {
+			if !unicode.IsSpace(r) {
+				n++
+			}
+		}
+		return n > c.Sections.AutoChars
+	}
+	return false
+}
+
+// splitSections 按不深于 level 级的标题切分文档。components.NewTrans 会去掉空行和缩进，
+// 这里只用它识别标题，再按标题在原文中的位置切分，代码块中的 # 不会被当作标题。
+// 只有标题没有正文的章节（例如紧跟二级标题的一级标题）会并入下一节
+func splitSections(ctx context.Context, content string, level int) ([]docSection, error) {
+	docs, err := components.NewTrans(ctx).Transform(ctx, []*schema.Document{{Content: content}})
+	if err != nil {
+		return nil, fmt.Errorf("按标题切分文档失败: %w", err)
+	}
+	var headings []string
+	for _, d := range docs {
+		first, _, _ := strings.Cut(d.Content, "\n")
+		first = strings.TrimSpace(first)
+		if n, _, ok := markdown.ParseHeading(first); ok && n <= level {
+			headings = append(headings, first)
+		}
+	}
+
+	var (
+		sections []docSection
+		current  docSection
+		lines    []string
+	)
+	flush := func() {
+		current.Content = strings.TrimSpace(strings.Join(lines, "\n"))
+		if current.Content != "" {
+			sections = append(sections, current)
+		}
+	}
+	all := strings.Split(content, "\n")
+	code := markdown.CodeLines(all)
+	for i, line := range all {
+		trimmed := strings.TrimSpace(line)
+		if !code[i] && len(headings) > 0 && trimmed == headings[0] {
+			headings = headings[1:]
+			// 前面只有标题时不单独成节，标题留给下一节
+			if !headingOnly(lines) {
+				flush()
+				lines = nil
+			}
+			_, title, _ := markdown.ParseHeading(trimmed)
+			current = docSection{Title: title}
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	for i := range sections {
+		if sections[i].Title == "" {
+			sections[i].Title = "开篇"
+		}
+	}
+	return sections, nil
+}
+
+// headingOnly 判断已收集的行是否只有标题和空行
+func headingOnly(lines []string) bool {
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" && !markdown.IsHeading(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// sectionAt 返回第 i 节在提示词中使用的位置信息
+func sectionAt(sections []docSection, i int) *sectionInfo {
+	s := &sectionInfo{Index: i + 1, Total: len(sections), Title: sections[i].Title}
+	if i > 0 {
+		s.Prev = sections[i-1].Title
+	}
+	if i+1 < len(sections) {
+		s.Next = sections[i+1].Title
+	}
+	return s
+}
+
+// sectionWriter 按章节改写的各个步骤共用的配置、模型和事件输出
+type sectionWriter struct {
+	cfg      *Config
+	model    model.BaseChatModel
+	sections []docSection
+	// outline 改写前确定的教学大纲，没有大纲阶段时为 nil
+	outline *outline.Outline
+	plan    string
+	// run 修订历史，每一节的修订都记录到这里，为 nil 时不记录
+	run *history.Run
+
+	mu      sync.Mutex
+	onEvent func(*adk.AgentEvent)
+}
+
+// runSectionRewrite 按章节并行改写长文档：切分章节 → 按教学大纲生成全局教学计划 → 每一节并行运行改写-评审循环 →
+// 拼接章节并生成过渡段 → 全局一致性检查 → 对照教学大纲检查全文 → 保存。o 为 nil 时没有大纲阶段，章节少于两个时退回整篇改写
+func runSectionRewrite(ctx context.Context, cfg *Config, documentPath, source string, o *outline.Outline, run *history.Run, onEvent func(*adk.AgentEvent)) (*RewriteResult, error) {
+	sections, err := splitSections(ctx, source, cfg.Sections.Level)
+	if err != nil {
+		return nil, err
+	}
+	if len(sections) < 2 {
+		return nil, errTooFewSections
+	}
+
+	w := &sectionWriter{cfg: cfg, model: cfg.chatModel(ctx, cfg.Summary), sections: sections, outline: o, run: run, onEvent: onEvent}
+	w.notify(fmt.Sprintf("按 %d 级标题切分为 %d 节，最多同时改写 %d 节", cfg.Sections.Level, len(sections), cfg.Sections.Workers))
+
+	if w.plan, err = w.loadPlan(ctx, documentPath); err != nil {
+		return nil, err
+	}
+	w.notify("【全局教学计划】\n" + w.plan)
+	if run != nil {
+		titles := make([]string, len(sections))
+		for i, s := range sections {
+			titles[i] = s.Title
+		}
+		if err := errors.Join(run.SetSections(titles), run.WriteFile(sectionPlanFile, []byte(w.plan))); err != nil {
+			log.Printf("记录修订历史失败: %v", err)
+		}
+	}
+
+	drafts := make([]string, len(sections))
+	results := make([]*RewriteResult, len(sections))
+	errs := make([]error, len(sections))
+	parallel(len(sections), cfg.Sections.Workers, func(i int) {
+		drafts[i], results[i], errs[i] = w.rewrite(ctx, i)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	result := &RewriteResult{Prompts: cfg.Prompts.Refs(), Sections: len(sections), Approved: true}
+	var unapproved []unapprovedPart
+	for i, r := range results {
+		result.Iterations = max(result.Iterations, r.Iterations)
+		result.Reviews = append(result.Reviews, r.Reviews...)
+		if r.Best != nil && (result.Best == nil || r.Best.Average < result.Best.Average) {
+			// 按章节改写时 Best 记录得分最低的章节的最好成绩，代表全文的短板
+			result.Best = r.Best
+		}
+		if !r.Approved {
+			result.Approved = false
+			title := fmt.Sprintf("第 %d 节《%s》", i+1, sections[i].Title)
+			if r.Best != nil {
+				unapproved = append(unapproved, unapprovedPart{Title: title, Result: r.Best})
+			}
+			w.notify(fmt.Sprintf("%s在 %d 轮内未通过评审，使用得分最高的改写稿", title, r.Iterations))
+		}
+	}
+
+	// 过渡段生成失败只影响衔接，不中断流程
+	transitions := make([]string, len(sections)-1)
+	parallel(len(transitions), cfg.Sections.Workers, func(i int) {
+		text, err := w.transition(ctx, drafts, i)
+		if err != nil {
+			w.notify(fmt.Sprintf("生成第 %d、%d 节之间的过渡段失败: %v", i+1, i+2, err))
+			return
+		}
+		transitions[i] = text
+	})
+	content := stitch(drafts, transitions)
+
+	if fixes, err := w.checkConsistency(ctx, drafts, transitions); err != nil {
+		w.notify(fmt.Sprintf("全局一致性检查失败，保留拼接结果: %v", err))
+	} else {
+		var applied []string
+		content, applied = applyFixes(content, fixes)
+		if len(applied) > 0 {
+			w.notify("全局一致性检查已统一:\n" + strings.Join(applied, "\n"))
+		}
+	}
+
+	// 每一节只评审自己的正文，章节是否齐全、顺序是否与大纲一致要在拼接后对照全文检查
+	if o != nil {
+		if report := outline.Check(o, content); !report.OK() {
+			w.notify(fmt.Sprintf("【大纲一致性检查】拼接后的全文与改写前确定的教学大纲（%d 节）不一致:\n%s", report.Sections, report.Format()))
+		}
+	}
+
+	if !result.Approved {
+		content = markUnapproved(cfg, content, unapproved)
+		result.Fallback = true
+	}
+	path, out, err := saveContent(ctx, cfg, outputName(documentPath, result.Approved), content)
+	w.notify(out)
+	if err != nil {
+		return result, err
+	}
+	result.OutputPath = path
+
+	// 与整篇改写时 ReviewerAgent 的做法一致，通过评审后同时保存到飞书。本地文件已经保存，飞书保存失败只提示不中断
+	if result.Approved {
+		out, err := saveToFeishu(ctx, cfg, documentPath, content)
+		switch {
+		case err == nil:
+			w.notify(out)
+		case errors.Is(err, feishu.ErrNotConfigured):
+		case out != "":
+			w.notify(out)
+		default:
+			w.notify(fmt.Sprintf("保存到飞书失败: %v", err))
+		}
+	}
+	return result, nil
+}
+
+// errTooFewSections 源文档切分后不足两节，按章节改写没有意义
+var errTooFewSections = errors.New("章节少于两个")
+
+// notify 以 sectionAgent 的名义输出一条进度消息
+func (w *sectionWriter) notify(text string) {
+	w.emit(progressEvent(sectionAgentName, text))
+}
+
+// emit 把事件交给 onEvent，多个章节同时改写时保证 onEvent 不会被并发调用
+func (w *sectionWriter) emit(event *adk.AgentEvent) {
+	if w.onEvent == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onEvent(event)
+}
+
+// config 返回第 i 节使用的配置：只评审不保存，带上全局教学计划和章节位置
+func (w *sectionWriter) config(i int) *Config {
+	sc := *w.cfg
+	sc.SkipSave = true
+	sc.plan = w.plan
+	sc.section = sectionAt(w.sections, i)
+	return &sc
+}
+
+// generate 渲染提示词模板并直接调用模型，返回去掉首尾空白的回复
+func (w *sectionWriter) generate(ctx context.Context, name string, data promptData) (string, error) {
+	query, err := w.cfg.Prompts.Render(name, data)
+	if err != nil {
+		return "", err
+	}
+	msg, err := w.model.Generate(ctx, []*schema.Message{schema.UserMessage(query)})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(msg.Content), nil
+}
+
+// loadPlan 从 checkpoint 恢复时沿用之前生成的全局教学计划，保证已经完成和尚未完成的章节按同一份计划改写；
+// 第一次运行时生成新的计划
+func (w *sectionWriter) loadPlan(ctx context.Context, documentPath string) (string, error) {
+	if w.run != nil {
+		if data, err := w.run.ReadFile(sectionPlanFile); err == nil && len(data) > 0 {
+			return string(data), nil
+		}
+	}
+	return w.makePlan(ctx, documentPath)
+}
+
+// makePlan 根据章节概要生成全局教学计划，只调用一次，所有章节共用。有教学大纲时计划按大纲安排，
+// 并把大纲放在计划前面，每一节的改写和评审都能看到它
+func (w *sectionWriter) makePlan(ctx context.Context, documentPath string) (string, error) {
+	var summary strings.Builder
+	for i, s := range w.sections {
+		fmt.Fprintf(&summary, "%d. %s（%d 字）\n", i+1, s.Title, utf8.RuneCountInString(s.Content))
+		var opening []string
+		body := strings.Split(s.Content, "\n")[1:]
+		code := markdown.CodeLines(body)
+		for j, line := range body {
+			if code[j] {
+				continue
+			}
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case markdown.IsHeading(trimmed):
+				fmt.Fprintf(&summary, "   - %s\n", trimmed)
+			case trimmed != "" && len(opening) < 2:
+				opening = append(opening, trimmed)
+			}
+		}
+		if len(opening) > 0 {
+			fmt.Fprintf(&summary, "   开头：%s\n", excerpt(strings.Join(opening, " "), 200, false))
+		}
+	}
+
+	data := w.config(0).promptData()
+	data.Filepath, data.Content = documentPath, summary.String()
+	if w.outline != nil {
+		data.Plan = w.outline.Markdown()
+	}
+	plan, err := w.generate(ctx, prompts.SectionPlan, data)
+	if err != nil {
+		return "", fmt.Errorf("生成全局教学计划失败: %w", err)
+	}
+	if data.Plan != "" {
+		plan = data.Plan + "\n" + plan
+	}
+	return plan, nil
+}
+
+// sectionOutcome 一节改写完成后写入修订历史目录的结果，从 checkpoint 恢复时已经完成的章节直接使用它
+type sectionOutcome struct {
+	Draft      string           `json:"draft"`
+	Approved   bool             `json:"approved"`
+	Iterations int              `json:"iterations"`
+	Reviews    []*review.Result `json:"reviews,omitempty"`
+	Best       *review.Result   `json:"best,omitempty"`
+}
+
+// sectionCheckpointID 第 i 节改写-评审循环的 checkpoint ID，同时是完成后结果文件的文件名
+func sectionCheckpointID(i int) string {
+	return fmt.Sprintf("section-%02d", i+1)
+}
+
+// rewrite 对第 i 节运行一次改写-评审循环，返回通过评审的改写稿，未通过时返回得分最高的改写稿。
+// 这一节在之前的运行中已经完成时直接返回记录的结果
+func (w *sectionWriter) rewrite(ctx context.Context, i int) (string, *RewriteResult, error) {
+	sc := w.config(i)
+	section := w.sections[i]
+	if draft, result, ok := w.loadOutcome(i); ok {
+		w.notify(fmt.Sprintf("第 %d 节《%s》已在之前的运行中完成，跳过", i+1, section.Title))
+		return draft, result, nil
+	}
+
+	loop, err := newRewriteLoop(ctx, fmt.Sprintf("第%d节改写Agent", i+1), "一个章节的改写-评审循环",
+		sc.MaxIterations, newRewriterAgent(ctx, sc), newReviewerAgent(ctx, sc, true))
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := sc.promptData()
+	data.Content = section.Content
+	query, err := sc.Prompts.Render(prompts.SectionRequest, data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	initial := &state.State{
+		Source: &state.Source{Path: w.cfg.Source, Content: section.Content, Section: i + 1, Title: section.Title},
+		Plan:   w.plan,
+	}
+	iter := runSteps(ctx, loop, w.cfg.checkpoints, sectionCheckpointID(i), []adk.Message{schema.UserMessage(query)}, initial.Values())
+
+	result := &RewriteResult{}
+	result.restore(w.run, i+1)
+	recorder := newRevisionRecorder(w.run, i+1)
+	var draft string
+	if recorder != nil {
+		// 从 checkpoint 恢复时，中断前的最后一版改写稿可能在恢复后直接通过评审
+		draft = recorder.draft
+	}
+	err = drain(iter, func(event *adk.AgentEvent) {
+		result.Observe(event)
+		recorder.Observe(event)
+		if text, ok := draftOf(event); ok {
+			draft = text
+		}
+		w.emit(event)
+	})
+	recorder.Close()
+	if err != nil {
+		return "", nil, fmt.Errorf("改写第 %d 节《%s》失败: %w", i+1, section.Title, err)
+	}
+	if !result.Approved && result.bestDraft != "" {
+		draft = result.bestDraft
+	}
+	if strings.TrimSpace(draft) == "" {
+		return "", nil, fmt.Errorf("改写第 %d 节《%s》失败: 没有产出改写稿", i+1, section.Title)
+	}
+	draft = strings.TrimSpace(draft)
+	w.saveOutcome(i, draft, result)
+	return draft, result, nil
+}
+
+// loadOutcome 读取第 i 节在之前的运行中完成后记录的结果
+func (w *sectionWriter) loadOutcome(i int) (string, *RewriteResult, bool) {
+	if w.run == nil {
+		return "", nil, false
+	}
+	data, err := w.run.ReadFile(sectionCheckpointID(i) + ".json")
+	if err != nil {
+		return "", nil, false
+	}
+	var o sectionOutcome
+	if err := json.Unmarshal(data, &o); err != nil || o.Draft == "" {
+		return "", nil, false
+	}
+	return o.Draft, &RewriteResult{Approved: o.Approved, Iterations: o.Iterations, Reviews: o.Reviews, Best: o.Best}, true
+}
+
+// saveOutcome 记录第 i 节的结果，恢复时不再改写这一节
+func (w *sectionWriter) saveOutcome(i int, draft string, result *RewriteResult) {
+	if w.run == nil {
+		return
+	}
+	data, err := json.MarshalIndent(&sectionOutcome{
+		Draft: draft, Approved: result.Approved, Iterations: result.Iterations, Reviews: result.Reviews, Best: result.Best,
+	}, "", "  ")
+	if err == nil {
+		err = w.run.WriteFile(sectionCheckpointID(i)+".json", data)
+	}
+	if err != nil {
+		log.Printf("记录第 %d 节的结果失败: %v", i+1, err)
+	}
+}
+
+// transition 为第 i 节和第 i+1 节之间生成过渡段
+func (w *sectionWriter) transition(ctx context.Context, drafts []string, i int) (string, error) {
+	data := w.config(i + 1).promptData()
+	data.Content = fmt.Sprintf("前一节《%s》的结尾：\n%s\n\n后一节《%s》的开头：\n%s",
+		w.sections[i].Title, excerpt(drafts[i], excerptRunes, true),
+		w.sections[i+1].Title, excerpt(drafts[i+1], excerptRunes, false))
+	return w.generate(ctx, prompts.SectionTransition, data)
+}
+
+// checkConsistency 根据全文摘要做全局一致性检查，返回需要统一的替换
+func (w *sectionWriter) checkConsistency(ctx context.Context, drafts, transitions []string) ([]consistencyFix, error) {
+	data := w.config(0).promptData()
+	data.Content = digest(drafts, transitions)
+	reply, err := w.generate(ctx, prompts.SectionConsistency, data)
+	if err != nil {
+		return nil, err
+	}
+	var fixes []consistencyFix
+	if err := json.Unmarshal([]byte(stripFence(reply)), &fixes); err != nil {
+		return nil, fmt.Errorf("解析检查结果失败: %w", err)
+	}
+	return fixes, nil
+}
+
+// stitch 按顺序拼接各节改写稿，相邻两节之间插入过渡段
+func stitch(drafts, transitions []string) string {
+	var b strings.Builder
+	for i, d := range drafts {
+		if i > 0 {
+			b.WriteString("\n\n")
+			if t := transitions[i-1]; t != "" {
+				b.WriteString(t + "\n\n")
+			}
+		}
+		b.WriteString(strings.TrimSpace(d))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// digest 生成全局一致性检查使用的全文摘要：每一节的标题、开头和出现的术语，以及各个过渡段
+func digest(drafts, transitions []string) string {
+	var b strings.Builder
+	for i, d := range drafts {
+		title, body, _ := strings.Cut(strings.TrimSpace(d), "\n")
+		fmt.Fprintf(&b, "### 第 %d 节 %s\n开头：%s\n", i+1, strings.TrimLeft(title, "# "), excerpt(body, excerptRunes/2, false))
+		if terms := sectionTerms(d); len(terms) > 0 {
+			fmt.Fprintf(&b, "术语：%s\n", strings.Join(terms, "、"))
+		}
+		if i < len(transitions) && transitions[i] != "" {
+			fmt.Fprintf(&b, "过渡段：%s\n", transitions[i])
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// sectionTerms 收集改写稿正文中以行内代码或加粗标出的术语，按出现顺序去重
+func sectionTerms(content string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, line := range markdown.ProseLines(content) {
+		for _, m := range sectionTermRe.FindAllStringSubmatch(line, -1) {
+			term := m[1] + m[2]
+			if !seen[term] && len(terms) < 30 {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// applyFixes 在代码块之外执行一致性检查给出的替换，返回替换后的内容和实际生效的替换说明
+func applyFixes(content string, fixes []consistencyFix) (string, []string) {
+	lines := strings.Split(content, "\n")
+	var applied []string
+	for _, f := range fixes {
+		if f.Find == "" || f.Find == f.Replace || strings.Contains(f.Find, "\n") {
+			continue
+		}
+		n := 0
+		code := markdown.CodeLines(lines)
+		for i, line := range lines {
+			if code[i] {
+				continue
+			}
+			n += strings.Count(line, f.Find)
+			lines[i] = strings.ReplaceAll(line, f.Find, f.Replace)
+		}
+		if n > 0 {
+			applied = append(applied, fmt.Sprintf("- %q → %q（%d 处）：%s", f.Find, f.Replace, n, f.Reason))
+		}
+	}
+	return strings.Join(lines, "\n"), applied
+}
+
+// excerpt 截取文本开头（tail 为 true 时截取结尾）的 n 个字符
+func excerpt(s string, n int, tail bool) string {
+	s = strings.TrimSpace(s)
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if tail {
+		return "…" + string(r[len(r)-n:])
+	}
+	return string(r[:n]) + "…"
+}
+
+// parallel 用最多 workers 个 goroutine 对 0..n-1 执行 fn，全部完成后返回
+func parallel(n, workers int, fn func(i int)) {
+	sem := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"eino_test/config"
+	"eino_test/feishu/feishutest"
+)
+
+// TestRunSectionRewriteFeishu 测试按章节改写通过评审后，拼接保存的全文同时保存到飞书
+func TestRunSectionRewriteFeishu(t *testing.T) {
+	srv := feishutest.NewServer("cli_sections", "secret")
+	defer srv.Close()
+
+	f := newRewriteFixture(t, "# Kafka\n\n## 主题与分区\n\n正文一\n\n## 消费者\n\n正文二\n", "## Kafka 入门\n\n改写后的正文\n", 9)
+	f.cfg.Sections.Mode = "sections"
+	f.cfg.Feishu = config.FeishuConfig{AppID: "cli_sections", AppSecret: "secret", BaseURL: srv.URL}
+
+	result, err := RunRewrite(context.Background(), f.cfg, f.source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sections != 2 || !result.Approved || result.OutputPath == "" {
+		t.Fatalf("执行结果不符合预期: sections=%d approved=%v output=%q", result.Sections, result.Approved, result.OutputPath)
+	}
+	docs := srv.Documents()
+	if len(docs) != 1 || docs[0].Title != "Kafka 入门" {
+		t.Fatalf("通过评审后应保存到飞书: %+v", docs)
+	}
+	if blocks := srv.Blocks(docs[0].ID); len(blocks) == 0 {
+		t.Error("飞书文档没有写入正文")
+	}
+}
@@ -2,8 +2,8 @@ package agent
 
 import (
 	"context"
-	"eino_test/common/constant"
-	"eino_test/components/models"
+	"eino_test/components/state"
+	"eino_test/prompts"
 	"eino_test/tools"
 	"log"
 
@@ -12,165 +12,55 @@ import (
 	"github.com/cloudwego/eino/compose"
 )
 
-func NewSummaryAgent(ctx context.Context) adk.Agent {
+// RewriterName 负责改写的 SummaryAgent 的名称，也是它完成一步时 StepAction.Agent 的值
+const RewriterName = "summaryAgent"
+
+func NewSummaryAgent(ctx context.Context, cfg *Config) adk.Agent {
+	cfg = cfg.withDefaults()
+
 	// 创建读取文档工具
 	readDocumentTool, err := tools.NewReadDocumentTool()
 	if err != nil {
 		log.Fatalf("创建读取文档工具失败: %v", err)
 	}
 
-	// SummaryAgent: 改写文档，输出保存到 session 的 "document_content" 中
-	a, err := adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
-		Name:        "summaryAgent",
-		Description: "文档改写agent",
-		Instruction: `你是一个专业的技术文档改写专家，专门为后端开发初学者讲解复杂的技术概念。
-
-【用户背景信息】（用于帮助你更好地调整内容难度和示例）
-- 编程语言：掌握 Java、Golang 的简单后端开发
-- 中间件经验：熟悉 MySQL、Kafka、Redis 的基本使用
-- 架构知识：了解分布式设计的基本概念
-- 学习阶段：正在从零开始学习和梳理后端开发框架
-
-【改写原则】
-1. 【保留结构】尽量保留原文的整体结构和内容，不要进行大幅删减。在原文合理的基础上进行改写
-2. 【语气调整】使用友好、亲切的语气，避免生硬的学术用语。用"我们"、"让我们"等包容性语言
-3. 【详细展开】不要过度总结，提供充分的细节和上下文。每个关键概念都要充分解释
-   - 对于每个重要概念，都要解释"是什么"、"为什么"、"怎么用"
-   - 不要假设读者已经知道某些概念，要从基础开始讲解
-   - 对于复杂的概念，要分步骤进行讲解
-4. 【举例学习】提供高质量的代码示例和场景示例（质量 > 数量）
-   - 对每个"大概念"至少提供 1 个完整示例
-   - 对容易混淆的概念对（如串行/并行、同步/异步）用对比表 + 一个对比示例
-   - 优先保证覆盖面，不要机械凑"2-3 个示例"
-   - 如果一个示例就够说明问题，避免重复类似示例
-   - 代码示例要完整、可运行
-   - 场景示例要贴近用户的实际工作
-   - 对于 Java/Golang 的概念，优先使用这两种语言的示例
-5. 【类比学习】使用生活中的类比来解释抽象概念。例如：
-   - 数据库事务 ≈ 银行转账（要么全部成功，要么全部失败）
-   - 缓存 ≈ 便利店（离家近，但商品有限）
-   - 消息队列 ≈ 邮局（异步处理，解耦发送者和接收者）
-   - 分布式锁 ≈ 停车位（多个人竞争同一个资源）
-6. 【对比学习】在文章中出现相似或相对的概念时，明确对比它们的差异和适用场景
-   - 使用表格或列表进行对比
-   - 说明何时选择哪个方案
-   - 突出各自的优缺点
-   - 例如：对比 MySQL 和 Redis、同步和异步、强一致性和最终一致性等
-7. 【图表辅助】对于复杂的概念、流程或架构，使用 Mermaid 图表进行辅助说明
-   - 使用三个反引号加 mermaid 代码块
-   - 例如：流程图、时序图、类图、部署图等
-   - 图表要清晰、有标注、易于理解
-   - Mermaid 会在 Markdown 中自动渲染为图片
-8. 【深度讲解】对于关键概念，要进行深度讲解
-   - 讲解原理和机制
-   - 讲解常见的坑和注意事项
-   - 讲解性能影响和优化方向
-   - 讲解与其他概念的关系
-9. 【代码注释】代码注释要有解释性，不是翻译式注释
-   - 对关键语句必须有解释性注释（不是翻译式注释）
-   - 优先解释：为什么要这样写、有什么坑、可替代写法与优缺点
-   - 重点关注关键步骤：重试策略、事务边界、错误处理等
-   - 避免"翻译式注释"（如 i++ // i 加 1）
-   - 注释要清晰、准确、易于理解
-10. 【章节过渡】在章节之间添加过渡性语句
-   - 在每个新章节开始前，添加引入语句
-   - 说明该章节与前一章节的关系
-   - 帮助读者理解内容的逻辑流程
-
-【Markdown 格式要求】
-- 使用 # 作为一级标题（文档标题）
-- 使用 ## 作为二级标题（主要章节）
-- 使用 ### 作为三级标题（子章节）
-- 使用 #### 作为四级标题（详细说明）
-- 使用 - 或 * 作为列表项
-- 使用 **文本** 进行加粗强调
-- 使用反引号进行代码高亮
-- 使用三个反引号加语言名称进行多行代码展示（代码要有详细注释）
-- 使用三个反引号加 mermaid 进行图表展示（会自动渲染为图片）
-- 使用 > 作为引用块（用于强调重要概念）
-- 使用 | 创建对比表格
-- 使用 --- 作为分隔线
-- 保持段落之间的空行以提高可读性
-- 极少使用 emoji，只在必要时使用（如 💡 表示提示、⚠️ 表示注意等），不要过度使用
+	// 教学大纲由 PlannerAgent 在改写前生成，已经渲染在 SummaryAgent 的指令中
+	a := newRewriterAgent(ctx, cfg, readDocumentTool)
+	reviewerAgent := NewReviewerAgent(ctx, cfg)
 
-【教学化规划阶段】（第一次改写时必须执行）
-
-第一步：生成教学大纲（存入 session["teaching_plan"]）
-1. 分析原文的全局结构
-2. 输出以下内容：
-   - 全局结构：本篇分几大部分，每部分解决什么问题
-   - 每一节的：
-     * 本节目标
-     * 前置知识
-     * 要回答的 3 个核心问题：是什么 / 为什么 / 怎么用
-   - 判定主线：列出读者完成后要能做到的 3～5 件事
-     * 例如："能搭起一个最小可用服务"
-     * 例如："能写一个基本的 Kafka 消费者"
-
-第二步：按照教学模板改写正文
-1. 每一节都必须遵守以下模板：
-   - 本节你会学到什么（与大纲对应）
-   - 前置知识（与大纲对应）
-   - 概念解释（是什么）
-   - 为什么需要它（动机 / 场景）
-   - 怎么用（步骤 + 示例代码）
-   - 坑点与最佳实践
-   - 小结（总结本节要点）
-2. 确保每一节都有这些结构块
-3. 确保正文与大纲的一致性（标题、顺序、内容对应）
-
-【工作流程】
-第一次改写（初始改写）：
-1. 使用 read_document 工具读取用户指定的 markdown 文件
-2. 第一步：生成教学大纲，存入 session["teaching_plan"]
-3. 第二步：按照教学模板改写正文
-4. 输出改写后的完整文档内容
+	loopAgent, err := newRewriteLoop(ctx, "文档改写Agent", "一个文档改写agent，包含改写和评审的循环",
+		cfg.MaxIterations, a, reviewerAgent)
+	if err != nil {
+		panic(err)
+	}
 
-后续改写（增量改进）：
-1. 如果收到评审反馈（改进建议），不要重新读取原文件
-2. 基于当前的改写版本和评审反馈，只修改有问题的部分
-3. 保留已经通过评审的内容，只改进不满足标准的部分
-4. 这样可以大幅减少 token 消耗
+	// 循环在单独的 Runner 中运行，启用修订历史时每完成一步保存一次 checkpoint
+	return &checkpointedLoop{Agent: loopAgent, store: cfg.checkpoints}
+}
 
-【输出要求】
-- 你的输出应该只包含改写后的文档内容，不要包含其他说明
-- 确保改写后的文档结构清晰、内容完整、易于理解
-- 改写后的文档应该比原文更详细、更易懂
-- 代码示例要有详细的行注释
-- 章节之间要有过渡性语句
+// newRewriterAgent 创建负责改写的 summaryAgent，除 extra 外都挂载 lint_markdown 工具
+func newRewriterAgent(ctx context.Context, cfg *Config, extra ...tool.BaseTool) adk.Agent {
+	// 创建格式检查工具，改写稿输出前可以先自查
+	lintTool, err := tools.NewLintMarkdownTool(cfg.Lint)
+	if err != nil {
+		log.Fatalf("创建格式检查工具失败: %v", err)
+	}
 
-【Token 优化建议】
-- 第一次改写时，需要读取原文件
-- 后续改写时，基于评审反馈进行增量改进，不要重新读取原文件
-- 这样可以避免重复处理已经满足要求的内容
-- 大幅降低 token 消耗`,
-		Model: models.NewQwenModel(ctx, constant.QWEN3_MAX_PREVIEW),
+	// SummaryAgent: 改写文档，输出保存到 session 的 "document_content" 中
+	a, err := adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
+		Name:        RewriterName,
+		Description: "文档改写agent",
+		Instruction: renderInstruction(cfg, prompts.Summary, cfg.promptData()),
+		Model:       cfg.chatModel(ctx, cfg.Summary),
 		ToolsConfig: adk.ToolsConfig{
 			ToolsNodeConfig: compose.ToolsNodeConfig{
-				Tools: []tool.BaseTool{readDocumentTool},
+				Tools: append(extra, lintTool),
 			},
 		},
-		OutputKey: "document_content", // 将输出保存到 session 中的 "document_content" 键
-	})
-	if err != nil {
-		panic(err)
-	}
-
-	reviewerAgent := NewReviewerAgent(ctx)
-
-	loopAgent, err := adk.NewLoopAgent(ctx, &adk.LoopAgentConfig{
-		Name:        "文档改写Agent",
-		Description: "一个文档改写agent，包含改写和评审的循环",
-		SubAgents: []adk.Agent{
-			a,
-			reviewerAgent,
-		},
-		MaxIterations: 5, // 最多迭代 5 次
+		OutputKey: state.DraftKey, // 将输出保存到 session 中的 "document_content" 键
 	})
-
 	if err != nil {
 		panic(err)
 	}
-
-	return loopAgent
+	return a
 }
@@ -2,48 +2,76 @@ package agent
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"strings"
 
-	"eino_test/common/constant"
-	"eino_test/components/models"
+	"eino_test/mermaid"
+	"eino_test/postprocess"
+	"eino_test/prompts"
 	"eino_test/tools"
 
 	"github.com/cloudwego/eino/adk"
 	"github.com/cloudwego/eino/components/tool"
-	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
 )
 
-func NewMainAgent(ctx context.Context) adk.Agent {
-	// 创建保存文档工具
-	saveDocumentTool, err := tools.NewSaveDocumentTool()
+// newSaveDocumentTool 按配置的后处理步骤创建 save_document 工具，保存时记录画像、提示词版本和模型
+func newSaveDocumentTool(ctx context.Context, cfg *Config) (tool.BaseTool, error) {
+	pipeline, err := postprocess.New(cfg.PostProcess, cfg.Mermaid, newMermaidFixer(ctx, cfg))
 	if err != nil {
-		log.Fatalf("创建保存文档工具失败: %v", err)
+		return nil, err
 	}
+	meta := &tools.DocumentMeta{
+		Persona: cfg.Persona.Name,
+		Prompts: cfg.Prompts.Refs(),
+		Models:  cfg.models(),
+	}
+	return tools.NewSaveDocumentTool(cfg.OutputDir, cfg.Source, pipeline, meta, cfg.Coverage)
+}
+
+// newMermaidFixer 创建 repair 步骤使用的修复函数：用 SummaryAgent 的模型只修复出错的那一个图表
+func newMermaidFixer(ctx context.Context, cfg *Config) postprocess.MermaidFixer {
+	model := cfg.chatModel(ctx, cfg.Summary)
+	return func(ctx context.Context, code string, errs []mermaid.SyntaxError) (string, error) {
+		lines := make([]string, len(errs))
+		for i, e := range errs {
+			lines[i] = "- " + e.Error()
+		}
+		data := cfg.promptData()
+		data.Content, data.Errors = code, strings.Join(lines, "\n")
+		query, err := cfg.Prompts.Render(prompts.MermaidRepair, data)
+		if err != nil {
+			return "", err
+		}
+		msg, err := model.Generate(ctx, []*schema.Message{schema.UserMessage(query)})
+		if err != nil {
+			return "", fmt.Errorf("请求模型修复图表失败: %w", err)
+		}
+		return stripFence(msg.Content), nil
+	}
+}
+
+// stripFence 去掉模型回复中多余的 ``` 围栏
+func stripFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	_, s, _ = strings.Cut(s, "\n")
+	s = strings.TrimSpace(s)
+	return strings.TrimSpace(strings.TrimSuffix(s, "```"))
+}
+
+// NewMainAgent 创建负责协调的 MainAgent：只把改写任务转交给改写-评审循环，不挂载保存工具。
+// 循环在单独的 session 中运行，保存由循环中的 ReviewerAgent 或流程结束后的程序完成
+func NewMainAgent(ctx context.Context, cfg *Config) adk.Agent {
+	cfg = cfg.withDefaults()
 
 	a, err := adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
 		Name:        "MainAgent",
 		Description: "一个负责与用户进行交互的agent，协调文档改写任务",
-		Instruction: `你是一个文档改写系统的协调者。你的任务是：
-1. 接收用户的文档改写请求
-2. 将任务转交给 SummaryAgent 进行改写（SummaryAgent 会进行多轮改写和评审）
-3. 获取改写结果后，必须使用 save_document 工具将改写后的完整文档保存到 markdown 文件中
-4. 告知用户文档已保存的位置
-
-重要提示：
-- 必须将文档改写任务转交给 SummaryAgent，不要自己进行改写
-- SummaryAgent 会自动进行改写和评审的循环，直到文档满意为止
-- 改写完成后，必须调用 save_document 工具保存文档，不要假设文档已经被保存
-- 确保保存的文件名清晰易识别（例如：改写文档_技术文档.md）
-- 从改写结果中提取完整的改写后文档内容，然后通过 save_document 工具保存`,
-		Model: models.NewQwenModel(ctx, constant.QWEN_TURBO),
-		ToolsConfig: adk.ToolsConfig{
-			ToolsNodeConfig: compose.ToolsNodeConfig{
-				Tools: []tool.BaseTool{saveDocumentTool},
-			},
-			ReturnDirectly: map[string]bool{
-				"save_document": true,
-			},
-		},
+		Instruction: renderInstruction(cfg, prompts.Main, cfg.promptData()),
+		Model:       cfg.chatModel(ctx, cfg.Supervisor),
 	})
 	if err != nil {
 		panic(err)
@@ -0,0 +1,153 @@
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status 单个文档在批量任务中的状态
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+	// StatusUnapproved 达到最大迭代次数仍未通过评审，保存的是得分最高的改写稿
+	StatusUnapproved Status = "unapproved"
+)
+
+// completed 是否已经保存了改写结果，续跑时跳过
+func (s Status) completed() bool {
+	return s == StatusDone || s == StatusUnapproved
+}
+
+// Entry 清单中的一条记录
+type Entry struct {
+	Source     string    `json:"source"`
+	Hash       string    `json:"hash"`
+	Status     Status    `json:"status"`
+	Output     string    `json:"output,omitempty"`
+	Iterations int       `json:"iterations"`
+	Error      string    `json:"error,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Manifest 批量改写的清单，记录每个源文件的处理结果，用于中断后续跑
+type Manifest struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+// LoadManifest 读取清单文件，文件不存在时返回空清单
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{path: path, entries: map[string]*Entry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取清单失败: %w", err)
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析清单 %s 失败: %w", path, err)
+	}
+	for _, e := range entries {
+		m.entries[e.Source] = e
+	}
+	return m, nil
+}
+
+// Get 返回指定源文件的记录副本
+func (m *Manifest) Get(source string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[source]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// Entries 按源文件路径排序返回所有记录的副本
+func (m *Manifest) Entries() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out
+}
+
+// outputs 返回清单中记录的所有输出文件的绝对路径
+func (m *Manifest) outputs() map[string]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[string]bool{}
+	for _, e := range m.entries {
+		if e.Output == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(e.Output); err == nil {
+			out[abs] = true
+		}
+	}
+	return out
+}
+
+// Update 写入一条记录并立即落盘，保证进程被中断时已完成的结果不会丢失
+func (m *Manifest) Update(e Entry) error {
+	e.UpdatedAt = time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[e.Source] = &e
+	return m.saveLocked()
+}
+
+// saveLocked 先写临时文件再重命名，避免写到一半时中断导致清单损坏
+func (m *Manifest) saveLocked() error {
+	entries := make([]*Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Source < entries[j].Source })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("创建清单目录失败: %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入清单失败: %w", err)
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// HashFile 计算文件内容的 SHA-256
+func HashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,198 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Result 单个文档改写的结果
+type Result struct {
+	Output     string
+	Iterations int
+	// NotApproved 达到最大迭代次数仍未通过评审，Output 是得分最高的改写稿
+	NotApproved bool
+}
+
+// RewriteFunc 改写单个文档，outputDir 为该文档对应的输出目录
+type RewriteFunc func(ctx context.Context, source, outputDir string) (*Result, error)
+
+// Options 批量改写的参数
+type Options struct {
+	// Dir 待改写文档所在目录，会递归查找其中的 .md 文件
+	Dir string
+	// OutputDir 输出根目录，源文件的相对目录结构会保留在其下
+	OutputDir string
+	// Workers 并发改写的文档数
+	Workers int
+	// Force 为 true 时忽略清单，重新改写所有文档
+	Force bool
+}
+
+// Summary 批量改写的统计信息
+type Summary struct {
+	Total     int
+	Skipped   int
+	Succeeded int
+	Failed    int
+	// Unapproved 已保存但未通过评审的文档数，计入 Succeeded
+	Unapproved int
+}
+
+// job 待处理的单个文档
+type job struct {
+	source string // 相对 Dir 的路径，作为清单的键
+	path   string
+	hash   string
+}
+
+// Run 遍历目录并用有界的 worker 池改写文档，每个文档完成后立即更新清单。
+// 清单中状态为 done 或 unapproved 且内容哈希未变化的文档会被跳过，因此中断后重新执行即可续跑。
+func Run(ctx context.Context, opts *Options, manifest *Manifest, rewrite RewriteFunc) (*Summary, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	jobs, err := collect(opts, manifest.outputs())
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{Total: len(jobs)}
+	var pending []job
+	for _, j := range jobs {
+		if e, ok := manifest.Get(j.source); ok && !opts.Force && e.Status.completed() && e.Hash == j.hash {
+			summary.Skipped++
+			continue
+		}
+		pending = append(pending, j)
+	}
+	log.Printf("共发现 %d 个文档，跳过 %d 个未变化的文档，待改写 %d 个", summary.Total, summary.Skipped, len(pending))
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	ch := make(chan job)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				status := process(ctx, opts, manifest, rewrite, j)
+				mu.Lock()
+				switch status {
+				case StatusDone:
+					summary.Succeeded++
+				case StatusUnapproved:
+					summary.Succeeded++
+					summary.Unapproved++
+				default:
+					summary.Failed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, j := range pending {
+		select {
+		case ch <- j:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	return summary, ctx.Err()
+}
+
+// process 改写单个文档并把结果写入清单，返回文档的最终状态
+func process(ctx context.Context, opts *Options, manifest *Manifest, rewrite RewriteFunc, j job) Status {
+	entry := Entry{Source: j.source, Hash: j.hash, Status: StatusRunning}
+	if err := manifest.Update(entry); err != nil {
+		log.Printf("更新清单失败: %v", err)
+	}
+
+	log.Printf("开始改写: %s", j.source)
+	outputDir := filepath.Join(opts.OutputDir, filepath.Dir(j.source))
+	result, err := rewrite(ctx, j.path, outputDir)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// 被取消的任务恢复为 pending，续跑时会重新处理
+		entry.Status = StatusPending
+		entry.Error = ctx.Err().Error()
+	case err != nil:
+		entry.Status = StatusFailed
+		entry.Error = err.Error()
+	case result != nil && result.NotApproved:
+		entry.Status = StatusUnapproved
+	default:
+		entry.Status = StatusDone
+	}
+	if result != nil {
+		entry.Output = result.Output
+		entry.Iterations = result.Iterations
+	}
+
+	if err := manifest.Update(entry); err != nil {
+		log.Printf("更新清单失败: %v", err)
+	}
+	switch entry.Status {
+	case StatusDone:
+		log.Printf("改写完成: %s -> %s（迭代 %d 次）", j.source, entry.Output, entry.Iterations)
+	case StatusUnapproved:
+		log.Printf("改写未通过评审: %s -> %s（迭代 %d 次，已保存得分最高的改写稿）", j.source, entry.Output, entry.Iterations)
+	default:
+		log.Printf("改写失败: %s: %s", j.source, entry.Error)
+	}
+	return entry.Status
+}
+
+// collect 递归查找目录下的 Markdown 文档，输出目录位于源目录内时会被排除。
+// outputs 是清单中记录的改写稿，输出目录就是源目录（例如默认的 "."）时改写稿和源文件放在一起，
+// 需要按路径排除，否则下次运行会把改写稿当作新文档再改写一遍
+func collect(opts *Options, outputs map[string]bool) ([]job, error) {
+	outAbs, _ := filepath.Abs(opts.OutputDir)
+
+	var jobs []job
+	err := filepath.WalkDir(opts.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if abs, _ := filepath.Abs(path); abs == outAbs && path != opts.Dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		if abs, _ := filepath.Abs(path); outputs[abs] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(opts.Dir, path)
+		if err != nil {
+			return err
+		}
+		hash, err := HashFile(path)
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", path, err)
+		}
+		jobs = append(jobs, job{source: filepath.ToSlash(rel), path: path, hash: hash})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("遍历目录失败: %w", err)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,149 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// writeFile 在测试目录中写入文件
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRunResumesFromManifest 测试清单续跑：未变化的文档（包括未通过评审的）跳过，变化和失败的文档重新改写
+func TestRunResumesFromManifest(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "docs")
+	out := filepath.Join(src, "output") // 输出目录位于源目录内，不能被当作源文件
+	writeFile(t, filepath.Join(src, "a.md"), "# A")
+	writeFile(t, filepath.Join(src, "sub", "b.md"), "# B")
+	writeFile(t, filepath.Join(src, "bad.md"), "# Bad")
+	writeFile(t, filepath.Join(src, "weak.md"), "# Weak")
+	writeFile(t, filepath.Join(src, "notes.txt"), "not markdown")
+
+	var calls atomic.Int32
+	rewrite := func(ctx context.Context, source, outputDir string) (*Result, error) {
+		calls.Add(1)
+		if filepath.Base(source) == "bad.md" {
+			return nil, errors.New("模型调用失败")
+		}
+		target := filepath.Join(outputDir, "改写_"+filepath.Base(source))
+		writeFile(t, target, "rewritten")
+		return &Result{Output: target, Iterations: 2, NotApproved: filepath.Base(source) == "weak.md"}, nil
+	}
+
+	manifestPath := filepath.Join(out, "manifest.json")
+	opts := &Options{Dir: src, OutputDir: out, Workers: 2}
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := Run(context.Background(), opts, manifest, rewrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Total != 4 || summary.Succeeded != 3 || summary.Failed != 1 || summary.Unapproved != 1 {
+		t.Fatalf("第一次运行统计不符合预期: %+v", summary)
+	}
+
+	e, ok := manifest.Get("sub/b.md")
+	if !ok || e.Status != StatusDone || e.Iterations != 2 || e.Output != filepath.Join(out, "sub", "改写_b.md") {
+		t.Fatalf("sub/b.md 的清单记录不符合预期: %+v", e)
+	}
+	if e, _ := manifest.Get("bad.md"); e.Status != StatusFailed || e.Error == "" {
+		t.Fatalf("bad.md 应记录为失败: %+v", e)
+	}
+	if e, _ := manifest.Get("weak.md"); e.Status != StatusUnapproved || e.Output == "" {
+		t.Fatalf("weak.md 应记录为未通过评审: %+v", e)
+	}
+
+	// 修改一个文档后从磁盘重新加载清单续跑
+	writeFile(t, filepath.Join(src, "a.md"), "# A v2")
+	calls.Store(0)
+	manifest, err = LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err = Run(context.Background(), opts, manifest, rewrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Skipped != 2 || calls.Load() != 2 {
+		t.Fatalf("续跑应只处理变化和失败的文档: %+v, calls=%d", summary, calls.Load())
+	}
+
+	// Force 模式重新处理所有文档
+	calls.Store(0)
+	opts.Force = true
+	if _, err := Run(context.Background(), opts, manifest, rewrite); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("Force 模式应处理所有文档, calls=%d", calls.Load())
+	}
+}
+
+// TestRunOutputInSourceDir 测试输出目录就是源目录时，上次保存的改写稿不会在下次运行时被当作新文档
+func TestRunOutputInSourceDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.md"), "# A")
+	writeFile(t, filepath.Join(dir, "sub", "b.md"), "# B")
+
+	var calls atomic.Int32
+	rewrite := func(ctx context.Context, source, outputDir string) (*Result, error) {
+		calls.Add(1)
+		name := strings.TrimSuffix(filepath.Base(source), ".md")
+		target := filepath.Join(outputDir, name+"_改写.md")
+		writeFile(t, target, "rewritten")
+		return &Result{Output: target, Iterations: 1, NotApproved: name == "b"}, nil
+	}
+
+	manifestPath := filepath.Join(dir, "batch_manifest.json")
+	opts := &Options{Dir: dir, OutputDir: dir, Workers: 2}
+	for run := 1; run <= 2; run++ {
+		manifest, err := LoadManifest(manifestPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls.Store(0)
+		summary, err := Run(context.Background(), opts, manifest, rewrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Total != 2 {
+			t.Fatalf("第 %d 次运行: 改写稿不应被当作源文档: %+v", run, summary)
+		}
+		if run == 2 && (summary.Skipped != 2 || calls.Load() != 0) {
+			t.Fatalf("第 2 次运行应跳过所有文档: %+v, calls=%d", summary, calls.Load())
+		}
+	}
+
+	// Force 模式也只重新改写源文档
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls.Store(0)
+	opts.Force = true
+	if _, err := Run(context.Background(), opts, manifest, rewrite); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("Force 模式应只改写 2 个源文档, calls=%d", calls.Load())
+	}
+	if _, ok := manifest.Get("a_改写.md"); ok {
+		t.Error("改写稿不应出现在清单的源文件中")
+	}
+}
@@ -0,0 +1,352 @@
+// Package codecheck 检查改写稿中的 Go 代码示例能否编译。评审标准要求代码"完整、可运行"，
+// 这里从文档中提取 ```go 代码块，缺少 package 声明的片段自动补全为可编译的文件，
+// 用 go/parser 和 go/types 做语法和类型检查，可选在临时模块中运行 go vet 或 go build，
+// 把带文档行号的编译错误交给 ReviewerAgent
+package codecheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"eino_test/config"
+	"eino_test/markdown"
+)
+
+// 检查模式，与 config.CodeCheckModes 一致
+const (
+	ModeOff   = "off"
+	ModeTypes = "types"
+	ModeVet   = "vet"
+	ModeBuild = "build"
+)
+
+// defaultTimeout 未配置超时时运行 go 命令的超时时间
+const defaultTimeout = 30 * time.Second
+
+// Block 文档中的一个 Go 代码块
+type Block struct {
+	// Index 第几个 Go 代码块，从 1 开始
+	Index int
+	// Line 代码第一行在文档中的行号
+	Line int
+	Code string
+}
+
+// Finding 一个编译问题
+type Finding struct {
+	// Line 问题在文档中的行号
+	Line int `json:"line"`
+	// Block 第几个 Go 代码块
+	Block int `json:"block"`
+	// Source 发现问题的检查：parser、types、vet 或 build
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("第 %d 行（第 %d 个 Go 代码块）[%s] %s", f.Line, f.Block, f.Source, f.Message)
+}
+
+// Report 一次检查的结果
+type Report struct {
+	// Blocks 文档中 Go 代码块的数量
+	Blocks   int       `json:"blocks"`
+	Findings []Finding `json:"findings,omitempty"`
+	// Notes 没有完整检查的原因，例如引用了第三方包
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Format 格式化为每行一条的文本
+func (r *Report) Format() string {
+	var lines []string
+	for _, f := range r.Findings {
+		lines = append(lines, "- "+f.String())
+	}
+	for _, n := range r.Notes {
+		lines = append(lines, "- 说明："+n)
+	}
+	return strings.Join(lines, "\n")
+}
+
+var fenceRe = regexp.MustCompile("^(`{3,}|~{3,})\\s*(go|golang)\\s*$")
+
+// Extract 提取文档中以 ```go 或 ```golang 开头的代码块
+func Extract(content string) []Block {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	var blocks []Block
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		m := fenceRe.FindStringSubmatch(trimmed)
+		if m == nil {
+			// 跳过其他语言的代码块，避免把其中的 ```go 当成代码块开头
+			if fence := markdown.FenceMarker(trimmed); fence != "" {
+				for i++; i < len(lines) && !markdown.ClosesFence(lines[i], fence); i++ {
+				}
+			}
+			continue
+		}
+		start := i + 1
+		for i++; i < len(lines) && !markdown.ClosesFence(lines[i], m[1]); i++ {
+		}
+		blocks = append(blocks, Block{Index: len(blocks) + 1, Line: start + 1, Code: strings.Join(lines[start:min(i, len(lines))], "\n")})
+	}
+	return blocks
+}
+
+// Check 按配置检查文档中的所有 Go 代码块，mode 为 off 或文档中没有 Go 代码时返回空报告
+func Check(ctx context.Context, content string, cfg config.CodeCheckConfig) *Report {
+	r := &Report{}
+	if cfg.Mode == "" || cfg.Mode == ModeOff {
+		return r
+	}
+	blocks := Extract(content)
+	r.Blocks = len(blocks)
+	imp := importer.ForCompiler(token.NewFileSet(), "source", nil)
+	for _, b := range blocks {
+		checkBlock(ctx, b, cfg, imp, r)
+	}
+	return r
+}
+
+// unit 补全后可以编译的源文件
+type unit struct {
+	src string
+	// lines 生成文件的每一行对应代码块中的第几行（从 0 开始），补全的行为 -1
+	lines []int
+	// snippet 语句片段被包进了 func main，未使用的变量和导入不算错误
+	snippet bool
+	fset    *token.FileSet
+	file    *ast.File
+}
+
+// docLine 把生成文件的行号转换为文档行号
+func (u *unit) docLine(b Block, line int) int {
+	if line >= 1 && line <= len(u.lines) && u.lines[line-1] >= 0 {
+		return b.Line + u.lines[line-1]
+	}
+	return b.Line
+}
+
+func checkBlock(ctx context.Context, b Block, cfg config.CodeCheckConfig, imp types.Importer, r *Report) {
+	u, err := complete(b.Code)
+	if err != nil {
+		var list scanner.ErrorList
+		if errors.As(err, &list) && len(list) > 0 {
+			for _, e := range list[:min(len(list), 3)] {
+				r.Findings = append(r.Findings, Finding{Line: u.docLine(b, e.Pos.Line), Block: b.Index, Source: "parser", Message: e.Msg})
+			}
+		} else {
+			r.Findings = append(r.Findings, Finding{Line: b.Line, Block: b.Index, Source: "parser", Message: err.Error()})
+		}
+		return
+	}
+
+	if third := thirdParty(u.file); len(third) > 0 {
+		r.Notes = append(r.Notes, fmt.Sprintf("第 %d 个 Go 代码块引用了第三方包 %s，只检查了语法", b.Index, strings.Join(third, "、")))
+		return
+	}
+
+	var typeErrs []types.Error
+	conf := types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			if te, ok := err.(types.Error); ok && !(u.snippet && te.Soft) {
+				typeErrs = append(typeErrs, te)
+			}
+		},
+	}
+	_, _ = conf.Check(u.file.Name.Name, u.fset, []*ast.File{u.file}, nil)
+	for _, te := range typeErrs {
+		r.Findings = append(r.Findings, Finding{Line: u.docLine(b, u.fset.Position(te.Pos).Line), Block: b.Index, Source: "types", Message: te.Msg})
+	}
+	if len(typeErrs) > 0 || cfg.Mode == ModeTypes {
+		return
+	}
+	if u.snippet {
+		r.Notes = append(r.Notes, fmt.Sprintf("第 %d 个 Go 代码块是语句片段，只做了类型检查", b.Index))
+		return
+	}
+	r.Findings = append(r.Findings, runGo(ctx, b, u, cfg, r)...)
+}
+
+// complete 把代码块补全为可以解析的源文件：有 package 声明时原样使用；只有顶层声明时补上 package；
+// 只有语句时把 import 以外的部分包进 func main。都解析失败时返回最接近成功的那次错误
+func complete(code string) (*unit, error) {
+	lines := strings.Split(code, "\n")
+	identity := make([]int, len(lines))
+	for i := range identity {
+		identity[i] = i
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "main.go", code, parser.PackageClauseOnly); err == nil {
+		u := &unit{src: code, lines: identity, fset: token.NewFileSet()}
+		u.file, err = parser.ParseFile(u.fset, "main.go", code, 0)
+		return u, err
+	}
+
+	decl := &unit{src: "package main\n" + code, lines: append([]int{-1}, identity...), fset: token.NewFileSet()}
+	var declErr error
+	if decl.file, declErr = parser.ParseFile(decl.fset, "main.go", decl.src, 0); declErr == nil {
+		if !hasMain(decl.file) {
+			// 没有 main 函数时当作普通包编译，go build 不会要求 main 函数
+			decl.src = "package snippet\n" + code
+			decl.file, declErr = parser.ParseFile(decl.fset, "main.go", decl.src, 0)
+		}
+		return decl, declErr
+	}
+
+	stmt := wrapStatements(lines)
+	var stmtErr error
+	if stmt.file, stmtErr = parser.ParseFile(stmt.fset, "main.go", stmt.src, 0); stmtErr == nil {
+		return stmt, nil
+	}
+	if errLine(stmt, stmtErr) > errLine(decl, declErr) {
+		return stmt, stmtErr
+	}
+	return decl, declErr
+}
+
+// wrapStatements 把开头的 import 留在顶层，其余语句包进 func main
+func wrapStatements(lines []string) *unit {
+	u := &unit{snippet: true, fset: token.NewFileSet(), lines: []int{-1}}
+	var b strings.Builder
+	b.WriteString("package main\n")
+	write := func(i int) {
+		b.WriteString(lines[i] + "\n")
+		u.lines = append(u.lines, i)
+	}
+
+	i := 0
+header:
+	for i < len(lines) {
+		trimmed := strings.TrimSpace(lines[i])
+		end := i
+		switch {
+		case strings.HasPrefix(trimmed, "import ("):
+			for end < len(lines)-1 && strings.TrimSpace(lines[end]) != ")" {
+				end++
+			}
+		case trimmed == "", strings.HasPrefix(trimmed, "//"), strings.HasPrefix(trimmed, "import "):
+		default:
+			break header
+		}
+		for ; i <= end; i++ {
+			write(i)
+		}
+	}
+
+	b.WriteString("func main() {\n")
+	u.lines = append(u.lines, -1)
+	for ; i < len(lines); i++ {
+		write(i)
+	}
+	b.WriteString("}\n")
+	u.lines = append(u.lines, -1)
+	u.src = b.String()
+	return u
+}
+
+// hasMain 文件中是否声明了 main 函数
+func hasMain(f *ast.File) bool {
+	for _, d := range f.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+// errLine 解析错误在代码块中的行号，用于比较哪种补全方式解析得更远
+func errLine(u *unit, err error) int {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 && list[0].Pos.Line <= len(u.lines) {
+		return u.lines[list[0].Pos.Line-1]
+	}
+	return -1
+}
+
+// thirdParty 返回引用的非标准库包，离线环境下无法加载这些包做类型检查
+func thirdParty(f *ast.File) []string {
+	var out []string
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+var goErrRe = regexp.MustCompile(`main\.go:(\d+)(?::\d+)?: (.+)`)
+
+// runGo 在临时模块中运行 go vet 或 go build，禁用网络和工作区，只依赖标准库
+func runGo(ctx context.Context, b Block, u *unit, cfg config.CodeCheckConfig, r *Report) []Finding {
+	bin := cfg.GoBin
+	if bin == "" {
+		bin = "go"
+	}
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		r.Notes = append(r.Notes, fmt.Sprintf("没有找到 go 命令（%s），第 %d 个 Go 代码块跳过了 go %s", bin, b.Index, cfg.Mode))
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "codecheck-*")
+	if err != nil {
+		r.Notes = append(r.Notes, fmt.Sprintf("创建临时模块失败: %v", err))
+		return nil
+	}
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module snippet\n\ngo 1.21\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte(u.src), 0644)
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := []string{cfg.Mode, "./..."}
+	if cfg.Mode == ModeBuild {
+		args = []string{"build", "-o", os.DevNull, "./..."}
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err == nil {
+		return nil
+	} else if ctx.Err() != nil {
+		r.Notes = append(r.Notes, fmt.Sprintf("第 %d 个 Go 代码块的 go %s 超时", b.Index, cfg.Mode))
+		return nil
+	}
+
+	var findings []Finding
+	for _, line := range strings.Split(out.String(), "\n") {
+		if m := goErrRe.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			findings = append(findings, Finding{Line: u.docLine(b, n), Block: b.Index, Source: cfg.Mode, Message: m[2]})
+		}
+	}
+	if len(findings) == 0 {
+		findings = append(findings, Finding{Line: b.Line, Block: b.Index, Source: cfg.Mode, Message: strings.TrimSpace(out.String())})
+	}
+	return findings
+}
@@ -0,0 +1,113 @@
+package codecheck
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+)
+
+const codeDoc = "# 示例\n" + // 1
+	"\n" + // 2
+	"```go\n" + // 3
+	"package main\n" + // 4
+	"\n" + // 5
+	"func main() {\n" + // 6
+	"\tfmt.Println(\"缺少 import\")\n" + // 7
+	"}\n" + // 8
+	"```\n" + // 9
+	"\n" + // 10
+	"```go\n" + // 11
+	"func add(a, b int) int {\n" + // 12
+	"\treturn a + \"b\"\n" + // 13
+	"}\n" + // 14
+	"```\n" + // 15
+	"\n" + // 16
+	"```golang\n" + // 17
+	"import \"fmt\"\n" + // 18
+	"\n" + // 19
+	"unused := 1\n" + // 20
+	"fmt.Println(\"片段中未使用的变量不算错误\")\n" + // 21
+	"```\n" + // 22
+	"\n" + // 23
+	"```go\n" + // 24
+	"func broken( {\n" + // 25
+	"```\n" + // 26
+	"\n" + // 27
+	"```go\n" + // 28
+	"import \"github.com/segmentio/kafka-go\"\n" + // 29
+	"\n" + // 30
+	"var w = kafka.Writer{}\n" + // 31
+	"```\n" + // 32
+	"\n" + // 33
+	"````markdown\n" + // 34
+	"```go\n" + // 35
+	"不是 Go 代码\n" + // 36
+	"```\n" + // 37
+	"````\n" // 38
+
+// TestExtract 测试只提取 go 代码块，并记录代码第一行的行号
+func TestExtract(t *testing.T) {
+	blocks := Extract(codeDoc)
+	if len(blocks) != 5 {
+		t.Fatalf("应该提取 5 个代码块，实际 %d 个", len(blocks))
+	}
+	for i, line := range []int{4, 12, 18, 25, 29} {
+		if blocks[i].Line != line || blocks[i].Index != i+1 {
+			t.Errorf("第 %d 个代码块的位置不对: %+v", i+1, blocks[i])
+		}
+	}
+}
+
+// TestCheck 测试补全代码片段后的语法和类型检查，以及错误行号对应到文档
+func TestCheck(t *testing.T) {
+	r := Check(context.Background(), codeDoc, config.CodeCheckConfig{Mode: ModeTypes})
+	if r.Blocks != 5 {
+		t.Errorf("代码块数量不对: %d", r.Blocks)
+	}
+	got := map[int]Finding{}
+	for _, f := range r.Findings {
+		got[f.Block] = f
+	}
+	if f := got[1]; f.Line != 7 || f.Source != "types" || !strings.Contains(f.Message, "undefined: fmt") {
+		t.Errorf("缺少 import 的错误不对: %+v", f)
+	}
+	if f := got[2]; f.Line != 13 || f.Source != "types" {
+		t.Errorf("类型不匹配的错误不对: %+v", f)
+	}
+	if _, ok := got[3]; ok {
+		t.Errorf("语句片段不应该有错误: %+v", got[3])
+	}
+	if f := got[4]; f.Line != 25 || f.Source != "parser" {
+		t.Errorf("语法错误不对: %+v", f)
+	}
+	if len(r.Notes) != 1 || !strings.Contains(r.Notes[0], "第 5 个 Go 代码块引用了第三方包 github.com/segmentio/kafka-go") {
+		t.Errorf("第三方包应该只检查语法: %v", r.Notes)
+	}
+	if !strings.Contains(r.Format(), "- 第 7 行（第 1 个 Go 代码块）[types] undefined: fmt") {
+		t.Errorf("格式化结果不对:\n%s", r.Format())
+	}
+
+	if r := Check(context.Background(), codeDoc, config.CodeCheckConfig{Mode: ModeOff}); r.Blocks != 0 || len(r.Findings) != 0 {
+		t.Errorf("off 模式不应该检查: %+v", r)
+	}
+}
+
+// TestCheckVet 测试类型检查通过后运行 go vet
+func TestCheckVet(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("没有 go 命令")
+	}
+	doc := "```go\npackage main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Printf(\"%d\\n\", \"不是数字\")\n}\n```\n"
+	r := Check(context.Background(), doc, config.CodeCheckConfig{Mode: ModeVet})
+	if len(r.Findings) != 1 || r.Findings[0].Source != "vet" || r.Findings[0].Line != 7 || !strings.Contains(r.Findings[0].Message, "Printf") {
+		t.Errorf("go vet 的结果不对: %+v %v", r.Findings, r.Notes)
+	}
+
+	r = Check(context.Background(), "```go\nfunc add(a, b int) int { return a + b }\n```\n", config.CodeCheckConfig{Mode: ModeBuild})
+	if len(r.Findings) != 0 {
+		t.Errorf("没有 main 函数的代码应该当作普通包编译: %+v %v", r.Findings, r.Notes)
+	}
+}
@@ -0,0 +1,925 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	myagent "eino_test/agent"
+	"eino_test/batch"
+	"eino_test/common/utils"
+	"eino_test/components"
+	"eino_test/config"
+	"eino_test/feishu"
+	"eino_test/history"
+	"eino_test/mcpserver"
+	"eino_test/mermaid"
+	"eino_test/persona"
+	"eino_test/server"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+)
+
+// errNotApproved 改写稿达到最大迭代次数仍未通过评审，已保存得分最高的版本，命令以退出码 3 结束
+var errNotApproved = errors.New("改写稿未通过评审")
+
+// configFlags 所有命令共用的配置文件参数
+type configFlags struct {
+	path    string
+	profile string
+}
+
+func (f *configFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.path, "config", "", "配置文件路径，默认读取环境变量 EINO_CONFIG 或 "+config.DefaultPath)
+	flags.StringVar(&f.profile, "profile", "", "启用配置文件中的 profile，默认读取环境变量 EINO_PROFILE")
+}
+
+// load 加载并校验配置，不要求配置调用模型所需的 API key
+func (f *configFlags) load() (*config.Config, error) {
+	return config.Load(config.LoadOptions{Path: f.path, Profile: f.profile})
+}
+
+// loadForModel 加载并校验配置，同时检查调用模型所需的配置，需要调用模型的命令使用
+func (f *configFlags) loadForModel() (*config.Config, error) {
+	cfg, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.RequireModel(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// agentFlags 改写和评审命令共用的参数，未指定的参数使用配置文件中的值
+type agentFlags struct {
+	configFlags
+	outputDir     string
+	persona       string
+	model         string
+	maxIterations int
+	sections      string
+	plan          string
+}
+
+func (f *agentFlags) register(flags *flag.FlagSet) {
+	f.configFlags.register(flags)
+	flags.StringVar(&f.outputDir, "o", "", "改写后文档的输出目录，默认使用配置中的 rewrite.output_dir")
+	flags.StringVar(&f.persona, "persona", "", "读者画像名称或文件路径，默认使用配置中的 rewrite.persona")
+	flags.StringVar(&f.model, "model", "", "SummaryAgent 和 ReviewerAgent 使用的模型，默认使用配置中的 agents.*.model")
+	flags.IntVar(&f.maxIterations, "max-iter", 0, "改写-评审循环的最大迭代次数，默认使用配置中的 rewrite.max_iterations")
+	flags.StringVar(&f.sections, "sections", "", "改写方式（whole|sections|auto），默认使用配置中的 rewrite.sections.mode")
+	flags.StringVar(&f.plan, "plan", "", "教学大纲（off|auto|edit），edit 在改写前暂停以便修改大纲，默认使用配置中的 rewrite.plan.mode")
+}
+
+// toConfig 用命令行参数覆盖应用配置，转换为 Agent 配置
+func (f *agentFlags) toConfig(app *config.Config) (*myagent.Config, error) {
+	if f.persona != "" {
+		app.Rewrite.Persona = f.persona
+	}
+	cfg, err := myagent.NewConfig(app)
+	if err != nil {
+		return nil, err
+	}
+	if f.outputDir != "" {
+		cfg.OutputDir = f.outputDir
+	}
+	if f.model != "" {
+		cfg.Summary.Model = f.model
+		cfg.Reviewer.Model = f.model
+	}
+	if f.maxIterations > 0 {
+		cfg.MaxIterations = f.maxIterations
+	}
+	if f.sections != "" {
+		if !slices.Contains(config.SectionModes, f.sections) {
+			return nil, fmt.Errorf("-sections 必须是 %s 之一", strings.Join(config.SectionModes, ", "))
+		}
+		cfg.Sections.Mode = f.sections
+	}
+	if f.plan != "" {
+		if !slices.Contains(config.PlanModes, f.plan) {
+			return nil, fmt.Errorf("-plan 必须是 %s 之一", strings.Join(config.PlanModes, ", "))
+		}
+		cfg.Plan.Mode = f.plan
+	}
+	return cfg, nil
+}
+
+// parseArgs 解析命令参数，允许参数出现在位置参数之后（例如 rewrite text.md -o out）
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// runRewrite 处理 rewrite <file> 命令
+func runRewrite(args []string) error {
+	flags := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	var af agentFlags
+	af.register(flags)
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("用法: rewrite [参数] <file>")
+	}
+	documentPath := positional[0]
+
+	// 检查文件是否存在
+	if _, err := os.Stat(documentPath); err != nil {
+		return fmt.Errorf("文档文件不存在: %s", documentPath)
+	}
+
+	app, err := af.loadForModel()
+	if err != nil {
+		return err
+	}
+	cfg, err := af.toConfig(app)
+	if err != nil {
+		return err
+	}
+	cfg.EditPlan = confirmPlan
+
+	// 创建回调处理器来打印 LLM 和 Agent 的输出
+	callbacks.InitCallbackHandlers([]callbacks.Handler{utils.NewOutputCallbackHandler()})
+
+	log.Println("========== 开始执行文档改写任务 ==========")
+	log.Println("文档路径:", documentPath)
+	log.Println()
+
+	// Ctrl-C 时取消改写，修订历史中记录中断，之后可以用 resume 从最后完成的一步继续
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := myagent.RunRewrite(ctx, cfg, documentPath, utils.Event)
+	if err != nil {
+		return err
+	}
+	return reportRewrite(result)
+}
+
+// runResume 处理 resume <run-id> 命令：从 checkpoint 继续一次中断的改写
+func runResume(args []string) error {
+	flags := flag.NewFlagSet("resume", flag.ExitOnError)
+	var af agentFlags
+	af.register(flags)
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("用法: resume [参数] <run-id>")
+	}
+	id := positional[0]
+
+	app, err := af.loadForModel()
+	if err != nil {
+		return err
+	}
+	if app.Rewrite.HistoryDir == "" {
+		return errors.New("没有配置修订历史目录（rewrite.history_dir），无法恢复")
+	}
+	info, _, err := history.Load(app.Rewrite.HistoryDir, id)
+	if err != nil {
+		return err
+	}
+	// 默认沿用中断前的读者画像，画像不同时评审标准也不同
+	if af.persona == "" && info.Persona != "" {
+		af.persona = info.Persona
+	}
+	cfg, err := af.toConfig(app)
+	if err != nil {
+		return err
+	}
+	cfg.EditPlan = confirmPlan
+
+	callbacks.InitCallbackHandlers([]callbacks.Handler{utils.NewOutputCallbackHandler()})
+
+	log.Println("========== 继续执行文档改写任务 ==========")
+	log.Println("修订历史:", info.ID)
+	log.Println("文档路径:", info.Source)
+	log.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := myagent.ResumeRewrite(ctx, cfg, info.ID, utils.Event)
+	if err != nil {
+		return err
+	}
+	return reportRewrite(result)
+}
+
+// confirmPlan 暂停改写，等用户修改教学大纲文件后按回车继续，Ctrl-C 取消改写
+func confirmPlan(ctx context.Context, path string) error {
+	fmt.Printf("\n%s教学大纲已保存到 %s，可以直接修改这个文件，保存后按回车开始改写（Ctrl-C 取消）%s\n", utils.Yellow, path, utils.Reset)
+	done := make(chan error, 1)
+	go func() {
+		_, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		done <- err
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("等待确认教学大纲失败: %w", err)
+		}
+		return nil
+	}
+}
+
+// reportRewrite 打印改写结果，未通过评审时返回 errNotApproved
+func reportRewrite(result *myagent.RewriteResult) error {
+	log.Println()
+	log.Printf("========== 文档改写任务完成（迭代 %d 次，评审通过: %v）==========", result.Iterations, result.Approved)
+	if result.Sections > 0 {
+		log.Printf("按 %d 个章节并行改写，迭代次数为各章节中最多的轮数", result.Sections)
+	}
+	for _, r := range result.Reviews {
+		log.Printf("第 %d 轮评审: 平均分 %.1f，未达标: %s", r.Iteration, r.Average, strings.Join(r.Failed, ", "))
+	}
+	if result.OutputPath != "" {
+		log.Println("输出文件:", result.OutputPath)
+	}
+	log.Println("提示词版本:", strings.Join(result.Prompts, ", "))
+	if result.RunID != "" {
+		log.Printf("修订历史: %s（查看: history list %s）", result.RunID, result.RunID)
+	}
+	if result.Approved {
+		return nil
+	}
+	if result.Fallback && result.Best != nil {
+		log.Printf("未通过评审，已保存得分最高的改写稿（第 %d 轮，加权平均分 %.1f），文档开头有未通过标记，文末附有未解决的评审问题",
+			result.Best.Iteration, result.Best.Average)
+	}
+	return errNotApproved
+}
+
+// runBatch 处理 batch <dir> 命令：批量改写目录下的所有 Markdown 文档
+func runBatch(args []string) error {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	var af agentFlags
+	af.register(flags)
+	workers := flags.Int("workers", 2, "同时改写的文档数")
+	manifestPath := flags.String("manifest", "", "清单文件路径，默认为 <输出目录>/batch_manifest.json")
+	force := flags.Bool("force", false, "忽略清单，重新改写所有文档")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("用法: batch [参数] <dir>")
+	}
+
+	app, err := af.loadForModel()
+	if err != nil {
+		return err
+	}
+	cfg, err := af.toConfig(app)
+	if err != nil {
+		return err
+	}
+	if *manifestPath == "" {
+		*manifestPath = filepath.Join(cfg.OutputDir, "batch_manifest.json")
+	}
+
+	manifest, err := batch.LoadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C 时取消正在执行的改写，已完成的结果已经写入清单，重新执行即可续跑
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 多个文档并发改写时不打印完整的事件流，只输出每个文档的进度
+	summary, err := batch.Run(ctx, &batch.Options{
+		Dir:       positional[0],
+		OutputDir: cfg.OutputDir,
+		Workers:   *workers,
+		Force:     *force,
+	}, manifest, func(ctx context.Context, source, outputDir string) (*batch.Result, error) {
+		docCfg := *cfg
+		docCfg.OutputDir = outputDir
+		result, err := myagent.RunRewrite(ctx, &docCfg, source, nil)
+		if result == nil {
+			return nil, err
+		}
+		if err == nil && result.OutputPath == "" {
+			err = errors.New("改写流程结束但没有保存文档")
+		}
+		return &batch.Result{Output: result.OutputPath, Iterations: result.Iterations, NotApproved: !result.Approved}, err
+	})
+	if summary != nil {
+		fmt.Printf("✓ 批量改写结束: 共 %d 个，跳过 %d 个，成功 %d 个（其中未通过评审 %d 个），失败 %d 个\n",
+			summary.Total, summary.Skipped, summary.Succeeded, summary.Unapproved, summary.Failed)
+		fmt.Printf("  清单文件: %s\n", *manifestPath)
+	}
+	if err == nil && summary != nil && summary.Unapproved > 0 {
+		return errNotApproved
+	}
+	return err
+}
+
+// runServe 处理 serve 命令：启动 HTTP 任务服务，通过 SSE 推送 Agent 事件
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	var af agentFlags
+	af.register(flags)
+	addr := flags.String("addr", ":8080", "监听地址")
+	workDir := flags.String("work-dir", "./jobs", "任务工作目录，保存上传的原文和改写结果")
+	concurrency := flags.Int("workers", 2, "同时运行的任务数")
+	if _, err := parseArgs(flags, args); err != nil {
+		return err
+	}
+
+	app, err := af.loadForModel()
+	if err != nil {
+		return err
+	}
+	cfg, err := af.toConfig(app)
+	if err != nil {
+		return err
+	}
+
+	srv := server.New(server.Options{
+		WorkDir:       *workDir,
+		MaxConcurrent: *concurrency,
+		Config:        cfg,
+	})
+	log.Printf("改写任务服务已启动: http://%s", *addr)
+	return http.ListenAndServe(*addr, srv.Handler())
+}
+
+// runMCP 处理 mcp 命令：通过标准输入输出提供 MCP 服务。
+// 标准输出是协议通道，这里不能注册打印到标准输出的回调，日志统一写到标准错误
+func runMCP(args []string) error {
+	flags := flag.NewFlagSet("mcp", flag.ExitOnError)
+	var af agentFlags
+	af.register(flags)
+	if _, err := parseArgs(flags, args); err != nil {
+		return err
+	}
+
+	app, err := af.loadForModel()
+	if err != nil {
+		return err
+	}
+	cfg, err := af.toConfig(app)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv, err := mcpserver.NewDefaultServer(ctx, app, cfg)
+	if err != nil {
+		return err
+	}
+	log.SetOutput(os.Stderr)
+	log.Println("MCP 服务已启动（stdio）")
+	return srv.Serve(ctx, os.Stdin, os.Stdout)
+}
+
+// runReview 处理 review <file> 命令
+func runReview(args []string) error {
+	flags := flag.NewFlagSet("review", flag.ExitOnError)
+	var af agentFlags
+	af.register(flags)
+	source := flags.String("source", "", "改写前的源文档，指定时对照它检查改写稿是否遗漏了内容")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("用法: review [参数] <file>")
+	}
+
+	content, err := os.ReadFile(positional[0])
+	if err != nil {
+		return fmt.Errorf("读取文档失败: %w", err)
+	}
+
+	app, err := af.loadForModel()
+	if err != nil {
+		return err
+	}
+	cfg, err := af.toConfig(app)
+	if err != nil {
+		return err
+	}
+	cfg.Source = *source
+
+	callbacks.InitCallbackHandlers([]callbacks.Handler{utils.NewOutputCallbackHandler()})
+
+	return myagent.RunReview(context.Background(), cfg, string(content), utils.Event)
+}
+
+// runIndex 处理 index <dir> 命令：按标题切分目录下的 Markdown 文档并写入 Milvus
+func runIndex(args []string) error {
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	var cf configFlags
+	cf.register(flags)
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("用法: index <dir>")
+	}
+	dir := positional[0]
+
+	cfg, err := cf.loadForModel()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var docs []*schema.Document
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		docs = append(docs, &schema.Document{
+			ID:       rel,
+			Content:  string(content),
+			MetaData: map[string]any{"source": rel},
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("遍历目录失败: %w", err)
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("目录 %s 下没有 Markdown 文档", dir)
+	}
+
+	splitter := components.NewTrans(ctx)
+	var chunks []*schema.Document
+	for _, doc := range docs {
+		parts, err := splitter.Transform(ctx, []*schema.Document{doc})
+		if err != nil {
+			return fmt.Errorf("切分文档 %s 失败: %w", doc.ID, err)
+		}
+		// 切分器生成的 ID 只在单个文档内唯一，加上来源路径作为前缀避免主键冲突
+		for _, part := range parts {
+			part.ID = fmt.Sprintf("%s#%s", doc.ID, part.ID)
+		}
+		chunks = append(chunks, parts...)
+	}
+
+	components.InitClient(cfg.Milvus.Address)
+	embedder := components.NewQwenEmbedder(ctx, cfg.Embedding)
+	indexer := components.NewQwenIndexer(ctx, embedder, cfg.Milvus.Collection, cfg.Embedding.Dimensions)
+	ids, err := indexer.Store(ctx, chunks)
+	if err != nil {
+		return fmt.Errorf("写入 Milvus 失败: %w", err)
+	}
+
+	fmt.Printf("✓ 已索引 %d 个文档，共 %d 个片段\n", len(docs), len(ids))
+	return nil
+}
+
+// runSearch 处理 search <query> 命令
+func runSearch(args []string) error {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	var cf configFlags
+	cf.register(flags)
+	topK := flags.Int("k", 5, "返回的结果数量")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return errors.New("用法: search [参数] <query>")
+	}
+	query := strings.Join(positional, " ")
+
+	cfg, err := cf.loadForModel()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	components.InitClient(cfg.Milvus.Address)
+	r := components.NewRetriever(ctx, components.NewQwenEmbedder(ctx, cfg.Embedding), cfg.Milvus.Collection)
+	docs, err := r.Retrieve(ctx, query, retriever.WithTopK(*topK))
+	if err != nil {
+		return fmt.Errorf("检索失败: %w", err)
+	}
+
+	for i, doc := range docs {
+		fmt.Printf("%s[%d] %s (score: %.4f)%s\n", utils.Cyan, i+1, doc.ID, doc.Score(), utils.Reset)
+		fmt.Println(doc.Content)
+		fmt.Println()
+	}
+	return nil
+}
+
+// runConfig 处理 config show 命令：打印合并默认值、配置文件、profile 和环境变量后的最终配置
+func runConfig(args []string) error {
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	var cf configFlags
+	cf.register(flags)
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || positional[0] != "show" {
+		return errors.New("用法: config [参数] show")
+	}
+
+	cfg, err := cf.load()
+	if err != nil {
+		return err
+	}
+	cfg.PrintConfig()
+	return nil
+}
+
+// runPersonas 处理 personas [name] 命令：列出可用的读者画像，指定名称时打印注入到指令中的读者背景
+func runPersonas(args []string) error {
+	flags := flag.NewFlagSet("personas", flag.ExitOnError)
+	var cf configFlags
+	cf.register(flags)
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("用法: personas [参数] [名称或文件]")
+	}
+
+	cfg, err := cf.load()
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		for _, name := range persona.List(cfg.Rewrite.PersonasDir) {
+			p, err := persona.Load(name, cfg.Rewrite.PersonasDir)
+			if err != nil {
+				fmt.Printf("  %-16s %s无法加载: %v%s\n", name, utils.Red, err, utils.Reset)
+				continue
+			}
+			mark := " "
+			if name == cfg.Rewrite.Persona {
+				mark = "*"
+			}
+			fmt.Printf("%s %-16s %s\n", mark, name, p.Audience)
+		}
+		return nil
+	}
+
+	p, err := persona.Load(positional[0], cfg.Rewrite.PersonasDir)
+	if err != nil {
+		return err
+	}
+	fmt.Println(p.Background())
+	return nil
+}
+
+// runRender 处理 render <file> 命令：用配置的渲染器把 .mmd 文件渲染为图片
+func runRender(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	var cf configFlags
+	cf.register(flags)
+	renderer := flags.String("renderer", "", "使用的渲染器：ink、kroki、mmdc、go，默认使用配置中的 mermaid.renderer")
+	format := flags.String("format", "svg", "输出格式：svg 或 png")
+	output := flags.String("o", "", "输出文件，默认与输入文件同名；在线渲染器只打印图片链接")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("用法: render [参数] <file.mmd>")
+	}
+
+	cfg, err := cf.load()
+	if err != nil {
+		return err
+	}
+	if *renderer != "" {
+		cfg.Mermaid.Renderer, cfg.Mermaid.Fallback = *renderer, nil
+	}
+	r, err := mermaid.New(cfg.Mermaid)
+	if err != nil {
+		return err
+	}
+
+	code, err := os.ReadFile(positional[0])
+	if err != nil {
+		return err
+	}
+	result, err := r.Render(context.Background(), string(code), *format)
+	if err != nil {
+		return fmt.Errorf("渲染失败: %w", err)
+	}
+	if len(result.Data) == 0 {
+		fmt.Println(result.URL)
+		return nil
+	}
+
+	target := *output
+	if target == "" {
+		target = strings.TrimSuffix(positional[0], filepath.Ext(positional[0])) + "." + result.Format
+	}
+	if err := os.WriteFile(target, result.Data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("✓ 已使用 %s 渲染到 %s\n", result.Renderer, target)
+	return nil
+}
+
+// runFeishu 处理 feishu login / feishu status 命令
+func runFeishu(args []string) error {
+	flags := flag.NewFlagSet("feishu", flag.ExitOnError)
+	var cf configFlags
+	cf.register(flags)
+	timeout := flags.Duration("timeout", 5*time.Minute, "等待浏览器完成授权的时间")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || (positional[0] != "login" && positional[0] != "status") {
+		return errors.New("用法: feishu [参数] login|status")
+	}
+
+	cfg, err := cf.load()
+	if err != nil {
+		return err
+	}
+	if positional[0] == "status" {
+		token, err := (&feishu.FileTokenStore{Path: cfg.Feishu.TokenFile}).Load()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("鉴权模式: %s\n", cfg.Feishu.AuthMode)
+		fmt.Printf("token 文件: %s\n", cfg.Feishu.TokenFile)
+		fmt.Printf("access token 过期时间: %s\n", token.ExpiresAt.Local().Format(time.DateTime))
+		if !token.RefreshExpiresAt.IsZero() {
+			fmt.Printf("refresh token 过期时间: %s\n", token.RefreshExpiresAt.Local().Format(time.DateTime))
+		}
+		return nil
+	}
+
+	cfg.Feishu.AuthMode = feishu.AuthModeUser
+	client, err := feishu.NewClient(cfg.Feishu)
+	if err != nil {
+		return err
+	}
+	return feishuLogin(client.OAuth(), cfg.Feishu.RedirectURI, *timeout)
+}
+
+// feishuLogin 在回调地址上启动临时 HTTP 服务，等待用户在浏览器中完成授权后用授权码换取 token
+func feishuLogin(oauth *feishu.OAuth, redirectURI string, timeout time.Duration) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return fmt.Errorf("回调地址 %q 不合法: %w", redirectURI, err)
+	}
+	state, err := randomState()
+	if err != nil {
+		return err
+	}
+
+	codes := make(chan string, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(u.Path, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != state || q.Get("code") == "" {
+			http.Error(w, "授权失败：state 不匹配或缺少 code", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "授权成功，可以关闭此页面")
+		select {
+		case codes <- q.Get("code"):
+		default:
+		}
+	})
+
+	listener, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return fmt.Errorf("监听回调地址失败: %w", err)
+	}
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(listener) }()
+	defer srv.Close()
+
+	fmt.Println("请在浏览器中打开以下地址完成飞书授权:")
+	fmt.Println(oauth.AuthorizeURL(state))
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	select {
+	case code := <-codes:
+		token, err := oauth.Exchange(ctx, code)
+		if err != nil {
+			return fmt.Errorf("换取飞书用户 token 失败: %w", err)
+		}
+		fmt.Printf("✓ 授权成功，access token 有效期至 %s，到期前会自动刷新\n", token.ExpiresAt.Local().Format(time.DateTime))
+		return nil
+	case <-ctx.Done():
+		return errors.New("等待授权超时")
+	}
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// runHistory 处理 history 命令：列出修订历史、查看某一次修订的评审、比较两次修订的改写稿
+func runHistory(args []string) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	var cf configFlags
+	cf.register(flags)
+	dir := flags.String("dir", "", "修订历史目录，默认使用配置中的 rewrite.history_dir")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	usage := errors.New("用法: history list [run-id] | history show <run-id> <序号> | history diff <run-id> [序号A] <序号B>")
+	if len(positional) == 0 {
+		return usage
+	}
+	if *dir == "" {
+		cfg, err := cf.load()
+		if err != nil {
+			return err
+		}
+		*dir = cfg.Rewrite.HistoryDir
+	}
+
+	switch sub, rest := positional[0], positional[1:]; {
+	case sub == "list" && len(rest) == 0:
+		return listRuns(*dir)
+	case sub == "list" && len(rest) == 1:
+		return listRevisions(*dir, rest[0])
+	case sub == "show" && len(rest) == 2:
+		return showRevision(*dir, rest[0], rest[1])
+	case sub == "diff" && (len(rest) == 2 || len(rest) == 3):
+		return diffRevisions(*dir, rest[0], rest[1:])
+	default:
+		return usage
+	}
+}
+
+// listRuns 列出所有记录了修订历史的改写
+func listRuns(dir string) error {
+	runs, err := history.List(dir)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Printf("%s 中没有修订历史\n", dir)
+		return nil
+	}
+	for _, r := range runs {
+		status := "进行中"
+		switch {
+		case r.Error != "":
+			status = "失败"
+		case r.FinishedAt.IsZero():
+		case r.Approved:
+			status = "通过"
+		default:
+			status = "未通过"
+		}
+		fmt.Printf("%-22s %s  %2d 次修订  %-6s %s\n", r.ID, r.StartedAt.Local().Format(time.DateTime), r.Revisions, status, r.Source)
+	}
+	return nil
+}
+
+// listRevisions 列出一次改写的所有修订及其评分和 token 用量
+func listRevisions(dir, id string) error {
+	info, revs, err := history.Load(dir, id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s  %s（画像 %s，开始于 %s）\n", info.ID, info.Source, info.Persona, info.StartedAt.Local().Format(time.DateTime))
+	if info.Output != "" {
+		fmt.Println("输出文件:", info.Output)
+	}
+	for _, rev := range revs {
+		where := fmt.Sprintf("第 %d 轮", rev.Iteration)
+		if rev.Section > 0 && rev.Section <= len(info.Sections) {
+			where = fmt.Sprintf("第 %d 节《%s》%s", rev.Section, info.Sections[rev.Section-1], where)
+		}
+		verdict := "未评审"
+		if r := rev.Review; r != nil {
+			verdict = fmt.Sprintf("平均分 %.1f", r.Average)
+			if r.Passed {
+				verdict += " 通过"
+			} else if len(r.Failed) > 0 {
+				verdict += " 未达标: " + strings.Join(r.Failed, ", ")
+			}
+		}
+		fmt.Printf("  #%-3d %s  %s  %d 字  %d tokens  %s\n",
+			rev.Seq, rev.DraftedAt.Local().Format(time.TimeOnly), where, rev.Chars, rev.Tokens(), verdict)
+	}
+	return nil
+}
+
+// showRevision 打印一次修订的评审结果、自动检查报告和 ReviewerAgent 的回复
+func showRevision(dir, id, seqArg string) error {
+	seq, err := strconv.Atoi(strings.TrimPrefix(seqArg, "#"))
+	if err != nil {
+		return fmt.Errorf("修订序号不合法: %s", seqArg)
+	}
+	_, revs, err := history.Load(dir, id)
+	if err != nil {
+		return err
+	}
+	for _, rev := range revs {
+		if rev.Seq != seq {
+			continue
+		}
+		fmt.Printf("修订 #%d（第 %d 轮，%d 字）\n", rev.Seq, rev.Iteration, rev.Chars)
+		for agentName, u := range rev.Usage {
+			fmt.Printf("  %s: 输入 %d / 输出 %d tokens\n", agentName, u.Prompt, u.Completion)
+		}
+		if rev.Feedback == "" {
+			fmt.Println("\n这一轮没有评审记录")
+			return nil
+		}
+		fmt.Println()
+		fmt.Println(rev.Feedback)
+		return nil
+	}
+	return fmt.Errorf("第 %d 次修订不存在", seq)
+}
+
+// diffRevisions 打印两次修订的改写稿之间的 unified diff，只给出一个序号时与前一次修订比较
+func diffRevisions(dir, id string, seqArgs []string) error {
+	seqs := make([]int, len(seqArgs))
+	for i, arg := range seqArgs {
+		n, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+		if err != nil {
+			return fmt.Errorf("修订序号不合法: %s", arg)
+		}
+		seqs[i] = n
+	}
+	if len(seqs) == 1 {
+		seqs = []int{seqs[0] - 1, seqs[0]}
+	}
+
+	drafts := make([]string, 2)
+	for i, seq := range seqs {
+		draft, err := history.ReadDraft(dir, id, seq)
+		if err != nil {
+			return err
+		}
+		drafts[i] = draft
+	}
+	diff := history.Diff(fmt.Sprintf("#%d", seqs[0]), drafts[0], fmt.Sprintf("#%d", seqs[1]), drafts[1])
+	if diff == "" {
+		fmt.Printf("修订 #%d 和 #%d 的改写稿相同\n", seqs[0], seqs[1])
+		return nil
+	}
+	fmt.Print(diff)
+	return nil
+}
@@ -9,14 +9,21 @@ import (
 
 var MilvusCli cli.Client
 
-func InitClient() {
+func InitClient(address string) {
 	//初始化客户端
-	ctx := context.Background()
+	if err := TryInitClient(context.Background(), address); err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+}
+
+// TryInitClient 初始化客户端，失败时返回错误而不是退出进程，供常驻服务使用
+func TryInitClient(ctx context.Context, address string) error {
 	client, err := cli.NewClient(ctx, cli.Config{
-		Address: "localhost:19530",
+		Address: address,
 	})
 	if err != nil {
-		log.Fatalf("Failed to create client: %v", err)
+		return err
 	}
 	MilvusCli = client
+	return nil
 }
@@ -5,18 +5,20 @@ import (
 	"os"
 	"time"
 
+	"eino_test/config"
+
 	openaiEmbedder "github.com/cloudwego/eino-ext/components/embedding/openai"
 )
 
-func NewQwenEmbedder(ctx context.Context) *openaiEmbedder.Embedder {
+func NewQwenEmbedder(ctx context.Context, cfg config.EmbeddingConfig) *openaiEmbedder.Embedder {
 	timeout := 30 * time.Second
-	var dimValue = 512
+	var dimValue = cfg.Dimensions
 	var dimensions = &dimValue
 	embedder, err := openaiEmbedder.NewEmbedder(ctx, &openaiEmbedder.EmbeddingConfig{
 		Timeout:    timeout,
 		APIKey:     os.Getenv("DASHSCOPE_API_KEY"),
 		BaseURL:    os.Getenv("DASHSCOPE_BASE_URL"),
-		Model:      "text-embedding-v3",
+		Model:      cfg.Model,
 		Dimensions: dimensions,
 	})
 	if err != nil {
@@ -3,14 +3,16 @@ package components
 import (
 	"context"
 	"log"
+	"strconv"
 
 	openaiEmbedder "github.com/cloudwego/eino-ext/components/embedding/openai"
 	"github.com/cloudwego/eino-ext/components/indexer/milvus"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 )
 
-func newQwenIndexer(ctx context.Context, embedder *openaiEmbedder.Embedder) *milvus.Indexer {
-	var collection = "test3"
+// NewQwenIndexer 创建写入 Milvus 的索引器，调用前需要先执行 InitClient。
+// 向量以二进制向量存储，每个 float32 占 32 位，因此字段维度为 embedding 维度的 32 倍
+func NewQwenIndexer(ctx context.Context, embedder *openaiEmbedder.Embedder, collection string, dimensions int) *milvus.Indexer {
 	var fields = []*entity.Field{
 		{
 			Name:     "id",
@@ -24,7 +26,7 @@ func newQwenIndexer(ctx context.Context, embedder *openaiEmbedder.Embedder) *mil
 			Name:     "vector", // 确保字段名匹配 - BinaryVector
 			DataType: entity.FieldTypeBinaryVector,
 			TypeParams: map[string]string{
-				"dim": "16384",
+				"dim": strconv.Itoa(dimensions * 32),
 			},
 		},
 		{
@@ -4,14 +4,22 @@ import (
 	"context"
 	"os"
 
+	"eino_test/config"
+
 	"github.com/cloudwego/eino-ext/components/model/openai"
 )
 
 func NewQwenModel(ctx context.Context, modelName string) *openai.ChatModel {
+	return NewChatModel(ctx, config.ModelConfig{Model: modelName})
+}
+
+// NewChatModel 按 Agent 的模型配置创建聊天模型
+func NewChatModel(ctx context.Context, cfg config.ModelConfig) *openai.ChatModel {
 	model, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
-		APIKey:  os.Getenv("DASHSCOPE_API_KEY"),
-		BaseURL: os.Getenv("DASHSCOPE_BASE_URL"),
-		Model:   modelName,
+		APIKey:      os.Getenv("DASHSCOPE_API_KEY"),
+		BaseURL:     os.Getenv("DASHSCOPE_BASE_URL"),
+		Model:       cfg.Model,
+		Temperature: cfg.Temperature,
 	})
 	if err != nil {
 		panic(err)
@@ -7,8 +7,7 @@ import (
 	milvusRetriver "github.com/cloudwego/eino-ext/components/retriever/milvus"
 )
 
-func NewRetriever(ctx context.Context, embedder *openai.Embedder) *milvusRetriver.Retriever {
-	collection := "test3"
+func NewRetriever(ctx context.Context, embedder *openai.Embedder, collection string) *milvusRetriver.Retriever {
 	retriever, err := milvusRetriver.NewRetriever(ctx, &milvusRetriver.RetrieverConfig{
 		Client:      MilvusCli,
 		Collection:  collection,
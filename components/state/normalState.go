@@ -1 +1,253 @@
+// Package state 定义改写-评审循环在 adk session 中共享的状态：教学大纲和教学计划、当前改写稿、
+// 每一轮的评审结果、评审轮次和源文档信息。Agent 和工具都通过这里的函数读写 session，
+// 不直接使用字符串键，保证各处对同一份状态的理解一致
 package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"eino_test/outline"
+	"eino_test/review"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
+)
+
+// session 中各项状态的键
+const (
+	// DraftKey 当前改写稿，SummaryAgent 通过 OutputKey 直接写入
+	DraftKey = "document_content"
+	// PlanKey 教学计划的文本，整篇改写时是结构化大纲渲染的 Markdown，按章节改写时是全局教学计划
+	PlanKey = "teaching_plan"
+	// OutlineKey PlannerAgent 生成的结构化教学大纲，评审前据此检查改写稿与大纲是否一致
+	OutlineKey = "outline"
+	// ReviewsKey 已完成的各轮评审结果
+	ReviewsKey = "reviews"
+	// IterationKey 当前是第几轮评审，从 1 开始，还没有开始评审时为 0
+	IterationKey = "iteration"
+	// SourceKey 源文档信息
+	SourceKey = "source"
+)
+
+func init() {
+	// checkpoint 用 gob 序列化 session，session 中的自定义类型需要注册
+	schema.RegisterName[*Source]("eino_test_state_source")
+	schema.RegisterName[*outline.Outline]("eino_test_state_outline")
+	schema.RegisterName[[]*review.Result]("eino_test_state_reviews")
+}
+
+// Source 本次改写对照的源文档
+type Source struct {
+	// Path 源文件路径，单独评审没有源文件时为空
+	Path string `json:"path,omitempty"`
+	// Content 原文。按章节改写时是这一节的原文，内容覆盖检查对照它而不是整个源文件
+	Content string `json:"content"`
+	// Section 按章节改写时的章节序号（从 1 开始），整篇改写时为 0
+	Section int `json:"section,omitempty"`
+	// Title 按章节改写时的章节名称
+	Title string `json:"title,omitempty"`
+}
+
+// State 一次改写-评审循环的全部状态，用于在运行前注入 session 和导出 session 中的状态
+type State struct {
+	Source    *Source          `json:"source,omitempty"`
+	Outline   *outline.Outline `json:"outline,omitempty"`
+	Plan      string           `json:"plan,omitempty"`
+	Draft     string           `json:"draft,omitempty"`
+	Reviews   []*review.Result `json:"reviews,omitempty"`
+	Iteration int              `json:"iteration"`
+}
+
+// Validate 检查状态是否自洽：评审轮次不为负，评审结果按轮次递增且不超过当前轮次
+func (s *State) Validate() error {
+	var errs []error
+	if s.Iteration < 0 {
+		errs = append(errs, fmt.Errorf("评审轮次不能为负数: %d", s.Iteration))
+	}
+	last := 0
+	for i, r := range s.Reviews {
+		switch {
+		case r == nil:
+			errs = append(errs, fmt.Errorf("第 %d 个评审结果为空", i+1))
+		case r.Iteration <= last:
+			errs = append(errs, fmt.Errorf("评审结果的轮次没有递增: 第 %d 轮出现在第 %d 轮之后", r.Iteration, last))
+		case r.Iteration > s.Iteration:
+			errs = append(errs, fmt.Errorf("第 %d 轮的评审结果超出了当前轮次 %d", r.Iteration, s.Iteration))
+		default:
+			last = r.Iteration
+		}
+	}
+	if s.Source != nil && s.Source.Section < 0 {
+		errs = append(errs, fmt.Errorf("章节序号不能为负数: %d", s.Source.Section))
+	}
+	return errors.Join(errs...)
+}
+
+// Values 返回写入 session 的键值，零值的状态不写入，配合 adk.WithSessionValues 在运行前注入
+func (s *State) Values() map[string]any {
+	values := map[string]any{}
+	if s.Source != nil {
+		values[SourceKey] = s.Source
+	}
+	if s.Outline != nil {
+		values[OutlineKey] = s.Outline
+	}
+	if s.Plan != "" {
+		values[PlanKey] = s.Plan
+	}
+	if s.Draft != "" {
+		values[DraftKey] = s.Draft
+	}
+	if len(s.Reviews) > 0 {
+		values[ReviewsKey] = s.Reviews
+	}
+	if s.Iteration > 0 {
+		values[IterationKey] = s.Iteration
+	}
+	return values
+}
+
+// FromValues 从 session 的键值还原状态，值的类型不对或状态不自洽时返回错误，其他键忽略
+func FromValues(values map[string]any) (*State, error) {
+	s := &State{}
+	var errs []error
+	check := func(key string, ok bool) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("session 中 %s 的类型不正确: %T", key, values[key]))
+		}
+	}
+	for key, value := range values {
+		if value == nil {
+			continue
+		}
+		var ok bool
+		switch key {
+		case SourceKey:
+			s.Source, ok = value.(*Source)
+		case OutlineKey:
+			s.Outline, ok = value.(*outline.Outline)
+		case PlanKey:
+			s.Plan, ok = value.(string)
+		case DraftKey:
+			s.Draft, ok = value.(string)
+		case ReviewsKey:
+			s.Reviews, ok = value.([]*review.Result)
+		case IterationKey:
+			s.Iteration, ok = value.(int)
+		default:
+			continue
+		}
+		check(key, ok)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load 导出当前 session 中的状态，不在 Agent 中运行时返回零值的状态
+func Load(ctx context.Context) (*State, error) {
+	return FromValues(adk.GetSessionValues(ctx))
+}
+
+// Draft 返回当前的改写稿，还没有改写稿时返回空字符串
+func Draft(ctx context.Context) string {
+	value, _ := adk.GetSessionValue(ctx, DraftKey)
+	draft, _ := value.(string)
+	return draft
+}
+
+// SetDraft 替换当前的改写稿
+func SetDraft(ctx context.Context, draft string) {
+	adk.AddSessionValue(ctx, DraftKey, draft)
+}
+
+// Plan 返回教学计划，还没有生成时返回空字符串
+func Plan(ctx context.Context) string {
+	value, _ := adk.GetSessionValue(ctx, PlanKey)
+	plan, _ := value.(string)
+	return plan
+}
+
+// SetPlan 保存教学计划
+func SetPlan(ctx context.Context, plan string) {
+	adk.AddSessionValue(ctx, PlanKey, plan)
+}
+
+// Outline 返回结构化的教学大纲，没有单独的大纲阶段时返回 nil
+func Outline(ctx context.Context) *outline.Outline {
+	value, _ := adk.GetSessionValue(ctx, OutlineKey)
+	o, _ := value.(*outline.Outline)
+	return o
+}
+
+// SetOutline 保存结构化的教学大纲，同时把渲染的 Markdown 保存为教学计划
+func SetOutline(ctx context.Context, o *outline.Outline) {
+	adk.AddSessionValue(ctx, OutlineKey, o)
+	SetPlan(ctx, o.Markdown())
+}
+
+// SourceOf 返回源文档信息，没有注入时返回 nil
+func SourceOf(ctx context.Context) *Source {
+	value, _ := adk.GetSessionValue(ctx, SourceKey)
+	source, _ := value.(*Source)
+	return source
+}
+
+// Iteration 返回当前是第几轮评审
+func Iteration(ctx context.Context) int {
+	value, _ := adk.GetSessionValue(ctx, IterationKey)
+	n, _ := value.(int)
+	return n
+}
+
+// NextIteration 开始新一轮评审，返回新的轮次。新一轮开始后 CurrentReview 返回 nil，直到这一轮提交评审结果
+func NextIteration(ctx context.Context) int {
+	n := Iteration(ctx) + 1
+	adk.AddSessionValue(ctx, IterationKey, n)
+	return n
+}
+
+// Reviews 返回已完成的各轮评审结果，按轮次排列
+func Reviews(ctx context.Context) []*review.Result {
+	value, _ := adk.GetSessionValue(ctx, ReviewsKey)
+	reviews, _ := value.([]*review.Result)
+	return reviews
+}
+
+// AddReview 记录当前这一轮的评审结果。result 的轮次必须是当前轮次，同一轮重复提交时替换之前的结果
+func AddReview(ctx context.Context, result *review.Result) error {
+	if result == nil {
+		return errors.New("评审结果不能为空")
+	}
+	if n := Iteration(ctx); result.Iteration != n {
+		return fmt.Errorf("评审结果的轮次 %d 与当前轮次 %d 不一致", result.Iteration, n)
+	}
+	reviews := slices.Clone(Reviews(ctx))
+	if len(reviews) > 0 && reviews[len(reviews)-1].Iteration == result.Iteration {
+		reviews[len(reviews)-1] = result
+	} else {
+		reviews = append(reviews, result)
+	}
+	adk.AddSessionValue(ctx, ReviewsKey, reviews)
+	return nil
+}
+
+// CurrentReview 返回当前这一轮的评审结果，这一轮还没有提交时返回 nil
+func CurrentReview(ctx context.Context) *review.Result {
+	reviews := Reviews(ctx)
+	if len(reviews) == 0 {
+		return nil
+	}
+	last := reviews[len(reviews)-1]
+	if last.Iteration != Iteration(ctx) {
+		return nil
+	}
+	return last
+}
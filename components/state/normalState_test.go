@@ -0,0 +1,61 @@
+package state
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"eino_test/outline"
+	"eino_test/review"
+)
+
+// TestValues 测试状态写入 session 键值后能原样还原，并且可以用 gob 序列化
+func TestValues(t *testing.T) {
+	s := &State{
+		Source:    &Source{Path: "docs/kafka.md", Content: "## 分区\n\n原文", Section: 2, Title: "分区"},
+		Outline:   &outline.Outline{Title: "Kafka", Parts: []outline.Part{{Title: "基础", Sections: []outline.Section{{Title: "分区"}}}}},
+		Plan:      "教学计划",
+		Draft:     "改写稿",
+		Reviews:   []*review.Result{{Iteration: 1, Average: 6}, {Iteration: 2, Average: 8, Passed: true}},
+		Iteration: 2,
+	}
+	values := s.Values()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		t.Fatalf("gob 序列化失败: %v", err)
+	}
+	var decoded map[string]any
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("gob 反序列化失败: %v", err)
+	}
+
+	got, err := FromValues(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Source.Title != "分区" || got.Outline.Sections()[0].Title != "分区" || got.Plan != "教学计划" || got.Draft != "改写稿" || got.Iteration != 2 ||
+		len(got.Reviews) != 2 || !got.Reviews[1].Passed {
+		t.Errorf("还原的状态不符合预期: %+v", got)
+	}
+	if len((&State{}).Values()) != 0 {
+		t.Error("零值的状态不应写入任何键")
+	}
+}
+
+// TestFromValuesInvalid 测试类型不对或轮次不自洽的 session 会报错
+func TestFromValuesInvalid(t *testing.T) {
+	tests := map[string]map[string]any{
+		"类型不对":    {IterationKey: "1"},
+		"评审超出轮次":  {IterationKey: 1, ReviewsKey: []*review.Result{{Iteration: 2}}},
+		"评审轮次不递增": {IterationKey: 3, ReviewsKey: []*review.Result{{Iteration: 2}, {Iteration: 2}}},
+	}
+	for name, values := range tests {
+		if _, err := FromValues(values); err == nil {
+			t.Errorf("%s: 应该报错", name)
+		}
+	}
+	if _, err := FromValues(map[string]any{"other": 1, DraftKey: nil}); err != nil {
+		t.Errorf("其他键和空值应忽略: %v", err)
+	}
+}
@@ -2,39 +2,396 @@ package config
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+
+	"eino_test/common/constant"
+
+	"gopkg.in/yaml.v3"
 )
 
+// DefaultPath 未指定配置文件时尝试读取的路径，文件不存在时只使用默认值和环境变量
+const DefaultPath = "config.yaml"
+
+// ModelConfig 单个 Agent 使用的模型参数
+type ModelConfig struct {
+	Model string `yaml:"model"`
+	// Temperature 为空时使用模型服务的默认值
+	Temperature *float32 `yaml:"temperature,omitempty"`
+}
+
+// AgentsConfig 各个 Agent 的模型配置
+type AgentsConfig struct {
+	Supervisor ModelConfig `yaml:"supervisor"`
+	Summary    ModelConfig `yaml:"summary"`
+	Reviewer   ModelConfig `yaml:"reviewer"`
+}
+
+// RewriteConfig 改写流程配置
+type RewriteConfig struct {
+	MaxIterations int    `yaml:"max_iterations"`
+	OutputDir     string `yaml:"output_dir"`
+	// Persona 读者画像的名称或文件路径，名称在 PersonasDir 和内置画像中查找
+	Persona string `yaml:"persona"`
+	// PersonasDir 自定义读者画像目录，目录中的 <名称>.yaml 会覆盖同名的内置画像
+	PersonasDir string `yaml:"personas_dir"`
+	// Language 改写后文档使用的语言
+	Language string `yaml:"language"`
+	// PromptsDir 提示词覆盖目录，目录中的 <模板名>.tmpl 会替换同名的内置模板
+	PromptsDir string `yaml:"prompts_dir"`
+	// Sections 长文档按章节并行改写
+	Sections SectionsConfig `yaml:"sections"`
+	// HistoryDir 修订历史目录，每次改写在其下创建一个 <run-id>/ 子目录，保存每一轮的改写稿和评审，为空时不记录
+	HistoryDir string `yaml:"history_dir"`
+	// Plan 整篇改写前的教学大纲阶段
+	Plan PlanConfig `yaml:"plan"`
+}
+
+// PlanConfig 整篇改写前由 PlannerAgent 生成结构化的教学大纲，SummaryAgent 按大纲改写，
+// 每轮评审前检查改写稿的章节是否与大纲一致。按章节改写时使用 sections 自己的全局教学计划
+type PlanConfig struct {
+	// Mode off（不单独生成大纲，由 SummaryAgent 直接改写）、auto（生成大纲后直接改写）、
+	// edit（生成大纲后暂停，等人修改大纲文件并确认后再改写，只对命令行的 rewrite 生效）
+	Mode string `yaml:"mode"`
+}
+
+// PlanModes 可选的教学大纲模式
+var PlanModes = []string{"off", "auto", "edit"}
+
+// SectionsConfig 按章节并行改写：按标题切分源文档，先生成全局教学计划，
+// 每一节各自运行改写-评审循环，最后拼接章节、生成过渡段并做全局一致性检查
+type SectionsConfig struct {
+	// Mode whole（整篇一次改写）、sections（按章节改写）、auto（源文档超过 AutoChars 字时按章节改写）
+	Mode string `yaml:"mode"`
+	// AutoChars auto 模式下按章节改写的源文档字数阈值（不含空白）
+	AutoChars int `yaml:"auto_chars"`
+	// Level 按这一级及更高级别的标题切分章节，下级标题留在所属章节中
+	Level int `yaml:"level"`
+	// Workers 同时改写的章节数
+	Workers int `yaml:"workers"`
+}
+
+// SectionModes 可选的改写模式
+var SectionModes = []string{"whole", "sections", "auto"}
+
+// ReviewConfig 评审通过的分数阈值，ReviewerAgent 给每个评审标准打 0～10 分
+type ReviewConfig struct {
+	// PassScore 平均分达到该值才算通过
+	PassScore float64 `yaml:"pass_score"`
+	// MinScore 每一项都不能低于该分数
+	MinScore int `yaml:"min_score"`
+	// ScoresFile 以 JSON Lines 格式追加每一轮评分的文件，为空时不记录
+	ScoresFile string `yaml:"scores_file"`
+	// Rubric 评审标准文件，为空时使用内置的 10 项标准。评审清单和改写原则都由它生成
+	Rubric string `yaml:"rubric"`
+}
+
+// LintConfig 评审前对改写稿做的确定性 Markdown 检查
+type LintConfig struct {
+	// Rules 启用的检查规则：emoji（数量过多）、heading（标题跳级）、fence（代码块未闭合）、
+	// sections（章节缺少结构块）、table（表格列数不一致）、mermaid（图表语法错误），为空时不检查
+	Rules []string `yaml:"rules"`
+	// MaxEmoji 全文允许的 emoji 数量
+	MaxEmoji int `yaml:"max_emoji"`
+	// SectionLevel sections 规则检查的标题级别
+	SectionLevel int `yaml:"section_level"`
+	// SectionBlocks sections 规则要求每一节包含的文字
+	SectionBlocks []string `yaml:"section_blocks,omitempty"`
+	// SkipSections 标题包含这些文字的章节不检查结构块，例如教学大纲、参考资料
+	SkipSections []string `yaml:"skip_sections,omitempty"`
+}
+
+// LintRules 可选的 Markdown 检查规则
+var LintRules = []string{"emoji", "heading", "fence", "sections", "table", "mermaid"}
+
+// CodeCheckConfig 评审前对改写稿中 Go 代码示例的编译检查
+type CodeCheckConfig struct {
+	// Mode off（不检查）、types（go/parser + go/types 检查）、vet（类型检查通过后再运行 go vet）、
+	// build（类型检查通过后再运行 go build）。vet 和 build 在离线的临时模块中运行，只支持标准库
+	Mode string `yaml:"mode"`
+	// GoBin go 命令路径，为空时从 PATH 中查找，vet 和 build 模式使用
+	GoBin string `yaml:"go_bin"`
+	// TimeoutSeconds 每个代码块运行 go 命令的超时时间
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+}
+
+// CodeCheckModes 可选的代码检查模式
+var CodeCheckModes = []string{"off", "types", "vet", "build"}
+
+// CoverageConfig 评审前对照源文档检查改写稿是否遗漏了内容
+type CoverageConfig struct {
+	// Checks 启用的检查：headings（源文档的标题被删掉）、terms（关键术语丢失）、
+	// code（代码块丢失）、sections（章节篇幅大幅缩水），为空时不检查
+	Checks []string `yaml:"checks"`
+	// ShrinkRatio 改写后章节长度低于源章节的这个比例时报告缩水
+	ShrinkRatio float64 `yaml:"shrink_ratio"`
+	// MinSectionChars 源章节字数少于该值时不检查缩水，避免短章节的误报
+	MinSectionChars int `yaml:"min_section_chars"`
+	// MinTermCount 普通英文术语在源文档中至少出现的次数，行内代码和加粗文字出现一次就算关键术语
+	MinTermCount int `yaml:"min_term_count"`
+}
+
+// CoverageChecks 可选的内容覆盖检查
+var CoverageChecks = []string{"headings", "terms", "code", "sections"}
+
+// MilvusConfig Milvus 连接配置
+type MilvusConfig struct {
+	Address    string `yaml:"address"`
+	Collection string `yaml:"collection"`
+}
+
+// EmbeddingConfig 向量模型配置
+type EmbeddingConfig struct {
+	Model      string `yaml:"model"`
+	Dimensions int    `yaml:"dimensions"`
+}
+
+// FeishuConfig 飞书应用配置
+type FeishuConfig struct {
+	AppID       string `yaml:"app_id"`
+	AppSecret   string `yaml:"app_secret"`
+	FolderToken string `yaml:"folder_token"`
+	// AuthMode 调用飞书接口使用的身份：tenant 使用应用身份（tenant_access_token），
+	// user 使用 feishu login 授权得到的用户身份（user_access_token）
+	AuthMode string `yaml:"auth_mode"`
+	// BaseURL 飞书开放平台地址，测试时可以指向本地的模拟服务
+	BaseURL string `yaml:"base_url"`
+	// TokenFile user 模式下保存 user_access_token 和 refresh_token 的本地文件
+	TokenFile string `yaml:"token_file"`
+	// RedirectURI OAuth 授权回调地址，需要在飞书应用的安全设置中登记
+	RedirectURI string `yaml:"redirect_uri"`
+	// DocsFile 记录源文件与飞书文档对应关系的文件，重复改写同一源文件时原地更新文档
+	DocsFile string `yaml:"docs_file"`
+}
+
+// MermaidConfig Mermaid 图表渲染配置
+type MermaidConfig struct {
+	// Renderer 使用的渲染器：ink（mermaid.ink）、kroki、mmdc（本地 mermaid-cli）、
+	// go（内置 SVG 渲染，不需要联网，支持流程图和时序图）
+	Renderer string `yaml:"renderer"`
+	// Fallback 主渲染器失败（例如图表类型不支持、mmdc 不存在）时依次尝试的渲染器
+	Fallback []string `yaml:"fallback,omitempty"`
+	InkURL   string   `yaml:"ink_url"`
+	KrokiURL string   `yaml:"kroki_url"`
+	// MmdcPath mermaid-cli 可执行文件路径，为空时从 PATH 中查找 mmdc
+	MmdcPath string `yaml:"mmdc_path"`
+}
+
+// LinkRule 链接改写规则，以 From 开头的链接把这段前缀替换为 To
+type LinkRule struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// PostProcessConfig save_document 写入文件前对 Markdown 的后处理
+type PostProcessConfig struct {
+	// Stages 按顺序执行的处理步骤：links（改写链接）、repair（让模型修复语法错误的图表）、
+	// mermaid（图表转图片）、assets（下载远程图片到本地）、toc（插入目录）
+	Stages []string `yaml:"stages"`
+	// RepairAttempts repair 步骤对每个图表最多请求模型修复的次数
+	RepairAttempts int `yaml:"repair_attempts"`
+	// AssetsDir 图表图片和下载的图片的保存目录，相对于文档所在目录
+	AssetsDir string `yaml:"assets_dir"`
+	// MermaidFormat 图表图片格式，svg 或 png
+	MermaidFormat string `yaml:"mermaid_format"`
+	// TOCDepth 目录包含的最深标题级别
+	TOCDepth int `yaml:"toc_depth"`
+	// LinkRules links 步骤使用的前缀替换规则，没有命中的相对链接按源文件位置重新计算
+	LinkRules []LinkRule `yaml:"link_rules,omitempty"`
+}
+
+// PostProcessStages 可选的后处理步骤
+var PostProcessStages = []string{"links", "repair", "mermaid", "assets", "toc"}
+
+// MermaidRenderers 可选的 Mermaid 渲染器
+var MermaidRenderers = []string{"ink", "kroki", "mmdc", "go"}
+
 // Config 存储应用配置
 type Config struct {
-	DashScopeAPIKey string
-	DashScopeURL    string
+	// Profile 当前生效的 profile，为空表示只使用基础配置
+	Profile string `yaml:"profile,omitempty"`
+
+	DashScopeAPIKey string `yaml:"dashscope_api_key"`
+	DashScopeURL    string `yaml:"dashscope_base_url"`
+
+	Agents    AgentsConfig    `yaml:"agents"`
+	Rewrite   RewriteConfig   `yaml:"rewrite"`
+	Review    ReviewConfig    `yaml:"review"`
+	Lint      LintConfig      `yaml:"lint"`
+	CodeCheck CodeCheckConfig `yaml:"codecheck"`
+	Coverage  CoverageConfig  `yaml:"coverage"`
+	Milvus    MilvusConfig    `yaml:"milvus"`
+	Embedding EmbeddingConfig `yaml:"embedding"`
+	Feishu    FeishuConfig    `yaml:"feishu"`
+	Mermaid   MermaidConfig   `yaml:"mermaid"`
+	// PostProcess 保存文档前的后处理
+	PostProcess PostProcessConfig `yaml:"postprocess"`
+}
+
+// fileConfig 配置文件的结构，profiles 中的每一项都是对基础配置的局部覆盖
+type fileConfig struct {
+	Config   `yaml:",inline"`
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// LoadOptions 加载配置的参数
+type LoadOptions struct {
+	// Path 配置文件路径，为空时依次尝试环境变量 EINO_CONFIG 和 DefaultPath
+	Path string
+	// Profile 要启用的 profile，为空时依次尝试环境变量 EINO_PROFILE 和配置文件中的 profile 字段
+	Profile string
 }
 
-// LoadConfig 从.env文件加载配置
+// Default 返回内置默认配置
+func Default() *Config {
+	return &Config{
+		Agents: AgentsConfig{
+			Supervisor: ModelConfig{Model: constant.QWEN_TURBO},
+			Summary:    ModelConfig{Model: constant.QWEN3_MAX_PREVIEW},
+			Reviewer:   ModelConfig{Model: constant.QWEN3_MAX_PREVIEW},
+		},
+		Rewrite: RewriteConfig{
+			MaxIterations: 5,
+			OutputDir:     ".",
+			Persona:       "backend",
+			PersonasDir:   "personas",
+			Language:      "简体中文",
+			PromptsDir:    "prompt_overrides",
+			Sections: SectionsConfig{
+				Mode:      "whole",
+				AutoChars: 30000,
+				Level:     2,
+				Workers:   3,
+			},
+			HistoryDir: "runs",
+			Plan:       PlanConfig{Mode: "auto"},
+		},
+		Review: ReviewConfig{
+			PassScore:  8,
+			MinScore:   6,
+			ScoresFile: "review_scores.jsonl",
+		},
+		Lint: LintConfig{
+			Rules:         slices.Clone(LintRules),
+			MaxEmoji:      5,
+			SectionLevel:  2,
+			SectionBlocks: []string{"本节你会学到什么", "小结"},
+			SkipSections:  []string{"大纲", "目录", "总结", "参考"},
+		},
+		CodeCheck: CodeCheckConfig{
+			Mode:           "types",
+			TimeoutSeconds: 30,
+		},
+		Coverage: CoverageConfig{
+			Checks:          slices.Clone(CoverageChecks),
+			ShrinkRatio:     0.5,
+			MinSectionChars: 200,
+			MinTermCount:    2,
+		},
+		Milvus: MilvusConfig{
+			Address:    "localhost:19530",
+			Collection: "test3",
+		},
+		Embedding: EmbeddingConfig{
+			Model:      "text-embedding-v3",
+			Dimensions: 512,
+		},
+		Feishu: FeishuConfig{
+			AuthMode:    "tenant",
+			BaseURL:     "https://open.feishu.cn",
+			TokenFile:   ".feishu_token.json",
+			RedirectURI: "http://localhost:9999/callback",
+			DocsFile:    ".feishu_docs.json",
+		},
+		Mermaid: MermaidConfig{
+			Renderer: "ink",
+			InkURL:   "https://mermaid.ink",
+			KrokiURL: "https://kroki.io",
+		},
+		PostProcess: PostProcessConfig{
+			Stages:         []string{"repair", "mermaid"},
+			RepairAttempts: 2,
+			AssetsDir:      "assets",
+			MermaidFormat:  "png",
+			TOCDepth:       3,
+		},
+	}
+}
+
+// LoadConfig 使用默认路径和 profile 加载配置
 func LoadConfig() (*Config, error) {
-	config := &Config{}
+	return Load(LoadOptions{})
+}
 
-	// 首先尝试从环境变量读取
-	config.DashScopeAPIKey = os.Getenv("DASHSCOPE_API_KEY")
-	config.DashScopeURL = os.Getenv("DASHSCOPE_BASE_URL")
+// Load 按 默认值 → 配置文件 → profile → .env 文件 → 环境变量 的顺序加载配置，并在返回前校验
+func Load(opts LoadOptions) (*Config, error) {
+	config := Default()
 
-	// 如果环境变量为空，尝试从.env文件读取
-	if config.DashScopeAPIKey == "" || config.DashScopeURL == "" {
-		err := loadEnvFile(".env", config)
-		if err != nil {
-			return nil, fmt.Errorf("加载配置失败: %v", err)
+	path, explicit := opts.Path, opts.Path != ""
+	if path == "" {
+		path = os.Getenv("EINO_CONFIG")
+		explicit = path != ""
+	}
+	if path == "" {
+		path = DefaultPath
+	}
+
+	profiles := map[string]yaml.Node{}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		fc := fileConfig{Config: *config}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
 		}
+		*config = fc.Config
+		profiles = fc.Profiles
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		// 没有配置文件时只使用默认值和环境变量
+	default:
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
 	}
 
-	// 验证必要的配置
-	if config.DashScopeAPIKey == "" {
-		return nil, fmt.Errorf("缺少 DASHSCOPE_API_KEY 配置")
+	profile := opts.Profile
+	if profile == "" {
+		profile = os.Getenv("EINO_PROFILE")
 	}
-	if config.DashScopeURL == "" {
-		return nil, fmt.Errorf("缺少 DASHSCOPE_BASE_URL 配置")
+	if profile == "" {
+		profile = config.Profile
+	}
+	if profile != "" {
+		node, ok := profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("profile %q 不存在，可选值: %s", profile, strings.Join(profileNames(profiles), ", "))
+		}
+		if err := decodeProfile(&node, config); err != nil {
+			return nil, fmt.Errorf("解析 profile %q 失败: %v", profile, err)
+		}
+		config.Profile = profile
+	}
+
+	// .env 文件只补充没有设置的环境变量，不覆盖已有值
+	if err := loadEnvFile(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("加载配置失败: %v", err)
+	}
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	// 验证必要的配置
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
 
 	// 将配置值设置到系统环境变量中
@@ -44,11 +401,289 @@ func LoadConfig() (*Config, error) {
 	return config, nil
 }
 
-// loadEnvFile 从.env文件读取配置
-func loadEnvFile(filename string, config *Config) error {
+// decodeProfile 在已有配置上解码 profile，profile 中没有出现的字段保持不变。
+// 与配置文件一样出现未知字段时报错，避免 profile 中的字段名拼错后修改悄悄失效
+func decodeProfile(node *yaml.Node, config *Config) error {
+	if node.Kind == 0 || node.ShortTag() == "!!null" {
+		return nil
+	}
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	return dec.Decode(config)
+}
+
+// envOverrides 环境变量到配置字段的映射
+func (c *Config) envOverrides() map[string]any {
+	return map[string]any{
+		"DASHSCOPE_API_KEY":      &c.DashScopeAPIKey,
+		"DASHSCOPE_BASE_URL":     &c.DashScopeURL,
+		"SUPERVISOR_MODEL":       &c.Agents.Supervisor.Model,
+		"SUMMARY_MODEL":          &c.Agents.Summary.Model,
+		"REVIEWER_MODEL":         &c.Agents.Reviewer.Model,
+		"MAX_ITERATIONS":         &c.Rewrite.MaxIterations,
+		"OUTPUT_DIR":             &c.Rewrite.OutputDir,
+		"PERSONA":                &c.Rewrite.Persona,
+		"PERSONAS_DIR":           &c.Rewrite.PersonasDir,
+		"REWRITE_LANGUAGE":       &c.Rewrite.Language,
+		"REWRITE_SECTIONS_MODE":  &c.Rewrite.Sections.Mode,
+		"HISTORY_DIR":            &c.Rewrite.HistoryDir,
+		"REWRITE_PLAN_MODE":      &c.Rewrite.Plan.Mode,
+		"PROMPTS_DIR":            &c.Rewrite.PromptsDir,
+		"REVIEW_PASS_SCORE":      &c.Review.PassScore,
+		"REVIEW_MIN_SCORE":       &c.Review.MinScore,
+		"REVIEW_SCORES_FILE":     &c.Review.ScoresFile,
+		"REVIEW_RUBRIC":          &c.Review.Rubric,
+		"LINT_RULES":             &c.Lint.Rules,
+		"LINT_MAX_EMOJI":         &c.Lint.MaxEmoji,
+		"CODECHECK_MODE":         &c.CodeCheck.Mode,
+		"CODECHECK_GO_BIN":       &c.CodeCheck.GoBin,
+		"COVERAGE_CHECKS":        &c.Coverage.Checks,
+		"COVERAGE_SHRINK_RATIO":  &c.Coverage.ShrinkRatio,
+		"MILVUS_ADDRESS":         &c.Milvus.Address,
+		"MILVUS_COLLECTION":      &c.Milvus.Collection,
+		"EMBEDDING_MODEL":        &c.Embedding.Model,
+		"EMBEDDING_DIMENSIONS":   &c.Embedding.Dimensions,
+		"FEISHU_APP_ID":          &c.Feishu.AppID,
+		"FEISHU_APP_SECRET":      &c.Feishu.AppSecret,
+		"FEISHU_FOLDER_TOKEN":    &c.Feishu.FolderToken,
+		"FEISHU_AUTH_MODE":       &c.Feishu.AuthMode,
+		"FEISHU_BASE_URL":        &c.Feishu.BaseURL,
+		"FEISHU_TOKEN_FILE":      &c.Feishu.TokenFile,
+		"FEISHU_REDIRECT_URI":    &c.Feishu.RedirectURI,
+		"FEISHU_DOCS_FILE":       &c.Feishu.DocsFile,
+		"MERMAID_RENDERER":       &c.Mermaid.Renderer,
+		"MERMAID_INK_URL":        &c.Mermaid.InkURL,
+		"MERMAID_KROKI_URL":      &c.Mermaid.KrokiURL,
+		"MERMAID_MMDC_PATH":      &c.Mermaid.MmdcPath,
+		"MERMAID_FALLBACK":       &c.Mermaid.Fallback,
+		"POSTPROCESS_STAGES":     &c.PostProcess.Stages,
+		"POSTPROCESS_ASSETS_DIR": &c.PostProcess.AssetsDir,
+		"SUMMARY_TEMPERATURE":    &c.Agents.Summary.Temperature,
+		"REVIEWER_TEMPERATURE":   &c.Agents.Reviewer.Temperature,
+		"SUPERVISOR_TEMPERATURE": &c.Agents.Supervisor.Temperature,
+	}
+}
+
+// applyEnv 用环境变量覆盖配置
+func (c *Config) applyEnv() error {
+	for key, target := range c.envOverrides() {
+		value, ok := os.LookupEnv(key)
+		if !ok || value == "" {
+			continue
+		}
+		switch t := target.(type) {
+		case *string:
+			*t = value
+		case *[]string:
+			// 逗号分隔的列表
+			*t = nil
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*t = append(*t, item)
+				}
+			}
+		case *int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s=%q 不是合法的整数", key, value)
+			}
+			*t = n
+		case *float64:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s=%q 不是合法的数字", key, value)
+			}
+			*t = f
+		case **float32:
+			f, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s=%q 不是合法的数字", key, value)
+			}
+			v := float32(f)
+			*t = &v
+		}
+	}
+
+	// 兼容 .env.example 中的 MILVUS_HOST / MILVUS_PORT
+	if host := os.Getenv("MILVUS_HOST"); host != "" && os.Getenv("MILVUS_ADDRESS") == "" {
+		port := os.Getenv("MILVUS_PORT")
+		if port == "" {
+			port = "19530"
+		}
+		c.Milvus.Address = net.JoinHostPort(host, port)
+	}
+	return nil
+}
+
+// RequireModel 检查调用模型所需的 DashScope 配置。只有调用模型的命令需要检查，
+// config show、history 等命令没有配置 API key 时也能使用
+func (c *Config) RequireModel() error {
+	var errs []error
+	if c.DashScopeAPIKey == "" {
+		errs = append(errs, errors.New("缺少 DASHSCOPE_API_KEY 配置（dashscope_api_key）"))
+	}
+	if c.DashScopeURL == "" {
+		errs = append(errs, errors.New("缺少 DASHSCOPE_BASE_URL 配置（dashscope_base_url）"))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate 校验配置，一次返回所有问题。调用模型所需的配置由 RequireModel 检查
+func (c *Config) Validate() error {
+	var errs []error
+	for name, m := range map[string]ModelConfig{
+		"supervisor": c.Agents.Supervisor,
+		"summary":    c.Agents.Summary,
+		"reviewer":   c.Agents.Reviewer,
+	} {
+		if m.Model == "" {
+			errs = append(errs, fmt.Errorf("agents.%s.model 不能为空", name))
+		}
+		if m.Temperature != nil && (*m.Temperature < 0 || *m.Temperature > 2) {
+			errs = append(errs, fmt.Errorf("agents.%s.temperature 必须在 0 到 2 之间，当前为 %v", name, *m.Temperature))
+		}
+	}
+
+	if s := c.Rewrite.Sections; !slices.Contains(SectionModes, s.Mode) {
+		errs = append(errs, fmt.Errorf("rewrite.sections.mode 只能是 %s，当前为 %q", strings.Join(SectionModes, "、"), s.Mode))
+	}
+	if m := c.Rewrite.Plan.Mode; !slices.Contains(PlanModes, m) {
+		errs = append(errs, fmt.Errorf("rewrite.plan.mode 只能是 %s，当前为 %q", strings.Join(PlanModes, "、"), m))
+	}
+	if l := c.Rewrite.Sections.Level; l < 1 || l > 6 {
+		errs = append(errs, fmt.Errorf("rewrite.sections.level 必须在 1 到 6 之间，当前为 %d", l))
+	}
+	if c.Rewrite.Sections.Workers < 1 {
+		errs = append(errs, fmt.Errorf("rewrite.sections.workers 必须大于 0，当前为 %d", c.Rewrite.Sections.Workers))
+	}
+	if c.Rewrite.MaxIterations < 1 {
+		errs = append(errs, fmt.Errorf("rewrite.max_iterations 必须大于 0，当前为 %d", c.Rewrite.MaxIterations))
+	}
+	if c.Rewrite.OutputDir == "" {
+		errs = append(errs, errors.New("rewrite.output_dir 不能为空"))
+	}
+	if c.Rewrite.Language == "" {
+		errs = append(errs, errors.New("rewrite.language 不能为空"))
+	}
+	if c.Review.PassScore < 0 || c.Review.PassScore > 10 {
+		errs = append(errs, fmt.Errorf("review.pass_score 必须在 0 到 10 之间，当前为 %v", c.Review.PassScore))
+	}
+	if c.Review.MinScore < 0 || c.Review.MinScore > 10 {
+		errs = append(errs, fmt.Errorf("review.min_score 必须在 0 到 10 之间，当前为 %d", c.Review.MinScore))
+	}
+	if _, _, err := net.SplitHostPort(c.Milvus.Address); err != nil {
+		errs = append(errs, fmt.Errorf("milvus.address %q 不是合法的 host:port", c.Milvus.Address))
+	}
+	if c.Milvus.Collection == "" {
+		errs = append(errs, errors.New("milvus.collection 不能为空"))
+	}
+	if c.Embedding.Model == "" {
+		errs = append(errs, errors.New("embedding.model 不能为空"))
+	}
+	if c.Embedding.Dimensions <= 0 {
+		errs = append(errs, fmt.Errorf("embedding.dimensions 必须大于 0，当前为 %d", c.Embedding.Dimensions))
+	}
+	if (c.Feishu.AppID == "") != (c.Feishu.AppSecret == "") {
+		errs = append(errs, errors.New("feishu.app_id 和 feishu.app_secret 需要同时配置"))
+	}
+	if c.Feishu.AuthMode != "tenant" && c.Feishu.AuthMode != "user" {
+		errs = append(errs, fmt.Errorf("feishu.auth_mode 只能是 tenant 或 user，当前为 %q", c.Feishu.AuthMode))
+	}
+	if c.Feishu.AuthMode == "user" && c.Feishu.TokenFile == "" {
+		errs = append(errs, errors.New("feishu.auth_mode 为 user 时 feishu.token_file 不能为空"))
+	}
+	for _, name := range append([]string{c.Mermaid.Renderer}, c.Mermaid.Fallback...) {
+		if !slices.Contains(MermaidRenderers, name) {
+			errs = append(errs, fmt.Errorf("mermaid 渲染器 %q 不存在，可选值: %s", name, strings.Join(MermaidRenderers, ", ")))
+		}
+	}
+	for _, rule := range c.Lint.Rules {
+		if !slices.Contains(LintRules, rule) {
+			errs = append(errs, fmt.Errorf("lint.rules 中的 %q 不存在，可选值: %s", rule, strings.Join(LintRules, ", ")))
+		}
+	}
+	if c.Lint.MaxEmoji < 0 {
+		errs = append(errs, fmt.Errorf("lint.max_emoji 不能为负数，当前为 %d", c.Lint.MaxEmoji))
+	}
+	if l := c.Lint.SectionLevel; l < 1 || l > 6 {
+		errs = append(errs, fmt.Errorf("lint.section_level 必须在 1 到 6 之间，当前为 %d", l))
+	}
+	if !slices.Contains(CodeCheckModes, c.CodeCheck.Mode) {
+		errs = append(errs, fmt.Errorf("codecheck.mode 只能是 %s，当前为 %q", strings.Join(CodeCheckModes, "、"), c.CodeCheck.Mode))
+	}
+	if c.CodeCheck.TimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("codecheck.timeout_seconds 必须大于 0，当前为 %d", c.CodeCheck.TimeoutSeconds))
+	}
+	for _, check := range c.Coverage.Checks {
+		if !slices.Contains(CoverageChecks, check) {
+			errs = append(errs, fmt.Errorf("coverage.checks 中的 %q 不存在，可选值: %s", check, strings.Join(CoverageChecks, ", ")))
+		}
+	}
+	if r := c.Coverage.ShrinkRatio; r <= 0 || r > 1 {
+		errs = append(errs, fmt.Errorf("coverage.shrink_ratio 必须在 0 到 1 之间，当前为 %g", r))
+	}
+	if c.Coverage.MinTermCount < 1 {
+		errs = append(errs, fmt.Errorf("coverage.min_term_count 必须大于 0，当前为 %d", c.Coverage.MinTermCount))
+	}
+	for _, stage := range c.PostProcess.Stages {
+		if !slices.Contains(PostProcessStages, stage) {
+			errs = append(errs, fmt.Errorf("postprocess.stages 中的 %q 不存在，可选值: %s", stage, strings.Join(PostProcessStages, ", ")))
+		}
+	}
+	// repair 修复的是图表源码，放在 mermaid 之后时图表已经转成图片，修复不再生效
+	if r, m := slices.Index(c.PostProcess.Stages, "repair"), slices.Index(c.PostProcess.Stages, "mermaid"); r >= 0 && m >= 0 && r > m {
+		errs = append(errs, fmt.Errorf("postprocess.stages 中 repair 必须在 mermaid 之前"))
+	}
+	if c.PostProcess.RepairAttempts < 1 {
+		errs = append(errs, fmt.Errorf("postprocess.repair_attempts 必须大于 0，当前为 %d", c.PostProcess.RepairAttempts))
+	}
+	if f := c.PostProcess.MermaidFormat; f != "svg" && f != "png" {
+		errs = append(errs, fmt.Errorf("postprocess.mermaid_format 只能是 svg 或 png，当前为 %q", f))
+	}
+	if d := c.PostProcess.TOCDepth; d < 2 || d > 6 {
+		errs = append(errs, fmt.Errorf("postprocess.toc_depth 必须在 2 到 6 之间，当前为 %d", d))
+	}
+	if c.PostProcess.AssetsDir == "" || filepath.IsAbs(c.PostProcess.AssetsDir) {
+		errs = append(errs, fmt.Errorf("postprocess.assets_dir 必须是相对路径，当前为 %q", c.PostProcess.AssetsDir))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return fmt.Errorf("配置校验失败:\n  - %w", joinLines(errs))
+}
+
+// joinLines 把多个错误拼成一个多行错误
+func joinLines(errs []error) error {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "\n  - "))
+}
+
+func profileNames(profiles map[string]yaml.Node) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return []string{"（配置文件中没有定义 profiles）"}
+	}
+	return names
+}
+
+// loadEnvFile 从.env文件读取配置，只设置当前进程中尚未设置的环境变量
+func loadEnvFile(filename string) error {
 	file, err := os.Open(filename)
 	if err != nil {
-		return fmt.Errorf("无法打开 %s 文件: %v", filename, err)
+		return err
 	}
 	defer file.Close()
 
@@ -73,11 +708,8 @@ func loadEnvFile(filename string, config *Config) error {
 		// 移除引号（如果有）
 		value = strings.Trim(value, "\"'")
 
-		switch key {
-		case "DASHSCOPE_API_KEY":
-			config.DashScopeAPIKey = value
-		case "DASHSCOPE_BASE_URL":
-			config.DashScopeURL = value
+		if _, ok := os.LookupEnv(key); !ok {
+			os.Setenv(key, value)
 		}
 	}
 
@@ -88,18 +720,33 @@ func loadEnvFile(filename string, config *Config) error {
 	return nil
 }
 
+// mask 隐藏敏感信息，避免短字符串导致的 panic
+func mask(s string) string {
+	if len(s) > 14 {
+		return s[:10] + "***" + s[len(s)-4:]
+	} else if len(s) > 0 {
+		return s[:1] + "***" + s[len(s)-1:]
+	}
+	return "***"
+}
+
 // PrintConfig 打印配置信息（隐藏敏感信息）
 func (c *Config) PrintConfig() {
-	fmt.Println("🔧 DashScope 配置信息:")
-	// 安全地处理 API Key 的显示，避免短字符串导致的 panic
-	apiKey := c.DashScopeAPIKey
-	if len(apiKey) > 14 {
-		apiKey = apiKey[:10] + "***" + apiKey[len(apiKey)-4:]
-	} else if len(apiKey) > 0 {
-		apiKey = apiKey[:1] + "***" + apiKey[len(apiKey)-1:]
-	} else {
-		apiKey = "***"
-	}
-	fmt.Printf("  • API Key: %s\n", apiKey)
-	fmt.Printf("  • Base URL: %s\n", c.DashScopeURL)
+	masked := *c
+	masked.DashScopeAPIKey = mask(c.DashScopeAPIKey)
+	if c.Feishu.AppSecret != "" {
+		masked.Feishu.AppSecret = mask(c.Feishu.AppSecret)
+	}
+
+	profile := c.Profile
+	if profile == "" {
+		profile = "（无）"
+	}
+	fmt.Printf("🔧 当前配置（profile: %s）:\n", profile)
+	out, err := yaml.Marshal(&masked)
+	if err != nil {
+		fmt.Printf("  序列化配置失败: %v\n", err)
+		return
+	}
+	fmt.Print(string(out))
 }
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+dashscope_api_key: sk-test
+dashscope_base_url: https://example.com/v1
+agents:
+  summary:
+    model: qwen3-max
+    temperature: 0.7
+rewrite:
+  output_dir: ./out
+profiles:
+  dev:
+    agents:
+      summary:
+        model: qwen-plus
+    rewrite:
+      max_iterations: 2
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadProfileAndEnv 测试 profile 局部覆盖和环境变量覆盖的优先级
+func TestLoadProfileAndEnv(t *testing.T) {
+	t.Setenv("DASHSCOPE_API_KEY", "")
+	t.Setenv("DASHSCOPE_BASE_URL", "")
+	t.Setenv("REVIEWER_MODEL", "qwen-turbo")
+	t.Setenv("MILVUS_ADDRESS", "milvus:19531")
+
+	cfg, err := Load(LoadOptions{Path: writeConfig(t, testConfig), Profile: "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Agents.Summary.Model != "qwen-plus" {
+		t.Errorf("profile 没有覆盖 summary 模型: %s", cfg.Agents.Summary.Model)
+	}
+	if cfg.Agents.Summary.Temperature == nil || *cfg.Agents.Summary.Temperature != 0.7 {
+		t.Errorf("profile 不应清空未覆盖的字段: %v", cfg.Agents.Summary.Temperature)
+	}
+	if cfg.Rewrite.MaxIterations != 2 || cfg.Rewrite.OutputDir != "./out" {
+		t.Errorf("rewrite 配置不符合预期: %+v", cfg.Rewrite)
+	}
+	if cfg.Agents.Reviewer.Model != "qwen-turbo" || cfg.Milvus.Address != "milvus:19531" {
+		t.Errorf("环境变量没有生效: %s %s", cfg.Agents.Reviewer.Model, cfg.Milvus.Address)
+	}
+	if cfg.Embedding.Dimensions != 512 {
+		t.Errorf("未配置的字段应使用默认值: %d", cfg.Embedding.Dimensions)
+	}
+}
+
+// TestLoadValidation 测试校验错误一次全部返回
+func TestLoadValidation(t *testing.T) {
+	t.Setenv("DASHSCOPE_API_KEY", "sk-test")
+	t.Setenv("DASHSCOPE_BASE_URL", "https://example.com/v1")
+
+	path := writeConfig(t, `
+agents:
+  reviewer:
+    temperature: 3
+milvus:
+  address: localhost
+feishu:
+  app_id: cli_xxx
+lint:
+  rules: [emoji, spelling]
+codecheck:
+  mode: compile
+coverage:
+  checks: [headings, spelling]
+postprocess:
+  stages: [mermaid, repair]
+rewrite:
+  sections:
+    mode: chapters
+  plan:
+    mode: manual
+`)
+	_, err := Load(LoadOptions{Path: path})
+	if err == nil {
+		t.Fatal("期望校验失败")
+	}
+	for _, want := range []string{"agents.reviewer.temperature", "milvus.address", "feishu.app_secret", "lint.rules 中的 \"spelling\"", "codecheck.mode", "repair 必须在 mermaid 之前", "coverage.checks 中的 \"spelling\"", "rewrite.sections.mode", "rewrite.plan.mode"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息缺少 %s:\n%v", want, err)
+		}
+	}
+
+	if _, err := Load(LoadOptions{Path: path, Profile: "missing"}); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("未知 profile 应报错: %v", err)
+	}
+	if _, err := Load(LoadOptions{Path: writeConfig(t, "unknown_field: 1\n")}); err == nil {
+		t.Error("未知字段应报错")
+	}
+}
+
+// TestLoadProfileUnknownField 测试 profile 中拼错的字段名和配置文件中一样报错
+func TestLoadProfileUnknownField(t *testing.T) {
+	path := writeConfig(t, testConfig+"  typo:\n    rewrite:\n      max_iteration: 2\n")
+	if _, err := Load(LoadOptions{Path: path, Profile: "dev"}); err != nil {
+		t.Fatalf("正确的 profile 不应报错: %v", err)
+	}
+	_, err := Load(LoadOptions{Path: path, Profile: "typo"})
+	if err == nil || !strings.Contains(err.Error(), "max_iteration") {
+		t.Errorf("profile 中的未知字段应报错: %v", err)
+	}
+}
+
+// TestRequireModel 测试没有 API key 时仍能加载配置，只有调用模型前才检查
+func TestRequireModel(t *testing.T) {
+	t.Setenv("DASHSCOPE_API_KEY", "")
+	t.Setenv("DASHSCOPE_BASE_URL", "")
+	cfg, err := Load(LoadOptions{Path: writeConfig(t, "rewrite:\n  output_dir: ./out\n")})
+	if err != nil {
+		t.Fatalf("没有 API key 时也应能加载配置: %v", err)
+	}
+	err = cfg.RequireModel()
+	if err == nil || !strings.Contains(err.Error(), "DASHSCOPE_API_KEY") || !strings.Contains(err.Error(), "DASHSCOPE_BASE_URL") {
+		t.Errorf("调用模型前应检查 API key: %v", err)
+	}
+	cfg.DashScopeAPIKey, cfg.DashScopeURL = "sk-test", "https://example.com/v1"
+	if err := cfg.RequireModel(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,372 @@
+// Package coverage 对照源文档检查改写稿有没有遗漏内容。改写原则的第一条是"保留结构，不要大幅删减"，
+// 这里用 components 中的 Markdown 标题分割器把两份文档切成章节，按标题对齐后报告被删掉的标题、
+// 丢失的关键术语、丢失的代码块和篇幅大幅缩水的章节，结果交给 ReviewerAgent 并写入 .meta.json
+package coverage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"eino_test/components"
+	"eino_test/config"
+	"eino_test/markdown"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 检查项的名称，与 config.CoverageChecks 一致
+const (
+	CheckHeadings = "headings"
+	CheckTerms    = "terms"
+	CheckCode     = "code"
+	CheckSections = "sections"
+)
+
+const (
+	// codeOverlap 改写稿的某个代码块包含源代码块这个比例的标识符时，认为源代码块被保留了（允许加注释、改格式）
+	codeOverlap = 0.6
+	// maxListedTerms Format 最多列出的丢失术语数量
+	maxListedTerms = 20
+	// maxTermRunes 行内代码超过这个长度时更像一段代码而不是术语，不作为关键术语
+	maxTermRunes = 40
+)
+
+var (
+	numberingRe  = regexp.MustCompile(`^(第[0-9一二三四五六七八九十百]+[章节部分篇]|[0-9]+(\.[0-9]+)*\.?|[一二三四五六七八九十]+[、.])\s*`)
+	inlineCodeRe = regexp.MustCompile("`([^`\n]+)`")
+	boldRe       = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	urlRe        = regexp.MustCompile(`\]\([^)]*\)|https?://\S+`)
+	wordRe       = regexp.MustCompile(`[A-Za-z][A-Za-z0-9]*(?:[._-][A-Za-z0-9]+)*`)
+	identRe      = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+)
+
+// stopWords 句首大写的常见英文单词，不作为关键术语
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "this": true, "that": true, "these": true, "those": true,
+	"and": true, "or": true, "but": true, "if": true, "when": true, "then": true, "so": true,
+	"in": true, "on": true, "at": true, "to": true, "of": true, "for": true, "with": true, "by": true, "from": true,
+	"is": true, "are": true, "be": true, "it": true, "we": true, "you": true, "they": true, "i": true,
+	"not": true, "no": true, "all": true, "each": true, "as": true, "how": true, "what": true, "why": true,
+	"note": true, "example": true, "see": true, "use": true, "also": true,
+}
+
+// Heading 源文档中的一个标题
+type Heading struct {
+	Level int    `json:"level"`
+	Title string `json:"title"`
+}
+
+func (h Heading) String() string {
+	return strings.Repeat("#", h.Level) + " " + h.Title
+}
+
+// CodeBlock 源文档中的一个代码块
+type CodeBlock struct {
+	// Line 代码块开头围栏在源文档中的行号
+	Line int    `json:"line"`
+	Lang string `json:"lang,omitempty"`
+	// Preview 第一行非空代码
+	Preview string `json:"preview"`
+}
+
+// Section 篇幅缩水的章节，字数不含空白，包括下级章节
+type Section struct {
+	Title   string `json:"title"`
+	Source  int    `json:"source_chars"`
+	Rewrite int    `json:"rewrite_chars"`
+}
+
+// Report 一次覆盖检查的结果
+type Report struct {
+	SourceHeadings  int       `json:"source_headings"`
+	DroppedHeadings []Heading `json:"dropped_headings,omitempty"`
+	// Terms 源文档中识别出的关键术语数量
+	Terms        int         `json:"terms"`
+	MissingTerms []string    `json:"missing_terms,omitempty"`
+	CodeBlocks   int         `json:"code_blocks"`
+	MissingCode  []CodeBlock `json:"missing_code,omitempty"`
+	Shrunk       []Section   `json:"shrunk_sections,omitempty"`
+	SourceChars  int         `json:"source_chars"`
+	RewriteChars int         `json:"rewrite_chars"`
+}
+
+// OK 没有发现遗漏
+func (r *Report) OK() bool {
+	return len(r.DroppedHeadings) == 0 && len(r.MissingTerms) == 0 && len(r.MissingCode) == 0 && len(r.Shrunk) == 0
+}
+
+// Format 格式化为每行一条的文本，没有问题时返回空字符串
+func (r *Report) Format() string {
+	var lines []string
+	for _, h := range r.DroppedHeadings {
+		lines = append(lines, fmt.Sprintf("- 标题被删除：源文档的 %q 在改写稿中找不到对应的标题", h.String()))
+	}
+	if n := len(r.MissingTerms); n > 0 {
+		listed := r.MissingTerms[:min(n, maxListedTerms)]
+		more := ""
+		if n > maxListedTerms {
+			more = " 等"
+		}
+		lines = append(lines, fmt.Sprintf("- 关键术语丢失（%d/%d 个）：%s%s", n, r.Terms, strings.Join(listed, "、"), more))
+	}
+	for _, c := range r.MissingCode {
+		lang := c.Lang
+		if lang == "" {
+			lang = "无语言标注的"
+		}
+		lines = append(lines, fmt.Sprintf("- 代码块丢失：源文档第 %d 行的 %s 代码块（%s）在改写稿中找不到", c.Line, lang, c.Preview))
+	}
+	for _, s := range r.Shrunk {
+		lines = append(lines, fmt.Sprintf("- 章节缩水：%q 源文档 %d 字，改写稿只有 %d 字（%d%%）",
+			s.Title, s.Source, s.Rewrite, s.Rewrite*100/max(s.Source, 1)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check 按配置启用的检查项对照源文档检查改写稿
+func Check(ctx context.Context, source, rewrite string, cfg config.CoverageConfig) (*Report, error) {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	rewrite = strings.ReplaceAll(rewrite, "\r\n", "\n")
+	r := &Report{SourceChars: countChars(source), RewriteChars: countChars(rewrite)}
+
+	src, err := split(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := split(ctx, rewrite)
+	if err != nil {
+		return nil, err
+	}
+	r.SourceHeadings = len(src)
+	matched := align(src, dst)
+
+	for _, check := range cfg.Checks {
+		switch check {
+		case CheckHeadings:
+			for i, s := range src {
+				if matched[i] < 0 {
+					r.DroppedHeadings = append(r.DroppedHeadings, Heading{Level: s.level, Title: s.title})
+				}
+			}
+		case CheckTerms:
+			terms := keyTerms(source, cfg.MinTermCount)
+			r.Terms = len(terms)
+			lower := strings.ToLower(rewrite)
+			for _, t := range terms {
+				if !strings.Contains(lower, strings.ToLower(t)) {
+					r.MissingTerms = append(r.MissingTerms, t)
+				}
+			}
+		case CheckCode:
+			blocks := codeBlocks(source)
+			r.CodeBlocks = len(blocks)
+			kept := codeBlocks(rewrite)
+			for _, b := range blocks {
+				if !slices.ContainsFunc(kept, func(k codeBlock) bool { return b.keptIn(k) }) {
+					r.MissingCode = append(r.MissingCode, b.CodeBlock)
+				}
+			}
+		case CheckSections:
+			for i, s := range src {
+				if j := matched[i]; j >= 0 && s.total >= cfg.MinSectionChars && float64(dst[j].total) < float64(s.total)*cfg.ShrinkRatio {
+					r.Shrunk = append(r.Shrunk, Section{Title: s.title, Source: s.total, Rewrite: dst[j].total})
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// section 标题分割器切出的一节，total 包括下级章节的字数
+type section struct {
+	level int
+	title string
+	key   string
+	chars int
+	total int
+}
+
+// split 用 components.NewTrans 按标题切分文档，第一个标题之前的内容不参与对齐
+func split(ctx context.Context, content string) ([]section, error) {
+	docs, err := components.NewTrans(ctx).Transform(ctx, []*schema.Document{{Content: content}})
+	if err != nil {
+		return nil, fmt.Errorf("按标题切分文档失败: %w", err)
+	}
+	var out []section
+	for _, d := range docs {
+		first, body, _ := strings.Cut(d.Content, "\n")
+		level, title, ok := markdown.ParseHeading(first)
+		if !ok {
+			continue
+		}
+		out = append(out, section{level: level, title: title, key: normalize(title), chars: countChars(body)})
+	}
+	for i := range out {
+		out[i].total = out[i].chars
+		for _, next := range out[i+1:] {
+			if next.level <= out[i].level {
+				break
+			}
+			out[i].total += next.chars
+		}
+	}
+	return out, nil
+}
+
+// align 为每个源章节找到改写稿中标题对应的章节，找不到时为 -1。
+// 改写时常给标题加编号或补充说明，去掉编号和标点后一方包含另一方就算对应
+func align(src, dst []section) []int {
+	matched := make([]int, len(src))
+	used := make([]bool, len(dst))
+	for i, s := range src {
+		matched[i] = -1
+		for j, d := range dst {
+			if !used[j] && sameTitle(s.key, d.key) {
+				matched[i], used[j] = j, true
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func sameTitle(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if utf8.RuneCountInString(a) > utf8.RuneCountInString(b) {
+		a, b = b, a
+	}
+	return utf8.RuneCountInString(a) >= 2 && strings.Contains(b, a)
+}
+
+// normalize 去掉标题的编号、标点、emoji 和空白，统一为小写
+func normalize(title string) string {
+	title = numberingRe.ReplaceAllString(strings.ToLower(strings.TrimSpace(title)), "")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, title)
+}
+
+func countChars(s string) int {
+	n := 0
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// keyTerms 提取源文档正文（代码块以外）中的关键术语：行内代码、加粗文字，
+// 以及出现至少 minCount 次、带大写字母的英文词（例如 Kafka、ISR、ZooKeeper）。
+// 中文正文没有分词，只通过行内代码和加粗文字识别术语
+func keyTerms(content string, minCount int) []string {
+	prose := strings.Join(markdown.ProseLines(content), "\n")
+
+	var terms []string
+	seen := map[string]bool{}
+	add := func(t string) {
+		t = strings.TrimSpace(t)
+		key := strings.ToLower(t)
+		if n := utf8.RuneCountInString(t); n < 2 || n > maxTermRunes || seen[key] {
+			return
+		}
+		seen[key] = true
+		terms = append(terms, t)
+	}
+	for _, m := range inlineCodeRe.FindAllStringSubmatch(prose, -1) {
+		add(m[1])
+	}
+	for _, m := range boldRe.FindAllStringSubmatch(prose, -1) {
+		add(m[1])
+	}
+
+	plain := urlRe.ReplaceAllString(inlineCodeRe.ReplaceAllString(prose, " "), " ")
+	counts := map[string]int{}
+	var words []string
+	for _, w := range wordRe.FindAllString(plain, -1) {
+		if strings.ToLower(w) == w || stopWords[strings.ToLower(w)] {
+			continue
+		}
+		if counts[w] == 0 {
+			words = append(words, w)
+		}
+		counts[w]++
+	}
+	for _, w := range words {
+		if counts[w] >= max(minCount, 1) {
+			add(w)
+		}
+	}
+	return terms
+}
+
+// codeBlock 带标识符集合的代码块，用于判断改写稿是否保留了源代码块
+type codeBlock struct {
+	CodeBlock
+	tokens map[string]bool
+}
+
+func codeBlocks(content string) []codeBlock {
+	lines := strings.Split(content, "\n")
+	var out []codeBlock
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		fence := markdown.FenceMarker(trimmed)
+		if fence == "" {
+			continue
+		}
+		start := i
+		for i++; i < len(lines) && !markdown.ClosesFence(lines[i], fence); i++ {
+		}
+		body := lines[start+1 : min(i, len(lines))]
+		b := codeBlock{CodeBlock: CodeBlock{Line: start + 1, Lang: strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1]))}, tokens: map[string]bool{}}
+		for _, line := range body {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if b.Preview == "" {
+				b.Preview = truncate(line, 40)
+			}
+			tokens := identRe.FindAllString(line, -1)
+			if len(tokens) == 0 {
+				// 没有标识符的行（例如纯中文或符号）按整行比较
+				tokens = []string{line}
+			}
+			for _, t := range tokens {
+				b.tokens[t] = true
+			}
+		}
+		if len(b.tokens) > 0 {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// keptIn 判断改写稿的代码块 k 是否保留了代码块 b 的大部分标识符
+func (b codeBlock) keptIn(k codeBlock) bool {
+	hit := 0
+	for t := range b.tokens {
+		if k.tokens[t] {
+			hit++
+		}
+	}
+	return float64(hit) >= float64(len(b.tokens))*codeOverlap
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
@@ -0,0 +1,70 @@
+package coverage
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+)
+
+var source = "# Kafka 入门\n\n" +
+	"Kafka 是一个分布式消息系统。Kafka 的消息按 Topic 组织。\n\n" +
+	"## 1. 生产者\n\n" +
+	"生产者通过 `acks` 参数控制**消息确认**。ISR 是同步副本集合，ISR 中的副本都确认后才算写入成功。" +
+	strings.Repeat("生产者把消息发送到分区的 leader 副本，", 10) + "\n\n" +
+	"```go\nproducer := kafka.NewProducer(config)\nproducer.Send(msg)\n```\n\n" +
+	"### 1.1 分区策略\n\n" + strings.Repeat("按 key 的哈希选择分区，", 10) + "\n\n" +
+	"## 2. 消费者\n\n" + strings.Repeat("消费者组内的消费者分摊分区，", 10) + "\n\n" +
+	"```bash\n# 标题分割器不会把代码块中的注释当成标题\nkafka-console-consumer --topic test\n```\n\n" +
+	"## 3. 运维\n\n定期检查磁盘。\n"
+
+// TestCheck 测试按标题对齐后报告被删除的标题、丢失的术语和代码块以及缩水的章节
+func TestCheck(t *testing.T) {
+	rewrite := "# Kafka 入门 🚀\n\n" +
+		"Kafka 是一个分布式消息系统，消息按 Topic 组织。\n\n" +
+		"## 第一章 生产者：消息怎么发出去\n\n" +
+		"本节你会学到什么：`acks` 参数和**消息确认**。" + strings.Repeat("生产者把消息发送到分区的 leader 副本，", 10) + "\n\n" +
+		"```go\n// 创建生产者\nproducer := kafka.NewProducer(config)\n// 发送消息\nproducer.Send(msg)\n```\n\n" +
+		"### 1.1 分区策略\n\n" + strings.Repeat("按 key 的哈希选择分区，", 10) + "\n\n" +
+		"## 2. 消费者\n\n消费者组分摊分区。\n"
+
+	r, err := Check(context.Background(), source, rewrite, config.Default().Coverage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SourceHeadings != 5 || len(r.DroppedHeadings) != 1 || r.DroppedHeadings[0].String() != "## 3. 运维" {
+		t.Errorf("被删除的标题不对: %d %+v", r.SourceHeadings, r.DroppedHeadings)
+	}
+	if !slices.Equal(r.MissingTerms, []string{"ISR"}) || r.Terms != 4 {
+		t.Errorf("丢失的术语不对: %v（共 %d 个）", r.MissingTerms, r.Terms)
+	}
+	if r.CodeBlocks != 2 || len(r.MissingCode) != 1 || r.MissingCode[0].Lang != "bash" || r.MissingCode[0].Line != 22 {
+		t.Errorf("加注释的代码块应该算保留，bash 代码块丢失: %+v", r.MissingCode)
+	}
+	if len(r.Shrunk) != 1 || r.Shrunk[0].Title != "2. 消费者" {
+		t.Errorf("缩水的章节不对: %+v", r.Shrunk)
+	}
+
+	text := r.Format()
+	for _, s := range []string{
+		`- 标题被删除：源文档的 "## 3. 运维" 在改写稿中找不到对应的标题`,
+		"- 关键术语丢失（1/4 个）：ISR",
+		"- 代码块丢失：源文档第 22 行的 bash 代码块（# 标题分割器不会把代码块中的注释当成标题）在改写稿中找不到",
+		`- 章节缩水："2. 消费者"`,
+	} {
+		if !strings.Contains(text, s) {
+			t.Errorf("报告缺少 %q:\n%s", s, text)
+		}
+	}
+
+	if r, _ := Check(context.Background(), source, source, config.Default().Coverage); !r.OK() || r.Format() != "" {
+		t.Errorf("与源文档相同时不应该有问题:\n%s", r.Format())
+	}
+	cfg := config.Default().Coverage
+	cfg.Checks = []string{CheckHeadings}
+	if r, _ := Check(context.Background(), source, rewrite, cfg); len(r.MissingTerms) != 0 || len(r.MissingCode) != 0 || len(r.Shrunk) != 0 {
+		t.Errorf("只启用 headings 时不应该检查其他项: %+v", r)
+	}
+}
@@ -0,0 +1,135 @@
+// Package feishu 封装飞书开放平台的鉴权和云文档接口
+package feishu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"eino_test/config"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkdocx "github.com/larksuite/oapi-sdk-go/v3/service/docx/v1"
+)
+
+// 鉴权模式
+const (
+	AuthModeTenant = "tenant"
+	AuthModeUser   = "user"
+)
+
+// ErrNotConfigured 没有配置飞书应用凭证
+var ErrNotConfigured = errors.New("未配置飞书应用凭证，请设置 FEISHU_APP_ID 和 FEISHU_APP_SECRET（或配置文件中的 feishu.app_id / feishu.app_secret）")
+
+// codeNotFound 文档或块不存在的错误码
+const codeNotFound = 1770002
+
+// APIError 飞书接口返回的业务错误
+type APIError struct {
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("飞书返回错误 %d: %s", e.Code, e.Msg)
+}
+
+func apiError(err larkcore.CodeError) error {
+	return &APIError{Code: err.Code, Msg: err.Msg}
+}
+
+// IsNotFound 判断错误是否表示文档或块不存在，例如文档已经被删除
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == codeNotFound
+}
+
+// Client 飞书接口客户端。tenant 模式由 SDK 自动获取并缓存 tenant_access_token，
+// user 模式在每次请求前从 OAuth 取得（必要时刷新）user_access_token
+type Client struct {
+	cfg   config.FeishuConfig
+	lark  *lark.Client
+	oauth *OAuth
+}
+
+// NewClient 根据配置创建客户端
+func NewClient(cfg config.FeishuConfig) (*Client, error) {
+	if cfg.AppID == "" || cfg.AppSecret == "" {
+		return nil, ErrNotConfigured
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = lark.FeishuBaseUrl
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	c := &Client{
+		cfg:  cfg,
+		lark: lark.NewClient(cfg.AppID, cfg.AppSecret, lark.WithOpenBaseUrl(cfg.BaseURL)),
+	}
+	switch cfg.AuthMode {
+	case "", AuthModeTenant:
+	case AuthModeUser:
+		c.oauth = NewOAuth(cfg, &FileTokenStore{Path: cfg.TokenFile})
+	default:
+		return nil, fmt.Errorf("未知的飞书鉴权模式: %q", cfg.AuthMode)
+	}
+	return c, nil
+}
+
+// OAuth 返回用户授权客户端，tenant 模式下为 nil
+func (c *Client) OAuth() *OAuth {
+	return c.oauth
+}
+
+// FolderToken 配置中的默认文件夹
+func (c *Client) FolderToken() string {
+	return c.cfg.FolderToken
+}
+
+// requestOptions 返回当前鉴权模式下的请求参数
+func (c *Client) requestOptions(ctx context.Context) ([]larkcore.RequestOptionFunc, error) {
+	if c.oauth == nil {
+		return nil, nil
+	}
+	token, err := c.oauth.AccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []larkcore.RequestOptionFunc{larkcore.WithUserAccessToken(token)}, nil
+}
+
+// CreateDocument 在文件夹中创建空白文档并返回文档 ID，folderToken 为空时使用配置中的默认文件夹
+func (c *Client) CreateDocument(ctx context.Context, folderToken, title string) (string, error) {
+	if folderToken == "" {
+		folderToken = c.cfg.FolderToken
+	}
+	opts, err := c.requestOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req := larkdocx.NewCreateDocumentReqBuilder().
+		Body(larkdocx.NewCreateDocumentReqBodyBuilder().
+			FolderToken(folderToken).
+			Title(title).
+			Build()).
+		Build()
+	resp, err := c.lark.Docx.V1.Document.Create(ctx, req, opts...)
+	if err != nil {
+		return "", err
+	}
+	if !resp.Success() {
+		return "", fmt.Errorf("创建飞书文档失败: %w", apiError(resp.CodeError))
+	}
+	if resp.Data == nil || resp.Data.Document == nil || resp.Data.Document.DocumentId == nil {
+		return "", errors.New("创建飞书文档失败: 无法获取文档 ID")
+	}
+	return *resp.Data.Document.DocumentId, nil
+}
+
+// DocumentURL 返回文档的访问链接
+func DocumentURL(documentID string) string {
+	return fmt.Sprintf("https://feishu.cn/docx/%s", documentID)
+}
@@ -0,0 +1,443 @@
+// Package feishutest 提供用于测试的本地飞书开放平台模拟服务
+package feishutest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	larkdocx "github.com/larksuite/oapi-sdk-go/v3/service/docx/v1"
+)
+
+// Server 模拟飞书的鉴权和云文档接口，只实现本项目用到的部分
+type Server struct {
+	*httptest.Server
+
+	AppID     string
+	AppSecret string
+	// AccessTokenTTL 签发的 user_access_token 有效期（秒）
+	AccessTokenTTL int
+	// AuthCode Exchange 时接受的授权码
+	AuthCode string
+	// MaxChildren / MaxBlocks 创建嵌套块接口单次请求的顶层块和总块数上限
+	MaxChildren int
+	MaxBlocks   int
+	// AppendLimit 大于 0 时创建嵌套块接口只有前 AppendLimit 次请求成功，之后都返回错误，用于模拟写入中途失败
+	AppendLimit int
+
+	mu            sync.Mutex
+	seq           int
+	tenantToken   string
+	userTokens    map[string]bool
+	refreshTokens map[string]bool
+	calls         map[string]int
+	appends       int
+	documents     map[string]*Document
+}
+
+// Document 模拟服务中保存的文档
+type Document struct {
+	ID          string
+	Title       string
+	FolderToken string
+	// Token 创建文档时使用的访问凭证
+	Token string
+
+	// blocks 以块 ID 索引的全部块，根节点（页面块）的 ID 与文档 ID 相同
+	blocks map[string]*larkdocx.Block
+}
+
+// NewServer 启动模拟服务，使用完后需要调用 Close
+func NewServer(appID, appSecret string) *Server {
+	s := &Server{
+		AppID:          appID,
+		AppSecret:      appSecret,
+		AccessTokenTTL: 7200,
+		AuthCode:       "test-code",
+		MaxChildren:    50,
+		MaxBlocks:      1000,
+		tenantToken:    "t-" + appID,
+		userTokens:     map[string]bool{},
+		refreshTokens:  map[string]bool{},
+		calls:          map[string]int{},
+		documents:      map[string]*Document{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /open-apis/auth/v3/tenant_access_token/internal", s.handleTenantToken)
+	mux.HandleFunc("POST /open-apis/authen/v2/oauth/token", s.handleOAuthToken)
+	mux.HandleFunc("POST /open-apis/docx/v1/documents", s.authorized(s.handleCreateDocument))
+	mux.HandleFunc("POST /open-apis/docx/v1/documents/{document_id}/blocks/{block_id}/descendant", s.authorized(s.handleCreateDescendants))
+	mux.HandleFunc("GET /open-apis/docx/v1/documents/{document_id}/blocks/{block_id}", s.authorized(s.handleGetBlock))
+	mux.HandleFunc("PATCH /open-apis/docx/v1/documents/{document_id}/blocks/{block_id}", s.authorized(s.handlePatchBlock))
+	mux.HandleFunc("DELETE /open-apis/docx/v1/documents/{document_id}/blocks/{block_id}/children/batch_delete", s.authorized(s.handleBatchDelete))
+	s.Server = httptest.NewServer(s.count(mux))
+	return s
+}
+
+// Calls 返回某个路径被请求的次数
+func (s *Server) Calls(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[path]
+}
+
+// Documents 返回已创建的文档
+func (s *Server) Documents() []*Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs := make([]*Document, 0, len(s.documents))
+	for _, doc := range s.documents {
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// Blocks 按文档顺序（深度优先）返回文档中除根节点以外的所有块
+func (s *Server) Blocks(documentID string) []*larkdocx.Block {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.documents[documentID]
+	if !ok {
+		return nil
+	}
+	var out []*larkdocx.Block
+	var walk func(id string)
+	walk = func(id string) {
+		for _, child := range doc.blocks[id].Children {
+			out = append(out, doc.blocks[child])
+			walk(child)
+		}
+	}
+	walk(documentID)
+	return out
+}
+
+// DeleteDocument 删除文档，模拟用户在飞书中删除了文档
+func (s *Server) DeleteDocument(documentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.documents, documentID)
+}
+
+// IssueUserToken 直接签发一对用户 token，用于构造测试初始状态
+func (s *Server) IssueUserToken() (accessToken, refreshToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issueLocked()
+}
+
+func (s *Server) issueLocked() (string, string) {
+	s.seq++
+	access := fmt.Sprintf("u-%d", s.seq)
+	refresh := fmt.Sprintf("r-%d", s.seq)
+	s.userTokens[access] = true
+	s.refreshTokens[refresh] = true
+	return access, refresh
+}
+
+func (s *Server) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.calls[r.URL.Path]++
+		s.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized 校验 Authorization 头中的 tenant 或 user token
+func (s *Server) authorized(next func(w http.ResponseWriter, r *http.Request, token string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.mu.Lock()
+		ok := token == s.tenantToken || s.userTokens[token]
+		s.mu.Unlock()
+		if !ok {
+			writeJSON(w, map[string]any{"code": 99991663, "msg": "invalid access token"})
+			return
+		}
+		next(w, r, token)
+	}
+}
+
+func (s *Server) handleTenantToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AppID     string `json:"app_id"`
+		AppSecret string `json:"app_secret"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.AppID != s.AppID || req.AppSecret != s.AppSecret {
+		writeJSON(w, map[string]any{"code": 10014, "msg": "app secret invalid"})
+		return
+	}
+	writeJSON(w, map[string]any{"code": 0, "msg": "ok", "tenant_access_token": s.tenantToken, "expire": 7200})
+}
+
+func (s *Server) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	var req map[string]string
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req["client_id"] != s.AppID || req["client_secret"] != s.AppSecret {
+		writeJSON(w, map[string]any{"code": 20002, "error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch req["grant_type"] {
+	case "authorization_code":
+		if req["code"] != s.AuthCode {
+			writeJSON(w, map[string]any{"code": 20003, "error": "invalid_grant", "error_description": "授权码无效"})
+			return
+		}
+	case "refresh_token":
+		// refresh_token 只能使用一次，刷新后旧的 access token 也随之失效
+		if !s.refreshTokens[req["refresh_token"]] {
+			writeJSON(w, map[string]any{"code": 20026, "error": "invalid_grant", "error_description": "refresh_token 无效"})
+			return
+		}
+		delete(s.refreshTokens, req["refresh_token"])
+		delete(s.userTokens, "u-"+strings.TrimPrefix(req["refresh_token"], "r-"))
+	default:
+		writeJSON(w, map[string]any{"code": 20001, "error": "unsupported_grant_type"})
+		return
+	}
+
+	access, refresh := s.issueLocked()
+	writeJSON(w, map[string]any{
+		"code":                     0,
+		"access_token":             access,
+		"expires_in":               s.AccessTokenTTL,
+		"refresh_token":            refresh,
+		"refresh_token_expires_in": 604800,
+		"token_type":               "Bearer",
+	})
+}
+
+func (s *Server) handleCreateDocument(w http.ResponseWriter, r *http.Request, token string) {
+	var req struct {
+		FolderToken string `json:"folder_token"`
+		Title       string `json:"title"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	s.mu.Lock()
+	s.seq++
+	doc := &Document{
+		ID:          fmt.Sprintf("doc%d", s.seq),
+		Title:       req.Title,
+		FolderToken: req.FolderToken,
+		Token:       token,
+	}
+	pageType := 1
+	doc.blocks = map[string]*larkdocx.Block{
+		doc.ID: {BlockId: &doc.ID, BlockType: &pageType, Page: &larkdocx.Text{}},
+	}
+	s.documents[doc.ID] = doc
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{
+		"code": 0,
+		"msg":  "success",
+		"data": map[string]any{
+			"document": map[string]any{"document_id": doc.ID, "revision_id": 1, "title": doc.Title},
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// handleCreateDescendants 模拟创建嵌套块接口：校验临时 ID 构成的树结构，分配真实 ID 后追加到父块末尾
+func (s *Server) handleCreateDescendants(w http.ResponseWriter, r *http.Request, token string) {
+	var req larkdocx.CreateDocumentBlockDescendantReqBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, map[string]any{"code": 1770001, "msg": "invalid param: " + err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, parent := s.lookupBlock(w, r)
+	if parent == nil {
+		return
+	}
+	if s.AppendLimit > 0 && s.appends >= s.AppendLimit {
+		writeJSON(w, map[string]any{"code": 1770000, "msg": "internal error"})
+		return
+	}
+	s.appends++
+	if len(req.ChildrenId) > s.MaxChildren || len(req.Descendants) > s.MaxBlocks {
+		writeJSON(w, map[string]any{"code": 1770001, "msg": fmt.Sprintf(
+			"invalid param: too many blocks (children %d, descendants %d)", len(req.ChildrenId), len(req.Descendants))})
+		return
+	}
+	if err := validateTree(req.ChildrenId, req.Descendants); err != nil {
+		writeJSON(w, map[string]any{"code": 1770001, "msg": "invalid param: " + err.Error()})
+		return
+	}
+
+	ids := map[string]string{}
+	for _, b := range req.Descendants {
+		s.seq++
+		ids[*b.BlockId] = fmt.Sprintf("blk%d", s.seq)
+	}
+	var relations []map[string]string
+	for _, b := range req.Descendants {
+		real := ids[*b.BlockId]
+		relations = append(relations, map[string]string{"temporary_block_id": *b.BlockId, "block_id": real})
+		b.BlockId = &real
+		for i, child := range b.Children {
+			b.Children[i] = ids[child]
+		}
+		doc.blocks[real] = b
+	}
+	for _, b := range req.Descendants {
+		for _, child := range b.Children {
+			doc.blocks[child].ParentId = b.BlockId
+		}
+	}
+	for _, child := range req.ChildrenId {
+		parent.Children = append(parent.Children, ids[child])
+		doc.blocks[ids[child]].ParentId = parent.BlockId
+	}
+
+	writeJSON(w, map[string]any{
+		"code": 0,
+		"msg":  "success",
+		"data": map[string]any{"block_id_relations": relations, "document_revision_id": s.seq},
+	})
+}
+
+// validateTree 校验每个临时 ID 都有对应的块、每个非顶层块恰好被引用一次，以及表格单元格数量
+func validateTree(childrenID []string, descendants []*larkdocx.Block) error {
+	byID := map[string]*larkdocx.Block{}
+	for _, b := range descendants {
+		if b.BlockId == nil || b.BlockType == nil {
+			return fmt.Errorf("block_id 和 block_type 不能为空")
+		}
+		if _, dup := byID[*b.BlockId]; dup {
+			return fmt.Errorf("重复的 block_id %s", *b.BlockId)
+		}
+		byID[*b.BlockId] = b
+	}
+
+	refs := map[string]int{}
+	for _, id := range childrenID {
+		refs[id]++
+	}
+	for _, b := range descendants {
+		for _, child := range b.Children {
+			refs[child]++
+		}
+		if *b.BlockType == 31 {
+			if b.Table == nil || b.Table.Property == nil {
+				return fmt.Errorf("表格 %s 缺少 property", *b.BlockId)
+			}
+			p := b.Table.Property
+			if p.RowSize == nil || p.ColumnSize == nil || *p.RowSize**p.ColumnSize != len(b.Children) {
+				return fmt.Errorf("表格 %s 的单元格数量与行列数不符", *b.BlockId)
+			}
+		}
+	}
+	for id, n := range refs {
+		if _, ok := byID[id]; !ok {
+			return fmt.Errorf("引用了不存在的 block_id %s", id)
+		}
+		if n != 1 {
+			return fmt.Errorf("block_id %s 被引用了 %d 次", id, n)
+		}
+	}
+	if len(refs) != len(descendants) {
+		return fmt.Errorf("存在没有被引用的块")
+	}
+	return nil
+}
+
+// lookupBlock 查找请求路径中的文档和块，不存在时写入错误响应并返回 nil
+func (s *Server) lookupBlock(w http.ResponseWriter, r *http.Request) (*Document, *larkdocx.Block) {
+	doc, ok := s.documents[r.PathValue("document_id")]
+	if !ok {
+		writeJSON(w, map[string]any{"code": 1770002, "msg": "document not found"})
+		return nil, nil
+	}
+	block, ok := doc.blocks[r.PathValue("block_id")]
+	if !ok {
+		writeJSON(w, map[string]any{"code": 1770002, "msg": "block not found"})
+		return nil, nil
+	}
+	return doc, block
+}
+
+func (s *Server) handleGetBlock(w http.ResponseWriter, r *http.Request, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, block := s.lookupBlock(w, r); block != nil {
+		writeJSON(w, map[string]any{"code": 0, "msg": "success", "data": map[string]any{"block": block}})
+	}
+}
+
+// handlePatchBlock 模拟更新块接口，只支持更新根节点（页面块）的文字，即修改文档标题
+func (s *Server) handlePatchBlock(w http.ResponseWriter, r *http.Request, token string) {
+	var req larkdocx.UpdateBlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UpdateTextElements == nil {
+		writeJSON(w, map[string]any{"code": 1770001, "msg": "invalid param"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, block := s.lookupBlock(w, r)
+	if block == nil {
+		return
+	}
+	if block.Page == nil {
+		writeJSON(w, map[string]any{"code": 1770001, "msg": "invalid param: 只支持更新页面块"})
+		return
+	}
+	block.Page.Elements = req.UpdateTextElements.Elements
+	var title strings.Builder
+	for _, el := range req.UpdateTextElements.Elements {
+		if el.TextRun != nil && el.TextRun.Content != nil {
+			title.WriteString(*el.TextRun.Content)
+		}
+	}
+	doc.Title = title.String()
+	writeJSON(w, map[string]any{"code": 0, "msg": "success", "data": map[string]any{"block": block, "document_revision_id": s.seq}})
+}
+
+// handleBatchDelete 删除父块下 [start_index, end_index) 范围内的子块及其子孙
+func (s *Server) handleBatchDelete(w http.ResponseWriter, r *http.Request, token string) {
+	var req struct {
+		StartIndex int `json:"start_index"`
+		EndIndex   int `json:"end_index"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, parent := s.lookupBlock(w, r)
+	if parent == nil {
+		return
+	}
+	if req.StartIndex < 0 || req.EndIndex > len(parent.Children) || req.StartIndex >= req.EndIndex {
+		writeJSON(w, map[string]any{"code": 1770001, "msg": "invalid param: index out of range"})
+		return
+	}
+
+	var remove func(id string)
+	remove = func(id string) {
+		for _, child := range doc.blocks[id].Children {
+			remove(child)
+		}
+		delete(doc.blocks, id)
+	}
+	for _, id := range parent.Children[req.StartIndex:req.EndIndex] {
+		remove(id)
+	}
+	parent.Children = append(parent.Children[:req.StartIndex:req.StartIndex], parent.Children[req.EndIndex:]...)
+	writeJSON(w, map[string]any{"code": 0, "msg": "success", "data": map[string]any{"document_revision_id": s.seq}})
+}
@@ -0,0 +1,94 @@
+package feishu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DocRecord 源文件对应的飞书文档
+type DocRecord struct {
+	Source     string    `json:"source"`
+	DocumentID string    `json:"document_id"`
+	Title      string    `json:"title"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// mappingMu 同一进程内可能有多个改写任务同时写映射文件，读改写整体加锁
+var mappingMu sync.Mutex
+
+// LookupDocument 在映射文件中查找源文件对应的飞书文档，source 会被转换为绝对路径
+func LookupDocument(path, source string) (DocRecord, bool, error) {
+	mappingMu.Lock()
+	defer mappingMu.Unlock()
+
+	records, err := loadMapping(path)
+	if err != nil {
+		return DocRecord{}, false, err
+	}
+	rec, ok := records[absPath(source)]
+	return rec, ok, nil
+}
+
+// RecordDocument 记录源文件对应的飞书文档并立即写回映射文件
+func RecordDocument(path string, rec DocRecord) error {
+	mappingMu.Lock()
+	defer mappingMu.Unlock()
+
+	records, err := loadMapping(path)
+	if err != nil {
+		return err
+	}
+	rec.Source = absPath(rec.Source)
+	rec.UpdatedAt = time.Now()
+	records[rec.Source] = rec
+
+	list := make([]DocRecord, 0, len(records))
+	for _, r := range records {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Source < list[j].Source })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建映射文件目录失败: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入映射文件失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadMapping(path string) (map[string]DocRecord, error) {
+	records := map[string]DocRecord{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取映射文件失败: %w", err)
+	}
+	var list []DocRecord
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("解析映射文件 %s 失败: %w", path, err)
+	}
+	for _, r := range list {
+		records[r.Source] = r
+	}
+	return records, nil
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
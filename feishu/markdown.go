@@ -0,0 +1,525 @@
+package feishu
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	larkdocx "github.com/larksuite/oapi-sdk-go/v3/service/docx/v1"
+)
+
+// 飞书文档的块类型，见 https://open.feishu.cn/document/server-docs/docs/docs/docx-v1/data-structure/block
+const (
+	BlockTypePage      = 1
+	BlockTypeText      = 2
+	BlockTypeHeading1  = 3
+	BlockTypeBullet    = 12
+	BlockTypeOrdered   = 13
+	BlockTypeCode      = 14
+	BlockTypeQuote     = 15
+	BlockTypeDivider   = 22
+	BlockTypeTable     = 31
+	BlockTypeTableCell = 32
+)
+
+// codeLanguages Markdown 代码块语言标识到飞书代码块语言枚举的映射，未列出的语言按纯文本处理
+var codeLanguages = map[string]int{
+	"plaintext": 1, "text": 1, "txt": 1,
+	"bash": 7, "csharp": 8, "cs": 8, "c#": 8, "cpp": 9, "c++": 9, "c": 10, "css": 12,
+	"dart": 15, "dockerfile": 18, "erlang": 19, "go": 22, "golang": 22, "groovy": 23,
+	"html": 24, "http": 26, "haskell": 27, "json": 28, "java": 29,
+	"javascript": 30, "js": 30, "kotlin": 32, "latex": 33, "lisp": 34, "lua": 36,
+	"matlab": 37, "makefile": 38, "markdown": 39, "md": 39, "nginx": 40,
+	"objective-c": 41, "objc": 41, "php": 43, "perl": 44, "powershell": 46,
+	"protobuf": 48, "proto": 48, "python": 49, "py": 49, "r": 50, "ruby": 52,
+	"rust": 53, "scss": 55, "sql": 56, "scala": 57, "shell": 60, "sh": 60, "zsh": 60,
+	"swift": 61, "thrift": 62, "typescript": 63, "ts": 63, "xml": 66,
+	"yaml": 67, "yml": 67, "cmake": 68, "diff": 69, "graphql": 71,
+	"properties": 73, "solidity": 74, "toml": 75,
+}
+
+// Node 转换后的文档块，Children 为嵌套的子块（例如表格单元格、嵌套列表）
+type Node struct {
+	Block    *larkdocx.Block
+	Children []*Node
+}
+
+// Count 返回节点及其所有子孙节点的数量
+func (n *Node) Count() int {
+	count := 1
+	for _, child := range n.Children {
+		count += child.Count()
+	}
+	return count
+}
+
+var (
+	headingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	fenceRe     = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([^\\s`]*)")
+	dividerRe   = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	listItemRe  = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	tableSepRe  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	quoteLineRe = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+)
+
+// ConvertMarkdown 把 Markdown 转换为飞书文档块。
+// 支持标题、段落、加粗/斜体/删除线/行内代码/链接、有序和无序列表（含嵌套）、引用、表格、分割线和带语言的代码块
+func ConvertMarkdown(markdown string) []*Node {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	p := &mdParser{lines: lines}
+	return p.parse()
+}
+
+type mdParser struct {
+	lines []string
+	pos   int
+}
+
+func (p *mdParser) parse() []*Node {
+	var nodes []*Node
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			nodes = append(nodes, textNode(BlockTypeText, strings.Join(paragraph, " ")))
+			paragraph = nil
+		}
+	}
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+			p.pos++
+		case fenceRe.MatchString(line):
+			flush()
+			nodes = append(nodes, p.parseCode())
+		case headingRe.MatchString(trimmed):
+			flush()
+			m := headingRe.FindStringSubmatch(trimmed)
+			nodes = append(nodes, textNode(BlockTypeHeading1+len(m[1])-1, m[2]))
+			p.pos++
+		case dividerRe.MatchString(line):
+			flush()
+			nodes = append(nodes, &Node{Block: &larkdocx.Block{
+				BlockType: intPtr(BlockTypeDivider),
+				Divider:   &larkdocx.Divider{},
+			}})
+			p.pos++
+		case quoteLineRe.MatchString(line):
+			flush()
+			nodes = append(nodes, p.parseQuote()...)
+		case listItemRe.MatchString(line):
+			flush()
+			nodes = append(nodes, p.parseList(indentWidth(line))...)
+		case strings.HasPrefix(trimmed, "|") && p.pos+1 < len(p.lines) && tableSepRe.MatchString(p.lines[p.pos+1]):
+			flush()
+			nodes = append(nodes, p.parseTable())
+		default:
+			paragraph = append(paragraph, trimmed)
+			p.pos++
+		}
+	}
+	flush()
+	return nodes
+}
+
+// parseCode 解析围栏代码块，没有闭合的代码块一直延续到文档末尾
+func (p *mdParser) parseCode() *Node {
+	m := fenceRe.FindStringSubmatch(p.lines[p.pos])
+	fence, lang := m[1], strings.ToLower(m[2])
+	p.pos++
+
+	var body []string
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		p.pos++
+		if t := strings.TrimSpace(line); strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+			break
+		}
+		body = append(body, line)
+	}
+
+	language, ok := codeLanguages[lang]
+	if !ok {
+		language = 1
+	}
+	content := strings.Join(body, "\n")
+	var elements []*larkdocx.TextElement
+	if content != "" {
+		elements = []*larkdocx.TextElement{textRun(content, style{})}
+	}
+	return &Node{Block: &larkdocx.Block{
+		BlockType: intPtr(BlockTypeCode),
+		Code: &larkdocx.Text{
+			Style:    &larkdocx.TextStyle{Language: intPtr(language), Wrap: boolPtr(false)},
+			Elements: elements,
+		},
+	}}
+}
+
+// parseQuote 解析连续的引用行，引用内的空行分隔出多个引用块
+func (p *mdParser) parseQuote() []*Node {
+	var nodes []*Node
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			nodes = append(nodes, textNode(BlockTypeQuote, strings.Join(paragraph, " ")))
+			paragraph = nil
+		}
+	}
+	for p.pos < len(p.lines) {
+		m := quoteLineRe.FindStringSubmatch(p.lines[p.pos])
+		if m == nil {
+			break
+		}
+		p.pos++
+		text := strings.TrimSpace(m[1])
+		// 引用内的标题和列表标记只保留文字
+		if h := headingRe.FindStringSubmatch(text); h != nil {
+			text = h[2]
+		}
+		if text == "" {
+			flush()
+			continue
+		}
+		paragraph = append(paragraph, text)
+	}
+	flush()
+	return nodes
+}
+
+// parseList 解析缩进不小于 indent 的列表项，缩进更深的列表项作为子节点
+func (p *mdParser) parseList(indent int) []*Node {
+	var items []*Node
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		m := listItemRe.FindStringSubmatch(line)
+		if m == nil {
+			// 紧跟在列表项后、缩进更深的非空行是上一项的续行
+			if len(items) > 0 && strings.TrimSpace(line) != "" && indentWidth(line) > indent {
+				last := items[len(items)-1]
+				appendText(last.Block, " "+strings.TrimSpace(line))
+				p.pos++
+				continue
+			}
+			break
+		}
+
+		width := indentWidth(m[1])
+		if width < indent {
+			break
+		}
+		if width > indent && len(items) > 0 {
+			last := items[len(items)-1]
+			last.Children = append(last.Children, p.parseList(width)...)
+			continue
+		}
+
+		blockType := BlockTypeBullet
+		if m[2][0] >= '0' && m[2][0] <= '9' {
+			blockType = BlockTypeOrdered
+		}
+		items = append(items, textNode(blockType, m[3]))
+		p.pos++
+	}
+	return items
+}
+
+// parseTable 解析 GFM 表格，每行的单元格数按表头对齐，多余的截断、不足的补空
+func (p *mdParser) parseTable() *Node {
+	header := splitTableRow(p.lines[p.pos])
+	p.pos += 2
+	rows := [][]string{header}
+	for p.pos < len(p.lines) && strings.HasPrefix(strings.TrimSpace(p.lines[p.pos]), "|") {
+		rows = append(rows, splitTableRow(p.lines[p.pos]))
+		p.pos++
+	}
+
+	columns := len(header)
+	table := &Node{Block: &larkdocx.Block{
+		BlockType: intPtr(BlockTypeTable),
+		Table: &larkdocx.Table{Property: &larkdocx.TableProperty{
+			RowSize:    intPtr(len(rows)),
+			ColumnSize: intPtr(columns),
+			HeaderRow:  boolPtr(true),
+		}},
+	}}
+	for _, row := range rows {
+		for col := 0; col < columns; col++ {
+			var cell string
+			if col < len(row) {
+				cell = row[col]
+			}
+			table.Children = append(table.Children, &Node{
+				Block:    &larkdocx.Block{BlockType: intPtr(BlockTypeTableCell), TableCell: &larkdocx.TableCell{}},
+				Children: []*Node{textNode(BlockTypeText, cell)},
+			})
+		}
+	}
+	return table
+}
+
+// splitTableRow 按未转义的 | 切分表格行
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	inCode := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case c == '`':
+			inCode = !inCode
+			cell.WriteByte(c)
+		case c == '|' && !inCode:
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(c)
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// indentWidth 计算行首缩进宽度，tab 按 4 个空格计算
+func indentWidth(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// textNode 创建文本类的块（文本、标题、列表、引用），块类型决定内容放在哪个字段
+func textNode(blockType int, markdown string) *Node {
+	text := &larkdocx.Text{Elements: parseInline(markdown)}
+	block := &larkdocx.Block{BlockType: intPtr(blockType)}
+	switch blockType {
+	case BlockTypeText:
+		block.Text = text
+	case BlockTypeHeading1:
+		block.Heading1 = text
+	case BlockTypeHeading1 + 1:
+		block.Heading2 = text
+	case BlockTypeHeading1 + 2:
+		block.Heading3 = text
+	case BlockTypeHeading1 + 3:
+		block.Heading4 = text
+	case BlockTypeHeading1 + 4:
+		block.Heading5 = text
+	case BlockTypeHeading1 + 5:
+		block.Heading6 = text
+	case BlockTypeBullet:
+		block.Bullet = text
+	case BlockTypeOrdered:
+		block.Ordered = text
+	case BlockTypeQuote:
+		block.Quote = text
+	}
+	return &Node{Block: block}
+}
+
+// BlockText 返回文本类块的内容字段，其他类型的块返回 nil
+func BlockText(block *larkdocx.Block) *larkdocx.Text {
+	for _, text := range []*larkdocx.Text{
+		block.Text, block.Heading1, block.Heading2, block.Heading3, block.Heading4, block.Heading5,
+		block.Heading6, block.Heading7, block.Heading8, block.Heading9,
+		block.Bullet, block.Ordered, block.Code, block.Quote, block.Todo,
+	} {
+		if text != nil {
+			return text
+		}
+	}
+	return nil
+}
+
+// appendText 把 Markdown 片段追加到文本块末尾
+func appendText(block *larkdocx.Block, markdown string) {
+	if text := BlockText(block); text != nil {
+		text.Elements = append(text.Elements, parseInline(markdown)...)
+	}
+}
+
+// style 行内样式
+type style struct {
+	bold, italic, strike, code bool
+	link                       string
+}
+
+func (s style) toLark() *larkdocx.TextElementStyle {
+	if s == (style{}) {
+		return nil
+	}
+	st := &larkdocx.TextElementStyle{}
+	if s.bold {
+		st.Bold = boolPtr(true)
+	}
+	if s.italic {
+		st.Italic = boolPtr(true)
+	}
+	if s.strike {
+		st.Strikethrough = boolPtr(true)
+	}
+	if s.code {
+		st.InlineCode = boolPtr(true)
+	}
+	if s.link != "" {
+		// 飞书要求链接地址经过 URL 编码
+		st.Link = &larkdocx.Link{Url: strPtr(url.QueryEscape(s.link))}
+	}
+	return st
+}
+
+func textRun(content string, s style) *larkdocx.TextElement {
+	return &larkdocx.TextElement{TextRun: &larkdocx.TextRun{
+		Content:          strPtr(content),
+		TextElementStyle: s.toLark(),
+	}}
+}
+
+// parseInline 解析行内样式。没有闭合的标记按普通文字处理
+func parseInline(s string) []*larkdocx.TextElement {
+	var elements []*larkdocx.TextElement
+	var buf strings.Builder
+	cur := style{}
+	emit := func(st style) {
+		if buf.Len() > 0 {
+			elements = append(elements, textRun(buf.String(), st))
+			buf.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_~[]()#|<>", rune(rest[1])):
+			buf.WriteByte(rest[1])
+			i += 2
+
+		case rest[0] == '`':
+			// 行内代码：找到相同长度的反引号作为结尾
+			n := len(rest) - len(strings.TrimLeft(rest, "`"))
+			marker := rest[:n]
+			end := strings.Index(rest[n:], marker)
+			if end < 0 {
+				buf.WriteString(marker)
+				i += n
+				continue
+			}
+			emit(cur)
+			code := cur
+			code.code = true
+			buf.WriteString(strings.TrimSpace(rest[n : n+end]))
+			emit(code)
+			i += n + end + n
+
+		case strings.HasPrefix(rest, "**"):
+			// 只识别 ** 加粗，__ 容易和 __init__ 这类标识符冲突
+			if !cur.bold && !strings.Contains(rest[2:], "**") {
+				buf.WriteString("**")
+				i += 2
+				continue
+			}
+			emit(cur)
+			cur.bold = !cur.bold
+			i += 2
+
+		case strings.HasPrefix(rest, "~~"):
+			if !cur.strike && !strings.Contains(rest[2:], "~~") {
+				buf.WriteString("~~")
+				i += 2
+				continue
+			}
+			emit(cur)
+			cur.strike = !cur.strike
+			i += 2
+
+		case rest[0] == '*':
+			// 单个 * 后面是空格时不是斜体标记，例如 a * b
+			if !cur.italic && (len(rest) < 2 || rest[1] == ' ' || !strings.Contains(rest[1:], "*")) {
+				buf.WriteByte('*')
+				i++
+				continue
+			}
+			emit(cur)
+			cur.italic = !cur.italic
+			i++
+
+		case rest[0] == '[':
+			text, href, n, ok := parseLink(rest)
+			if !ok {
+				buf.WriteByte('[')
+				i++
+				continue
+			}
+			emit(cur)
+			linked := cur
+			linked.link = href
+			for _, el := range parseInline(text) {
+				st := linked
+				if el.TextRun.TextElementStyle != nil {
+					ts := el.TextRun.TextElementStyle
+					st.bold = st.bold || ts.Bold != nil
+					st.italic = st.italic || ts.Italic != nil
+					st.strike = st.strike || ts.Strikethrough != nil
+					st.code = st.code || ts.InlineCode != nil
+				}
+				elements = append(elements, textRun(*el.TextRun.Content, st))
+			}
+			i += n
+
+		default:
+			buf.WriteByte(rest[0])
+			i++
+		}
+	}
+	emit(cur)
+
+	if len(elements) == 0 {
+		return nil
+	}
+	return elements
+}
+
+// parseLink 解析 [text](url)，返回消耗的字节数
+func parseLink(s string) (text, href string, n int, ok bool) {
+	closeText := strings.Index(s, "](")
+	if closeText < 0 {
+		return "", "", 0, false
+	}
+	closeHref := strings.IndexByte(s[closeText+2:], ')')
+	if closeHref < 0 {
+		return "", "", 0, false
+	}
+	text = s[1:closeText]
+	href = strings.TrimSpace(s[closeText+2 : closeText+2+closeHref])
+	// 去掉可选的标题 [text](url "title")
+	if sp := strings.IndexByte(href, ' '); sp > 0 {
+		href = href[:sp]
+	}
+	if href == "" {
+		return "", "", 0, false
+	}
+	return text, href, closeText + 2 + closeHref + 1, true
+}
+
+func intPtr(v int) *int       { return &v }
+func boolPtr(v bool) *bool    { return &v }
+func strPtr(v string) *string { return &v }
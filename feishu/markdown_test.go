@@ -0,0 +1,162 @@
+package feishu
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+	"eino_test/feishu/feishutest"
+
+	larkdocx "github.com/larksuite/oapi-sdk-go/v3/service/docx/v1"
+)
+
+const sampleMarkdown = "# Kafka 入门\n" +
+	"\n" +
+	"Kafka 是一个**分布式**消息队列，使用 `Producer` 发送消息，详见[官方文档](https://kafka.apache.org/docs)。\n" +
+	"\n" +
+	"## 本节你会学到什么\n" +
+	"\n" +
+	"- 基本概念\n" +
+	"  - Topic 和 Partition\n" +
+	"- 消费者组\n" +
+	"\n" +
+	"1. 启动服务\n" +
+	"2. 发送消息\n" +
+	"\n" +
+	"> 类比：Topic 就像快递站的货架\n" +
+	"\n" +
+	"| 概念 | 说明 |\n" +
+	"|------|------|\n" +
+	"| Broker | 服务节点 |\n" +
+	"| Offset |\n" +
+	"\n" +
+	"---\n" +
+	"\n" +
+	"```go\n" +
+	"func main() {}\n" +
+	"```\n"
+
+// flatten 按深度优先顺序展开节点
+func flatten(nodes []*Node) []*larkdocx.Block {
+	var out []*larkdocx.Block
+	for _, n := range nodes {
+		out = append(out, n.Block)
+		out = append(out, flatten(n.Children)...)
+	}
+	return out
+}
+
+func plainText(block *larkdocx.Block) string {
+	text := BlockText(block)
+	if text == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, el := range text.Elements {
+		sb.WriteString(*el.TextRun.Content)
+	}
+	return sb.String()
+}
+
+// TestConvertMarkdown 测试各类 Markdown 结构转换为对应的飞书块
+func TestConvertMarkdown(t *testing.T) {
+	nodes := ConvertMarkdown(sampleMarkdown)
+
+	var got []string
+	for _, b := range flatten(nodes) {
+		got = append(got, fmt.Sprintf("%d:%s", *b.BlockType, plainText(b)))
+	}
+	want := []string{
+		"3:Kafka 入门",
+		"2:Kafka 是一个分布式消息队列，使用 Producer 发送消息，详见官方文档。",
+		"4:本节你会学到什么",
+		"12:基本概念",
+		"12:Topic 和 Partition",
+		"12:消费者组",
+		"13:启动服务",
+		"13:发送消息",
+		"15:类比：Topic 就像快递站的货架",
+		"31:",
+		"32:", "2:概念", "32:", "2:说明",
+		"32:", "2:Broker", "32:", "2:服务节点",
+		"32:", "2:Offset", "32:", "2:",
+		"22:",
+		"14:func main() {}",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("转换结果不符合预期:\n%s", strings.Join(got, "\n"))
+	}
+
+	// 行内样式
+	elements := nodes[1].Block.Text.Elements
+	if len(elements) != 7 {
+		t.Fatalf("段落应拆分为 7 段，实际 %d", len(elements))
+	}
+	if st := elements[1].TextRun.TextElementStyle; st == nil || st.Bold == nil {
+		t.Error("分布式 应为加粗")
+	}
+	if st := elements[3].TextRun.TextElementStyle; st == nil || st.InlineCode == nil {
+		t.Error("Producer 应为行内代码")
+	}
+	if st := elements[5].TextRun.TextElementStyle; st == nil || st.Link == nil || *st.Link.Url != "https%3A%2F%2Fkafka.apache.org%2Fdocs" {
+		t.Errorf("链接不符合预期: %+v", st)
+	}
+
+	// 嵌套列表、表格行列数和代码语言
+	if len(nodes[3].Children) != 1 {
+		t.Error("嵌套列表应作为子块")
+	}
+	table := nodes[8].Block.Table.Property
+	if *table.RowSize != 3 || *table.ColumnSize != 2 {
+		t.Errorf("表格行列数为 %dx%d", *table.RowSize, *table.ColumnSize)
+	}
+	if lang := *nodes[10].Block.Code.Style.Language; lang != 22 {
+		t.Errorf("go 代码块语言应为 22，实际 %d", lang)
+	}
+
+	// 没有闭合的标记按原文保留
+	if got := plainText(textNode(BlockTypeText, "a * b **未闭合 `x").Block); got != "a * b **未闭合 `x" {
+		t.Errorf("未闭合标记处理错误: %q", got)
+	}
+}
+
+// TestAppendMarkdownBatches 测试长文档分批写入且顺序不变
+func TestAppendMarkdownBatches(t *testing.T) {
+	srv := feishutest.NewServer("cli_blocks", "secret")
+	defer srv.Close()
+
+	client, err := NewClient(config.FeishuConfig{AppID: "cli_blocks", AppSecret: "secret", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	docID, err := client.CreateDocument(ctx, "", "批量写入")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	for i := 1; i <= 120; i++ {
+		fmt.Fprintf(&sb, "第 %d 段\n\n", i)
+	}
+	sb.WriteString(sampleMarkdown)
+
+	total, err := client.AppendMarkdown(ctx, docID, sb.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := srv.Blocks(docID)
+	if len(blocks) != total {
+		t.Fatalf("写入 %d 个块，文档中有 %d 个", total, len(blocks))
+	}
+	if plainText(blocks[0]) != "第 1 段" || plainText(blocks[119]) != "第 120 段" || *blocks[len(blocks)-1].BlockType != BlockTypeCode {
+		t.Fatal("分批写入后块的顺序不正确")
+	}
+	path := fmt.Sprintf("/open-apis/docx/v1/documents/%s/blocks/%s/descendant", docID, docID)
+	if n := srv.Calls(path); n != 3 {
+		t.Fatalf("131 个顶层块应分 3 批写入，实际 %d 批", n)
+	}
+}
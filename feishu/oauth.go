@@ -0,0 +1,157 @@
+package feishu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"eino_test/config"
+)
+
+const (
+	// oauthTokenPath 获取和刷新 user_access_token 的接口
+	oauthTokenPath = "/open-apis/authen/v2/oauth/token"
+	// authorizePath 用户授权页
+	authorizePath = "/open-apis/authen/v1/authorize"
+
+	// DefaultScope 创建和编辑云文档需要的权限，offline_access 用于获取 refresh_token
+	DefaultScope = "docx:document drive:drive offline_access"
+
+	// refreshBefore access token 剩余有效期小于该值时提前刷新
+	refreshBefore = 5 * time.Minute
+)
+
+// OAuth 管理用户身份的授权和 token 刷新
+type OAuth struct {
+	cfg        config.FeishuConfig
+	store      TokenStore
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewOAuth 创建 OAuth 客户端，token 保存在 store 中
+func NewOAuth(cfg config.FeishuConfig, store TokenStore) *OAuth {
+	return &OAuth{
+		cfg:        cfg,
+		store:      store,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		now:        time.Now,
+	}
+}
+
+// AuthorizeURL 返回用户授权页地址，用户同意授权后飞书会带着 code 和 state 跳转到 RedirectURI
+func (o *OAuth) AuthorizeURL(state string) string {
+	q := url.Values{}
+	q.Set("client_id", o.cfg.AppID)
+	q.Set("redirect_uri", o.cfg.RedirectURI)
+	q.Set("scope", DefaultScope)
+	q.Set("state", state)
+	return strings.TrimSuffix(o.cfg.BaseURL, "/") + authorizePath + "?" + q.Encode()
+}
+
+// Exchange 用授权码换取 token 并保存
+func (o *OAuth) Exchange(ctx context.Context, code string) (*Token, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	token, err := o.requestToken(ctx, map[string]string{
+		"grant_type":   "authorization_code",
+		"code":         code,
+		"redirect_uri": o.cfg.RedirectURI,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, o.store.Save(token)
+}
+
+// AccessToken 返回可用的 user_access_token，快要过期时先用 refresh_token 刷新并保存新 token
+func (o *OAuth) AccessToken(ctx context.Context) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	token, err := o.store.Load()
+	if err != nil {
+		return "", err
+	}
+	now := o.now()
+	if !token.expiresWithin(now, refreshBefore) {
+		return token.AccessToken, nil
+	}
+	if !token.canRefresh(now) {
+		return "", fmt.Errorf("飞书用户 token 已过期且无法刷新，请重新执行 feishu login")
+	}
+
+	refreshed, err := o.requestToken(ctx, map[string]string{
+		"grant_type":    "refresh_token",
+		"refresh_token": token.RefreshToken,
+	})
+	if err != nil {
+		return "", fmt.Errorf("刷新飞书用户 token 失败: %w", err)
+	}
+	if err := o.store.Save(refreshed); err != nil {
+		return "", err
+	}
+	return refreshed.AccessToken, nil
+}
+
+// tokenResponse oauth token 接口的响应
+type tokenResponse struct {
+	Code                  int    `json:"code"`
+	Error                 string `json:"error"`
+	ErrorDescription      string `json:"error_description"`
+	AccessToken           string `json:"access_token"`
+	ExpiresIn             int    `json:"expires_in"`
+	RefreshToken          string `json:"refresh_token"`
+	RefreshTokenExpiresIn int    `json:"refresh_token_expires_in"`
+}
+
+func (o *OAuth) requestToken(ctx context.Context, params map[string]string) (*Token, error) {
+	params["client_id"] = o.cfg.AppID
+	params["client_secret"] = o.cfg.AppSecret
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(o.cfg.BaseURL, "/")+oauthTokenPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	start := o.now()
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("解析 token 响应失败（HTTP %d）: %w", resp.StatusCode, err)
+	}
+	if tr.Code != 0 || tr.AccessToken == "" {
+		return nil, fmt.Errorf("飞书返回错误 %d: %s %s", tr.Code, tr.Error, tr.ErrorDescription)
+	}
+
+	token := &Token{
+		AccessToken:  tr.AccessToken,
+		RefreshToken: tr.RefreshToken,
+		// 以发起请求的时间计算过期时间，偏保守
+		ExpiresAt: start.Add(time.Duration(tr.ExpiresIn) * time.Second),
+	}
+	if tr.RefreshTokenExpiresIn > 0 {
+		token.RefreshExpiresAt = start.Add(time.Duration(tr.RefreshTokenExpiresIn) * time.Second)
+	}
+	return token, nil
+}
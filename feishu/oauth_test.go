@@ -0,0 +1,61 @@
+package feishu
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"eino_test/config"
+	"eino_test/feishu/feishutest"
+)
+
+// TestAccessTokenRefreshBeforeExpiry 测试 access token 临近过期时自动刷新并写回本地存储
+func TestAccessTokenRefreshBeforeExpiry(t *testing.T) {
+	srv := feishutest.NewServer("cli_oauth", "secret")
+	defer srv.Close()
+
+	store := &FileTokenStore{Path: filepath.Join(t.TempDir(), "token.json")}
+	access, refresh := srv.IssueUserToken()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := store.Save(&Token{AccessToken: access, RefreshToken: refresh, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	oauth := NewOAuth(config.FeishuConfig{AppID: "cli_oauth", AppSecret: "secret", BaseURL: srv.URL}, store)
+	oauth.now = func() time.Time { return now }
+
+	// 距离过期还有一小时，直接使用已有 token
+	got, err := oauth.AccessToken(context.Background())
+	if err != nil || got != access {
+		t.Fatalf("AccessToken() = %q, %v，期望 %q", got, err, access)
+	}
+
+	// 距离过期不足 refreshBefore 时提前刷新
+	now = now.Add(time.Hour - 2*time.Minute)
+	got, err = oauth.AccessToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == access {
+		t.Fatal("临近过期时应刷新 token")
+	}
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.AccessToken != got || saved.RefreshToken == refresh || !saved.ExpiresAt.Equal(now.Add(2*time.Hour)) {
+		t.Fatalf("刷新后的 token 没有正确保存: %+v", saved)
+	}
+	if n := srv.Calls("/open-apis/authen/v2/oauth/token"); n != 1 {
+		t.Fatalf("期望刷新 1 次，实际 %d 次", n)
+	}
+
+	// refresh token 也过期时要求重新登录
+	saved.ExpiresAt = now
+	saved.RefreshExpiresAt = now
+	_ = store.Save(saved)
+	if _, err := oauth.AccessToken(context.Background()); err == nil {
+		t.Fatal("refresh token 过期时应返回错误")
+	}
+}
@@ -0,0 +1,77 @@
+package feishu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoToken 本地还没有保存用户 token，需要先执行 feishu login
+var ErrNoToken = errors.New("没有找到飞书用户 token，请先执行 feishu login 完成授权")
+
+// Token 用户身份的访问凭证
+type Token struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// expiresWithin access token 是否会在 d 之内过期
+func (t *Token) expiresWithin(now time.Time, d time.Duration) bool {
+	return t.AccessToken == "" || !now.Add(d).Before(t.ExpiresAt)
+}
+
+// canRefresh refresh token 是否仍然可用
+func (t *Token) canRefresh(now time.Time) bool {
+	return t.RefreshToken != "" && (t.RefreshExpiresAt.IsZero() || now.Before(t.RefreshExpiresAt))
+}
+
+// TokenStore 保存用户 token
+type TokenStore interface {
+	// Load 读取 token，没有保存过时返回 ErrNoToken
+	Load() (*Token, error)
+	Save(token *Token) error
+}
+
+// FileTokenStore 以 JSON 文件保存 token，文件权限为 0600
+type FileTokenStore struct {
+	Path string
+}
+
+// Load 读取 token 文件
+func (s *FileTokenStore) Load() (*Token, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 token 文件失败: %w", err)
+	}
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("解析 token 文件 %s 失败: %w", s.Path, err)
+	}
+	return &token, nil
+}
+
+// Save 先写临时文件再重命名，避免进程中断时留下不完整的 token 文件
+func (s *FileTokenStore) Save(token *Token) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("创建 token 目录失败: %w", err)
+		}
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入 token 文件失败: %w", err)
+	}
+	return os.Rename(tmp, s.Path)
+}
@@ -0,0 +1,221 @@
+package feishu
+
+import (
+	"context"
+	"fmt"
+
+	larkdocx "github.com/larksuite/oapi-sdk-go/v3/service/docx/v1"
+)
+
+// 创建嵌套块接口的单次请求上限
+const (
+	maxChildrenPerRequest = 50
+	maxBlocksPerRequest   = 1000
+)
+
+// AppendMarkdown 把 Markdown 转换为文档块追加到文档末尾，返回创建的块数量
+func (c *Client) AppendMarkdown(ctx context.Context, documentID, markdown string) (int, error) {
+	nodes := ConvertMarkdown(markdown)
+	if err := c.AppendBlocks(ctx, documentID, nodes); err != nil {
+		return 0, err
+	}
+	return countBlocks(nodes), nil
+}
+
+// ReplaceMarkdown 用新的 Markdown 内容替换文档正文并更新标题，文档 ID 和链接保持不变。
+// 先把新内容追加到原有正文之后，全部写入成功后再删除原有的块；写入失败时删掉已经追加的部分，原有正文保持不变
+func (c *Client) ReplaceMarkdown(ctx context.Context, documentID, title, markdown string) (int, error) {
+	old, err := c.childCount(ctx, documentID)
+	if err != nil {
+		return 0, err
+	}
+	nodes := ConvertMarkdown(markdown)
+	if err := c.AppendBlocks(ctx, documentID, nodes); err != nil {
+		if rerr := c.truncate(ctx, documentID, old); rerr != nil {
+			return 0, fmt.Errorf("%w（撤销已写入的内容失败: %v）", err, rerr)
+		}
+		return 0, err
+	}
+	if old > 0 {
+		if err := c.deleteChildren(ctx, documentID, 0, old); err != nil {
+			return 0, fmt.Errorf("删除飞书文档原有内容失败: %w", err)
+		}
+	}
+	if title != "" {
+		if err := c.SetTitle(ctx, documentID, title); err != nil {
+			return 0, err
+		}
+	}
+	return countBlocks(nodes), nil
+}
+
+// SetTitle 修改文档标题，即根节点（页面块）的文字
+func (c *Client) SetTitle(ctx context.Context, documentID, title string) error {
+	opts, err := c.requestOptions(ctx)
+	if err != nil {
+		return err
+	}
+	req := larkdocx.NewPatchDocumentBlockReqBuilder().
+		DocumentId(documentID).
+		BlockId(documentID).
+		DocumentRevisionId(-1).
+		UpdateBlockRequest(larkdocx.NewUpdateBlockRequestBuilder().
+			UpdateTextElements(larkdocx.NewUpdateTextElementsRequestBuilder().
+				Elements([]*larkdocx.TextElement{textRun(title, style{})}).
+				Build()).
+			Build()).
+		Build()
+	resp, err := c.lark.Docx.V1.DocumentBlock.Patch(ctx, req, opts...)
+	if err != nil {
+		return err
+	}
+	if !resp.Success() {
+		return fmt.Errorf("修改飞书文档标题失败: %w", apiError(resp.CodeError))
+	}
+	return nil
+}
+
+// childCount 返回文档根节点下的顶层块数量
+func (c *Client) childCount(ctx context.Context, documentID string) (int, error) {
+	opts, err := c.requestOptions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	req := larkdocx.NewGetDocumentBlockReqBuilder().
+		DocumentId(documentID).
+		BlockId(documentID).
+		DocumentRevisionId(-1).
+		Build()
+	resp, err := c.lark.Docx.V1.DocumentBlock.Get(ctx, req, opts...)
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Success() {
+		return 0, fmt.Errorf("读取飞书文档失败: %w", apiError(resp.CodeError))
+	}
+	if resp.Data == nil || resp.Data.Block == nil {
+		return 0, nil
+	}
+	return len(resp.Data.Block.Children), nil
+}
+
+// truncate 删除根节点下第 n 个之后的顶层块，用于撤销写了一半的内容
+func (c *Client) truncate(ctx context.Context, documentID string, n int) error {
+	total, err := c.childCount(ctx, documentID)
+	if err != nil || total <= n {
+		return err
+	}
+	return c.deleteChildren(ctx, documentID, n, total)
+}
+
+// deleteChildren 删除根节点下 [start, end) 范围内的顶层块及其子孙
+func (c *Client) deleteChildren(ctx context.Context, documentID string, start, end int) error {
+	opts, err := c.requestOptions(ctx)
+	if err != nil {
+		return err
+	}
+	req := larkdocx.NewBatchDeleteDocumentBlockChildrenReqBuilder().
+		DocumentId(documentID).
+		BlockId(documentID).
+		DocumentRevisionId(-1).
+		Body(larkdocx.NewBatchDeleteDocumentBlockChildrenReqBodyBuilder().
+			StartIndex(start).
+			EndIndex(end).
+			Build()).
+		Build()
+	resp, err := c.lark.Docx.V1.DocumentBlockChildren.BatchDelete(ctx, req, opts...)
+	if err != nil {
+		return err
+	}
+	if !resp.Success() {
+		return apiError(resp.CodeError)
+	}
+	return nil
+}
+
+// countBlocks 统计节点及其子孙的块数量
+func countBlocks(nodes []*Node) int {
+	total := 0
+	for _, n := range nodes {
+		total += n.Count()
+	}
+	return total
+}
+
+// AppendBlocks 分批创建文档块。每批的顶层块和总块数不超过接口限制，
+// 单个节点（例如很大的表格）超过限制时单独作为一批提交
+func (c *Client) AppendBlocks(ctx context.Context, documentID string, nodes []*Node) error {
+	for _, batch := range splitBatches(nodes) {
+		if err := c.createDescendants(ctx, documentID, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitBatches 按接口限制切分顶层节点，保持原有顺序
+func splitBatches(nodes []*Node) [][]*Node {
+	var batches [][]*Node
+	var current []*Node
+	blocks := 0
+	for _, n := range nodes {
+		count := n.Count()
+		if len(current) > 0 && (len(current) >= maxChildrenPerRequest || blocks+count > maxBlocksPerRequest) {
+			batches = append(batches, current)
+			current, blocks = nil, 0
+		}
+		current = append(current, n)
+		blocks += count
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// createDescendants 调用创建嵌套块接口，使用临时 ID 描述父子关系，追加到文档根节点末尾
+func (c *Client) createDescendants(ctx context.Context, documentID string, nodes []*Node) error {
+	var (
+		descendants []*larkdocx.Block
+		childrenID  []string
+		seq         int
+	)
+	var flatten func(n *Node) string
+	flatten = func(n *Node) string {
+		seq++
+		id := fmt.Sprintf("tmp_%d", seq)
+		block := *n.Block
+		block.BlockId = strPtr(id)
+		block.Children = nil
+		descendants = append(descendants, &block)
+		for _, child := range n.Children {
+			block.Children = append(block.Children, flatten(child))
+		}
+		return id
+	}
+	for _, n := range nodes {
+		childrenID = append(childrenID, flatten(n))
+	}
+
+	opts, err := c.requestOptions(ctx)
+	if err != nil {
+		return err
+	}
+	req := larkdocx.NewCreateDocumentBlockDescendantReqBuilder().
+		DocumentId(documentID).
+		BlockId(documentID).
+		DocumentRevisionId(-1).
+		Body(larkdocx.NewCreateDocumentBlockDescendantReqBodyBuilder().
+			ChildrenId(childrenID).
+			Descendants(descendants).
+			Build()).
+		Build()
+	resp, err := c.lark.Docx.V1.DocumentBlockDescendant.Create(ctx, req, opts...)
+	if err != nil {
+		return err
+	}
+	if !resp.Success() {
+		return fmt.Errorf("写入飞书文档内容失败: %w", apiError(resp.CodeError))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package feishu
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+	"eino_test/feishu/feishutest"
+)
+
+// TestReplaceMarkdownFailedAppend 测试替换正文时写入中途失败，已经追加的内容被撤销，原有正文和标题保持不变
+func TestReplaceMarkdownFailedAppend(t *testing.T) {
+	srv := feishutest.NewServer("cli_replace", "secret")
+	defer srv.Close()
+
+	client, err := NewClient(config.FeishuConfig{AppID: "cli_replace", AppSecret: "secret", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	docID, err := client.CreateDocument(ctx, "", "旧标题")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AppendMarkdown(ctx, docID, "# 旧版\n\n旧内容"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新内容超过单次请求的顶层块上限，分两批写入，第二批失败
+	var b strings.Builder
+	for i := 0; i < maxChildrenPerRequest+10; i++ {
+		b.WriteString("新段落\n\n")
+	}
+	srv.AppendLimit = 2
+	if _, err := client.ReplaceMarkdown(ctx, docID, "新标题", b.String()); err == nil {
+		t.Fatal("写入失败时应返回错误")
+	}
+	blocks := srv.Blocks(docID)
+	if len(blocks) != 2 || *BlockText(blocks[0]).Elements[0].TextRun.Content != "旧版" {
+		t.Fatalf("写入失败后原有正文应保持不变，实际 %d 个块", len(blocks))
+	}
+	if title := srv.Documents()[0].Title; title != "旧标题" {
+		t.Errorf("写入失败时不应修改标题: %q", title)
+	}
+
+	srv.AppendLimit = 0
+	n, err := client.ReplaceMarkdown(ctx, docID, "新标题", b.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks := srv.Blocks(docID); n != maxChildrenPerRequest+10 || len(blocks) != n {
+		t.Fatalf("正文没有被替换: 写入 %d 个块，文档中有 %d 个", n, len(blocks))
+	}
+	if title := srv.Documents()[0].Title; title != "新标题" {
+		t.Errorf("标题没有更新: %q", title)
+	}
+}
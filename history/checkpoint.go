@@ -0,0 +1,62 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkpointDir 修订历史目录中保存 checkpoint 的子目录
+const checkpointDir = "checkpoints"
+
+// CheckPointStore 把 adk Runner 的 checkpoint 保存为文件，每个 checkpoint ID 一个文件，实现 compose.CheckPointStore
+type CheckPointStore struct {
+	dir string
+}
+
+// NewCheckPointStore 创建保存在 dir 下的 checkpoint 存储，目录在第一次写入时创建
+func NewCheckPointStore(dir string) *CheckPointStore {
+	return &CheckPointStore{dir: dir}
+}
+
+// Get 读取 checkpoint，不存在时返回 false
+func (s *CheckPointStore) Get(_ context.Context, id string) ([]byte, bool, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("读取 checkpoint %s 失败: %w", id, err)
+	}
+	return data, true, nil
+}
+
+// Set 写入 checkpoint。先写临时文件再重命名，写到一半被中断时不会留下损坏的 checkpoint
+func (s *CheckPointStore) Set(_ context.Context, id string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("创建 checkpoint 目录失败: %w", err)
+	}
+	tmp := s.path(id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入 checkpoint %s 失败: %w", id, err)
+	}
+	if err := os.Rename(tmp, s.path(id)); err != nil {
+		return fmt.Errorf("写入 checkpoint %s 失败: %w", id, err)
+	}
+	return nil
+}
+
+// Delete 删除 checkpoint，流程正常结束后不再需要从中断处继续
+func (s *CheckPointStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("删除 checkpoint %s 失败: %w", id, err)
+	}
+	return nil
+}
+
+func (s *CheckPointStore) path(id string) string {
+	return filepath.Join(s.dir, id+".gob")
+}
@@ -0,0 +1,151 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext unified diff 中每处改动前后保留的行数
+const diffContext = 3
+
+// diffOp 编辑脚本中的一行：' ' 未变化，'-' 删除，'+' 新增。a、b 是这一行之前两边已经经过的行数
+type diffOp struct {
+	kind byte
+	line string
+	a, b int
+}
+
+// Diff 返回从 a 到 b 的 unified diff，nameA、nameB 用于文件头。内容相同时返回空字符串
+func Diff(nameA, a, nameB, b string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+
+		// 两处改动之间未变化的行不超过两倍上下文时合并为一个 hunk
+		start, end := max(i-diffContext, 0), i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			j := end
+			for j < len(ops) && ops[j].kind == ' ' {
+				j++
+			}
+			if j == len(ops) || j-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = j
+		}
+
+		hunk := ops[start:end]
+		var countA, countB int
+		for _, op := range hunk {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunk[0].a, countA), hunkRange(hunk[0].b, countB))
+		for _, op := range hunk {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange 格式化 hunk 头中的行号范围，没有行时按惯例使用前一行的行号
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// diffLines 用最长公共子序列计算逐行的编辑脚本，相同的开头和结尾不参与计算
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lcs[i][j] 为 ma[i:] 与 mb[j:] 的最长公共子序列长度
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	ia, ib := 0, 0
+	emit := func(kind byte, line string) {
+		ops = append(ops, diffOp{kind: kind, line: line, a: ia, b: ib})
+		if kind != '+' {
+			ia++
+		}
+		if kind != '-' {
+			ib++
+		}
+	}
+	for _, line := range a[:prefix] {
+		emit(' ', line)
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			emit(' ', ma[i])
+			i++
+			j++
+		case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+			emit('-', ma[i])
+			i++
+		default:
+			emit('+', mb[j])
+			j++
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		emit(' ', line)
+	}
+	return ops
+}
+
+// splitLines 按行切分，忽略结尾的换行
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,377 @@
+// Package history 保存每次改写的修订历史：每一轮的改写稿、评审结果、token 用量和时间，
+// 用于事后查看评审意见让改写稿发生了哪些变化
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"eino_test/review"
+)
+
+// runFile 每次改写的基本信息文件
+const runFile = "run.json"
+
+// RunInfo 一次改写的基本信息，写入 <dir>/<id>/run.json
+type RunInfo struct {
+	ID      string            `json:"id"`
+	Source  string            `json:"source"`
+	Persona string            `json:"persona,omitempty"`
+	Prompts []string          `json:"prompts,omitempty"`
+	Models  map[string]string `json:"models,omitempty"`
+	// Mode 改写方式：whole 整篇改写，sections 按章节改写。从 checkpoint 恢复时沿用
+	Mode string `json:"mode,omitempty"`
+	// Sections 按章节改写时各节的标题，整篇改写时为空
+	Sections   []string  `json:"sections,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	// ResumedAt 每次从 checkpoint 恢复的时间
+	ResumedAt []time.Time `json:"resumed_at,omitempty"`
+	Approved  bool        `json:"approved"`
+	Output    string      `json:"output,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	// Revisions 已记录的修订数
+	Revisions int `json:"revisions"`
+}
+
+// Finished 改写是否已经正常结束（不论是否通过评审），结束的改写不需要恢复
+func (info *RunInfo) Finished() bool {
+	return !info.FinishedAt.IsZero() && info.Error == ""
+}
+
+// Usage 一个 Agent 的 token 用量
+type Usage struct {
+	Prompt     int `json:"prompt"`
+	Completion int `json:"completion"`
+	Total      int `json:"total"`
+}
+
+// Add 累加另一份用量
+func (u *Usage) Add(prompt, completion, total int) {
+	u.Prompt += prompt
+	u.Completion += completion
+	u.Total += total
+}
+
+// Revision 一轮改写及其评审，改写稿保存在 rev-<Seq>.md，其余信息保存在 rev-<Seq>.json
+type Revision struct {
+	// Seq 本次改写中的修订序号，从 1 开始，按改写稿产出的先后编号
+	Seq int `json:"seq"`
+	// Section 按章节改写时的章节序号（从 1 开始），整篇改写时为 0
+	Section int `json:"section,omitempty"`
+	// Iteration 所在改写-评审循环的第几轮
+	Iteration int `json:"iteration"`
+	// Chars 改写稿的字符数
+	Chars      int       `json:"chars"`
+	DraftedAt  time.Time `json:"drafted_at"`
+	ReviewedAt time.Time `json:"reviewed_at,omitzero"`
+	// Review submit_review 提交的结构化评审，评审没有完成时为空
+	Review *review.Result `json:"review,omitempty"`
+	// Feedback 评审前的自动检查报告和 ReviewerAgent 的回复
+	Feedback string `json:"feedback,omitempty"`
+	// Usage 这一轮各个 Agent 的 token 用量，改写的用量计入产出的改写稿，评审的用量计入被评审的改写稿
+	Usage map[string]*Usage `json:"usage,omitempty"`
+}
+
+// Tokens 这一轮所有 Agent 的 token 总用量
+func (r *Revision) Tokens() int {
+	n := 0
+	for _, u := range r.Usage {
+		n += u.Total
+	}
+	return n
+}
+
+// Run 正在记录的一次改写，多个章节可以并发写入
+type Run struct {
+	dir string
+
+	mu   sync.Mutex
+	info RunInfo
+}
+
+// Create 在 dir 下为一次改写创建修订历史目录，目录名即 run ID
+func Create(dir string, info RunInfo) (*Run, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	info.ID = id
+	if info.StartedAt.IsZero() {
+		info.StartedAt = time.Now()
+	}
+	r := &Run{dir: filepath.Join(dir, id), info: info}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建修订历史目录失败: %w", err)
+	}
+	return r, r.writeInfo()
+}
+
+// Open 打开已有的修订历史，用于从 checkpoint 恢复中断的改写，id 可以是 run ID 的前缀
+func Open(dir, id string) (*Run, error) {
+	runDir, err := resolve(dir, id)
+	if err != nil {
+		return nil, err
+	}
+	info, err := readInfo(runDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Run{dir: runDir, info: *info}, nil
+}
+
+// ID 返回本次改写的 run ID
+func (r *Run) ID() string {
+	return r.info.ID
+}
+
+// Dir 返回本次改写的修订历史目录
+func (r *Run) Dir() string {
+	return r.dir
+}
+
+// Info 返回本次改写的基本信息
+func (r *Run) Info() RunInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.info
+}
+
+// Resume 记录一次恢复，清除上次中断时记录的结束时间和错误
+func (r *Run) Resume() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info.ResumedAt = append(r.info.ResumedAt, time.Now())
+	r.info.FinishedAt, r.info.Error = time.Time{}, ""
+	return r.writeInfo()
+}
+
+// CheckPoints 返回保存在修订历史目录中的 checkpoint 存储
+func (r *Run) CheckPoints() *CheckPointStore {
+	return NewCheckPointStore(filepath.Join(r.dir, checkpointDir))
+}
+
+// SetSections 记录按章节改写时各节的标题
+func (r *Run) SetSections(titles []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info.Sections = titles
+	return r.writeInfo()
+}
+
+// NextSeq 为新产出的改写稿分配修订序号
+func (r *Run) NextSeq() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info.Revisions++
+	_ = r.writeInfo()
+	return r.info.Revisions
+}
+
+// WriteRevision 写入一轮修订。同一个 Seq 可以多次写入，评审完成后再次写入即可补上评审结果
+func (r *Run) WriteRevision(rev *Revision, draft string) error {
+	base := filepath.Join(r.dir, revisionName(rev.Seq))
+	if err := os.WriteFile(base+".md", []byte(draft), 0644); err != nil {
+		return fmt.Errorf("写入第 %d 次修订失败: %w", rev.Seq, err)
+	}
+	data, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return fmt.Errorf("写入第 %d 次修订失败: %w", rev.Seq, err)
+	}
+	return nil
+}
+
+// WriteFile 在修订历史目录中写入其他文件，例如按章节改写的全局教学计划
+func (r *Run) WriteFile(name string, data []byte) error {
+	return os.WriteFile(filepath.Join(r.dir, name), data, 0644)
+}
+
+// ReadFile 读取 WriteFile 写入的文件，文件不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func (r *Run) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(r.dir, name))
+}
+
+// Revisions 返回已经记录的某一节（整篇改写时为 0）的修订和对应的改写稿，按序号排列
+func (r *Run) Revisions(section int) ([]*Revision, []string, error) {
+	all, err := loadRevisions(r.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var revs []*Revision
+	var drafts []string
+	for _, rev := range all {
+		if rev.Section != section {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(r.dir, revisionName(rev.Seq)+".md"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取第 %d 次修订失败: %w", rev.Seq, err)
+		}
+		revs, drafts = append(revs, rev), append(drafts, string(data))
+	}
+	return revs, drafts, nil
+}
+
+// Finish 记录改写结束的时间和结果
+func (r *Run) Finish(approved bool, output string, runErr error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info.FinishedAt = time.Now()
+	r.info.Approved, r.info.Output = approved, output
+	if runErr != nil {
+		r.info.Error = runErr.Error()
+	}
+	return r.writeInfo()
+}
+
+func (r *Run) writeInfo() error {
+	data, err := json.MarshalIndent(&r.info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, runFile), data, 0644); err != nil {
+		return fmt.Errorf("写入修订历史失败: %w", err)
+	}
+	return nil
+}
+
+// List 返回 dir 下记录的所有改写，最近开始的在前。dir 不存在时返回空列表
+func List(dir string) ([]RunInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取修订历史目录失败: %w", err)
+	}
+	var runs []RunInfo
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		info, err := readInfo(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue // 不是修订历史目录
+		}
+		runs = append(runs, *info)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	return runs, nil
+}
+
+// Load 读取一次改写的基本信息和所有修订（按序号排列），id 可以是 run ID 的前缀
+func Load(dir, id string) (*RunInfo, []*Revision, error) {
+	runDir, err := resolve(dir, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := readInfo(runDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	revs, err := loadRevisions(runDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, revs, nil
+}
+
+// loadRevisions 读取目录下所有修订的信息，按序号排列
+func loadRevisions(runDir string) ([]*Revision, error) {
+	paths, err := filepath.Glob(filepath.Join(runDir, "rev-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var revs []*Revision
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("读取修订失败: %w", err)
+		}
+		var rev Revision
+		if err := json.Unmarshal(data, &rev); err != nil {
+			return nil, fmt.Errorf("解析修订 %s 失败: %w", filepath.Base(p), err)
+		}
+		revs = append(revs, &rev)
+	}
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Seq < revs[j].Seq })
+	return revs, nil
+}
+
+// ReadDraft 读取一次修订的改写稿
+func ReadDraft(dir, id string, seq int) (string, error) {
+	runDir, err := resolve(dir, id)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(runDir, revisionName(seq)+".md"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("第 %d 次修订不存在", seq)
+	}
+	return string(data), err
+}
+
+// resolve 按 run ID 或其唯一前缀找到修订历史目录
+func resolve(dir, id string) (string, error) {
+	if id == "" {
+		return "", errors.New("run ID 不能为空")
+	}
+	if _, err := os.Stat(filepath.Join(dir, id, runFile)); err == nil {
+		return filepath.Join(dir, id), nil
+	}
+	runs, err := List(dir)
+	if err != nil {
+		return "", err
+	}
+	var matched []string
+	for _, r := range runs {
+		if strings.HasPrefix(r.ID, id) {
+			matched = append(matched, r.ID)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", fmt.Errorf("修订历史 %s 不存在", id)
+	case 1:
+		return filepath.Join(dir, matched[0]), nil
+	default:
+		return "", fmt.Errorf("run ID 前缀 %s 不唯一: %s", id, strings.Join(matched, ", "))
+	}
+}
+
+func readInfo(runDir string) (*RunInfo, error) {
+	data, err := os.ReadFile(filepath.Join(runDir, runFile))
+	if err != nil {
+		return nil, err
+	}
+	var info RunInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", filepath.Join(runDir, runFile), err)
+	}
+	return &info, nil
+}
+
+func revisionName(seq int) string {
+	return fmt.Sprintf("rev-%03d", seq)
+}
+
+// newID 生成按时间排序的 run ID，例如 20250101-150405-1a2b
+func newID() (string, error) {
+	b := make([]byte, 2)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成 run ID 失败: %w", err)
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b), nil
+}
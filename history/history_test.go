@@ -0,0 +1,154 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"eino_test/review"
+)
+
+// TestRun 测试记录修订、补写评审结果后按 ID 前缀读取
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	run, err := Create(dir, RunInfo{Source: "docs/kafka.md", Persona: "backend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := &Revision{Seq: run.NextSeq(), Iteration: 1, DraftedAt: time.Now(),
+		Usage: map[string]*Usage{"summaryAgent": {Prompt: 100, Completion: 50, Total: 150}}}
+	if err := run.WriteRevision(first, "# Kafka\n\n第一版\n"); err != nil {
+		t.Fatal(err)
+	}
+	// 评审完成后再次写入同一个修订
+	first.Review = &review.Result{Iteration: 1, Average: 6.5}
+	first.Usage["reviewerAgent"] = &Usage{Total: 80}
+	if err := run.WriteRevision(first, "# Kafka\n\n第一版\n"); err != nil {
+		t.Fatal(err)
+	}
+	second := &Revision{Seq: run.NextSeq(), Iteration: 2, DraftedAt: time.Now()}
+	if err := run.WriteRevision(second, "# Kafka\n\n第二版\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Finish(true, "out/kafka.md", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := List(dir)
+	if err != nil || len(runs) != 1 || runs[0].ID != run.ID() || runs[0].Revisions != 2 || !runs[0].Approved {
+		t.Fatalf("List 结果不符合预期: %+v, %v", runs, err)
+	}
+	info, revs, err := Load(dir, run.ID()[:10])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Source != "docs/kafka.md" || len(revs) != 2 || revs[0].Review == nil || revs[0].Review.Average != 6.5 || revs[0].Tokens() != 230 {
+		t.Fatalf("Load 结果不符合预期: %+v %+v", info, revs)
+	}
+	draft, err := ReadDraft(dir, run.ID(), 2)
+	if err != nil || draft != "# Kafka\n\n第二版\n" {
+		t.Errorf("ReadDraft = %q, %v", draft, err)
+	}
+	if _, err := ReadDraft(dir, run.ID(), 3); err == nil {
+		t.Error("不存在的修订应报错")
+	}
+	if _, _, err := Load(dir, "missing"); err == nil {
+		t.Error("不存在的 run 应报错")
+	}
+}
+
+// TestResume 测试中断的改写重新打开后继续记录，checkpoint 按 ID 保存在修订历史目录中
+func TestResume(t *testing.T) {
+	dir := t.TempDir()
+	run, err := Create(dir, RunInfo{Source: "docs/kafka.md", Mode: "whole"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.WriteRevision(&Revision{Seq: run.NextSeq(), Iteration: 1}, "第一版"); err != nil {
+		t.Fatal(err)
+	}
+	store := run.CheckPoints()
+	if err := store.Set(context.Background(), "rewrite", []byte("step")); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Finish(false, "", errors.New("interrupted")); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir, run.ID()[:10])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := reopened.Info(); info.Finished() || info.Mode != "whole" {
+		t.Fatalf("中断的改写不应视为结束: %+v", info)
+	}
+	if err := reopened.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if reopened.NextSeq() != 2 {
+		t.Error("恢复后修订序号应接着之前的修订")
+	}
+	revs, drafts, err := reopened.Revisions(0)
+	if err != nil || len(revs) != 1 || drafts[0] != "第一版" {
+		t.Fatalf("Revisions = %+v %q, %v", revs, drafts, err)
+	}
+
+	data, ok, err := reopened.CheckPoints().Get(context.Background(), "rewrite")
+	if err != nil || !ok || string(data) != "step" {
+		t.Fatalf("Get = %q %v, %v", data, ok, err)
+	}
+	if err := reopened.CheckPoints().Delete("rewrite"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := store.Get(context.Background(), "rewrite"); ok || err != nil {
+		t.Errorf("删除后不应再读到 checkpoint: %v, %v", ok, err)
+	}
+
+	if err := reopened.Finish(true, "out/kafka.md", nil); err != nil {
+		t.Fatal(err)
+	}
+	info, _, err := Load(dir, run.ID())
+	if err != nil || !info.Finished() || info.Error != "" || len(info.ResumedAt) != 1 {
+		t.Errorf("恢复后结束的信息不符合预期: %+v, %v", info, err)
+	}
+}
+
+// TestDiff 测试 unified diff 的 hunk 划分和行号
+func TestDiff(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		a = append(a, "line")
+		b = append(b, "line")
+	}
+	a[1], b[1] = "旧的第二行", "新的第二行"
+	b = append(b[:15], append([]string{"插入的一行"}, b[15:]...)...)
+
+	got := Diff("rev-001.md", strings.Join(a, "\n")+"\n", "rev-002.md", strings.Join(b, "\n")+"\n")
+	want := `--- rev-001.md
++++ rev-002.md
+@@ -1,5 +1,5 @@
+ line
+-旧的第二行
++新的第二行
+ line
+ line
+ line
+@@ -13,6 +13,7 @@
+ line
+ line
+ line
++插入的一行
+ line
+ line
+ line
+`
+	if got != want {
+		t.Errorf("Diff 结果不符合预期:\n%s", got)
+	}
+	if Diff("a", "same\n", "b", "same\n") != "" {
+		t.Error("内容相同时应返回空字符串")
+	}
+}
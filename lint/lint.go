@@ -0,0 +1,298 @@
+// Package lint 对改写稿做确定性的 Markdown 检查：emoji 过多、标题跳级、代码块未闭合、
+// 章节缺少教学结构块、表格列数不一致、Mermaid 图表语法错误。这些问题不需要模型判断，程序检查更快也更稳定，
+// 检查结果带行号，ReviewerAgent 只需要关注需要判断的部分
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"eino_test/config"
+	"eino_test/markdown"
+	"eino_test/mermaid"
+)
+
+// 检查规则的名称，与 config.LintRules 一致
+const (
+	RuleEmoji    = "emoji"
+	RuleHeading  = "heading"
+	RuleFence    = "fence"
+	RuleSections = "sections"
+	RuleTable    = "table"
+	RuleMermaid  = "mermaid"
+)
+
+// maxListedLines emoji 问题最多列出的行号数量
+const maxListedLines = 10
+
+var separatorRe = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+
+// Issue 一个检查出的问题
+type Issue struct {
+	// Line 问题所在的行号，从 1 开始
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("第 %d 行 [%s] %s", i.Line, i.Rule, i.Message)
+}
+
+// Format 把问题格式化为每行一条的文本，没有问题时返回空字符串
+func Format(issues []Issue) string {
+	lines := make([]string, len(issues))
+	for i, is := range issues {
+		lines[i] = "- " + is.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check 按配置启用的规则检查 Markdown 内容，返回按行号排序的问题
+func Check(content string, cfg config.LintConfig) []Issue {
+	d := newDoc(content)
+	var issues []Issue
+	for _, rule := range cfg.Rules {
+		switch rule {
+		case RuleEmoji:
+			issues = append(issues, d.emoji(cfg.MaxEmoji)...)
+		case RuleHeading:
+			issues = append(issues, d.headingLevels()...)
+		case RuleFence:
+			issues = append(issues, d.fences()...)
+		case RuleSections:
+			issues = append(issues, d.sections(cfg)...)
+		case RuleTable:
+			issues = append(issues, d.tables()...)
+		case RuleMermaid:
+			issues = append(issues, d.mermaid()...)
+		}
+	}
+	slices.SortStableFunc(issues, func(a, b Issue) int { return a.Line - b.Line })
+	return issues
+}
+
+// doc 按行拆分的文档，code 标记每一行是否属于代码块（包括围栏本身）
+type doc struct {
+	lines []string
+	code  []bool
+}
+
+func newDoc(content string) *doc {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	return &doc{lines: lines, code: markdown.CodeLines(lines)}
+}
+
+// emoji 全文 emoji 超过 limit 个时报告一次，列出出现 emoji 的行
+func (d *doc) emoji(limit int) []Issue {
+	total := 0
+	var lines []string
+	first := 0
+	for i, line := range d.lines {
+		if d.code[i] {
+			continue
+		}
+		n := countEmoji(line)
+		if n == 0 {
+			continue
+		}
+		if first == 0 {
+			first = i + 1
+		}
+		total += n
+		if len(lines) < maxListedLines {
+			lines = append(lines, fmt.Sprint(i+1))
+		}
+	}
+	if total <= limit {
+		return nil
+	}
+	listed := strings.Join(lines, "、")
+	if len(lines) == maxListedLines {
+		listed += " 等"
+	}
+	return []Issue{{Line: first, Rule: RuleEmoji, Message: fmt.Sprintf("全文共有 %d 个 emoji，超过上限 %d 个，出现在第 %s 行，只在必要的提示处保留", total, limit, listed)}}
+}
+
+// countEmoji 统计一行中的 emoji 数量，变体选择符和零宽连接符不单独计数
+func countEmoji(line string) int {
+	n := 0
+	for len(line) > 0 {
+		r, size := utf8.DecodeRuneInString(line)
+		line = line[size:]
+		switch {
+		case r >= 0x1F000 && r <= 0x1FAFF, // 表情、符号和象形文字
+			r >= 0x2600 && r <= 0x27BF, // 杂项符号和装饰符号，例如 ⚠ ✅
+			r >= 0x2B00 && r <= 0x2BFF, // 例如 ⭐ ⬆
+			r >= 0x2300 && r <= 0x23FF: // 例如 ⏰ ⌛
+			n++
+		}
+	}
+	return n
+}
+
+// headingLevels 标题比上一个标题深两级及以上时报告跳级，例如 ## 之后直接出现 ####
+func (d *doc) headingLevels() []Issue {
+	var issues []Issue
+	prev := 0
+	for _, h := range markdown.Headings(d.lines) {
+		if prev > 0 && h.Level > prev+1 {
+			issues = append(issues, Issue{Line: h.Line, Rule: RuleHeading, Message: fmt.Sprintf(
+				"标题 %q 是 %d 级标题，但上一个标题是 %d 级，跳过了 %d 级标题", h.Text, h.Level, prev, prev+1)})
+		}
+		prev = h.Level
+	}
+	return issues
+}
+
+// fences 检查代码块围栏：没有闭合的代码块，以及代码块内部出现带语言名的开头围栏
+// （通常是上一个代码块忘了闭合，后面的代码块和正文被错当成代码）
+func (d *doc) fences() []Issue {
+	var issues []Issue
+	fence, open := "", 0
+	for i, line := range d.lines {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			if fence = markdown.FenceMarker(trimmed); fence != "" {
+				open = i + 1
+			}
+			continue
+		}
+		if markdown.ClosesFence(line, fence) {
+			fence = ""
+			continue
+		}
+		if inner := markdown.FenceMarker(trimmed); inner != "" && inner[0] == fence[0] && len(inner) >= len(fence) {
+			issues = append(issues, Issue{Line: i + 1, Rule: RuleFence, Message: fmt.Sprintf(
+				"第 %d 行开始的代码块还没有闭合，这里又出现了 %q，请检查上一个代码块是否缺少结尾的 %s", open, trimmed, fence)})
+		}
+	}
+	if fence != "" {
+		issues = append(issues, Issue{Line: open, Rule: RuleFence, Message: fmt.Sprintf("代码块没有闭合，缺少结尾的 %s", fence)})
+	}
+	return issues
+}
+
+// sections 检查 section_level 级的每一节是否包含要求的结构块，标题包含 skip_sections 中文字的章节跳过
+func (d *doc) sections(cfg config.LintConfig) []Issue {
+	if len(cfg.SectionBlocks) == 0 {
+		return nil
+	}
+	var issues []Issue
+	hs := markdown.Headings(d.lines)
+	for i, h := range hs {
+		if h.Level != cfg.SectionLevel || slices.ContainsFunc(cfg.SkipSections, func(s string) bool { return strings.Contains(h.Text, s) }) {
+			continue
+		}
+		end := len(d.lines)
+		for _, next := range hs[i+1:] {
+			if next.Level <= cfg.SectionLevel {
+				end = next.Line - 1
+				break
+			}
+		}
+		body := strings.Join(d.lines[h.Line:end], "\n")
+		var missing []string
+		for _, block := range cfg.SectionBlocks {
+			if !strings.Contains(body, block) {
+				missing = append(missing, "“"+block+"”")
+			}
+		}
+		if len(missing) > 0 {
+			issues = append(issues, Issue{Line: h.Line, Rule: RuleSections, Message: fmt.Sprintf(
+				"章节 %q 缺少 %s", h.Text, strings.Join(missing, "、"))})
+		}
+	}
+	return issues
+}
+
+// tables 检查以 | 开头的表格：第二行必须是分隔行，每一行的列数与表头一致
+func (d *doc) tables() []Issue {
+	var issues []Issue
+	for i := 0; i < len(d.lines); i++ {
+		if d.code[i] || !isTableRow(d.lines[i]) {
+			continue
+		}
+		start := i
+		for i+1 < len(d.lines) && !d.code[i+1] && isTableRow(d.lines[i+1]) {
+			i++
+		}
+		rows := d.lines[start : i+1]
+		cols := len(cells(rows[0]))
+		if len(rows) < 2 || !separatorRe.MatchString(strings.TrimSpace(rows[1])) {
+			issues = append(issues, Issue{Line: start + 1, Rule: RuleTable, Message: "表格的第二行不是 |---| 分隔行，表格不会被渲染"})
+			continue
+		}
+		for j, row := range rows[1:] {
+			if n := len(cells(row)); n != cols {
+				issues = append(issues, Issue{Line: start + j + 2, Rule: RuleTable, Message: fmt.Sprintf(
+					"表格这一行有 %d 列，表头有 %d 列", n, cols)})
+			}
+		}
+	}
+	return issues
+}
+
+// mermaid 用 mermaid.Validate 检查每个 mermaid 代码块，错误行号换算为文档中的行号
+func (d *doc) mermaid() []Issue {
+	var issues []Issue
+	n := 0
+	for i := 0; i < len(d.lines); i++ {
+		trimmed := strings.TrimSpace(d.lines[i])
+		fence := markdown.FenceMarker(trimmed)
+		if fence == "" {
+			continue
+		}
+		start := i
+		for i+1 < len(d.lines) && !markdown.ClosesFence(d.lines[i+1], fence) {
+			i++
+		}
+		body := d.lines[start+1 : i+1]
+		i++
+		if strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) != "mermaid" {
+			continue
+		}
+		n++
+		for _, e := range mermaid.Validate(strings.Join(body, "\n")) {
+			issues = append(issues, Issue{Line: start + 1 + e.Line, Rule: RuleMermaid, Message: fmt.Sprintf(
+				"第 %d 个 Mermaid 图表：%s", n, e.Message)})
+		}
+	}
+	return issues
+}
+
+func isTableRow(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "|")
+}
+
+// cells 拆分表格行的单元格，忽略转义的 \| 和行内代码中的 |
+func cells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var out []string
+	var cur strings.Builder
+	inCode := false
+	for i := 0; i < len(row); i++ {
+		c := row[i]
+		switch {
+		case c == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cur.WriteString(`\|`)
+			i++
+			continue
+		case c == '`':
+			inCode = !inCode
+		case c == '|' && !inCode:
+			out = append(out, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	return append(out, cur.String())
+}
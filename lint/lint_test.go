@@ -0,0 +1,115 @@
+package lint
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+)
+
+const lintDoc = `# Kafka 入门 🚀
+
+## 1. 教学大纲
+
+大纲不检查结构块 ✅ ✅ ✅
+
+## 2. 生产者
+
+本节你会学到什么：如何发送消息 🎉
+
+#### 2.1 发送消息
+
+| 参数 | 说明 | 默认值 |
+|------|------|--------|
+| acks | 确认级别 |
+| ` + "`a|b`" + ` | 行内代码中的竖线 | 无 |
+
+小结：生产者负责发送消息 💡
+
+## 3. 消费者
+
+` + "```go" + `
+// 代码块中的内容不检查 🚀🚀🚀
+// | 不是表格 |
+func main() {}
+` + "```python" + `
+print("忘了闭合上一个代码块")
+` + "```" + `
+
+| 没有分隔行 | 的表格 |
+| a | b |
+
+` + "```bash" + `
+echo "没有闭合"
+`
+
+// TestCheck 测试每条规则报告的行号和问题
+func TestCheck(t *testing.T) {
+	issues := Check(lintDoc, config.Default().Lint)
+	got := map[string][]int{}
+	for _, is := range issues {
+		got[is.Rule] = append(got[is.Rule], is.Line)
+	}
+	want := map[string][]int{
+		RuleEmoji:    {1},
+		RuleHeading:  {11},
+		RuleTable:    {15, 30},
+		RuleSections: {20},
+		RuleFence:    {26, 33},
+	}
+	for rule, lines := range want {
+		if !slices.Equal(got[rule], lines) {
+			t.Errorf("规则 %s 的行号为 %v，期望 %v", rule, got[rule], lines)
+		}
+	}
+	if len(issues) != 7 {
+		t.Errorf("问题数量不对:\n%s", Format(issues))
+	}
+	for i := 1; i < len(issues); i++ {
+		if issues[i].Line < issues[i-1].Line {
+			t.Errorf("问题没有按行号排序:\n%s", Format(issues))
+		}
+	}
+
+	text := Format(issues)
+	for _, s := range []string{
+		"第 1 行 [emoji] 全文共有 6 个 emoji，超过上限 5 个，出现在第 1、5、9、18 行",
+		"第 11 行 [heading] 标题 \"2.1 发送消息\" 是 4 级标题，但上一个标题是 2 级",
+		"第 15 行 [table] 表格这一行有 2 列，表头有 3 列",
+		"第 20 行 [sections] 章节 \"3. 消费者\" 缺少 “本节你会学到什么”、“小结”",
+		"第 26 行 [fence] 第 22 行开始的代码块还没有闭合",
+		"第 30 行 [table] 表格的第二行不是 |---| 分隔行",
+		"第 33 行 [fence] 代码块没有闭合",
+	} {
+		if !strings.Contains(text, s) {
+			t.Errorf("缺少问题 %q:\n%s", s, text)
+		}
+	}
+}
+
+// TestCheckRules 测试只运行启用的规则，干净的文档没有问题
+func TestCheckRules(t *testing.T) {
+	cfg := config.Default().Lint
+	cfg.Rules = []string{RuleHeading}
+	if issues := Check(lintDoc, cfg); len(issues) != 1 || issues[0].Rule != RuleHeading {
+		t.Errorf("只启用 heading 时结果不对:\n%s", Format(issues))
+	}
+	cfg.Rules = nil
+	if issues := Check(lintDoc, cfg); len(issues) != 0 {
+		t.Errorf("没有启用规则时不应该有问题:\n%s", Format(issues))
+	}
+
+	cfg.Rules = []string{RuleMermaid}
+	diagrams := "# 图表\n\n```mermaid\ngraph TD\n    A[开始] --> B(处理(重试))\n```\n\n```mermaid\nsequenceDiagram\n    A->>B: 请求\n```\n\n```mermaid\nflowchart LR\n    A --> B\n    B => C\n```\n"
+	issues := Check(diagrams, cfg)
+	if len(issues) != 2 || issues[0].Line != 5 || issues[1].Line != 16 ||
+		!strings.Contains(issues[0].Message, "第 1 个 Mermaid 图表") || !strings.Contains(issues[1].Message, "第 3 个 Mermaid 图表") {
+		t.Errorf("mermaid 规则的结果不对:\n%s", Format(issues))
+	}
+
+	clean := "# 标题\n\n## 1. 概念\n\n本节你会学到什么：概念\n\n### 1.1 细节\n\n| a | b |\n|---|:-:|\n| 1 | 2 |\n\n```go\nfmt.Println()\n```\n\n小结：完成 💡\n"
+	if issues := Check(clean, config.Default().Lint); len(issues) != 0 {
+		t.Errorf("干净的文档不应该有问题:\n%s", Format(issues))
+	}
+}
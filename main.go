@@ -1,95 +1,87 @@
 package main
 
 import (
-	"context"
-	myagent "eino_test/agent"
 	"eino_test/common/utils"
+	"errors"
 	"fmt"
-	"log"
 	"os"
-
-	"eino_test/config"
-
-	"github.com/cloudwego/eino/adk"
-	"github.com/cloudwego/eino/adk/prebuilt/supervisor"
-	"github.com/cloudwego/eino/callbacks"
-	"github.com/cloudwego/eino/components/prompt"
-	"github.com/cloudwego/eino/schema"
 )
 
-func main() {
-	ctx := context.Background()
-	config.LoadConfig()
-
-	// 创建 MainAgent 作为 Supervisor
-	mainAgent := myagent.NewMainAgent(ctx)
+const usage = `文档改写系统
+
+用法:
+  eino_demo <命令> [参数]
+
+命令:
+  rewrite <file>    改写指定的 Markdown 文档
+  resume <run-id>   从最后完成的一步继续一次中断的改写（需要记录修订历史）
+  batch <dir>       批量改写目录下的 Markdown 文档，支持中断后续跑
+  review <file>     只评审指定的文档，不保存
+  serve             启动 HTTP 任务服务，通过 SSE 推送 Agent 事件
+  mcp               通过标准输入输出提供 MCP 服务
+  index <dir>       将目录下的 Markdown 文档切分后写入 Milvus
+  search <query>    在 Milvus 知识库中检索
+  render <file>     渲染 Mermaid 文件（.mmd），支持离线渲染
+  personas [name]   列出可用的读者画像，或查看指定画像的读者背景
+  feishu login      通过浏览器授权飞书用户身份，token 保存在本地并自动刷新
+  feishu status     查看本地飞书用户 token 的有效期
+  config show       打印当前配置
+  history list      列出修订历史，history list <run-id> 列出一次改写的每一轮修订
+  history show      查看一次修订的评审意见
+  history diff      比较两次修订的改写稿（unified diff）
+
+使用 "eino_demo <命令> -h" 查看命令的参数说明
+
+退出码: 0 成功，1 出错，2 用法错误，3 改写稿未通过评审（rewrite、resume、batch 已保存得分最高的版本）
+`
 
-	// 创建 SummaryAgent 作为子 Agent
-	summaryAgent := myagent.NewSummaryAgent(ctx)
-
-	// 使用 Supervisor 编排 SummaryAgent
-	supervisorAgent, err := supervisor.New(ctx, &supervisor.Config{
-		Supervisor: mainAgent,
-		SubAgents:  []adk.Agent{summaryAgent},
-	})
-	if err != nil {
-		panic(err)
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
 	}
 
-	// 定义要改写的文档文件路径
-	documentPath := "./text.md"
-
-	// 检查文件是否存在
-	if _, err := os.Stat(documentPath); err != nil {
-		panic(fmt.Sprintf("文档文件不存在: %s", documentPath))
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "rewrite":
+		err = runRewrite(args)
+	case "resume":
+		err = runResume(args)
+	case "batch":
+		err = runBatch(args)
+	case "review":
+		err = runReview(args)
+	case "serve":
+		err = runServe(args)
+	case "mcp":
+		err = runMCP(args)
+	case "index":
+		err = runIndex(args)
+	case "search":
+		err = runSearch(args)
+	case "render":
+		err = runRender(args)
+	case "personas":
+		err = runPersonas(args)
+	case "feishu":
+		err = runFeishu(args)
+	case "config":
+		err = runConfig(args)
+	case "history":
+		err = runHistory(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n%s", cmd, usage)
+		os.Exit(2)
 	}
 
-	// 使用 Supervisor 执行文档改写任务
-	log.Println("========== 开始执行文档改写任务 ==========")
-	log.Println("文档路径:", documentPath)
-	log.Println()
-
-	// 创建回调处理器来打印 LLM 和 Agent 的输出
-	callbackHandler := utils.NewOutputCallbackHandler()
-	// 设置全局回调处理器
-	callbacks.InitCallbackHandlers([]callbacks.Handler{callbackHandler})
-
-	// 创建 prompt 模板
-	// 注意：详细的改写原则已经在 SummaryAgent 的 Instruction 中定义
-	// 这里只需要简单的用户请求即可，传递文件路径而不是文件内容
-	promptTemplate := prompt.FromMessages(schema.FString,
-		schema.SystemMessage("你是一个文档改写系统的协调者。你的任务是：\n"+
-			"1. 接收用户的文档改写请求和文件路径\n"+
-			"2. 将任务转交给 SummaryAgent 进行改写\n"+
-			"3. SummaryAgent 会使用 read_document 工具读取文件，然后根据用户背景信息和改写原则进行改写\n"+
-			"4. 改写完成后，文档会被自动保存到 markdown 文件中"),
-		schema.UserMessage("请你帮我改写这份技术文档，文档路径为：{filepath}"),
-	)
-
-	// 使用模板格式化消息
-	formattedMessages, err := promptTemplate.Format(ctx, map[string]any{
-		"filepath": documentPath,
-	})
-	if err != nil {
-		log.Fatalf("格式化 prompt 模板失败: %v", err)
+	if errors.Is(err, errNotApproved) {
+		fmt.Fprintf(os.Stderr, "%s警告: %v%s\n", utils.Yellow, err, utils.Reset)
+		os.Exit(3)
 	}
-
-	iter := supervisorAgent.Run(ctx, &adk.AgentInput{
-		Messages: formattedMessages,
-	})
-
-	// 处理执行过程中的事件
-	for {
-		event, ok := iter.Next()
-		if !ok {
-			break
-		}
-		if event.Err != nil {
-			log.Fatal(event.Err)
-		}
-		utils.Event(event)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s错误: %v%s\n", utils.Red, err, utils.Reset)
+		os.Exit(1)
 	}
-
-	log.Println()
-	log.Println("========== 文档改写任务完成 ==========")
 }
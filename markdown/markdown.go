@@ -0,0 +1,94 @@
+// Package markdown 提供各个检查和后处理模块共用的 Markdown 扫描：识别围栏代码块和 ATX 标题。
+// 格式检查、内容覆盖检查、代码编译检查、后处理和按章节改写都需要跳过代码块里的内容，
+// 统一在这里实现，保证它们对"哪些行是代码、哪些行是标题"的判断一致
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+var headingRe = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// FenceMarker 返回代码块围栏（``` 或 ~~~，至少三个），不是围栏时返回空。line 需要先去掉首尾空白
+func FenceMarker(line string) string {
+	for _, c := range []string{"`", "~"} {
+		if n := len(line) - len(strings.TrimLeft(line, c)); n >= 3 {
+			return strings.Repeat(c, n)
+		}
+	}
+	return ""
+}
+
+// ClosesFence 判断该行是否结束以 fence 开始的代码块
+func ClosesFence(line, fence string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, fence) && strings.Trim(line, fence[:1]) == ""
+}
+
+// CodeLines 标记每一行是否属于围栏代码块（包括围栏本身），没有闭合的代码块延续到文末
+func CodeLines(lines []string) []bool {
+	code := make([]bool, len(lines))
+	fence := ""
+	for i, line := range lines {
+		if fence == "" {
+			fence = FenceMarker(strings.TrimSpace(line))
+			code[i] = fence != ""
+			continue
+		}
+		code[i] = true
+		if ClosesFence(line, fence) {
+			fence = ""
+		}
+	}
+	return code
+}
+
+// ProseLines 返回代码块以外的行
+func ProseLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	code := CodeLines(lines)
+	var out []string
+	for i, line := range lines {
+		if !code[i] {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// ParseHeading 解析一行 ATX 标题，返回级别和去掉结尾 # 的标题文字，不是标题时 ok 为 false。
+// 不判断该行是否在代码块中，需要时配合 CodeLines 或 Headings 使用
+func ParseHeading(line string) (level int, text string, ok bool) {
+	m := headingRe.FindStringSubmatch(line)
+	if m == nil {
+		return 0, "", false
+	}
+	return len(m[1]), m[2], true
+}
+
+// IsHeading 判断一行是否是 ATX 标题
+func IsHeading(line string) bool {
+	return headingRe.MatchString(line)
+}
+
+// Heading 代码块以外的标题，Line 从 1 开始
+type Heading struct {
+	Line  int
+	Level int
+	Text  string
+}
+
+// Headings 按顺序返回代码块以外的标题
+func Headings(lines []string) []Heading {
+	var hs []Heading
+	for i, code := range CodeLines(lines) {
+		if code {
+			continue
+		}
+		if level, text, ok := ParseHeading(lines[i]); ok {
+			hs = append(hs, Heading{Line: i + 1, Level: level, Text: text})
+		}
+	}
+	return hs
+}
@@ -0,0 +1,67 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const scanDoc = "# Kafka 入门 #\n" +
+	"\n" +
+	"````markdown\n" +
+	"```go\n" +
+	"# 代码块中的标题\n" +
+	"```\n" +
+	"````\n" +
+	"\n" +
+	"## 生产者\n" +
+	"~~~\n" +
+	"#### 没有闭合的代码块延续到文末\n"
+
+// TestCodeLines 测试围栏代码块的识别：外层围栏更长时内层的 ``` 不结束代码块，没有闭合的代码块延续到文末
+func TestCodeLines(t *testing.T) {
+	lines := strings.Split(scanDoc, "\n")
+	got := CodeLines(lines)
+	want := []bool{false, false, true, true, true, true, true, false, false, true, true, true}
+	if !slices.Equal(got, want) {
+		t.Errorf("CodeLines = %v，期望 %v", got, want)
+	}
+	if prose := ProseLines(scanDoc); len(prose) != 4 || prose[3] != "## 生产者" {
+		t.Errorf("ProseLines = %q", prose)
+	}
+}
+
+// TestHeadings 测试只返回代码块以外的标题，标题文字去掉结尾的 #
+func TestHeadings(t *testing.T) {
+	got := Headings(strings.Split(scanDoc, "\n"))
+	want := []Heading{{Line: 1, Level: 1, Text: "Kafka 入门"}, {Line: 9, Level: 2, Text: "生产者"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("Headings = %+v，期望 %+v", got, want)
+	}
+	if _, _, ok := ParseHeading("#没有空格"); ok {
+		t.Error("# 后没有空格不是标题")
+	}
+	if _, _, ok := ParseHeading("####### 七级"); ok {
+		t.Error("超过 6 个 # 不是标题")
+	}
+}
+
+// TestFence 测试围栏的识别和闭合：闭合围栏的字符相同、长度不短于开头围栏且后面没有其他内容
+func TestFence(t *testing.T) {
+	cases := []struct {
+		line, fence string
+	}{
+		{"```go", "```"},
+		{"~~~~", "~~~~"},
+		{"``", ""},
+		{"正文", ""},
+	}
+	for _, c := range cases {
+		if got := FenceMarker(c.line); got != c.fence {
+			t.Errorf("FenceMarker(%q) = %q，期望 %q", c.line, got, c.fence)
+		}
+	}
+	if !ClosesFence("  ````  ", "```") || ClosesFence("```go", "```") || ClosesFence("~~~", "```") || ClosesFence("```", "````") {
+		t.Error("ClosesFence 判断错误")
+	}
+}
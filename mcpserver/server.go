@@ -0,0 +1,266 @@
+package mcpserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// ProtocolVersion 默认支持的 MCP 协议版本
+const ProtocolVersion = "2024-11-05"
+
+// JSON-RPC 错误码
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type toolDescriptor struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InputSchema any    `json:"inputSchema"`
+}
+
+type callParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type callResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError"`
+}
+
+// Server 基于标准输入输出的 MCP 服务，把 eino 的 InvokableTool 暴露为 MCP 工具。
+// 消息使用换行分隔的 JSON-RPC 2.0 编码，tools/call 在独立的 goroutine 中执行，
+// 可以通过 notifications/cancelled 取消
+type Server struct {
+	name    string
+	version string
+	tools   map[string]tool.InvokableTool
+	order   []string
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+
+	callsMu sync.Mutex
+	calls   map[string]context.CancelFunc
+}
+
+// NewServer 创建 MCP 服务
+func NewServer(name, version string) *Server {
+	return &Server{
+		name:    name,
+		version: version,
+		tools:   map[string]tool.InvokableTool{},
+		calls:   map[string]context.CancelFunc{},
+	}
+}
+
+// AddTool 注册一个工具，工具名和参数 schema 取自 tool.Info
+func (s *Server) AddTool(ctx context.Context, t tool.InvokableTool) error {
+	info, err := t.Info(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.tools[info.Name]; !ok {
+		s.order = append(s.order, info.Name)
+	}
+	s.tools[info.Name] = t
+	return nil
+}
+
+// Serve 从 r 读取请求并把响应写入 w，直到 r 关闭或 ctx 被取消
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.enc = json.NewEncoder(w)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.reply(nil, nil, &rpcError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if req.JSONRPC != "2.0" {
+			s.reply(req.ID, nil, &rpcError{Code: codeInvalidRequest, Message: "jsonrpc 必须为 2.0"})
+			continue
+		}
+
+		if req.Method == "tools/call" && req.ID != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.handleCall(ctx, req)
+			}()
+			continue
+		}
+		s.handle(ctx, req)
+	}
+	return scanner.Err()
+}
+
+// handle 处理除 tools/call 以外的请求和通知
+func (s *Server) handle(ctx context.Context, req request) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		version := params.ProtocolVersion
+		if version == "" {
+			version = ProtocolVersion
+		}
+		s.reply(req.ID, map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": s.name, "version": s.version},
+		}, nil)
+	case "ping":
+		s.reply(req.ID, map[string]any{}, nil)
+	case "tools/list":
+		s.handleList(ctx, req)
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			s.callsMu.Lock()
+			if cancel, ok := s.calls[string(params.RequestID)]; ok {
+				cancel()
+			}
+			s.callsMu.Unlock()
+		}
+	default:
+		// 通知没有 id，不需要响应
+		if req.ID != nil {
+			s.reply(req.ID, nil, &rpcError{Code: codeMethodNotFound, Message: "未知方法: " + req.Method})
+		}
+	}
+}
+
+func (s *Server) handleList(ctx context.Context, req request) {
+	descriptors := make([]toolDescriptor, 0, len(s.order))
+	for _, name := range s.order {
+		info, err := s.tools[name].Info(ctx)
+		if err != nil {
+			s.reply(req.ID, nil, &rpcError{Code: codeInvalidRequest, Message: err.Error()})
+			return
+		}
+		var inputSchema any = map[string]any{"type": "object"}
+		if info.ParamsOneOf != nil {
+			js, err := info.ParamsOneOf.ToJSONSchema()
+			if err != nil {
+				s.reply(req.ID, nil, &rpcError{Code: codeInvalidRequest, Message: err.Error()})
+				return
+			}
+			if js != nil {
+				inputSchema = js
+			}
+		}
+		descriptors = append(descriptors, toolDescriptor{
+			Name:        info.Name,
+			Description: info.Desc,
+			InputSchema: inputSchema,
+		})
+	}
+	s.reply(req.ID, map[string]any{"tools": descriptors}, nil)
+}
+
+// handleCall 执行工具调用，工具返回的错误以 isError 结果返回给客户端而不是 JSON-RPC 错误
+func (s *Server) handleCall(ctx context.Context, req request) {
+	var params callParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		s.reply(req.ID, nil, &rpcError{Code: codeInvalidParams, Message: err.Error()})
+		return
+	}
+	t, ok := s.tools[params.Name]
+	if !ok {
+		s.reply(req.ID, nil, &rpcError{Code: codeInvalidParams, Message: "未知工具: " + params.Name})
+		return
+	}
+
+	args := string(params.Arguments)
+	if args == "" || args == "null" {
+		args = "{}"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	key := string(req.ID)
+	s.callsMu.Lock()
+	s.calls[key] = cancel
+	s.callsMu.Unlock()
+	defer func() {
+		s.callsMu.Lock()
+		delete(s.calls, key)
+		s.callsMu.Unlock()
+		cancel()
+	}()
+
+	log.Printf("[mcp] 调用工具 %s", params.Name)
+	out, err := t.InvokableRun(ctx, args)
+	if err != nil {
+		text := out
+		if text == "" {
+			text = err.Error()
+		}
+		s.reply(req.ID, callResult{Content: []textContent{{Type: "text", Text: text}}, IsError: true}, nil)
+		return
+	}
+	s.reply(req.ID, callResult{Content: []textContent{{Type: "text", Text: out}}}, nil)
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rpcErr *rpcError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.enc.Encode(response{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr}); err != nil {
+		log.Printf("[mcp] 写入响应失败: %v", fmt.Errorf("id %s: %w", id, err))
+	}
+}
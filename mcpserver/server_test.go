@@ -0,0 +1,96 @@
+package mcpserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+type echoInput struct {
+	Text string `json:"text" jsonschema_description:"要回显的文本"`
+}
+
+func newEchoTool(t *testing.T) tool.InvokableTool {
+	t.Helper()
+	echo, err := utils.InferTool("echo", "回显输入", func(ctx context.Context, in *echoInput) (string, error) {
+		if in.Text == "" {
+			return "", errors.New("text 不能为空")
+		}
+		return in.Text, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return echo
+}
+
+// TestServeStdio 测试 initialize、tools/list、tools/call 和未知方法
+func TestServeStdio(t *testing.T) {
+	s := NewServer("test", "0.0.1")
+	if err := s.AddTool(context.Background(), newEchoTool(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"你好"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"resources/list"}`,
+	}, "\n")
+
+	pr, pw := io.Pipe()
+	go func() {
+		_ = s.Serve(context.Background(), strings.NewReader(input), pw)
+		pw.Close()
+	}()
+
+	responses := map[string]map[string]any{}
+	scanner := bufio.NewScanner(pr)
+	for scanner.Scan() {
+		var resp map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			t.Fatalf("响应不是合法 JSON: %s", scanner.Text())
+		}
+		id, _ := json.Marshal(resp["id"])
+		responses[string(id)] = resp
+	}
+
+	// 通知不应该有响应
+	if len(responses) != 5 {
+		t.Fatalf("期望 5 个响应，实际 %d: %v", len(responses), responses)
+	}
+
+	init := responses["1"]["result"].(map[string]any)
+	if init["protocolVersion"] != "2025-03-26" {
+		t.Fatalf("initialize 应回应客户端的协议版本: %v", init)
+	}
+
+	list := responses["2"]["result"].(map[string]any)["tools"].([]any)
+	echo := list[0].(map[string]any)
+	if echo["name"] != "echo" || echo["inputSchema"].(map[string]any)["type"] != "object" {
+		t.Fatalf("tools/list 结果不符合预期: %v", list)
+	}
+
+	call := responses["3"]["result"].(map[string]any)
+	text := call["content"].([]any)[0].(map[string]any)["text"]
+	if text != "你好" || call["isError"] != false {
+		t.Fatalf("tools/call 结果不符合预期: %v", call)
+	}
+
+	if failed := responses["4"]["result"].(map[string]any); failed["isError"] != true {
+		t.Fatalf("工具报错时应返回 isError: %v", failed)
+	}
+
+	if rpcErr := responses["5"]["error"].(map[string]any); rpcErr["code"] != float64(codeMethodNotFound) {
+		t.Fatalf("未知方法应返回 -32601: %v", rpcErr)
+	}
+}
@@ -0,0 +1,200 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	myagent "eino_test/agent"
+	"eino_test/components"
+	"eino_test/config"
+	"eino_test/persona"
+	"eino_test/tools"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+)
+
+// RewriteDocumentInput rewrite_document 工具的输入参数
+type RewriteDocumentInput struct {
+	Filepath      string `json:"filepath" jsonschema_description:"要改写的 markdown 文件路径"`
+	OutputDir     string `json:"output_dir,omitempty" jsonschema_description:"改写后文档的保存目录，默认使用服务启动时的配置"`
+	Persona       string `json:"persona,omitempty" jsonschema_description:"读者画像名称（如 backend、frontend、data-analyst）或 YAML 格式的画像内容，默认使用服务启动时的配置"`
+	MaxIterations int    `json:"max_iterations,omitempty" jsonschema_description:"改写-评审循环的最大迭代次数"`
+}
+
+// ReviewDocumentInput review_document 工具的输入参数
+type ReviewDocumentInput struct {
+	Filepath string `json:"filepath,omitempty" jsonschema_description:"要评审的 markdown 文件路径，与 content 二选一"`
+	Content  string `json:"content,omitempty" jsonschema_description:"要评审的文档内容，与 filepath 二选一"`
+	Persona  string `json:"persona,omitempty" jsonschema_description:"读者画像名称（如 backend、frontend、data-analyst）或 YAML 格式的画像内容，默认使用服务启动时的配置"`
+}
+
+// SearchKnowledgeBaseInput search_knowledge_base 工具的输入参数
+type SearchKnowledgeBaseInput struct {
+	Query string `json:"query" jsonschema_description:"检索内容"`
+	TopK  int    `json:"top_k,omitempty" jsonschema_description:"返回的结果数量，默认 5"`
+}
+
+// NewDefaultServer 创建暴露改写流程的 MCP 服务，cfg 为各工具未指定参数时使用的默认配置，
+// app 提供知识库检索所需的 Milvus 和向量模型配置
+func NewDefaultServer(ctx context.Context, app *config.Config, cfg *myagent.Config) (*Server, error) {
+	s := NewServer("doc-rewriter", "0.1.0")
+
+	constructors := []func(cfg *myagent.Config) (tool.BaseTool, error){
+		newRewriteDocumentTool,
+		newReviewDocumentTool,
+		func(*myagent.Config) (tool.BaseTool, error) { return tools.NewReadDocumentTool() },
+		func(*myagent.Config) (tool.BaseTool, error) { return newSearchKnowledgeBaseTool(app) },
+	}
+	for _, newTool := range constructors {
+		t, err := newTool(cfg)
+		if err != nil {
+			return nil, err
+		}
+		invokable, ok := t.(tool.InvokableTool)
+		if !ok {
+			return nil, fmt.Errorf("工具 %T 不支持同步调用", t)
+		}
+		if err := s.AddTool(ctx, invokable); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// newRewriteDocumentTool 运行完整的改写流程并返回保存后的文档内容
+func newRewriteDocumentTool(cfg *myagent.Config) (tool.BaseTool, error) {
+	return utils.InferTool(
+		"rewrite_document",
+		"对指定的 markdown 文档执行完整的改写-评审流程，保存改写结果并返回改写后的文档内容",
+		func(ctx context.Context, input *RewriteDocumentInput) (string, error) {
+			if _, err := os.Stat(input.Filepath); err != nil {
+				return "", fmt.Errorf("文档文件不存在: %s", input.Filepath)
+			}
+
+			runCfg := *cfg
+			if input.OutputDir != "" {
+				runCfg.OutputDir = input.OutputDir
+			}
+			if input.Persona != "" {
+				p, err := persona.Lookup(input.Persona, runCfg.PersonasDir)
+				if err != nil {
+					return "", err
+				}
+				runCfg.Persona = p
+			}
+			if input.MaxIterations > 0 {
+				runCfg.MaxIterations = input.MaxIterations
+			}
+
+			result, err := myagent.RunRewrite(ctx, &runCfg, input.Filepath, nil)
+			if err != nil {
+				return "", fmt.Errorf("改写失败: %w", err)
+			}
+			if result.OutputPath == "" {
+				return "", fmt.Errorf("改写流程结束但没有保存文档（迭代 %d 次）", result.Iterations)
+			}
+
+			content, err := os.ReadFile(result.OutputPath)
+			if err != nil {
+				return "", fmt.Errorf("读取改写结果失败: %w", err)
+			}
+			return fmt.Sprintf("文档已保存到: %s\n迭代次数: %d\n评审通过: %v\n\n%s",
+				result.OutputPath, result.Iterations, result.Approved, content), nil
+		},
+	)
+}
+
+// newReviewDocumentTool 只运行 ReviewerAgent 并返回评审意见
+func newReviewDocumentTool(cfg *myagent.Config) (tool.BaseTool, error) {
+	return utils.InferTool(
+		"review_document",
+		"按 10 项评审标准评审一份改写后的文档，返回评审结论和改进建议，不会保存文档",
+		func(ctx context.Context, input *ReviewDocumentInput) (string, error) {
+			content := input.Content
+			if content == "" {
+				if input.Filepath == "" {
+					return "", fmt.Errorf("filepath 和 content 至少需要提供一个")
+				}
+				data, err := os.ReadFile(input.Filepath)
+				if err != nil {
+					return "", fmt.Errorf("读取文档失败: %w", err)
+				}
+				content = string(data)
+			}
+
+			runCfg := *cfg
+			if input.Persona != "" {
+				p, err := persona.Lookup(input.Persona, runCfg.PersonasDir)
+				if err != nil {
+					return "", err
+				}
+				runCfg.Persona = p
+			}
+
+			var review strings.Builder
+			err := myagent.RunReview(ctx, &runCfg, content, func(event *adk.AgentEvent) {
+				if event.Output == nil || event.Output.MessageOutput == nil {
+					return
+				}
+				mo := event.Output.MessageOutput
+				if mo.Role == schema.Assistant && mo.Message != nil && mo.Message.Content != "" {
+					review.WriteString(mo.Message.Content)
+					review.WriteString("\n")
+				}
+			})
+			if err != nil {
+				return "", fmt.Errorf("评审失败: %w", err)
+			}
+			return strings.TrimSpace(review.String()), nil
+		},
+	)
+}
+
+// newSearchKnowledgeBaseTool 在 Milvus 知识库中检索，首次调用时才连接 Milvus
+func newSearchKnowledgeBaseTool(app *config.Config) (tool.BaseTool, error) {
+	var (
+		once    sync.Once
+		r       retriever.Retriever
+		initErr error
+	)
+
+	return utils.InferTool(
+		"search_knowledge_base",
+		"在已索引的 Milvus 知识库中检索与查询相关的文档片段",
+		func(ctx context.Context, input *SearchKnowledgeBaseInput) (string, error) {
+			once.Do(func() {
+				if initErr = components.TryInitClient(ctx, app.Milvus.Address); initErr == nil {
+					r = components.NewRetriever(ctx, components.NewQwenEmbedder(ctx, app.Embedding), app.Milvus.Collection)
+				}
+			})
+			if initErr != nil {
+				return "", fmt.Errorf("连接 Milvus 失败: %w", initErr)
+			}
+
+			topK := input.TopK
+			if topK <= 0 {
+				topK = 5
+			}
+			docs, err := r.Retrieve(ctx, input.Query, retriever.WithTopK(topK))
+			if err != nil {
+				return "", fmt.Errorf("检索失败: %w", err)
+			}
+			if len(docs) == 0 {
+				return "没有找到相关内容", nil
+			}
+
+			var sb strings.Builder
+			for i, doc := range docs {
+				fmt.Fprintf(&sb, "[%d] %s (score: %.4f)\n%s\n\n", i+1, doc.ID, doc.Score(), doc.Content)
+			}
+			return strings.TrimSpace(sb.String()), nil
+		},
+	)
+}
@@ -0,0 +1,65 @@
+package mermaid
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CLIRenderer 调用本地安装的 mermaid-cli（mmdc）渲染，结果与浏览器中的效果一致，不需要联网
+type CLIRenderer struct {
+	// Path mmdc 可执行文件路径，为空时从 PATH 中查找
+	Path string
+}
+
+// NewCLIRenderer 创建 mmdc 渲染器
+func NewCLIRenderer(path string) *CLIRenderer {
+	return &CLIRenderer{Path: path}
+}
+
+func (r *CLIRenderer) Name() string { return "mmdc" }
+
+func (r *CLIRenderer) Render(ctx context.Context, code, format string) (*Result, error) {
+	format, err := normalizeFormat(code, format)
+	if err != nil {
+		return nil, err
+	}
+
+	path := r.Path
+	if path == "" {
+		path = "mmdc"
+	}
+	bin, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("找不到 mermaid-cli（%s），可以通过 npm install -g @mermaid-js/mermaid-cli 安装: %w", path, ErrUnsupported)
+	}
+
+	dir, err := os.MkdirTemp("", "mermaid-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "diagram.mmd")
+	output := filepath.Join(dir, "diagram."+format)
+	if err := os.WriteFile(input, []byte(code), 0644); err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "-i", input, "-o", output, "-b", "white")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("mmdc 渲染失败: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("读取 mmdc 输出失败: %v", err)
+	}
+	return &Result{URL: dataURL(format, data), Data: data, Format: format, Renderer: r.Name()}, nil
+}
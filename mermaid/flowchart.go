@@ -0,0 +1,591 @@
+package mermaid
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// flowNode 流程图节点，x、y 为中心坐标
+type flowNode struct {
+	id    string
+	label []string
+	shape string
+	w, h  float64
+	x, y  float64
+	rank  int
+	order float64
+}
+
+// flowEdge 流程图连线
+type flowEdge struct {
+	from, to string
+	label    string
+	// style 线型：normal、dotted、thick
+	style string
+	// head、tail 终点和起点的箭头：arrow、circle、cross，空表示没有
+	head, tail string
+	// back 是否是形成环的回边，布局时不参与分层，绘制时绕开中间的节点
+	back bool
+}
+
+type flowchart struct {
+	dir   string
+	nodes map[string]*flowNode
+	// ids 节点首次出现的顺序，作为布局的初始顺序
+	ids   []string
+	edges []*flowEdge
+	// strict 校验时使用，拒绝渲染器能容忍但 mermaid.js 无法解析的写法
+	strict bool
+}
+
+// nodeShapes 节点形状的起止符号，较长的符号排在前面
+var nodeShapes = []struct{ open, close, shape string }{
+	{"([", "])", "stadium"},
+	{"[[", "]]", "subroutine"},
+	{"[(", ")]", "cylinder"},
+	{"(((", ")))", "circle"},
+	{"((", "))", "circle"},
+	{"{{", "}}", "hexagon"},
+	{"[/", "/]", "parallelogram"},
+	{`[\`, `\]`, "parallelogram"},
+	{"[", "]", "rect"},
+	{"(", ")", "round"},
+	{"{", "}", "diamond"},
+	{">", "]", "asymmetric"},
+}
+
+var (
+	nodeIDRe    = regexp.MustCompile(`^[\p{L}\p{N}_]+`)
+	classRe     = regexp.MustCompile(`^:::[\w-]+`)
+	labeledLink = regexp.MustCompile(`^(<?)(--|==|-\.)\s+(.+?)\s+(-{2,}[>ox]|-{3,}|={2,}[>ox]|={3,}|\.+-[>ox]?)`)
+	plainLink   = regexp.MustCompile(`^(<?)(-{2,}|={2,}|-\.+-)([>ox]?)`)
+	pipeLabel   = regexp.MustCompile(`^\s*\|([^|]*)\|`)
+)
+
+// flowKeywords 不影响节点和连线的语句，渲染时忽略
+var flowKeywords = map[string]bool{
+	"classDef": true, "class": true, "style": true, "linkStyle": true,
+	"click": true, "direction": true, "subgraph": true, "end": true,
+}
+
+func parseFlowchart(header, lines []string) (*flowchart, error) {
+	fc := &flowchart{dir: "TD", nodes: map[string]*flowNode{}}
+	if len(header) > 0 {
+		fc.dir = strings.ToUpper(strings.TrimSuffix(header[0], ";"))
+	}
+	switch fc.dir {
+	case "TB":
+		fc.dir = "TD"
+	case "TD", "BT", "LR", "RL":
+	default:
+		return nil, fmt.Errorf("流程图方向 %q 不合法，可选值: TD、TB、BT、LR、RL", fc.dir)
+	}
+
+	for i, line := range lines {
+		for _, stmt := range strings.Split(line, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if stmt == "" || flowKeywords[strings.Fields(stmt)[0]] {
+				continue
+			}
+			if err := fc.parseStatement(stmt); err != nil {
+				return nil, fmt.Errorf("第 %d 行: %w", i+2, err)
+			}
+		}
+	}
+	if len(fc.nodes) == 0 {
+		return nil, fmt.Errorf("流程图中没有节点")
+	}
+	return fc, nil
+}
+
+// parseStatement 解析一条语句，支持链式连线（A --> B --> C）和 & 连接多个节点
+func (fc *flowchart) parseStatement(stmt string) error {
+	rest := stmt
+	prev, err := fc.parseGroup(&rest)
+	if err != nil {
+		return err
+	}
+	for {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return nil
+		}
+		edge, ok := parseLink(&rest)
+		if !ok {
+			return fmt.Errorf("无法解析连线: %q", rest)
+		}
+		rest = strings.TrimSpace(rest)
+		next, err := fc.parseGroup(&rest)
+		if err != nil {
+			return err
+		}
+		for _, from := range prev {
+			for _, to := range next {
+				e := *edge
+				e.from, e.to = from, to
+				fc.edges = append(fc.edges, &e)
+			}
+		}
+		prev = next
+	}
+}
+
+func (fc *flowchart) parseGroup(rest *string) ([]string, error) {
+	var ids []string
+	for {
+		id, err := fc.parseNode(rest)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		trimmed := strings.TrimSpace(*rest)
+		if !strings.HasPrefix(trimmed, "&") {
+			return ids, nil
+		}
+		*rest = strings.TrimSpace(trimmed[1:])
+	}
+}
+
+// parseNode 解析节点引用，带形状时同时设置节点的文字和形状
+func (fc *flowchart) parseNode(rest *string) (string, error) {
+	id := nodeIDRe.FindString(*rest)
+	if id == "" {
+		return "", fmt.Errorf("缺少节点: %q", *rest)
+	}
+	if fc.strict && id == "end" {
+		return "", fmt.Errorf("end 是关键字，不能作为节点 ID，可以改成 End 或 done")
+	}
+	s := (*rest)[len(id):]
+
+	node, ok := fc.nodes[id]
+	if !ok {
+		node = &flowNode{id: id, label: []string{id}, shape: "rect"}
+		fc.nodes[id] = node
+		fc.ids = append(fc.ids, id)
+	}
+
+	for _, shape := range nodeShapes {
+		if !strings.HasPrefix(s, shape.open) {
+			continue
+		}
+		body := s[len(shape.open):]
+		var label string
+		if strings.HasPrefix(body, `"`) {
+			end := strings.Index(body[1:], `"`)
+			if end < 0 {
+				return "", fmt.Errorf("节点 %s 的文字缺少结束引号", id)
+			}
+			label = body[1 : end+1]
+			body = strings.TrimLeft(body[end+2:], " ")
+			if !strings.HasPrefix(body, shape.close) {
+				return "", fmt.Errorf("节点 %s 缺少 %s", id, shape.close)
+			}
+			body = body[len(shape.close):]
+		} else {
+			end := strings.Index(body, shape.close)
+			if end < 0 {
+				return "", fmt.Errorf("节点 %s 缺少 %s", id, shape.close)
+			}
+			label = body[:end]
+			body = body[end+len(shape.close):]
+			if fc.strict && strings.ContainsAny(label, "()[]{}") {
+				return "", fmt.Errorf("节点 %s 的文字 %q 中有括号，需要用双引号包起来，例如 %s%s\"%s\"%s", id, label, id, shape.open, label, shape.close)
+			}
+		}
+		node.label = labelLines(label)
+		node.shape = shape.shape
+		s = body
+		break
+	}
+	s = classRe.ReplaceAllString(s, "")
+	*rest = s
+	return id, nil
+}
+
+// parseLink 解析连线，支持 -->、---、-.->、==>、--o、--x、<--> 以及 |文字| 和 -- 文字 --> 两种标签写法
+func parseLink(rest *string) (*flowEdge, bool) {
+	edge := &flowEdge{style: "normal"}
+	var open, close string
+	if m := labeledLink.FindStringSubmatch(*rest); m != nil {
+		open, close = m[2], m[4]
+		edge.tail = headMarker(m[1])
+		edge.label = m[3]
+		*rest = (*rest)[len(m[0]):]
+	} else if m := plainLink.FindStringSubmatch(*rest); m != nil {
+		open, close = m[2], m[3]
+		after := (*rest)[len(m[0]):]
+		// --o、--x 后面紧跟文字时，o、x 是下一个节点名的一部分
+		if (close == "o" || close == "x") && nodeIDRe.MatchString(after) {
+			close = ""
+			after = (*rest)[len(m[0])-1:]
+		}
+		edge.tail = headMarker(m[1])
+		*rest = after
+		if pm := pipeLabel.FindStringSubmatch(*rest); pm != nil {
+			edge.label = strings.TrimSpace(pm[1])
+			*rest = (*rest)[len(pm[0]):]
+		}
+	} else {
+		return nil, false
+	}
+
+	switch {
+	case strings.Contains(open+close, "."):
+		edge.style = "dotted"
+	case strings.HasPrefix(open, "="):
+		edge.style = "thick"
+	}
+	if close != "" {
+		edge.head = headMarker(close[len(close)-1:])
+	}
+	edge.label = strings.Trim(edge.label, `"`)
+	return edge, true
+}
+
+func headMarker(s string) string {
+	switch s {
+	case ">", "<":
+		return "arrow"
+	case "o":
+		return "circle"
+	case "x":
+		return "cross"
+	}
+	return ""
+}
+
+// vertical 是否从上到下（或从下到上）排布
+func (fc *flowchart) vertical() bool {
+	return fc.dir == "TD" || fc.dir == "BT"
+}
+
+// layout 分层布局：按连线方向给节点分层，用重心法减少交叉，再计算坐标。返回画布大小
+func (fc *flowchart) layout() (float64, float64) {
+	for _, n := range fc.nodes {
+		n.measure()
+	}
+
+	// 深度优先遍历找出回边，去掉回边后按最长路径分层
+	out := map[string][]int{}
+	for i, e := range fc.edges {
+		out[e.from] = append(out[e.from], i)
+	}
+	state := map[string]int{}
+	var post []string
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = 1
+		for _, i := range out[id] {
+			to := fc.edges[i].to
+			switch state[to] {
+			case 0:
+				visit(to)
+			case 1:
+				fc.edges[i].back = true
+			}
+		}
+		state[id] = 2
+		post = append(post, id)
+	}
+	for _, id := range fc.ids {
+		if state[id] == 0 {
+			visit(id)
+		}
+	}
+	maxRank := 0
+	for i := len(post) - 1; i >= 0; i-- {
+		from := fc.nodes[post[i]]
+		for _, ei := range out[from.id] {
+			if fc.edges[ei].back {
+				continue
+			}
+			to := fc.nodes[fc.edges[ei].to]
+			to.rank = max(to.rank, from.rank+1)
+			maxRank = max(maxRank, to.rank)
+		}
+	}
+
+	layers := make([][]*flowNode, maxRank+1)
+	for _, id := range fc.ids {
+		n := fc.nodes[id]
+		n.order = float64(len(layers[n.rank]))
+		layers[n.rank] = append(layers[n.rank], n)
+	}
+	fc.orderLayers(layers)
+
+	// 层间距需要给连线上的文字留出位置
+	rankGap, nodeGap := 50.0, 30.0
+	for _, e := range fc.edges {
+		if e.label == "" {
+			continue
+		}
+		w, h := textSize(labelLines(e.label))
+		if fc.vertical() {
+			rankGap = max(rankGap, h+40)
+		} else {
+			rankGap = max(rankGap, w+40)
+		}
+	}
+
+	// main 为分层方向上的尺寸，cross 为层内排列方向上的尺寸
+	size := func(n *flowNode) (float64, float64) {
+		if fc.vertical() {
+			return n.h, n.w
+		}
+		return n.w, n.h
+	}
+	crossTotal := make([]float64, len(layers))
+	maxCross := 0.0
+	for r, layer := range layers {
+		for i, n := range layer {
+			_, c := size(n)
+			crossTotal[r] += c
+			if i > 0 {
+				crossTotal[r] += nodeGap
+			}
+		}
+		maxCross = max(maxCross, crossTotal[r])
+	}
+
+	mainPos := float64(margin)
+	for r, layer := range layers {
+		layerMain := 0.0
+		for _, n := range layer {
+			m, _ := size(n)
+			layerMain = max(layerMain, m)
+		}
+		crossPos := margin + (maxCross-crossTotal[r])/2
+		for _, n := range layer {
+			_, c := size(n)
+			mc, cc := mainPos+layerMain/2, crossPos+c/2
+			if fc.vertical() {
+				n.x, n.y = cc, mc
+			} else {
+				n.x, n.y = mc, cc
+			}
+			crossPos += c + nodeGap
+		}
+		mainPos += layerMain + rankGap
+	}
+	mainTotal := mainPos - rankGap + margin
+
+	width, height := maxCross+2*margin, mainTotal
+	if !fc.vertical() {
+		width, height = mainTotal, maxCross+2*margin
+	}
+	for _, n := range fc.nodes {
+		switch fc.dir {
+		case "BT":
+			n.y = height - n.y
+		case "RL":
+			n.x = width - n.x
+		}
+	}
+	return width, height
+}
+
+// orderLayers 上下交替按相邻层邻居的平均位置（重心）排序，减少连线交叉
+func (fc *flowchart) orderLayers(layers [][]*flowNode) {
+	neighbors := map[string][]*flowNode{}
+	for _, e := range fc.edges {
+		from, to := fc.nodes[e.from], fc.nodes[e.to]
+		if from != to {
+			neighbors[from.id] = append(neighbors[from.id], to)
+			neighbors[to.id] = append(neighbors[to.id], from)
+		}
+	}
+	sweep := func(r, adjacent int) {
+		layer := layers[r]
+		for _, n := range layer {
+			sum, count := 0.0, 0
+			for _, nb := range neighbors[n.id] {
+				if nb.rank == adjacent {
+					sum += nb.order
+					count++
+				}
+			}
+			if count > 0 {
+				n.order = sum / float64(count)
+			}
+		}
+		sort.SliceStable(layer, func(i, j int) bool { return layer[i].order < layer[j].order })
+		for i, n := range layer {
+			n.order = float64(i)
+		}
+	}
+	for iter := 0; iter < 4; iter++ {
+		for r := 1; r < len(layers); r++ {
+			sweep(r, r-1)
+		}
+		for r := len(layers) - 2; r >= 0; r-- {
+			sweep(r, r+1)
+		}
+	}
+}
+
+// measure 根据文字和形状计算节点大小
+func (n *flowNode) measure() {
+	tw, th := textSize(n.label)
+	n.w, n.h = max(tw+30, 50), th+20
+	switch n.shape {
+	case "circle":
+		d := max(tw, th) + 24
+		n.w, n.h = d, d
+	case "diamond":
+		n.w, n.h = tw+2*th+20, 2*th+20
+	case "stadium":
+		n.w += n.h / 2
+	case "hexagon", "parallelogram", "asymmetric":
+		n.w += 20
+	case "cylinder":
+		n.h += 10
+	}
+}
+
+// boundary 从节点中心指向 (tx, ty) 的射线与节点边框的交点
+func (n *flowNode) boundary(tx, ty float64) (float64, float64) {
+	dx, dy := tx-n.x, ty-n.y
+	if dx == 0 && dy == 0 {
+		return n.x, n.y
+	}
+	hw, hh := n.w/2, n.h/2
+	var t float64
+	switch n.shape {
+	case "circle":
+		t = hw / math.Hypot(dx, dy)
+	case "diamond":
+		t = 1 / (math.Abs(dx)/hw + math.Abs(dy)/hh)
+	default:
+		t = math.Min(safeDiv(hw, math.Abs(dx)), safeDiv(hh, math.Abs(dy)))
+	}
+	return n.x + dx*t, n.y + dy*t
+}
+
+func safeDiv(a, b float64) float64 {
+	if b == 0 {
+		return math.Inf(1)
+	}
+	return a / b
+}
+
+func (fc *flowchart) render() []byte {
+	width, height := fc.layout()
+	var b strings.Builder
+
+	// 同一对节点之间有多条连线时，按序号向两侧弯曲，避免重叠
+	type pair struct{ a, b string }
+	key := func(e *flowEdge) pair {
+		if e.from < e.to {
+			return pair{e.from, e.to}
+		}
+		return pair{e.to, e.from}
+	}
+	total, seen := map[pair]int{}, map[pair]int{}
+	for _, e := range fc.edges {
+		total[key(e)]++
+	}
+
+	var labels strings.Builder
+	for _, e := range fc.edges {
+		from, to := fc.nodes[e.from], fc.nodes[e.to]
+		class := "edge"
+		if e.style != "normal" {
+			class += " " + e.style
+		}
+		markers := markerAttr(e.tail, e.head)
+
+		var lx, ly float64
+		if from == to {
+			x, y, hw, hh := from.x, from.y, from.w/2, from.h/2
+			fmt.Fprintf(&b, `<path class="%s" d="M%.1f,%.1f C%.1f,%.1f %.1f,%.1f %.1f,%.1f" %s/>`+"\n",
+				class, x+hw/2, y-hh, x+hw/2, y-hh-35, x+hw+35, y, x+hw, y, markers)
+			lx, ly = x+hw+20, y-hh-20
+		} else {
+			k := key(e)
+			offset := (float64(seen[k]) - float64(total[k]-1)/2) * 30
+			seen[k]++
+			if k.a != e.from {
+				offset = -offset
+			}
+			if offset == 0 && e.back {
+				offset = 40
+			}
+			mx, my := (from.x+to.x)/2, (from.y+to.y)/2
+			dx, dy := to.x-from.x, to.y-from.y
+			length := math.Hypot(dx, dy)
+			cx, cy := mx-dy/length*offset*2, my+dx/length*offset*2
+			x1, y1 := from.boundary(cx, cy)
+			x2, y2 := to.boundary(cx, cy)
+			if offset == 0 {
+				x1, y1 = from.boundary(to.x, to.y)
+				x2, y2 = to.boundary(from.x, from.y)
+				fmt.Fprintf(&b, `<path class="%s" d="M%.1f,%.1f L%.1f,%.1f" %s/>`+"\n", class, x1, y1, x2, y2, markers)
+				lx, ly = (x1+x2)/2, (y1+y2)/2
+			} else {
+				fmt.Fprintf(&b, `<path class="%s" d="M%.1f,%.1f Q%.1f,%.1f %.1f,%.1f" %s/>`+"\n", class, x1, y1, cx, cy, x2, y2, markers)
+				lx, ly = 0.25*x1+0.5*cx+0.25*x2, 0.25*y1+0.5*cy+0.25*y2
+			}
+		}
+
+		if e.label != "" {
+			lines := labelLines(e.label)
+			w, h := textSize(lines)
+			fmt.Fprintf(&labels, `<rect class="label-bg" x="%.1f" y="%.1f" width="%.1f" height="%.1f"/>`+"\n", lx-w/2-4, ly-h/2-2, w+8, h+4)
+			writeText(&labels, lx, ly, lines, "middle", "")
+		}
+	}
+
+	for _, id := range fc.ids {
+		fc.nodes[id].render(&b)
+	}
+	b.WriteString(labels.String())
+	return svgDocument(width, height, b.String())
+}
+
+func (n *flowNode) render(b *strings.Builder) {
+	x, y, hw, hh := n.x, n.y, n.w/2, n.h/2
+	l, r, t, btm := x-hw, x+hw, y-hh, y+hh
+	polygon := func(points ...float64) {
+		b.WriteString(`<polygon class="node" points="`)
+		for i := 0; i < len(points); i += 2 {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(b, "%.1f,%.1f", points[i], points[i+1])
+		}
+		b.WriteString("\"/>\n")
+	}
+
+	switch n.shape {
+	case "circle":
+		fmt.Fprintf(b, `<circle class="node" cx="%.1f" cy="%.1f" r="%.1f"/>`+"\n", x, y, hw)
+	case "diamond":
+		polygon(x, t, r, y, x, btm, l, y)
+	case "hexagon":
+		polygon(l, y, l+15, t, r-15, t, r, y, r-15, btm, l+15, btm)
+	case "parallelogram":
+		polygon(l+10, t, r, t, r-10, btm, l, btm)
+	case "asymmetric":
+		polygon(l, t, r, t, r, btm, l, btm, l+12, y)
+	case "cylinder":
+		e := 5.0
+		fmt.Fprintf(b, `<path class="node" d="M%.1f,%.1f A%.1f,%.1f 0 0 1 %.1f,%.1f L%.1f,%.1f A%.1f,%.1f 0 0 1 %.1f,%.1f Z M%.1f,%.1f A%.1f,%.1f 0 0 0 %.1f,%.1f"/>`+"\n",
+			l, t+e, hw, e, r, t+e, r, btm-e, hw, e, l, btm-e, l, t+e, hw, e, r, t+e)
+	default:
+		rx := 0.0
+		switch n.shape {
+		case "round":
+			rx = 8
+		case "stadium":
+			rx = hh
+		}
+		fmt.Fprintf(b, `<rect class="node" x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="%.1f"/>`+"\n", l, t, n.w, n.h, rx)
+		if n.shape == "subroutine" {
+			fmt.Fprintf(b, `<path class="node" d="M%.1f,%.1f V%.1f M%.1f,%.1f V%.1f"/>`+"\n", l+8, t, btm, r-8, t, btm)
+		}
+	}
+	writeText(b, x, y, n.label, "middle", "")
+}
@@ -0,0 +1,215 @@
+package mermaid
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+)
+
+// checkSVG 校验输出是合法的 XML，并且包含所有期望的文字
+func checkSVG(t *testing.T, data []byte, texts ...string) {
+	t.Helper()
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("输出不是合法的 SVG: %v\n%s", err, data)
+		}
+	}
+	for _, text := range texts {
+		if !strings.Contains(string(data), ">"+text+"<") {
+			t.Errorf("SVG 中缺少文字 %q", text)
+		}
+	}
+}
+
+// TestRenderFlowchart 测试流程图的解析和布局
+func TestRenderFlowchart(t *testing.T) {
+	code := "graph TD\n" +
+		"    A[开始] --> B{判断}\n" +
+		"    B -->|是| C[处理1]\n" +
+		"    B -- 否 --> D((处理2))\n" +
+		"    C & D --> E[(结束)]\n" +
+		"    E -.-> A\n" +
+		"    %% 注释会被忽略\n" +
+		"    style A fill:#f9f"
+	lines := diagramLines(code)
+	fc, err := parseFlowchart(strings.Fields(lines[0])[1:], lines[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.nodes) != 5 || len(fc.edges) != 6 {
+		t.Fatalf("节点或连线数量不对: %d 个节点, %d 条连线", len(fc.nodes), len(fc.edges))
+	}
+	if fc.nodes["B"].shape != "diamond" || fc.nodes["D"].shape != "circle" || fc.nodes["E"].shape != "cylinder" {
+		t.Errorf("节点形状解析错误: B=%s D=%s E=%s", fc.nodes["B"].shape, fc.nodes["D"].shape, fc.nodes["E"].shape)
+	}
+	if e := fc.edges[2]; e.label != "否" || e.head != "arrow" {
+		t.Errorf("带文字的连线解析错误: %+v", e)
+	}
+	if e := fc.edges[5]; e.style != "dotted" {
+		t.Errorf("虚线解析错误: %+v", e)
+	}
+
+	data := fc.render()
+	if !fc.edges[5].back {
+		t.Error("E -.-> A 应该被识别为回边")
+	}
+	// 自上而下布局时，每条非回边的终点都在起点下方
+	for _, e := range fc.edges {
+		if !e.back && fc.nodes[e.to].y <= fc.nodes[e.from].y {
+			t.Errorf("%s -> %s 的终点没有位于起点下方", e.from, e.to)
+		}
+	}
+	checkSVG(t, data, "开始", "判断", "处理1", "处理2", "结束", "是", "否")
+
+	if _, err := RenderSVG("graph TD\n    A[未闭合 --> B"); err == nil {
+		t.Error("节点缺少 ] 时应该报错")
+	}
+}
+
+// TestRenderSequence 测试时序图的参与者、消息、注释和区块
+func TestRenderSequence(t *testing.T) {
+	code := "sequenceDiagram\n" +
+		"    autonumber\n" +
+		"    actor U as 用户\n" +
+		"    participant S as 服务器\n" +
+		"    U->>S: 查询请求\n" +
+		"    alt 命中缓存\n" +
+		"        S-->>U: 返回缓存\n" +
+		"    else 未命中\n" +
+		"        S->>DB: 查询 <数据库>\n" +
+		"        DB--xS: 超时\n" +
+		"    end\n" +
+		"    Note over U,S: 长连接"
+	lines := diagramLines(code)
+	sd, err := parseSequence(lines[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sd.participants) != 3 || !sd.participants[0].actor {
+		t.Fatalf("参与者解析错误: %+v", sd.participants)
+	}
+	if e := sd.events[5]; e.kind != "message" || e.head != "cross" || !e.dashed {
+		t.Errorf("--x 消息解析错误: %+v", e)
+	}
+
+	data := sd.render()
+	if sd.participants[0].x >= sd.participants[1].x || sd.participants[1].x >= sd.participants[2].x {
+		t.Error("参与者应该按出现顺序从左到右排列")
+	}
+	checkSVG(t, data, "用户", "服务器", "DB", "1. 查询请求", "3. 查询 &lt;数据库&gt;", "长连接", "alt", "[未命中]")
+
+	for _, bad := range []string{
+		"sequenceDiagram\n    loop 每秒\n    A->>B: ping",
+		"sequenceDiagram\n    A->>B: ping\n    end",
+		"sequenceDiagram\n    这一行不是合法的语句",
+	} {
+		if _, err := RenderSVG(bad); err == nil {
+			t.Errorf("应该报错: %q", bad)
+		}
+	}
+}
+
+// TestNewRendererFallback 测试按配置组合渲染器，内置渲染器不支持的图表交给后备渲染器
+func TestNewRendererFallback(t *testing.T) {
+	cfg := config.Default().Mermaid
+	cfg.Renderer = "go"
+	cfg.Fallback = []string{"kroki"}
+	cfg.KrokiURL = "http://kroki.internal:8000/"
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	res, err := r.Render(ctx, "graph LR\n    A --> B", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Renderer != "go" || !strings.HasPrefix(res.URL, "data:image/svg+xml;base64,") || len(res.Data) == 0 {
+		t.Errorf("流程图应该由内置渲染器完成: %+v", res.Renderer)
+	}
+
+	class := "classDiagram\n    Animal <|-- Dog"
+	if _, err := NewSVGRenderer().Render(ctx, class, ""); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("类图应该返回 ErrUnsupported: %v", err)
+	}
+	res, err = r.Render(ctx, class, FormatPNG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "http://kroki.internal:8000/mermaid/png/"
+	if res.Renderer != "kroki" || !strings.HasPrefix(res.URL, prefix) {
+		t.Fatalf("类图应该交给 kroki: %+v", res)
+	}
+	// kroki 的编码是 deflate + URL 安全的 Base64
+	raw, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(res.URL, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, _ := io.ReadAll(zr)
+	if string(decoded) != class {
+		t.Errorf("kroki 编码解不回原文: %q", decoded)
+	}
+
+	if _, err := New(config.MermaidConfig{Renderer: "plantuml"}); err == nil {
+		t.Error("未知渲染器应该报错")
+	}
+}
+
+// TestValidate 测试离线语法检查，错误的行号相对于 Mermaid 代码
+func TestValidate(t *testing.T) {
+	for _, ok := range []string{
+		"graph TD\n    A[开始] --> B{判断}\n    B -->|是| C[\"处理(1)\"]\n    subgraph 子图\n        C --> D\n    end",
+		"sequenceDiagram\n    participant A as 客户端\n    A->>B: 请求\n    alt 成功\n        B-->>A: 响应\n    else 失败\n        B--xA: 错误\n    end\n    Note over A,B: 结束",
+		"%% 注释\nclassDiagram\n    class Animal {\n        +name string\n    }\n    Animal <|-- Dog",
+		"pie title 占比\n    \"A\" : 40\n    \"B\" : 60",
+	} {
+		if errs := Validate(ok); len(errs) > 0 {
+			t.Errorf("不应该报错: %v\n%s", errs, ok)
+		}
+	}
+
+	cases := []struct {
+		code string
+		line int
+		msg  string
+	}{
+		{"", 1, "为空"},
+		{"\nflowchat TD\n    A --> B", 2, "未知的图表类型"},
+		{"graph XY\n    A --> B", 1, "方向"},
+		{"graph TD\n    A[开始] --> B(处理(1))", 2, "需要用双引号"},
+		{"graph TD\n    A --> end", 2, "end 是关键字"},
+		{"graph TD\n    A --> B\n    B => C", 3, "连线写法"},
+		{"graph TD\n    subgraph 子图\n    A --> B", 2, "subgraph 缺少对应的 end"},
+		{"graph TD\n    A[\"开始] --> B", 2, "双引号不成对"},
+		{"sequenceDiagram\n    A->>B", 2, "缺少冒号"},
+		{"sequenceDiagram\n    loop 每秒\n        A->>B: ping", 2, "loop 区块缺少 end"},
+		{"sequenceDiagram\n    A->>B: ping\n    else\n    end", 3, "不在 alt/par/critical"},
+		{"classDiagram\n    class Animal {\n        +name string", 2, "缺少对应的 }"},
+	}
+	for _, c := range cases {
+		errs := Validate(c.code)
+		if len(errs) == 0 {
+			t.Errorf("应该报错: %q", c.code)
+			continue
+		}
+		if errs[0].Line != c.line || !strings.Contains(errs[0].Message, c.msg) {
+			t.Errorf("%q 的错误是 %v，期望第 %d 行包含 %q", c.code, errs, c.line, c.msg)
+		}
+	}
+}
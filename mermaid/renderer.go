@@ -0,0 +1,191 @@
+// Package mermaid 把 Mermaid 代码渲染为图片。在线服务（mermaid.ink、kroki）只生成图片链接，
+// 本地渲染（mmdc、内置 SVG 渲染）直接得到图片内容，适合无法联网的环境
+package mermaid
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"eino_test/config"
+)
+
+// 支持的输出格式
+const (
+	FormatSVG = "svg"
+	FormatPNG = "png"
+)
+
+// ErrUnsupported 渲染器不支持该图表类型或输出格式，调用方可以换用其他渲染器
+var ErrUnsupported = errors.New("渲染器不支持")
+
+// Result 渲染结果
+type Result struct {
+	// URL 图片地址。在线服务返回服务地址，本地渲染返回 data URL
+	URL string
+	// Data 本地渲染得到的图片内容，在线服务为空
+	Data []byte
+	// Format 图片格式，svg 或 png
+	Format string
+	// Renderer 实际完成渲染的渲染器名称
+	Renderer string
+}
+
+// Renderer Mermaid 渲染器
+type Renderer interface {
+	// Name 渲染器名称，与配置中的取值一致
+	Name() string
+	// Render 渲染 Mermaid 代码，format 为空时使用 svg
+	Render(ctx context.Context, code, format string) (*Result, error)
+}
+
+// New 根据配置创建渲染器，配置了 fallback 时按顺序组合成一个渲染链
+func New(cfg config.MermaidConfig) (Renderer, error) {
+	names := append([]string{cfg.Renderer}, cfg.Fallback...)
+	renderers := make([]Renderer, 0, len(names))
+	for _, name := range names {
+		r, err := newRenderer(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		renderers = append(renderers, r)
+	}
+	if len(renderers) == 1 {
+		return renderers[0], nil
+	}
+	return Chain(renderers...), nil
+}
+
+func newRenderer(cfg config.MermaidConfig, name string) (Renderer, error) {
+	switch name {
+	case "", "ink":
+		return NewInkRenderer(cfg.InkURL), nil
+	case "kroki":
+		return NewKrokiRenderer(cfg.KrokiURL), nil
+	case "mmdc":
+		return NewCLIRenderer(cfg.MmdcPath), nil
+	case "go":
+		return NewSVGRenderer(), nil
+	default:
+		return nil, fmt.Errorf("未知的 Mermaid 渲染器 %q，可选值: %s", name, strings.Join(config.MermaidRenderers, ", "))
+	}
+}
+
+// chain 依次尝试多个渲染器，返回第一个成功的结果
+type chain []Renderer
+
+// Chain 把多个渲染器组合成一个，前一个失败时使用下一个
+func Chain(renderers ...Renderer) Renderer {
+	return chain(renderers)
+}
+
+func (c chain) Name() string {
+	names := make([]string, len(c))
+	for i, r := range c {
+		names[i] = r.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (c chain) Render(ctx context.Context, code, format string) (*Result, error) {
+	var errs []error
+	for _, r := range c {
+		res, err := r.Render(ctx, code, format)
+		if err == nil {
+			return res, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", r.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// InkRenderer 生成 mermaid.ink 图片链接，不发起请求
+type InkRenderer struct {
+	BaseURL string
+}
+
+// NewInkRenderer 创建 mermaid.ink 渲染器，baseURL 为空时使用公共服务
+func NewInkRenderer(baseURL string) *InkRenderer {
+	if baseURL == "" {
+		baseURL = "https://mermaid.ink"
+	}
+	return &InkRenderer{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (r *InkRenderer) Name() string { return "ink" }
+
+func (r *InkRenderer) Render(_ context.Context, code, format string) (*Result, error) {
+	format, err := normalizeFormat(code, format)
+	if err != nil {
+		return nil, err
+	}
+	// mermaid.ink 接受不带填充的 URL 安全 Base64 编码
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(code))
+	url := fmt.Sprintf("%s/svg/%s", r.BaseURL, encoded)
+	if format == FormatPNG {
+		url = fmt.Sprintf("%s/img/%s?type=png", r.BaseURL, encoded)
+	}
+	return &Result{URL: url, Format: format, Renderer: r.Name()}, nil
+}
+
+// KrokiRenderer 生成 kroki 图片链接，不发起请求。可以指向自建的 kroki 服务
+type KrokiRenderer struct {
+	BaseURL string
+}
+
+// NewKrokiRenderer 创建 kroki 渲染器，baseURL 为空时使用公共服务
+func NewKrokiRenderer(baseURL string) *KrokiRenderer {
+	if baseURL == "" {
+		baseURL = "https://kroki.io"
+	}
+	return &KrokiRenderer{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (r *KrokiRenderer) Name() string { return "kroki" }
+
+func (r *KrokiRenderer) Render(_ context.Context, code, format string) (*Result, error) {
+	format, err := normalizeFormat(code, format)
+	if err != nil {
+		return nil, err
+	}
+	// kroki 的 GET 接口要求先 deflate 压缩再做 URL 安全的 Base64 编码
+	var buf bytes.Buffer
+	w, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	if _, err := w.Write([]byte(code)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	encoded := base64.URLEncoding.EncodeToString(buf.Bytes())
+	url := fmt.Sprintf("%s/mermaid/%s/%s", r.BaseURL, format, encoded)
+	return &Result{URL: url, Format: format, Renderer: r.Name()}, nil
+}
+
+// normalizeFormat 校验代码和输出格式，格式为空时使用 svg
+func normalizeFormat(code, format string) (string, error) {
+	if strings.TrimSpace(code) == "" {
+		return "", errors.New("mermaid 代码不能为空")
+	}
+	switch format {
+	case "":
+		return FormatSVG, nil
+	case FormatSVG, FormatPNG:
+		return format, nil
+	default:
+		return "", fmt.Errorf("输出格式只支持 svg 或 png，当前为 %q", format)
+	}
+}
+
+// dataURL 把图片内容编码为可以直接嵌入 Markdown 的 data URL
+func dataURL(format string, data []byte) string {
+	mime := "image/svg+xml"
+	if format == FormatPNG {
+		mime = "image/png"
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mime, base64.StdEncoding.EncodeToString(data))
+}
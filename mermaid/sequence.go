@@ -0,0 +1,389 @@
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// participant 时序图参与者，x 为生命线的横坐标
+type participant struct {
+	id    string
+	label []string
+	actor bool
+	w, h  float64
+	x     float64
+}
+
+// seqEvent 时序图中按顺序排列的元素
+type seqEvent struct {
+	// kind 元素类型：message、note、start（loop/alt 等区块开始）、split（else/and）、end
+	kind     string
+	from, to int
+	text     string
+	dashed   bool
+	// head 消息箭头：arrow（->>）、open（-)）、cross（-x），空表示没有箭头（->）
+	head string
+	// position 注释位置：left、right、over
+	position string
+}
+
+type sequence struct {
+	participants []*participant
+	index        map[string]int
+	events       []seqEvent
+	autonumber   bool
+}
+
+var (
+	participantRe = regexp.MustCompile(`^(participant|actor)\s+(.+?)(?:\s+as\s+(.+))?$`)
+	messageRe     = regexp.MustCompile(`^(.+?)\s*(-->>|->>|--x|-x|--\)|-\)|-->|->)\s*[+-]?\s*(.+?)\s*(?::(.*))?$`)
+	noteRe        = regexp.MustCompile(`(?i)^note\s+(left of|right of|over)\s+([^,:]+?)\s*(?:,\s*([^:]+?))?\s*:(.*)$`)
+)
+
+// frameKinds 会画出边框的区块类型
+var frameKinds = map[string]bool{
+	"loop": true, "alt": true, "opt": true, "par": true,
+	"critical": true, "break": true, "rect": true,
+}
+
+func parseSequence(lines []string) (*sequence, error) {
+	sd := &sequence{index: map[string]int{}}
+	// stack 记录尚未结束的区块，box 只用于分组参与者，不画边框
+	var stack []string
+	for i, line := range lines {
+		keyword, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		switch {
+		case keyword == "autonumber":
+			sd.autonumber = true
+		case keyword == "title", keyword == "activate", keyword == "deactivate":
+		case keyword == "box":
+			stack = append(stack, keyword)
+		case frameKinds[keyword]:
+			stack = append(stack, keyword)
+			sd.events = append(sd.events, seqEvent{kind: "start", text: keyword, position: rest})
+		case keyword == "else" || keyword == "and" || keyword == "option":
+			if len(stack) == 0 || stack[len(stack)-1] == "box" {
+				return nil, fmt.Errorf("第 %d 行: %s 不在 alt/par/critical 区块中", i+2, keyword)
+			}
+			sd.events = append(sd.events, seqEvent{kind: "split", text: keyword, position: rest})
+		case keyword == "end":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("第 %d 行: 多余的 end", i+2)
+			}
+			if stack[len(stack)-1] != "box" {
+				sd.events = append(sd.events, seqEvent{kind: "end"})
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if err := sd.parseLine(line); err != nil {
+				return nil, fmt.Errorf("第 %d 行: %w", i+2, err)
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%s 区块缺少 end", stack[len(stack)-1])
+	}
+	if len(sd.participants) == 0 {
+		return nil, fmt.Errorf("时序图中没有参与者")
+	}
+	return sd, nil
+}
+
+func (sd *sequence) parseLine(line string) error {
+	if m := participantRe.FindStringSubmatch(line); m != nil {
+		label := m[3]
+		if label == "" {
+			label = m[2]
+		}
+		p := sd.participant(m[2])
+		p.label = labelLines(label)
+		p.actor = m[1] == "actor"
+		return nil
+	}
+	if m := noteRe.FindStringSubmatch(line); m != nil {
+		from := sd.participantIndex(m[2])
+		to := from
+		if m[3] != "" {
+			to = sd.participantIndex(m[3])
+		}
+		if from > to {
+			from, to = to, from
+		}
+		position := strings.Fields(strings.ToLower(m[1]))[0]
+		sd.events = append(sd.events, seqEvent{kind: "note", from: from, to: to, text: strings.TrimSpace(m[4]), position: position})
+		return nil
+	}
+	if m := messageRe.FindStringSubmatch(line); m != nil {
+		arrow := m[2]
+		e := seqEvent{
+			kind:   "message",
+			from:   sd.participantIndex(m[1]),
+			to:     sd.participantIndex(m[3]),
+			text:   strings.TrimSpace(m[4]),
+			dashed: strings.HasPrefix(arrow, "--"),
+		}
+		switch {
+		case strings.HasSuffix(arrow, ">>"):
+			e.head = "arrow"
+		case strings.HasSuffix(arrow, "x"):
+			e.head = "cross"
+		case strings.HasSuffix(arrow, ")"):
+			e.head = "open"
+		}
+		sd.events = append(sd.events, e)
+		return nil
+	}
+	return fmt.Errorf("无法解析: %q", line)
+}
+
+// participant 返回参与者，第一次出现时创建
+func (sd *sequence) participant(id string) *participant {
+	return sd.participants[sd.participantIndex(id)]
+}
+
+func (sd *sequence) participantIndex(id string) int {
+	id = strings.TrimSpace(id)
+	if i, ok := sd.index[id]; ok {
+		return i
+	}
+	sd.index[id] = len(sd.participants)
+	sd.participants = append(sd.participants, &participant{id: id, label: []string{id}})
+	return len(sd.participants) - 1
+}
+
+// messageLines 消息文字，开启 autonumber 时在前面加序号
+func (sd *sequence) messageLines(e seqEvent, n int) []string {
+	lines := labelLines(e.text)
+	if sd.autonumber {
+		lines[0] = fmt.Sprintf("%d. %s", n, lines[0])
+	}
+	return lines
+}
+
+func noteSize(text string) (float64, float64) {
+	w, h := textSize(labelLines(text))
+	return max(w+20, 80), h + 16
+}
+
+// layoutX 计算参与者的横坐标，保证相邻参与者之间放得下消息和注释文字，返回画布宽度
+func (sd *sequence) layoutX() float64 {
+	ps := sd.participants
+	for _, p := range ps {
+		tw, th := textSize(p.label)
+		p.w, p.h = max(tw+30, 80), th+20
+	}
+
+	gaps := make([]float64, len(ps))
+	for i := 1; i < len(ps); i++ {
+		gaps[i] = (ps[i-1].w+ps[i].w)/2 + 40
+	}
+	leftPad, rightPad := ps[0].w/2, ps[len(ps)-1].w/2
+
+	type span struct {
+		lo, hi int
+		dist   float64
+	}
+	var spans []span
+	// need 要求第 i 个参与者右侧留出 dist 的空间
+	need := func(i int, dist float64) {
+		if i == len(ps)-1 {
+			rightPad = max(rightPad, dist)
+		} else {
+			spans = append(spans, span{i, i + 1, dist})
+		}
+	}
+	n := 0
+	for _, e := range sd.events {
+		switch e.kind {
+		case "message":
+			n++
+			w, _ := textSize(sd.messageLines(e, n))
+			lo, hi := min(e.from, e.to), max(e.from, e.to)
+			if lo == hi {
+				need(lo, w+60)
+			} else {
+				spans = append(spans, span{lo, hi, w + 40})
+			}
+		case "note":
+			w, _ := noteSize(e.text)
+			switch {
+			case e.position == "left" && e.from == 0:
+				leftPad = max(leftPad, w+20)
+			case e.position == "left":
+				spans = append(spans, span{e.from - 1, e.from, w + 20})
+			case e.position == "right":
+				need(e.from, w+20)
+			case e.from == e.to:
+				if e.from == 0 {
+					leftPad = max(leftPad, w/2)
+				}
+				if e.from == len(ps)-1 {
+					rightPad = max(rightPad, w/2)
+				}
+			default:
+				spans = append(spans, span{e.from, e.to, w - 20})
+			}
+		}
+	}
+
+	// 先满足跨度小的约束，跨度大的约束不足时把差值平均分到中间的每个间隔上
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].hi-spans[i].lo < spans[j].hi-spans[j].lo })
+	for _, s := range spans {
+		current := 0.0
+		for i := s.lo + 1; i <= s.hi; i++ {
+			current += gaps[i]
+		}
+		if current < s.dist {
+			extra := (s.dist - current) / float64(s.hi-s.lo)
+			for i := s.lo + 1; i <= s.hi; i++ {
+				gaps[i] += extra
+			}
+		}
+	}
+
+	// 两侧额外留出区块边框的位置
+	x := margin + leftPad + 20
+	for i, p := range ps {
+		x += gaps[i]
+		p.x = x
+	}
+	return x + rightPad + 20 + margin
+}
+
+// frame 正在排版的区块，记录范围以便结束时画边框
+type frame struct {
+	kind, text  string
+	top         float64
+	minX, maxX  float64
+	splits      []float64
+	splitLabels []string
+	hasContent  bool
+}
+
+func (f *frame) include(l, r float64) {
+	if !f.hasContent {
+		f.minX, f.maxX, f.hasContent = l, r, true
+		return
+	}
+	f.minX, f.maxX = min(f.minX, l), max(f.maxX, r)
+}
+
+func (sd *sequence) render() []byte {
+	width := sd.layoutX()
+	ps := sd.participants
+
+	boxH := 0.0
+	for _, p := range ps {
+		boxH = max(boxH, p.h)
+	}
+
+	var back, front strings.Builder
+	var stack []*frame
+	include := func(l, r float64) {
+		for _, f := range stack {
+			f.include(l, r)
+		}
+	}
+
+	y := float64(margin) + boxH + 20
+	n := 0
+	for _, e := range sd.events {
+		switch e.kind {
+		case "message":
+			n++
+			lines := sd.messageLines(e, n)
+			tw, th := textSize(lines)
+			from, to := ps[e.from].x, ps[e.to].x
+			class := "edge"
+			if e.dashed {
+				class += " dotted"
+			}
+			lineY := y + th + 6
+			if e.from == e.to {
+				writeText(&front, from+10, y+th/2, lines, "start", "")
+				fmt.Fprintf(&front, `<path class="%s" d="M%.1f,%.1f H%.1f V%.1f H%.1f" %s/>`+"\n",
+					class, from, lineY, from+40, lineY+20, from, markerAttr("", e.head))
+				include(from, max(from+50, from+tw+20))
+				y = lineY + 40
+			} else {
+				writeText(&front, (from+to)/2, y+th/2, lines, "middle", "")
+				fmt.Fprintf(&front, `<path class="%s" d="M%.1f,%.1f H%.1f" %s/>`+"\n", class, from, lineY, to, markerAttr("", e.head))
+				include(min(from, to), max(from, to))
+				y = lineY + 20
+			}
+		case "note":
+			w, h := noteSize(e.text)
+			var l float64
+			switch {
+			case e.position == "left":
+				l = ps[e.from].x - 10 - w
+			case e.position == "right":
+				l = ps[e.from].x + 10
+			case e.from == e.to:
+				l = ps[e.from].x - w/2
+			default:
+				w = max(w, ps[e.to].x-ps[e.from].x+40)
+				l = ps[e.from].x - 20
+			}
+			fmt.Fprintf(&front, `<rect class="note" x="%.1f" y="%.1f" width="%.1f" height="%.1f"/>`+"\n", l, y, w, h)
+			writeText(&front, l+w/2, y+h/2, labelLines(e.text), "middle", "")
+			include(l, l+w)
+			y += h + 15
+		case "start":
+			stack = append(stack, &frame{kind: e.text, text: e.position, top: y})
+			y += 40
+		case "split":
+			f := stack[len(stack)-1]
+			f.splits = append(f.splits, y)
+			f.splitLabels = append(f.splitLabels, e.position)
+			y += 30
+		case "end":
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !f.hasContent {
+				f.include(ps[0].x, ps[len(ps)-1].x)
+			}
+			tagW := textWidth(f.kind) + 20
+			labelW := textWidth("["+f.text+"]") + 10
+			pad := 15.0
+			l, r := f.minX-pad, max(f.maxX+pad, f.minX-pad+tagW+labelW)
+			bottom := y + 5
+			fmt.Fprintf(&back, `<rect class="frame" x="%.1f" y="%.1f" width="%.1f" height="%.1f"/>`+"\n", l, f.top, r-l, bottom-f.top)
+			fmt.Fprintf(&front, `<path class="frame-tag" d="M%.1f,%.1f H%.1f V%.1f L%.1f,%.1f H%.1f Z"/>`+"\n",
+				l, f.top, l+tagW, f.top+14, l+tagW-6, f.top+20, l)
+			writeText(&front, l+tagW/2-3, f.top+10, []string{f.kind}, "middle", "title")
+			if f.text != "" {
+				writeText(&front, l+tagW+5, f.top+10, []string{"[" + f.text + "]"}, "start", "title")
+			}
+			for i, sy := range f.splits {
+				fmt.Fprintf(&back, `<line class="frame-split" x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`+"\n", l, sy, r, sy)
+				if f.splitLabels[i] != "" {
+					writeText(&front, (l+r)/2, sy+14, []string{"[" + f.splitLabels[i] + "]"}, "middle", "title")
+				}
+			}
+			// 外层区块要包住内层区块的边框
+			include(l-5, r+5)
+			y = bottom + 15
+		}
+	}
+
+	bottomY := y + 10
+	height := bottomY + boxH + margin
+	var mid strings.Builder
+	for _, p := range ps {
+		fmt.Fprintf(&mid, `<line class="lifeline" x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`+"\n", p.x, margin+boxH, p.x, bottomY)
+		for _, top := range []float64{margin, bottomY} {
+			rx := 3.0
+			if p.actor {
+				rx = boxH / 2
+			}
+			fmt.Fprintf(&mid, `<rect class="actor" x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="%.1f"/>`+"\n", p.x-p.w/2, top, p.w, boxH, rx)
+			writeText(&mid, p.x, top+boxH/2, p.label, "middle", "")
+		}
+	}
+
+	return svgDocument(width, height, back.String()+mid.String()+front.String())
+}
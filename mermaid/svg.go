@@ -0,0 +1,190 @@
+package mermaid
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// SVGRenderer 纯 Go 实现的 SVG 渲染器，不依赖网络和外部程序。
+// 目前支持流程图（graph / flowchart）和时序图（sequenceDiagram），其他图表类型返回 ErrUnsupported
+type SVGRenderer struct{}
+
+// NewSVGRenderer 创建内置 SVG 渲染器
+func NewSVGRenderer() *SVGRenderer {
+	return &SVGRenderer{}
+}
+
+func (r *SVGRenderer) Name() string { return "go" }
+
+func (r *SVGRenderer) Render(_ context.Context, code, format string) (*Result, error) {
+	format, err := normalizeFormat(code, format)
+	if err != nil {
+		return nil, err
+	}
+	if format != FormatSVG {
+		return nil, fmt.Errorf("内置渲染器只能输出 svg: %w", ErrUnsupported)
+	}
+	data, err := RenderSVG(code)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{URL: dataURL(format, data), Data: data, Format: format, Renderer: r.Name()}, nil
+}
+
+// RenderSVG 把 Mermaid 代码渲染为 SVG
+func RenderSVG(code string) ([]byte, error) {
+	lines := diagramLines(code)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("mermaid 代码不能为空")
+	}
+	header := strings.Fields(lines[0])
+	switch header[0] {
+	case "graph", "flowchart":
+		fc, err := parseFlowchart(header[1:], lines[1:])
+		if err != nil {
+			return nil, err
+		}
+		return fc.render(), nil
+	case "sequenceDiagram":
+		sd, err := parseSequence(lines[1:])
+		if err != nil {
+			return nil, err
+		}
+		return sd.render(), nil
+	default:
+		return nil, fmt.Errorf("内置渲染器暂不支持 %s 图表: %w", header[0], ErrUnsupported)
+	}
+}
+
+// diagramLines 去掉空行、注释和 %%{init}%% 指令，返回去掉首尾空白的有效行
+func diagramLines(code string) []string {
+	var lines []string
+	for _, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// 排版参数
+const (
+	fontSize   = 14
+	lineHeight = 18
+	margin     = 20
+)
+
+// textWidth 估算文本宽度。没有字体信息，按字符宽度粗略计算：中日韩字符占一个字号宽，ASCII 约占 0.6
+func textWidth(s string) float64 {
+	w := 0.0
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf:
+			w += fontSize * 0.6
+		case isWide(r):
+			w += fontSize
+		default:
+			w += fontSize * 0.8
+		}
+	}
+	return w
+}
+
+func isWide(r rune) bool {
+	return (r >= 0x1100 && r <= 0x115F) ||
+		(r >= 0x2E80 && r <= 0xA4CF) ||
+		(r >= 0xAC00 && r <= 0xD7A3) ||
+		(r >= 0xF900 && r <= 0xFAFF) ||
+		(r >= 0xFE30 && r <= 0xFE4F) ||
+		(r >= 0xFF00 && r <= 0xFF60) ||
+		(r >= 0xFFE0 && r <= 0xFFE6) ||
+		r >= 0x1F300
+}
+
+// labelLines 把标签按 <br> 拆成多行，并去掉包裹的引号
+func labelLines(label string) []string {
+	label = strings.TrimSpace(label)
+	if len(label) >= 2 && label[0] == '"' && label[len(label)-1] == '"' {
+		label = label[1 : len(label)-1]
+	}
+	for _, br := range []string{"<br/>", "<br />", "<BR>", "<br>"} {
+		label = strings.ReplaceAll(label, br, "\n")
+	}
+	return strings.Split(label, "\n")
+}
+
+// textSize 多行文本的宽高
+func textSize(lines []string) (float64, float64) {
+	w := 0.0
+	for _, line := range lines {
+		w = max(w, textWidth(line))
+	}
+	return w, float64(len(lines)) * lineHeight
+}
+
+// writeText 以 (x, y) 为中心写多行文本，anchor 为 start/middle/end
+func writeText(b *strings.Builder, x, y float64, lines []string, anchor, class string) {
+	top := y - float64(len(lines)-1)*lineHeight/2
+	fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="%s" dominant-baseline="central"`, x, top, anchor)
+	if class != "" {
+		fmt.Fprintf(b, ` class="%s"`, class)
+	}
+	b.WriteString(">")
+	for i, line := range lines {
+		if i == 0 {
+			fmt.Fprintf(b, "<tspan x=\"%.1f\">%s</tspan>", x, html.EscapeString(line))
+			continue
+		}
+		fmt.Fprintf(b, "<tspan x=\"%.1f\" dy=\"%d\">%s</tspan>", x, lineHeight, html.EscapeString(line))
+	}
+	b.WriteString("</text>\n")
+}
+
+// svgDocument 拼装完整的 SVG 文档，包含公共样式和箭头定义
+func svgDocument(width, height float64, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n", width, height, width, height)
+	b.WriteString(`<style>
+text { font-family: "PingFang SC", "Microsoft YaHei", "Noto Sans CJK SC", sans-serif; font-size: 14px; fill: #333; }
+.node { fill: #ECECFF; stroke: #9370DB; stroke-width: 1; }
+.edge { fill: none; stroke: #333; stroke-width: 1.5; }
+.dotted { stroke-dasharray: 3 3; }
+.thick { stroke-width: 3; }
+.label-bg { fill: #fff; opacity: 0.9; }
+.actor { fill: #ECECFF; stroke: #9370DB; stroke-width: 1; }
+.lifeline { stroke: #999; stroke-width: 1; stroke-dasharray: 4 4; }
+.note { fill: #FFF5AD; stroke: #AAAA33; stroke-width: 1; }
+.frame { fill: none; stroke: #666; stroke-width: 1; }
+.frame-tag { fill: #EEE; stroke: #666; stroke-width: 1; }
+.frame-split { stroke: #666; stroke-width: 1; stroke-dasharray: 4 3; }
+.title { font-size: 12px; }
+</style>
+<defs>
+<marker id="arrow" viewBox="0 0 10 10" refX="9" refY="5" markerWidth="9" markerHeight="9" markerUnits="userSpaceOnUse" orient="auto-start-reverse"><path d="M0,0 L10,5 L0,10 z" fill="#333"/></marker>
+<marker id="open" viewBox="0 0 10 10" refX="9" refY="5" markerWidth="9" markerHeight="9" markerUnits="userSpaceOnUse" orient="auto-start-reverse"><path d="M0,0 L10,5 L0,10" fill="none" stroke="#333" stroke-width="1.5"/></marker>
+<marker id="circle" viewBox="0 0 10 10" refX="8" refY="5" markerWidth="9" markerHeight="9" markerUnits="userSpaceOnUse" orient="auto-start-reverse"><circle cx="5" cy="5" r="4" fill="#fff" stroke="#333" stroke-width="1.5"/></marker>
+<marker id="cross" viewBox="0 0 10 10" refX="5" refY="5" markerWidth="10" markerHeight="10" markerUnits="userSpaceOnUse" orient="auto-start-reverse"><path d="M1,1 L9,9 M9,1 L1,9" stroke="#333" stroke-width="2"/></marker>
+</defs>
+`)
+	fmt.Fprintf(&b, `<rect width="%.0f" height="%.0f" fill="#fff"/>`+"\n", width, height)
+	b.WriteString(body)
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// markerAttr 返回线段两端的箭头属性
+func markerAttr(start, end string) string {
+	var attrs []string
+	if start != "" {
+		attrs = append(attrs, fmt.Sprintf(`marker-start="url(#%s)"`, start))
+	}
+	if end != "" {
+		attrs = append(attrs, fmt.Sprintf(`marker-end="url(#%s)"`, end))
+	}
+	return strings.Join(attrs, " ")
+}
@@ -0,0 +1,199 @@
+package mermaid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError Mermaid 代码中的一个语法错误
+type SyntaxError struct {
+	// Line 错误所在的行，从 1 开始，相对于 Mermaid 代码（不含 ``` 围栏）
+	Line    int
+	Message string
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("第 %d 行: %s", e.Line, e.Message)
+}
+
+// diagramTypes 可以识别的图表类型，流程图和时序图逐行解析，其他类型只做通用检查
+var diagramTypes = map[string]bool{
+	"graph": true, "flowchart": true, "sequenceDiagram": true,
+	"classDiagram": true, "classDiagram-v2": true, "stateDiagram": true, "stateDiagram-v2": true,
+	"erDiagram": true, "gantt": true, "pie": true, "journey": true, "gitGraph": true,
+	"mindmap": true, "timeline": true, "quadrantChart": true, "requirementDiagram": true,
+	"C4Context": true, "C4Container": true, "C4Component": true,
+	"xychart-beta": true, "sankey-beta": true, "block-beta": true,
+}
+
+// Validate 检查 Mermaid 代码的语法，返回发现的所有错误。流程图和时序图按内置渲染器的语法逐行解析，
+// 并比渲染器更严格：未加引号的节点文字中不能有括号（mermaid.js 无法解析）；
+// 其他图表类型检查图表声明、引号和花括号是否成对
+func Validate(code string) []SyntaxError {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	header := -1
+	for i, line := range lines {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "%%") {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return []SyntaxError{{Line: 1, Message: "Mermaid 代码为空"}}
+	}
+
+	fields := strings.Fields(lines[header])
+	kind := strings.TrimSuffix(fields[0], ";")
+	if !diagramTypes[kind] {
+		return []SyntaxError{{Line: header + 1, Message: fmt.Sprintf("未知的图表类型 %q，例如流程图应以 flowchart TD 或 graph LR 开头", kind)}}
+	}
+
+	errs := checkQuotes(lines, header)
+	switch kind {
+	case "graph", "flowchart":
+		errs = append(errs, validateFlowchart(fields[1:], lines, header)...)
+	case "sequenceDiagram":
+		errs = append(errs, validateSequence(lines, header)...)
+	default:
+		errs = append(errs, checkBraces(lines, header)...)
+	}
+	return errs
+}
+
+// body 遍历图表声明之后的有效行，lineNo 从 1 开始
+func body(lines []string, header int, fn func(lineNo int, line string)) {
+	for i := header + 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+		fn(i+1, line)
+	}
+}
+
+func validateFlowchart(header, lines []string, start int) []SyntaxError {
+	var errs []SyntaxError
+	fc := &flowchart{nodes: map[string]*flowNode{}, strict: true}
+	if len(header) > 0 {
+		switch dir := strings.ToUpper(strings.TrimSuffix(header[0], ";")); dir {
+		case "TB", "TD", "BT", "LR", "RL":
+		default:
+			errs = append(errs, SyntaxError{Line: start + 1, Message: fmt.Sprintf("流程图方向 %q 不合法，可选值: TD、TB、BT、LR、RL", dir)})
+		}
+	}
+
+	var subgraphs []int
+	body(lines, start, func(lineNo int, line string) {
+		for _, stmt := range strings.Split(line, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if stmt == "" {
+				continue
+			}
+			switch keyword := strings.Fields(stmt)[0]; {
+			case keyword == "subgraph":
+				subgraphs = append(subgraphs, lineNo)
+				continue
+			case keyword == "end":
+				if len(subgraphs) == 0 {
+					errs = append(errs, SyntaxError{Line: lineNo, Message: "多余的 end，没有对应的 subgraph"})
+				} else {
+					subgraphs = subgraphs[:len(subgraphs)-1]
+				}
+				continue
+			case flowKeywords[keyword]:
+				continue
+			}
+			if err := fc.parseStatement(stmt); err != nil {
+				msg := err.Error()
+				if strings.HasPrefix(msg, "无法解析连线") {
+					msg += "，流程图的连线写法为 -->、---、-.->、==>，带文字时写成 -->|文字|"
+				}
+				errs = append(errs, SyntaxError{Line: lineNo, Message: msg})
+			}
+		}
+	})
+	for _, lineNo := range subgraphs {
+		errs = append(errs, SyntaxError{Line: lineNo, Message: "subgraph 缺少对应的 end"})
+	}
+	if len(fc.nodes) == 0 && len(errs) == 0 {
+		errs = append(errs, SyntaxError{Line: start + 1, Message: "流程图中没有节点"})
+	}
+	return errs
+}
+
+func validateSequence(lines []string, start int) []SyntaxError {
+	var errs []SyntaxError
+	sd := &sequence{index: map[string]int{}}
+	type block struct {
+		keyword string
+		line    int
+	}
+	var stack []block
+	body(lines, start, func(lineNo int, line string) {
+		keyword, _, _ := strings.Cut(line, " ")
+		switch {
+		case keyword == "autonumber", keyword == "title", keyword == "activate", keyword == "deactivate":
+		case keyword == "box", frameKinds[keyword]:
+			stack = append(stack, block{keyword, lineNo})
+		case keyword == "else" || keyword == "and" || keyword == "option":
+			if len(stack) == 0 || stack[len(stack)-1].keyword == "box" {
+				errs = append(errs, SyntaxError{Line: lineNo, Message: keyword + " 不在 alt/par/critical 区块中"})
+			}
+		case keyword == "end":
+			if len(stack) == 0 {
+				errs = append(errs, SyntaxError{Line: lineNo, Message: "多余的 end"})
+			} else {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			if m := messageRe.FindStringSubmatchIndex(line); m != nil && m[8] < 0 && !participantRe.MatchString(line) && !noteRe.MatchString(line) {
+				errs = append(errs, SyntaxError{Line: lineNo, Message: fmt.Sprintf("消息缺少冒号和文字，例如 A->>B: 请求: %q", line)})
+			} else if err := sd.parseLine(line); err != nil {
+				errs = append(errs, SyntaxError{Line: lineNo, Message: err.Error() + "，消息的箭头写法为 ->>、-->>、->、-->、-x、-)"})
+			}
+		}
+	})
+	for _, b := range stack {
+		errs = append(errs, SyntaxError{Line: b.line, Message: b.keyword + " 区块缺少 end"})
+	}
+	return errs
+}
+
+// checkQuotes 每一行的双引号必须成对
+func checkQuotes(lines []string, header int) []SyntaxError {
+	var errs []SyntaxError
+	body(lines, header, func(lineNo int, line string) {
+		if strings.Count(line, `"`)%2 != 0 {
+			errs = append(errs, SyntaxError{Line: lineNo, Message: "双引号不成对"})
+		}
+	})
+	return errs
+}
+
+// checkBraces 花括号必须成对，引号中的花括号不计
+func checkBraces(lines []string, header int) []SyntaxError {
+	var errs []SyntaxError
+	var open []int
+	body(lines, header, func(lineNo int, line string) {
+		quoted := false
+		for _, r := range line {
+			switch {
+			case r == '"':
+				quoted = !quoted
+			case quoted:
+			case r == '{':
+				open = append(open, lineNo)
+			case r == '}':
+				if len(open) == 0 {
+					errs = append(errs, SyntaxError{Line: lineNo, Message: "多余的 }"})
+				} else {
+					open = open[:len(open)-1]
+				}
+			}
+		}
+	})
+	for _, lineNo := range open {
+		errs = append(errs, SyntaxError{Line: lineNo, Message: "{ 缺少对应的 }"})
+	}
+	return errs
+}
@@ -0,0 +1,103 @@
+package outline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"eino_test/markdown"
+)
+
+var numberingRe = regexp.MustCompile(`^(第[0-9一二三四五六七八九十百]+[章节部分篇]|[0-9]+(\.[0-9]+)*\.?|[一二三四五六七八九十]+[、.])\s*`)
+
+// Misplaced 改写稿中顺序与大纲不一致的章节
+type Misplaced struct {
+	Title string `json:"title"`
+	// After 大纲中排在它前面、改写稿中却出现在它后面的章节
+	After string `json:"after"`
+}
+
+// Report 改写稿与教学大纲的一致性检查结果
+type Report struct {
+	// Sections 大纲中的章节数
+	Sections int `json:"sections"`
+	// Missing 改写稿中找不到对应标题的章节
+	Missing []string `json:"missing,omitempty"`
+	// Misplaced 改写稿中顺序与大纲不一致的章节
+	Misplaced []Misplaced `json:"misplaced,omitempty"`
+}
+
+// OK 改写稿的章节与大纲一致
+func (r *Report) OK() bool {
+	return len(r.Missing) == 0 && len(r.Misplaced) == 0
+}
+
+// Format 把检查结果格式化为交给 ReviewerAgent 和 SummaryAgent 的文本
+func (r *Report) Format() string {
+	var b strings.Builder
+	for _, title := range r.Missing {
+		fmt.Fprintf(&b, "- 缺少章节：大纲中的《%s》在改写稿中没有对应的标题\n", title)
+	}
+	for _, m := range r.Misplaced {
+		fmt.Fprintf(&b, "- 顺序不一致：大纲中《%s》在《%s》之后，改写稿中却出现在它前面\n", m.Title, m.After)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Check 对照大纲检查改写稿：大纲中的每一节都要有对应的标题，并且按大纲的顺序出现。
+// 标题忽略编号、空白和标点，改写稿的标题包含大纲中的节标题（例如补充了英文原文）也算对应
+func Check(o *Outline, content string) *Report {
+	headings := headingsOf(content)
+	sections := o.Sections()
+	report := &Report{Sections: len(sections)}
+
+	used := make([]bool, len(headings))
+	last, lastTitle := -1, ""
+	for _, s := range sections {
+		pos := -1
+		for i, h := range headings {
+			if !used[i] && matches(h, s.Title) {
+				pos = i
+				break
+			}
+		}
+		if pos < 0 {
+			report.Missing = append(report.Missing, s.Title)
+			continue
+		}
+		used[pos] = true
+		if pos < last {
+			report.Misplaced = append(report.Misplaced, Misplaced{Title: s.Title, After: lastTitle})
+			continue
+		}
+		last, lastTitle = pos, s.Title
+	}
+	return report
+}
+
+// headingsOf 按顺序返回改写稿中的标题文字，跳过代码块中以 # 开头的行
+func headingsOf(content string) []string {
+	var headings []string
+	for _, h := range markdown.Headings(strings.Split(content, "\n")) {
+		headings = append(headings, h.Text)
+	}
+	return headings
+}
+
+// matches 改写稿的标题是否对应大纲中的节标题
+func matches(heading, title string) bool {
+	h, t := normalize(heading), normalize(title)
+	return t != "" && strings.Contains(h, t)
+}
+
+// normalize 去掉标题的编号、空白和标点并转为小写
+func normalize(title string) string {
+	title = numberingRe.ReplaceAllString(strings.TrimSpace(title), "")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, title)
+}
@@ -0,0 +1,178 @@
+// Package outline 定义整篇改写前由 PlannerAgent 生成的结构化教学大纲：全文分几部分、每一节的目标、
+// 前置知识和 3 个核心问题，以及读者读完后要能做到的 3～5 件事。大纲在改写前校验，保存为 YAML 文件供人修改，
+// 评审前再对照大纲检查改写稿的章节是否齐全、顺序是否一致
+package outline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// QuestionsPerSection 每一节要回答的核心问题数：是什么 / 为什么 / 怎么用
+	QuestionsPerSection = 3
+	// MinOutcomes、MaxOutcomes 判定主线（读者读完要能做到的事）的数量范围
+	MinOutcomes = 3
+	MaxOutcomes = 5
+)
+
+// Section 大纲中的一节，对应改写稿中的一个标题
+type Section struct {
+	Title         string   `json:"title" yaml:"title" jsonschema_description:"本节标题，改写稿中会使用这个标题，不带编号"`
+	Goal          string   `json:"goal" yaml:"goal" jsonschema_description:"本节目标：读者学完这一节能理解或做到什么"`
+	Prerequisites []string `json:"prerequisites,omitempty" yaml:"prerequisites,omitempty" jsonschema_description:"前置知识，可以引用前面的章节标题，没有时留空"`
+	Questions     []string `json:"questions" yaml:"questions" jsonschema:"minItems=3,maxItems=3" jsonschema_description:"本节要回答的 3 个核心问题，依次对应是什么、为什么、怎么用"`
+}
+
+// Part 大纲中的一个部分，由若干节组成
+type Part struct {
+	Title    string    `json:"title" yaml:"title" jsonschema_description:"这一部分的名称"`
+	Problem  string    `json:"problem" yaml:"problem" jsonschema_description:"这一部分解决什么问题，一两句话"`
+	Sections []Section `json:"sections" yaml:"sections" jsonschema:"minItems=1" jsonschema_description:"这一部分包含的章节，按改写稿中的顺序排列"`
+}
+
+// Outline 教学大纲，PlannerAgent 通过 save_teaching_plan 工具提交
+type Outline struct {
+	Title    string   `json:"title" yaml:"title" jsonschema_description:"文档标题"`
+	Parts    []Part   `json:"parts" yaml:"parts" jsonschema:"minItems=1" jsonschema_description:"全局结构：全文分几大部分，按顺序排列"`
+	Outcomes []string `json:"outcomes" yaml:"outcomes" jsonschema:"minItems=3,maxItems=5" jsonschema_description:"判定主线：读者读完后要能做到的 3～5 件事，例如\"能写一个基本的 Kafka 消费者\""`
+}
+
+// Validate 校验大纲：至少一个部分，每个部分至少一节，每一节有标题、目标和恰好 3 个核心问题，
+// 节标题不重复，判定主线 3～5 条。一次返回所有问题，方便模型或人一次改完
+func (o *Outline) Validate() error {
+	var errs []error
+	if strings.TrimSpace(o.Title) == "" {
+		errs = append(errs, errors.New("缺少文档标题 title"))
+	}
+	if len(o.Parts) == 0 {
+		errs = append(errs, errors.New("parts 至少要有一个部分"))
+	}
+	seen := map[string]bool{}
+	for i, p := range o.Parts {
+		where := fmt.Sprintf("第 %d 部分", i+1)
+		if strings.TrimSpace(p.Title) == "" {
+			errs = append(errs, fmt.Errorf("%s缺少 title", where))
+		} else {
+			where = fmt.Sprintf("%s《%s》", where, p.Title)
+		}
+		if strings.TrimSpace(p.Problem) == "" {
+			errs = append(errs, fmt.Errorf("%s缺少 problem", where))
+		}
+		if len(p.Sections) == 0 {
+			errs = append(errs, fmt.Errorf("%s至少要有一节", where))
+		}
+		for j, s := range p.Sections {
+			at := fmt.Sprintf("%s的第 %d 节", where, j+1)
+			if strings.TrimSpace(s.Title) == "" {
+				errs = append(errs, fmt.Errorf("%s缺少 title", at))
+			} else {
+				at = fmt.Sprintf("%s《%s》", at, s.Title)
+				if key := normalize(s.Title); seen[key] {
+					errs = append(errs, fmt.Errorf("%s的标题与前面的章节重复", at))
+				} else {
+					seen[key] = true
+				}
+			}
+			if strings.TrimSpace(s.Goal) == "" {
+				errs = append(errs, fmt.Errorf("%s缺少 goal", at))
+			}
+			if n := countNonEmpty(s.Questions); n != QuestionsPerSection || len(s.Questions) != QuestionsPerSection {
+				errs = append(errs, fmt.Errorf("%s需要恰好 %d 个核心问题，当前为 %d 个", at, QuestionsPerSection, n))
+			}
+		}
+	}
+	if n := countNonEmpty(o.Outcomes); n < MinOutcomes || n > MaxOutcomes || n != len(o.Outcomes) {
+		errs = append(errs, fmt.Errorf("outcomes 需要 %d～%d 条非空的判定主线，当前为 %d 条", MinOutcomes, MaxOutcomes, n))
+	}
+	return errors.Join(errs...)
+}
+
+// Sections 按顺序返回所有章节
+func (o *Outline) Sections() []Section {
+	var sections []Section
+	for _, p := range o.Parts {
+		sections = append(sections, p.Sections...)
+	}
+	return sections
+}
+
+// Markdown 把大纲渲染为 Markdown，写入提示词和 session 中的教学计划
+func (o *Outline) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# 教学大纲：%s\n\n## 全局结构\n\n", o.Title)
+	for i, p := range o.Parts {
+		fmt.Fprintf(&b, "%d. **%s**：%s\n", i+1, p.Title, p.Problem)
+	}
+	for i, p := range o.Parts {
+		fmt.Fprintf(&b, "\n## 第 %d 部分：%s\n", i+1, p.Title)
+		for _, s := range p.Sections {
+			fmt.Fprintf(&b, "\n### %s\n\n- 本节目标：%s\n", s.Title, s.Goal)
+			if len(s.Prerequisites) > 0 {
+				fmt.Fprintf(&b, "- 前置知识：%s\n", strings.Join(s.Prerequisites, "；"))
+			} else {
+				b.WriteString("- 前置知识：无\n")
+			}
+			b.WriteString("- 核心问题：\n")
+			for k, q := range s.Questions {
+				fmt.Fprintf(&b, "  %d. %s\n", k+1, q)
+			}
+		}
+	}
+	b.WriteString("\n## 判定主线\n\n读完后你能够：\n")
+	for _, item := range o.Outcomes {
+		fmt.Fprintf(&b, "- %s\n", item)
+	}
+	return b.String()
+}
+
+// Load 读取 YAML（或 JSON）格式的大纲文件并校验，出现未知字段时报错，避免字段名拼错后修改悄悄失效
+func Load(path string) (*Outline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取教学大纲失败: %w", err)
+	}
+	o := &Outline{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(o); err != nil {
+		return nil, fmt.Errorf("解析教学大纲 %s 失败: %v", path, err)
+	}
+	if err := o.Validate(); err != nil {
+		return nil, fmt.Errorf("教学大纲 %s 不合法:\n%w", path, err)
+	}
+	return o, nil
+}
+
+// Save 把大纲写入 YAML 文件，目录不存在时创建
+func (o *Outline) Save(path string) error {
+	data, err := yaml.Marshal(o)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	header := "# 教学大纲：每一节需要 title、goal 和恰好 3 个 questions，outcomes 为 3～5 条。\n" +
+		"# 修改后保存即可，改写前会重新校验\n"
+	if err := os.WriteFile(path, append([]byte(header), data...), 0644); err != nil {
+		return fmt.Errorf("保存教学大纲失败: %w", err)
+	}
+	return nil
+}
+
+func countNonEmpty(items []string) int {
+	n := 0
+	for _, item := range items {
+		if strings.TrimSpace(item) != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,103 @@
+package outline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testOutline() *Outline {
+	return &Outline{
+		Title: "Kafka 入门",
+		Parts: []Part{
+			{Title: "基础概念", Problem: "Kafka 是什么、解决什么问题", Sections: []Section{
+				{Title: "主题与分区", Goal: "理解分区如何提高吞吐", Questions: []string{"分区是什么", "为什么要分区", "怎么选择分区数"}},
+				{Title: "消费者组", Goal: "理解消费者组的负载均衡", Prerequisites: []string{"主题与分区"},
+					Questions: []string{"消费者组是什么", "为什么需要消费者组", "怎么配置 group.id"}},
+			}},
+			{Title: "实践", Problem: "写出可用的生产者和消费者", Sections: []Section{
+				{Title: "位移提交", Goal: "避免重复消费和丢消息", Questions: []string{"位移是什么", "为什么要手动提交", "怎么提交位移"}},
+			}},
+		},
+		Outcomes: []string{"能创建主题", "能写一个基本的消费者", "能排查重复消费"},
+	}
+}
+
+// TestValidate 测试合法的大纲通过校验，缺少字段、核心问题数量不对、章节重复和判定主线数量不对时报错
+func TestValidate(t *testing.T) {
+	if err := testOutline().Validate(); err != nil {
+		t.Fatalf("合法的大纲不应报错: %v", err)
+	}
+
+	o := testOutline()
+	o.Parts[0].Sections[0].Questions = o.Parts[0].Sections[0].Questions[:2]
+	o.Parts[1].Sections[0].Title = "主题与分区"
+	o.Parts[1].Problem = ""
+	o.Outcomes = append(o.Outcomes, "a", "b", "c")
+	err := o.Validate()
+	if err == nil {
+		t.Fatal("不合法的大纲应报错")
+	}
+	for _, want := range []string{"需要恰好 3 个核心问题，当前为 2 个", "标题与前面的章节重复", "缺少 problem", "当前为 6 条"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息缺少 %q:\n%v", want, err)
+		}
+	}
+	if err := (&Outline{}).Validate(); err == nil || !strings.Contains(err.Error(), "parts 至少要有一个部分") {
+		t.Errorf("空大纲的错误信息不对: %v", err)
+	}
+}
+
+// TestSaveLoad 测试大纲保存为 YAML 后能读回，人工修改后的文件重新校验
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan", "outline.yaml")
+	if err := testOutline().Save(path); err != nil {
+		t.Fatal(err)
+	}
+	o, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Sections()) != 3 || o.Parts[0].Sections[1].Prerequisites[0] != "主题与分区" {
+		t.Errorf("读回的大纲不符合预期: %+v", o)
+	}
+
+	data, _ := os.ReadFile(path)
+	edited := strings.Replace(string(data), "- 能排查重复消费\n", "", 1)
+	edited = strings.Replace(edited, "outcomes:", "outcome:", 1)
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "outcome") {
+		t.Errorf("字段名拼错时应报错: %v", err)
+	}
+
+	md := testOutline().Markdown()
+	if !strings.Contains(md, "### 消费者组\n\n- 本节目标：理解消费者组的负载均衡\n- 前置知识：主题与分区\n") ||
+		!strings.Contains(md, "  3. 怎么提交位移\n") || !strings.Contains(md, "- 能排查重复消费\n") {
+		t.Errorf("Markdown 渲染结果不对:\n%s", md)
+	}
+}
+
+// TestCheck 测试对照大纲检查改写稿的章节是否齐全、顺序是否一致
+func TestCheck(t *testing.T) {
+	content := "# Kafka 入门\n\n## 1. 主题与分区（Topic & Partition）\n\n正文\n\n" +
+		"```bash\n# 位移提交\n```\n\n## 2. 位移提交\n\n正文\n\n## 3. 消费者组\n\n正文\n"
+	report := Check(testOutline(), content)
+	if len(report.Missing) != 0 || len(report.Misplaced) != 1 || report.Misplaced[0].Title != "位移提交" ||
+		report.Misplaced[0].After != "消费者组" {
+		t.Errorf("顺序检查结果不对: %+v", report)
+	}
+	if !strings.Contains(report.Format(), "大纲中《位移提交》在《消费者组》之后") {
+		t.Errorf("Format 结果不对:\n%s", report.Format())
+	}
+
+	report = Check(testOutline(), "## 主题与分区\n\n```\n## 消费者组\n```\n\n## 位移提交\n")
+	if len(report.Missing) != 1 || report.Missing[0] != "消费者组" || len(report.Misplaced) != 0 || report.OK() {
+		t.Errorf("代码块中的标题不应算作章节: %+v", report)
+	}
+	if !Check(testOutline(), "## 主题与分区\n## 消费者组\n## 位移提交\n").OK() {
+		t.Error("章节齐全且顺序一致时应通过")
+	}
+}
@@ -0,0 +1,211 @@
+// Package persona 定义改写文档面向的读者画像。画像描述读者会的编程语言、用过的中间件、
+// 当前水平、偏好的示例语言和喜欢的类比，会被注入到 SummaryAgent 和 ReviewerAgent 的指令中
+package persona
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultName 未指定画像时使用的内置画像
+const DefaultName = "backend"
+
+// defaultAudience 纯文本画像没有描述读者身份时使用的称呼
+const defaultAudience = "技术初学者"
+
+//go:embed builtin/*.yaml
+var builtin embed.FS
+
+// ErrNotFound 指定名称的画像不存在
+var ErrNotFound = errors.New("读者画像不存在")
+
+// Persona 读者画像
+type Persona struct {
+	// Name 画像名称，对应画像目录中的文件名（不含扩展名）
+	Name string `yaml:"name"`
+	// Audience 一句话描述读者，例如“后端开发初学者”
+	Audience string `yaml:"audience"`
+	// Languages 读者掌握的编程语言
+	Languages []string `yaml:"languages,omitempty"`
+	// Middleware 读者用过的中间件和工具
+	Middleware []string `yaml:"middleware,omitempty"`
+	// Level 读者当前的水平和学习阶段
+	Level string `yaml:"level,omitempty"`
+	// ExampleLanguage 代码示例优先使用的语言
+	ExampleLanguage string `yaml:"example_language,omitempty"`
+	// Analogies 读者容易理解的类比，改写时作为示范
+	Analogies []string `yaml:"analogies,omitempty"`
+	// Notes 其他补充说明
+	Notes []string `yaml:"notes,omitempty"`
+
+	// text 纯文本画像的原文，设置后 Background 直接返回原文
+	text string
+}
+
+// Default 返回内置的后端初学者画像
+func Default() *Persona {
+	p, err := loadBuiltin(DefaultName)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Parse 解析画像文件内容。YAML 映射按字段解析，其他内容当作纯文本的读者背景，
+// 兼容直接写一段背景描述的旧画像文件。name 在内容没有指定名称时使用
+func Parse(name string, data []byte) (*Persona, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil || len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			return nil, errors.New("读者画像内容为空")
+		}
+		return &Persona{Name: name, Audience: defaultAudience, text: text}, nil
+	}
+
+	p := &Persona{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("解析读者画像失败: %v", err)
+	}
+	if p.Name == "" {
+		p.Name = name
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate 校验画像的必填字段
+func (p *Persona) Validate() error {
+	var errs []error
+	if p.Name == "" {
+		errs = append(errs, errors.New("读者画像缺少 name"))
+	}
+	if p.Audience == "" {
+		errs = append(errs, fmt.Errorf("读者画像 %q 缺少 audience", p.Name))
+	}
+	return errors.Join(errs...)
+}
+
+// Load 加载画像。ref 是文件路径时直接读取文件，否则按名称依次在 dir 目录和内置画像中查找，
+// 目录中的同名画像会覆盖内置画像。ref 为空时返回默认画像
+func Load(ref, dir string) (*Persona, error) {
+	if ref == "" {
+		ref = DefaultName
+	}
+	if isPath(ref) {
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return nil, fmt.Errorf("读取读者画像文件失败: %w", err)
+		}
+		return Parse(strings.TrimSuffix(filepath.Base(ref), filepath.Ext(ref)), data)
+	}
+	return loadNamed(ref, dir)
+}
+
+// Lookup 解析通过接口传入的画像：包含换行时当作画像内容解析，否则当作画像名称查找。
+// 与 Load 不同，不接受文件路径，避免调用方读取服务器上的任意文件
+func Lookup(value, dir string) (*Persona, error) {
+	if strings.Contains(strings.TrimSpace(value), "\n") {
+		return Parse("custom", []byte(value))
+	}
+	if isPath(value) {
+		return nil, fmt.Errorf("%w: %q，只能指定画像名称，可选值: %s", ErrNotFound, value, strings.Join(List(dir), ", "))
+	}
+	return loadNamed(value, dir)
+}
+
+// List 返回可用的画像名称，包括内置画像和 dir 目录中的画像
+func List(dir string) []string {
+	var names []string
+	entries, _ := fs.ReadDir(builtin, "builtin")
+	if dir != "" {
+		local, _ := os.ReadDir(dir)
+		entries = append(entries, local...)
+	}
+	for _, e := range entries {
+		if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			if name := strings.TrimSuffix(e.Name(), ext); !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func loadNamed(name, dir string) (*Persona, error) {
+	if dir != "" {
+		for _, ext := range []string{".yaml", ".yml"} {
+			data, err := os.ReadFile(filepath.Join(dir, name+ext))
+			if err == nil {
+				return Parse(name, data)
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("读取读者画像失败: %w", err)
+			}
+		}
+	}
+	p, err := loadBuiltin(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %q，可选值: %s", ErrNotFound, name, strings.Join(List(dir), ", "))
+	}
+	return p, err
+}
+
+func loadBuiltin(name string) (*Persona, error) {
+	data, err := builtin.ReadFile("builtin/" + name + ".yaml")
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, data)
+}
+
+// isPath 带路径分隔符或文件扩展名的引用当作文件路径
+func isPath(ref string) bool {
+	return strings.ContainsAny(ref, `/\`) || filepath.Ext(ref) != ""
+}
+
+// Background 渲染为注入到指令中的读者背景信息
+func (p *Persona) Background() string {
+	if p.text != "" {
+		return p.text
+	}
+	lines := []string{"- 读者：" + p.Audience}
+	if len(p.Languages) > 0 {
+		lines = append(lines, "- 编程语言："+strings.Join(p.Languages, "、"))
+	}
+	if len(p.Middleware) > 0 {
+		lines = append(lines, "- 中间件经验："+strings.Join(p.Middleware, "、"))
+	}
+	if p.Level != "" {
+		lines = append(lines, "- 当前水平："+p.Level)
+	}
+	if p.ExampleLanguage != "" {
+		lines = append(lines, "- 示例语言：代码示例优先使用 "+p.ExampleLanguage)
+	}
+	for _, note := range p.Notes {
+		lines = append(lines, "- "+note)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Examples 代码示例优先使用的语言，画像没有指定时使用笼统的描述
+func (p *Persona) Examples() string {
+	if p.ExampleLanguage != "" {
+		return p.ExampleLanguage
+	}
+	return "读者熟悉的编程语言"
+}
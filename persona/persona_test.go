@@ -0,0 +1,89 @@
+package persona
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBuiltin 测试内置画像都能加载，且默认画像与原先硬编码的读者背景一致
+func TestBuiltin(t *testing.T) {
+	names := List("")
+	if strings.Join(names, ",") != "backend,data-analyst,frontend" {
+		t.Fatalf("内置画像不符合预期: %v", names)
+	}
+	for _, name := range names {
+		p, err := Load(name, "")
+		if err != nil {
+			t.Fatalf("加载内置画像 %s 失败: %v", name, err)
+		}
+		if p.Name != name || p.ExampleLanguage == "" || len(p.Analogies) == 0 {
+			t.Errorf("内置画像 %s 缺少字段: %+v", name, p)
+		}
+	}
+
+	bg := Default().Background()
+	for _, want := range []string{"Java、Golang", "MySQL、Kafka、Redis", "代码示例优先使用 Java/Golang"} {
+		if !strings.Contains(bg, want) {
+			t.Errorf("默认读者背景缺少 %q:\n%s", want, bg)
+		}
+	}
+}
+
+// TestLoad 测试按名称、目录覆盖和文件路径加载画像
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	custom := "audience: 运维工程师\nlanguages: [Shell, Python]\nexample_language: Shell\n"
+	os.WriteFile(filepath.Join(dir, "ops.yaml"), []byte(custom), 0644)
+	os.WriteFile(filepath.Join(dir, "backend.yml"), []byte("audience: 覆盖后的后端读者\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "legacy.txt"), []byte("- 编程语言：掌握 C++\n"), 0644)
+
+	p, err := Load("ops", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "ops" || p.Examples() != "Shell" || !strings.Contains(p.Background(), "编程语言：Shell、Python") {
+		t.Errorf("目录中的画像解析不正确: %+v", p)
+	}
+	if p, _ := Load("backend", dir); p == nil || p.Audience != "覆盖后的后端读者" {
+		t.Errorf("目录中的同名画像应该覆盖内置画像: %+v", p)
+	}
+	if names := strings.Join(List(dir), ","); names != "backend,data-analyst,frontend,ops" {
+		t.Errorf("画像列表不符合预期: %s", names)
+	}
+
+	// 纯文本文件整体作为读者背景
+	p, err = Load(filepath.Join(dir, "legacy.txt"), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "legacy" || p.Background() != "- 编程语言：掌握 C++" || p.Examples() != "读者熟悉的编程语言" {
+		t.Errorf("纯文本画像解析不正确: %+v", p)
+	}
+
+	if _, err := Load("nobody", dir); !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "ops") {
+		t.Errorf("不存在的画像应该返回 ErrNotFound 并列出可选值: %v", err)
+	}
+	if _, err := Parse("bad", []byte("audience: x\nlevle: 初学者\n")); err == nil {
+		t.Error("拼错的字段应该报错")
+	}
+	if _, err := Parse("bad", []byte("languages: [Go]\n")); err == nil {
+		t.Error("缺少 audience 应该报错")
+	}
+}
+
+// TestLookup 测试接口传入的画像只能是名称或画像内容，不能读取文件
+func TestLookup(t *testing.T) {
+	if p, err := Lookup("frontend", ""); err != nil || p.Name != "frontend" {
+		t.Errorf("应该按名称查找内置画像: %v", err)
+	}
+	p, err := Lookup("audience: 产品经理\nlevel: 不写代码\n", "")
+	if err != nil || p.Audience != "产品经理" || p.Name != "custom" {
+		t.Errorf("应该解析画像内容: %+v %v", p, err)
+	}
+	if _, err := Lookup("/etc/passwd", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("不应该按路径读取文件: %v", err)
+	}
+}
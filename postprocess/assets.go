@@ -0,0 +1,99 @@
+package postprocess
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxAssetBytes 单个图片的大小上限
+const maxAssetBytes = 10 << 20
+
+// assetExts 图片类型对应的扩展名
+var assetExts = map[string]string{
+	"image/png":     ".png",
+	"image/jpeg":    ".jpg",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+}
+
+// AssetDownloader 把文档中引用的远程图片下载到 Dir 目录并改为相对链接，
+// 文档离线查看时图片也能显示。下载失败的图片保留原链接
+type AssetDownloader struct {
+	Dir    string
+	Client *http.Client
+}
+
+func (a *AssetDownloader) Name() string { return "assets" }
+
+func (a *AssetDownloader) Process(ctx context.Context, doc *Document) error {
+	if doc.Path == "" {
+		return nil
+	}
+	// 同一张图片引用多次时只下载一次
+	done := map[string]string{}
+	doc.Content = mapLinks(doc.Content, func(image bool, target string) string {
+		if !image || !(strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")) {
+			return target
+		}
+		if local, ok := done[target]; ok {
+			return local
+		}
+		local, err := a.download(ctx, doc, target)
+		if err != nil {
+			doc.Warnf("下载图片失败，保留原链接 %s: %v", target, err)
+			local = target
+		}
+		done[target] = local
+		return local
+	})
+	return nil
+}
+
+func (a *AssetDownloader) download(ctx context.Context, doc *Document, target string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	contentType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	ext, ok := assetExts[strings.TrimSpace(contentType)]
+	if !ok {
+		return "", fmt.Errorf("不是图片: %s", contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAssetBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxAssetBytes {
+		return "", fmt.Errorf("图片超过 %d MB", maxAssetBytes>>20)
+	}
+
+	// 文件名使用原文件名加链接的哈希，既能辨认又不会冲突
+	base := "image"
+	if u, err := url.Parse(target); err == nil {
+		if name := strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path)); name != "" && name != "." && name != "/" && len(name) <= 40 {
+			base = name
+		}
+	}
+	sum := sha1.Sum([]byte(target))
+	return doc.writeAsset(a.Dir, fmt.Sprintf("%s-%x%s", base, sum[:4], ext), data)
+}
@@ -0,0 +1,62 @@
+package postprocess
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"eino_test/config"
+)
+
+// LinkRewriter 改写文档中的链接：先按规则替换前缀，没有命中规则的相对链接
+// 按源文件位置重新计算，保证文档保存到输出目录后链接仍然有效
+type LinkRewriter struct {
+	Rules []config.LinkRule
+}
+
+func (l *LinkRewriter) Name() string { return "links" }
+
+func (l *LinkRewriter) Process(_ context.Context, doc *Document) error {
+	doc.Content = mapLinks(doc.Content, func(_ bool, target string) string {
+		for _, rule := range l.Rules {
+			if rule.From != "" && strings.HasPrefix(target, rule.From) {
+				return rule.To + strings.TrimPrefix(target, rule.From)
+			}
+		}
+		return rebase(target, doc.Source, doc.Path)
+	})
+	return nil
+}
+
+// rebase 把相对于源文件的链接改为相对于输出文件，锚点、绝对路径和带协议的链接保持不变
+func rebase(target, source, output string) string {
+	if source == "" || output == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "/") {
+		return target
+	}
+	if u, err := url.Parse(target); err != nil || u.Scheme != "" || u.Host != "" {
+		return target
+	}
+	// 只改写路径部分，保留原来的查询参数和锚点
+	pathPart, suffix := target, ""
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		pathPart, suffix = target[:i], target[i:]
+	}
+	if pathPart == "" {
+		return target
+	}
+
+	srcDir, err := filepath.Abs(filepath.Dir(source))
+	if err != nil {
+		return target
+	}
+	outDir, err := filepath.Abs(filepath.Dir(output))
+	if err != nil || srcDir == outDir {
+		return target
+	}
+	rel, err := filepath.Rel(outDir, filepath.Join(srcDir, filepath.FromSlash(pathPart)))
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel) + suffix
+}
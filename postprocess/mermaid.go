@@ -0,0 +1,103 @@
+package postprocess
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"strings"
+
+	"eino_test/markdown"
+	"eino_test/mermaid"
+)
+
+// mermaidSummary 折叠块的标题，也用来识别已经处理过的图表
+const mermaidSummary = "<summary>Mermaid 源码</summary>"
+
+// MermaidImages 把 Mermaid 代码块替换为图片链接，源码保留在图片下方的折叠块中。
+// 本地渲染器得到的图片写入 AssetsDir，文档没有路径时以 data URL 内嵌
+type MermaidImages struct {
+	Renderer mermaid.Renderer
+	// Format 图片格式，渲染器不支持时退回 svg
+	Format    string
+	AssetsDir string
+}
+
+func (m *MermaidImages) Name() string { return "mermaid" }
+
+func (m *MermaidImages) Process(ctx context.Context, doc *Document) error {
+	lines := strings.Split(doc.Content, "\n")
+	out := make([]string, 0, len(lines))
+	count := 0
+	for i := 0; i < len(lines); i++ {
+		fence := markdown.FenceMarker(strings.TrimSpace(lines[i]))
+		if fence == "" {
+			out = append(out, lines[i])
+			continue
+		}
+
+		lang := strings.TrimSpace(strings.TrimSpace(lines[i])[len(fence):])
+		start := i + 1
+		// 兼容 ``` 后换行再写 mermaid 的写法
+		if lang == "" && i+1 < len(lines) && strings.TrimSpace(lines[i+1]) == "mermaid" {
+			lang, start = "mermaid", i+2
+		}
+		end := start
+		for end < len(lines) && !markdown.ClosesFence(lines[end], fence) {
+			end++
+		}
+		if end == len(lines) {
+			// 没有闭合的代码块原样保留
+			out = append(out, lines[i:]...)
+			break
+		}
+
+		block := lines[i : end+1]
+		i = end
+		if fields := strings.Fields(lang); len(fields) == 0 || fields[0] != "mermaid" || wrapped(out) {
+			out = append(out, block...)
+			continue
+		}
+
+		count++
+		source := lines[start:end]
+		link, err := m.render(ctx, doc, strings.Join(source, "\n"))
+		if err != nil {
+			doc.Warnf("第 %d 个 Mermaid 图表渲染失败，保留源码: %v", count, err)
+			out = append(out, block...)
+			continue
+		}
+		out = append(out, fmt.Sprintf("![Mermaid Diagram](%s)", link), "", "<details>", mermaidSummary, "", "```mermaid")
+		out = append(out, source...)
+		out = append(out, "```", "", "</details>")
+	}
+	doc.Content = strings.Join(out, "\n")
+	return nil
+}
+
+// wrapped 判断代码块是否已经位于折叠块中，避免重复处理同一份文档时生成多张图片
+func wrapped(out []string) bool {
+	for i := len(out) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(out[i]); line != "" {
+			return line == mermaidSummary
+		}
+	}
+	return false
+}
+
+func (m *MermaidImages) render(ctx context.Context, doc *Document, code string) (string, error) {
+	res, err := m.Renderer.Render(ctx, code, m.Format)
+	if errors.Is(err, mermaid.ErrUnsupported) && m.Format != mermaid.FormatSVG {
+		res, err = m.Renderer.Render(ctx, code, mermaid.FormatSVG)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(res.Data) == 0 || doc.Path == "" {
+		return res.URL, nil
+	}
+	// 按内容命名，重复保存同一份文档时覆盖而不是堆积文件
+	sum := sha1.Sum([]byte(code))
+	name := fmt.Sprintf("mermaid-%x.%s", sum[:6], res.Format)
+	return doc.writeAsset(m.AssetsDir, name, res.Data)
+}
@@ -0,0 +1,126 @@
+// Package postprocess 在 save_document 写入文件前对 Markdown 做后处理，
+// 每个处理步骤实现 Processor 接口，按配置的顺序组成 Pipeline
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"eino_test/config"
+	"eino_test/markdown"
+	"eino_test/mermaid"
+)
+
+// Document 正在处理的文档
+type Document struct {
+	Content string
+	// Path 文档将要写入的路径，图表和下载的图片保存在它旁边。为空时不写资源文件
+	Path string
+	// Source 改写前的源文件路径，用于修正相对链接。为空时不修正
+	Source string
+	// Assets 处理过程中写入的资源文件
+	Assets []string
+	// Warnings 不影响保存的问题，例如某个图表渲染失败、某张图片下载失败
+	Warnings []string
+}
+
+// Warnf 记录一条警告
+func (d *Document) Warnf(format string, args ...any) {
+	d.Warnings = append(d.Warnings, fmt.Sprintf(format, args...))
+}
+
+// writeAsset 把资源文件写到文档旁边的 dir 目录中，返回相对于文档的链接
+func (d *Document) writeAsset(dir, name string, data []byte) (string, error) {
+	rel := filepath.Join(dir, name)
+	target := filepath.Join(filepath.Dir(d.Path), rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("创建资源目录失败: %w", err)
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		return "", fmt.Errorf("写入资源文件失败: %w", err)
+	}
+	d.Assets = append(d.Assets, target)
+	return filepath.ToSlash(rel), nil
+}
+
+// Processor 一个后处理步骤
+type Processor interface {
+	// Name 步骤名称，与配置中的取值一致
+	Name() string
+	// Process 修改 doc.Content。返回错误时该步骤的修改会被丢弃
+	Process(ctx context.Context, doc *Document) error
+}
+
+// Pipeline 按顺序执行的后处理步骤
+type Pipeline []Processor
+
+// New 根据配置创建后处理流程，fixer 是 repair 步骤修复图表使用的模型调用，为空时 repair 只检查不修复
+func New(cfg config.PostProcessConfig, mermaidCfg config.MermaidConfig, fixer MermaidFixer) (Pipeline, error) {
+	var p Pipeline
+	for _, stage := range cfg.Stages {
+		switch stage {
+		case "links":
+			p = append(p, &LinkRewriter{Rules: cfg.LinkRules})
+		case "repair":
+			p = append(p, &MermaidRepair{Fixer: fixer, Attempts: cfg.RepairAttempts})
+		case "mermaid":
+			renderer, err := mermaid.New(mermaidCfg)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, &MermaidImages{Renderer: renderer, Format: cfg.MermaidFormat, AssetsDir: cfg.AssetsDir})
+		case "assets":
+			p = append(p, &AssetDownloader{Dir: cfg.AssetsDir, Client: &http.Client{Timeout: 30 * time.Second}})
+		case "toc":
+			p = append(p, &TOC{Depth: cfg.TOCDepth})
+		default:
+			return nil, fmt.Errorf("未知的后处理步骤 %q，可选值: %s", stage, strings.Join(config.PostProcessStages, ", "))
+		}
+	}
+	return p, nil
+}
+
+// Names 返回各步骤的名称
+func (p Pipeline) Names() []string {
+	names := make([]string, len(p))
+	for i, proc := range p {
+		names[i] = proc.Name()
+	}
+	return names
+}
+
+// Run 依次执行每个步骤。某个步骤失败时撤销它的修改并记录警告，不影响后面的步骤
+func (p Pipeline) Run(ctx context.Context, doc *Document) {
+	for _, proc := range p {
+		before := doc.Content
+		if err := proc.Process(ctx, doc); err != nil {
+			doc.Content = before
+			doc.Warnf("%s 处理失败，已跳过: %v", proc.Name(), err)
+		}
+	}
+}
+
+// linkRe 匹配 Markdown 链接和图片：[文字](地址 "标题")
+var linkRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^()\s]+)((?:\s+"[^"]*")?)\)`)
+
+// mapLinks 对代码块以外的每个链接调用 fn，用返回值替换链接地址
+func mapLinks(content string, fn func(image bool, target string) string) string {
+	lines := strings.Split(content, "\n")
+	code := markdown.CodeLines(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		lines[i] = linkRe.ReplaceAllStringFunc(line, func(m string) string {
+			sm := linkRe.FindStringSubmatch(m)
+			return sm[1] + "[" + sm[2] + "](" + fn(sm[1] == "!", sm[3]) + sm[4] + ")"
+		})
+	}
+	return strings.Join(lines, "\n")
+}
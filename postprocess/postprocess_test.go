@@ -0,0 +1,268 @@
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+	"eino_test/mermaid"
+)
+
+const mermaidDoc = "# 标题\n\n" +
+	"```mermaid\ngraph TD\n    A[开始] --> B[结束]\n```\n\n" +
+	"```\nmermaid\nsequenceDiagram\n    A->>B: 请求\n```\n\n" +
+	"```mermaid\nclassDiagram\n    Animal <|-- Dog\n```\n\n" +
+	"````markdown\n```mermaid\ngraph LR\n    X --> Y\n```\n````\n\n" +
+	"```go\nfmt.Println(\"hello\")\n```\n"
+
+// TestMermaidImages 测试图表替换为图片、源码保留在折叠块中，以及重复处理时不会生成多张图片
+func TestMermaidImages(t *testing.T) {
+	dir := t.TempDir()
+	doc := &Document{Content: mermaidDoc, Path: filepath.Join(dir, "out.md")}
+	stage := &MermaidImages{Renderer: mermaid.NewSVGRenderer(), Format: mermaid.FormatPNG, AssetsDir: "assets"}
+	if err := stage.Process(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(doc.Content, "![Mermaid Diagram](assets/mermaid-"); n != 2 {
+		t.Fatalf("应该生成 2 张图片，实际 %d 张:\n%s", n, doc.Content)
+	}
+	if len(doc.Assets) != 2 {
+		t.Fatalf("应该写入 2 个资源文件: %v", doc.Assets)
+	}
+	for _, asset := range doc.Assets {
+		if data, err := os.ReadFile(asset); err != nil || !strings.HasPrefix(string(data), "<svg") {
+			t.Errorf("资源文件 %s 不是 SVG: %v", asset, err)
+		}
+	}
+	if strings.Count(doc.Content, mermaidSummary) != 2 || !strings.Contains(doc.Content, "    A[开始] --> B[结束]") {
+		t.Errorf("源码没有保留在折叠块中:\n%s", doc.Content)
+	}
+	// 内置渲染器不支持类图，保留源码并给出警告
+	if len(doc.Warnings) != 1 || !strings.Contains(doc.Content, "```mermaid\nclassDiagram") {
+		t.Errorf("不支持的图表应该保留源码并记录警告: %v", doc.Warnings)
+	}
+	if !strings.Contains(doc.Content, "````markdown\n```mermaid\ngraph LR") || !strings.Contains(doc.Content, "```go\nfmt.Println") {
+		t.Error("其他代码块不应该被修改")
+	}
+
+	first := doc.Content
+	doc.Warnings = nil
+	if err := stage.Process(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != first {
+		t.Errorf("重复处理不应该改变内容:\n%s", doc.Content)
+	}
+
+	// 没有输出路径时使用在线渲染器的链接
+	doc = &Document{Content: "```mermaid\ngraph TD\n    A --> B\n```"}
+	stage = &MermaidImages{Renderer: mermaid.NewInkRenderer(""), Format: mermaid.FormatPNG}
+	if err := stage.Process(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(doc.Content, "![Mermaid Diagram](https://mermaid.ink/img/") {
+		t.Errorf("应该使用 mermaid.ink 链接:\n%s", doc.Content)
+	}
+}
+
+// TestAssetDownloader 测试远程图片下载到本地，失败时保留原链接
+func TestAssetDownloader(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG fake"))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	content := "![logo](" + srv.URL + "/logo.png)\n" +
+		"再引用一次 ![logo](" + srv.URL + "/logo.png \"标题\")\n" +
+		"![missing](" + srv.URL + "/missing.png)\n" +
+		"![html](" + srv.URL + "/page.html)\n" +
+		"[普通链接](" + srv.URL + "/logo.png)\n" +
+		"```\n![code](" + srv.URL + "/logo.png)\n```"
+	doc := &Document{Content: content, Path: filepath.Join(dir, "doc", "out.md")}
+	stage := &AssetDownloader{Dir: "assets", Client: srv.Client()}
+	if err := stage.Process(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(doc.Content, "\n")
+	if !strings.HasPrefix(lines[0], "![logo](assets/logo-") || !strings.HasSuffix(lines[0], ".png)") {
+		t.Errorf("图片没有改为本地链接: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "(assets/logo-") || !strings.Contains(lines[1], `"标题")`) {
+		t.Errorf("重复引用的图片应该使用同一个本地文件并保留标题: %s", lines[1])
+	}
+	if calls != 3 {
+		t.Errorf("同一张图片只应该下载一次，请求次数 %d", calls)
+	}
+	if !strings.Contains(lines[2], srv.URL) || !strings.Contains(lines[3], srv.URL) || len(doc.Warnings) != 2 {
+		t.Errorf("下载失败的图片应该保留原链接并记录警告: %v", doc.Warnings)
+	}
+	if !strings.Contains(lines[4], srv.URL) || !strings.Contains(lines[6], srv.URL) {
+		t.Error("普通链接和代码块中的图片不应该被下载")
+	}
+	if data, err := os.ReadFile(doc.Assets[0]); err != nil || string(data) != "\x89PNG fake" {
+		t.Errorf("资源文件内容不对: %v", err)
+	}
+}
+
+// TestTOC 测试目录的插入位置、锚点生成和重复处理
+func TestTOC(t *testing.T) {
+	content := "# 数据模型\n\n引言\n\n## 1. 关系模型 vs 文档模型\n\n### 使用 `JOIN`\n\n#### 太深的标题\n\n" +
+		"```bash\n## 代码中的注释\n```\n\n## 小结\n\n## 小结\n"
+	doc := &Document{Content: content}
+	stage := &TOC{Depth: 3}
+	if err := stage.Process(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# 数据模型\n\n" + tocStart + "\n**目录**\n\n" +
+		"- [1. 关系模型 vs 文档模型](#1-关系模型-vs-文档模型)\n" +
+		"  - [使用 JOIN](#使用-join)\n" +
+		"- [小结](#小结)\n" +
+		"- [小结](#小结-1)\n\n" + tocEnd + "\n\n引言\n"
+	if !strings.HasPrefix(doc.Content, want) {
+		t.Fatalf("目录不符合预期:\n%s", doc.Content)
+	}
+
+	first := doc.Content
+	if err := stage.Process(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != first {
+		t.Errorf("重复处理不应该改变内容:\n%s", doc.Content)
+	}
+
+	doc = &Document{Content: "# 只有一节\n\n## 唯一的小节\n"}
+	stage.Process(context.Background(), doc)
+	if strings.Contains(doc.Content, tocStart) {
+		t.Error("标题太少时不应该生成目录")
+	}
+}
+
+// TestLinkRewriter 测试前缀替换规则和相对链接的重新计算
+func TestLinkRewriter(t *testing.T) {
+	root := t.TempDir()
+	doc := &Document{
+		Content: "[wiki](http://wiki.internal/page)\n" +
+			"![图](images/arch.png)\n" +
+			"[下一篇](../other/next.md#第二节)\n" +
+			"[锚点](#小结) [外链](https://example.com/a.md) [邮件](mailto:a@b.c)\n" +
+			"```\n[块](images/arch.png)\n```",
+		Source: filepath.Join(root, "docs", "guide", "src.md"),
+		Path:   filepath.Join(root, "output", "out.md"),
+	}
+	stage := &LinkRewriter{Rules: []config.LinkRule{{From: "http://wiki.internal/", To: "https://wiki.example.com/"}}}
+	if err := stage.Process(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(doc.Content, "\n")
+	want := []string{
+		"[wiki](https://wiki.example.com/page)",
+		"![图](../docs/guide/images/arch.png)",
+		"[下一篇](../docs/other/next.md#第二节)",
+		"[锚点](#小结) [外链](https://example.com/a.md) [邮件](mailto:a@b.c)",
+	}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("第 %d 行:\n got: %s\nwant: %s", i+1, lines[i], w)
+		}
+	}
+	if lines[5] != "[块](images/arch.png)" {
+		t.Errorf("代码块中的链接不应该被改写: %s", lines[5])
+	}
+}
+
+type failingStage struct{}
+
+func (failingStage) Name() string { return "failing" }
+
+func (failingStage) Process(_ context.Context, doc *Document) error {
+	doc.Content = "被破坏的内容"
+	return errors.New("boom")
+}
+
+// TestPipeline 测试按配置组装流程，以及失败的步骤被撤销且不影响后续步骤
+func TestPipeline(t *testing.T) {
+	cfg := config.Default().PostProcess
+	cfg.Stages = []string{"links", "mermaid", "assets", "toc"}
+	p, err := New(cfg, config.Default().Mermaid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(p.Names(), ","); got != "links,mermaid,assets,toc" {
+		t.Errorf("步骤顺序不对: %s", got)
+	}
+
+	cfg.Stages = []string{"spellcheck"}
+	if _, err := New(cfg, config.Default().Mermaid, nil); err == nil {
+		t.Error("未知步骤应该报错")
+	}
+
+	doc := &Document{Content: "# 标题\n\n## 一\n\n## 二\n"}
+	Pipeline{failingStage{}, &TOC{}}.Run(context.Background(), doc)
+	if !strings.Contains(doc.Content, tocStart) || strings.Contains(doc.Content, "被破坏") {
+		t.Errorf("失败步骤的修改应该被撤销，后续步骤继续执行:\n%s", doc.Content)
+	}
+	if len(doc.Warnings) != 1 || !strings.Contains(doc.Warnings[0], "failing") {
+		t.Errorf("应该记录失败步骤的警告: %v", doc.Warnings)
+	}
+}
+
+// TestMermaidRepair 测试只把语法错误的图表交给修复函数，修复后重新检查，修复不了的保留原样
+func TestMermaidRepair(t *testing.T) {
+	content := "# 图表\n\n```mermaid\ngraph TD\n    A --> B\n```\n\n```mermaid\ngraph TD\n    A[开始] --> B(处理(重试))\n```\n\n```mermaid\nsequenceDiagram\n    A->>B\n```\n"
+	var calls []string
+	fixer := func(ctx context.Context, code string, errs []mermaid.SyntaxError) (string, error) {
+		calls = append(calls, code)
+		if strings.HasPrefix(code, "sequenceDiagram") {
+			// 两次修复后仍然缺少消息文字
+			return "sequenceDiagram\n    A-)B", nil
+		}
+		return "graph TD\n    A[开始] --> B(\"处理(重试)\")", nil
+	}
+
+	doc := &Document{Content: content}
+	if err := (&MermaidRepair{Fixer: fixer, Attempts: 2}).Process(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || strings.Contains(calls[0], "A --> B\n") {
+		t.Fatalf("应该只修复出错的两个图表，时序图重试一次: %q", calls)
+	}
+	if !strings.Contains(doc.Content, "```mermaid\ngraph TD\n    A --> B\n```") ||
+		!strings.Contains(doc.Content, "B(\"处理(重试)\")\n```") {
+		t.Errorf("流程图没有被正确替换:\n%s", doc.Content)
+	}
+	// 两次都没有修好时保留原样
+	if !strings.Contains(doc.Content, "```mermaid\nsequenceDiagram\n    A->>B\n```") || len(doc.Warnings) != 2 ||
+		!strings.Contains(doc.Warnings[1], "第 3 个 Mermaid 图表有语法错误，自动修复失败") {
+		t.Errorf("修复失败的图表应该保留原样并记录警告: %v\n%s", doc.Warnings, doc.Content)
+	}
+
+	doc = &Document{Content: content}
+	if err := (&MermaidRepair{}).Process(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != content || len(doc.Warnings) != 2 {
+		t.Errorf("没有修复函数时只记录警告: %v", doc.Warnings)
+	}
+}
@@ -0,0 +1,110 @@
+package postprocess
+
+import (
+	"context"
+	"strings"
+
+	"eino_test/markdown"
+	"eino_test/mermaid"
+)
+
+// MermaidFixer 根据语法错误修复一段 Mermaid 代码，返回修复后的代码（不含 ``` 围栏）
+type MermaidFixer func(ctx context.Context, code string, errs []mermaid.SyntaxError) (string, error)
+
+// MermaidRepair 用 mermaid.Validate 检查每个 Mermaid 代码块，有语法错误时只把这一个图表交给 Fixer 修复，
+// 修复后重新检查，最多尝试 Attempts 次。修复不了的图表保留原样并记录警告。
+// Fixer 为空时只检查不修复
+type MermaidRepair struct {
+	Fixer    MermaidFixer
+	Attempts int
+}
+
+func (m *MermaidRepair) Name() string { return "repair" }
+
+func (m *MermaidRepair) Process(ctx context.Context, doc *Document) error {
+	lines := strings.Split(doc.Content, "\n")
+	out := make([]string, 0, len(lines))
+	count := 0
+	for i := 0; i < len(lines); i++ {
+		fence := markdown.FenceMarker(strings.TrimSpace(lines[i]))
+		if fence == "" {
+			out = append(out, lines[i])
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !markdown.ClosesFence(lines[end], fence) {
+			end++
+		}
+		if end == len(lines) {
+			out = append(out, lines[i:]...)
+			break
+		}
+
+		lang := strings.TrimSpace(strings.TrimSpace(lines[i])[len(fence):])
+		start := i + 1
+		// 与 MermaidImages 一致，兼容 ``` 后换行再写 mermaid 的写法
+		if lang == "" && start < end && strings.TrimSpace(lines[start]) == "mermaid" {
+			lang, start = "mermaid", start+1
+		}
+		block := lines[i : end+1]
+		head := lines[i:start]
+		i = end
+		if fields := strings.Fields(lang); len(fields) == 0 || fields[0] != "mermaid" || wrapped(out) {
+			out = append(out, block...)
+			continue
+		}
+
+		count++
+		code := strings.Join(lines[start:end], "\n")
+		errs := mermaid.Validate(code)
+		if len(errs) == 0 {
+			out = append(out, block...)
+			continue
+		}
+		fixed, err := m.fix(ctx, code, errs)
+		if err != nil {
+			doc.Warnf("第 %d 个 Mermaid 图表有语法错误，自动修复失败，保留原样: %v", count, err)
+			out = append(out, block...)
+			continue
+		}
+		doc.Warnf("第 %d 个 Mermaid 图表有语法错误，已自动修复: %s", count, joinErrors(errs))
+		out = append(out, head...)
+		out = append(out, strings.Split(fixed, "\n")...)
+		out = append(out, block[len(block)-1])
+	}
+	doc.Content = strings.Join(out, "\n")
+	return nil
+}
+
+// fix 反复请求修复直到代码通过检查，返回修复后的代码
+func (m *MermaidRepair) fix(ctx context.Context, code string, errs []mermaid.SyntaxError) (string, error) {
+	if m.Fixer == nil {
+		return "", &repairError{errs}
+	}
+	for range max(m.Attempts, 1) {
+		fixed, err := m.Fixer(ctx, code, errs)
+		if err != nil {
+			return "", err
+		}
+		code = strings.Trim(fixed, "\n")
+		if errs = mermaid.Validate(code); len(errs) == 0 {
+			return code, nil
+		}
+	}
+	return "", &repairError{errs}
+}
+
+// repairError 修复后仍然存在的语法错误
+type repairError struct {
+	errs []mermaid.SyntaxError
+}
+
+func (e *repairError) Error() string { return joinErrors(e.errs) }
+
+func joinErrors(errs []mermaid.SyntaxError) string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "；")
+}
@@ -0,0 +1,142 @@
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"eino_test/markdown"
+)
+
+// 目录的起止标记，再次处理时替换标记之间的内容
+const (
+	tocStart = "<!-- toc -->"
+	tocEnd   = "<!-- tocstop -->"
+)
+
+// minTOCEntries 标题少于该数量时不生成目录
+const minTOCEntries = 2
+
+var inlineLinkRe = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+
+// TOC 在一级标题之后插入目录，包含二级到 Depth 级标题，锚点与 GitHub 的生成规则一致
+type TOC struct {
+	Depth int
+}
+
+func (t *TOC) Name() string { return "toc" }
+
+type tocEntry struct {
+	level int
+	text  string
+	slug  string
+}
+
+func (t *TOC) Process(_ context.Context, doc *Document) error {
+	depth := t.Depth
+	if depth <= 0 {
+		depth = 3
+	}
+
+	lines := strings.Split(doc.Content, "\n")
+	// 去掉已有的目录
+	if start, end := indexOf(lines, tocStart), indexOf(lines, tocEnd); start >= 0 && end > start {
+		lines = append(lines[:start:start], lines[end+1:]...)
+		if start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+			lines = append(lines[:start], lines[start+1:]...)
+		}
+	}
+
+	code := markdown.CodeLines(lines)
+	var entries []tocEntry
+	slugs := map[string]int{}
+	insertAt := 0
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		level, text, ok := markdown.ParseHeading(line)
+		if !ok {
+			continue
+		}
+		text = plainHeading(text)
+		// 所有标题都参与锚点去重，和渲染器保持一致
+		slug := slugify(text)
+		if n := slugs[slug]; n > 0 {
+			slugs[slug]++
+			slug = fmt.Sprintf("%s-%d", slug, n)
+		} else {
+			slugs[slug] = 1
+		}
+		if level == 1 {
+			if insertAt == 0 && len(entries) == 0 {
+				insertAt = i + 1
+			}
+			continue
+		}
+		if level <= depth {
+			entries = append(entries, tocEntry{level: level, text: text, slug: slug})
+		}
+	}
+	if len(entries) < minTOCEntries {
+		doc.Content = strings.Join(lines, "\n")
+		return nil
+	}
+
+	top := entries[0].level
+	for _, e := range entries {
+		top = min(top, e.level)
+	}
+	toc := []string{tocStart, "**目录**", ""}
+	for _, e := range entries {
+		toc = append(toc, fmt.Sprintf("%s- [%s](#%s)", strings.Repeat("  ", e.level-top), e.text, e.slug))
+	}
+	toc = append(toc, "", tocEnd)
+
+	var out []string
+	out = append(out, lines[:insertAt]...)
+	if insertAt > 0 {
+		out = append(out, "")
+	}
+	out = append(out, toc...)
+	rest := lines[insertAt:]
+	for len(rest) > 0 && strings.TrimSpace(rest[0]) == "" {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		out = append(out, "")
+	}
+	doc.Content = strings.Join(append(out, rest...), "\n")
+	return nil
+}
+
+func indexOf(lines []string, marker string) int {
+	for i, line := range lines {
+		if strings.TrimSpace(line) == marker {
+			return i
+		}
+	}
+	return -1
+}
+
+// plainHeading 去掉标题中的行内格式，只保留文字
+func plainHeading(text string) string {
+	text = inlineLinkRe.ReplaceAllString(text, "$1")
+	return strings.NewReplacer("**", "", "__", "", "`", "", "~~", "").Replace(text)
+}
+
+// slugify 按 GitHub 的规则生成锚点：转小写，去掉标点，空格换成连字符
+func slugify(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,217 @@
+// Package prompts 管理各 Agent 的提示词模板。模板是带版本号的 text/template 文件，
+// 内置模板编译进程序，项目可以在覆盖目录中放同名文件替换内置模板，
+// 每次改写都会记录实际使用的模板版本，方便对比不同版本提示词的效果
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 内置模板的名称，对应 templates 目录下的 <名称>.tmpl
+const (
+	Main           = "main"
+	Summary        = "summary"
+	Reviewer       = "reviewer"
+	RewriteSystem  = "rewrite_system"
+	RewriteRequest = "rewrite_request"
+	ReviewRequest  = "review_request"
+	MermaidRepair  = "mermaid_repair"
+	// Planner 整篇改写前生成教学大纲
+	Planner = "planner"
+	// 按章节改写使用的模板
+	SectionPlan        = "section_plan"
+	SectionRequest     = "section_request"
+	SectionTransition  = "section_transition"
+	SectionConsistency = "section_consistency"
+)
+
+// ext 模板文件的扩展名
+const ext = ".tmpl"
+
+//go:embed templates/*.tmpl
+var builtin embed.FS
+
+// funcs 模板中可以使用的函数
+var funcs = template.FuncMap{
+	// inc 把从 0 开始的 range 下标转换为从 1 开始的编号
+	"inc": func(i int) int { return i + 1 },
+}
+
+// Template 一个带版本号的提示词模板
+type Template struct {
+	Name string
+	// Version 模板版本，修改模板内容时需要同时修改版本号
+	Version string
+	// Description 模板用途说明
+	Description string
+	// Path 覆盖模板的文件路径，内置模板为空
+	Path string
+
+	tmpl *template.Template
+}
+
+// header 模板文件开头 --- 之间的元信息
+type header struct {
+	Version     string `yaml:"version"`
+	Description string `yaml:"description"`
+}
+
+// Ref 模板的版本标识，例如 summary@2，覆盖模板会带上文件路径
+func (t *Template) Ref() string {
+	if t.Path != "" {
+		return fmt.Sprintf("%s@%s (%s)", t.Name, t.Version, t.Path)
+	}
+	return t.Name + "@" + t.Version
+}
+
+// Render 渲染模板，去掉首尾空白。模板引用了 data 中不存在的字段时返回错误
+func (t *Template) Render(data any) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("渲染提示词模板 %s 失败: %w", t.Ref(), err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Library 一组提示词模板
+type Library struct {
+	templates map[string]*Template
+}
+
+// Default 返回只包含内置模板的模板库
+func Default() *Library {
+	lib, err := Load("")
+	if err != nil {
+		panic(err)
+	}
+	return lib
+}
+
+// Load 加载内置模板，再用 dir 目录中的同名 .tmpl 文件覆盖。dir 为空或不存在时只使用内置模板，
+// 目录中出现内置模板之外的文件时报错，避免文件名拼错后覆盖悄悄失效
+func Load(dir string) (*Library, error) {
+	lib := &Library{templates: map[string]*Template{}}
+	entries, err := fs.ReadDir(builtin, "templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		data, err := builtin.ReadFile("templates/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		t, err := Parse(strings.TrimSuffix(e.Name(), ext), data)
+		if err != nil {
+			return nil, err
+		}
+		lib.templates[t.Name] = t
+	}
+	if dir == "" {
+		return lib, nil
+	}
+
+	overrides, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return lib, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取提示词目录失败: %w", err)
+	}
+	var errs []error
+	for _, e := range overrides {
+		if e.IsDir() || filepath.Ext(e.Name()) != ext {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ext)
+		if _, ok := lib.templates[name]; !ok {
+			errs = append(errs, fmt.Errorf("提示词模板 %s 不存在，可选值: %s", e.Name(), strings.Join(lib.Names(), ", ")))
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		t, err := Parse(name, data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		t.Path = path
+		lib.templates[name] = t
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return lib, nil
+}
+
+// Parse 解析模板文件。文件必须以 --- 包围的元信息开头，其中 version 必填
+func Parse(name string, data []byte) (*Template, error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	rest, ok := bytes.CutPrefix(data, []byte("---\n"))
+	if !ok {
+		return nil, errors.New("模板文件缺少开头的 --- 元信息")
+	}
+	meta, body, ok := bytes.Cut(rest, []byte("\n---\n"))
+	if !ok {
+		return nil, errors.New("模板文件的元信息缺少结尾的 ---")
+	}
+	var h header
+	if err := yaml.Unmarshal(meta, &h); err != nil {
+		return nil, fmt.Errorf("解析模板元信息失败: %v", err)
+	}
+	if h.Version == "" {
+		return nil, errors.New("模板元信息缺少 version")
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(funcs).Parse(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("解析模板失败: %w", err)
+	}
+	return &Template{Name: name, Version: h.Version, Description: h.Description, tmpl: tmpl}, nil
+}
+
+// Get 返回指定名称的模板，不存在时返回 nil
+func (l *Library) Get(name string) *Template {
+	return l.templates[name]
+}
+
+// Render 渲染指定名称的模板
+func (l *Library) Render(name string, data any) (string, error) {
+	t := l.Get(name)
+	if t == nil {
+		return "", fmt.Errorf("提示词模板 %s 不存在", name)
+	}
+	return t.Render(data)
+}
+
+// Names 返回所有模板名称
+func (l *Library) Names() []string {
+	names := make([]string, 0, len(l.templates))
+	for name := range l.templates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Refs 返回所有模板的版本标识，按名称排序
+func (l *Library) Refs() []string {
+	refs := make([]string, 0, len(l.templates))
+	for _, name := range l.Names() {
+		refs = append(refs, l.templates[name].Ref())
+	}
+	return refs
+}
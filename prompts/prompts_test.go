@@ -0,0 +1,179 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+	"eino_test/persona"
+	"eino_test/review"
+)
+
+// testData 与 agent 包传给模板的数据字段一致
+type testData struct {
+	Persona    *persona.Persona
+	Language   string
+	Rubric     *review.Rubric
+	Review     config.ReviewConfig
+	ReviewOnly bool
+	Feishu     bool
+	Filepath   string
+	Content    string
+	Errors     string
+	Plan       string
+	Section    struct {
+		Index, Total int
+		Title        string
+		Prev, Next   string
+	}
+}
+
+// TestBuiltin 测试所有内置模板都带版本号，并且能用读者画像渲染
+func TestBuiltin(t *testing.T) {
+	lib := Default()
+	want := []string{Main, MermaidRepair, Planner, ReviewRequest, Reviewer, RewriteRequest, RewriteSystem,
+		SectionConsistency, SectionPlan, SectionRequest, SectionTransition, Summary}
+	if got := strings.Join(lib.Names(), ","); got != strings.Join(want, ",") {
+		t.Fatalf("内置模板不符合预期: %s", got)
+	}
+
+	data := testData{
+		Persona:  persona.Default(),
+		Language: "简体中文",
+		Rubric:   review.DefaultRubric(),
+		Review:   config.Default().Review,
+		Filepath: "docs/kafka.md",
+	}
+	for _, name := range lib.Names() {
+		tmpl := lib.Get(name)
+		if tmpl.Version == "" || tmpl.Path != "" {
+			t.Errorf("内置模板 %s 的元信息不对: %+v", name, tmpl)
+		}
+		if _, err := tmpl.Render(data); err != nil {
+			t.Errorf("渲染 %s 失败: %v", name, err)
+		}
+	}
+
+	summary, _ := lib.Render(Summary, data)
+	if !strings.HasPrefix(summary, "你是一个专业的技术文档改写专家，专门为后端开发初学者") ||
+		!strings.Contains(summary, "代码示例优先使用 Java/Golang") || !strings.Contains(summary, "使用简体中文撰写") {
+		t.Errorf("summary 模板没有填充画像和语言:\n%s", summary[:300])
+	}
+	if !strings.Contains(summary, "10. 【Markdown 格式】（必须满足）正确使用 Markdown 格式") || !strings.Contains(summary, "   - 避免\"翻译式注释\"") {
+		t.Error("summary 模板没有从评审标准生成改写原则")
+	}
+	if request, _ := lib.Render(RewriteRequest, data); request != "请你帮我改写这份技术文档，文档路径为：docs/kafka.md" {
+		t.Errorf("rewrite_request 渲染结果不对: %q", request)
+	}
+
+	reviewer, _ := lib.Render(Reviewer, data)
+	if !strings.Contains(reviewer, "10. 【Markdown 格式】（id: markdown，必须满足，权重 1）") || !strings.Contains(reviewer, "每一项不低于 6 分且加权平均分不低于 8 分") {
+		t.Error("reviewer 模板没有填充评审标准和分数阈值")
+	}
+	// 改写原则和评审清单来自同一份评审标准，替换标准后两边同时变化
+	data.Rubric = &review.Rubric{Name: "api", Criteria: []review.Criterion{
+		{ID: "accuracy", Name: "接口准确性", Level: review.LevelMust, Weight: 2, Description: "参数和返回值与实现一致",
+			Guidelines: []string{"每个参数都写明类型和默认值"}, Checklist: []string{"参数说明是否与实现一致？"}},
+	}}
+	summary, _ = lib.Render(Summary, data)
+	custom, _ := lib.Render(Reviewer, data)
+	if !strings.Contains(summary, "1. 【接口准确性】（必须满足）参数和返回值与实现一致\n   - 每个参数都写明类型和默认值") ||
+		strings.Contains(summary, "图表辅助") {
+		t.Errorf("summary 模板没有使用替换后的评审标准:\n%s", summary)
+	}
+	if !strings.Contains(custom, "1. 【接口准确性】（id: accuracy，必须满足，权重 2）") || !strings.Contains(custom, "逐一检查上述 1 个标准") ||
+		strings.Contains(custom, "图表辅助") {
+		t.Errorf("reviewer 模板没有使用替换后的评审标准:\n%s", custom)
+	}
+	data.Rubric = review.DefaultRubric()
+
+	if !strings.Contains(reviewer, "未配置飞书") {
+		t.Error("没有飞书时应该提示只保存到本地")
+	}
+	data.Feishu = true
+	if reviewer, _ = lib.Render(Reviewer, data); strings.Contains(reviewer, "【本次运行说明】") {
+		t.Error("挂载了飞书工具时不需要运行说明")
+	}
+	data.ReviewOnly = true
+	if reviewer, _ = lib.Render(Reviewer, data); !strings.Contains(reviewer, "本次只做评审") {
+		t.Error("只评审时应该提示不要保存")
+	}
+
+	// 整篇改写时有教学大纲才带上大纲，并要求章节与大纲一致
+	if strings.Contains(summary, "【教学大纲】") || !strings.Contains(summary, "改写前先分析原文的全局结构") {
+		t.Error("没有教学大纲时不应该出现大纲")
+	}
+	data.Plan = "# 教学大纲：Kafka"
+	if withPlan, _ := lib.Render(Summary, data); !strings.Contains(withPlan, "【教学大纲】（改写前已经确定，正文必须与大纲一致）\n# 教学大纲：Kafka") ||
+		!strings.Contains(withPlan, "按大纲的顺序排列") {
+		t.Errorf("summary 模板没有填充教学大纲:\n%s", withPlan)
+	}
+	data.Plan = ""
+
+	// 按章节改写时 summary 和 reviewer 都带上章节位置，整篇改写时没有这一段
+	if strings.Contains(summary, "【按章节改写】") || strings.Contains(reviewer, "【按章节评审】") {
+		t.Error("整篇改写时不应该出现按章节改写的说明")
+	}
+	data.Section.Index, data.Section.Total, data.Section.Title = 2, 5, "消费者组"
+	data.Section.Prev, data.Section.Next = "生产者", "位移提交"
+	data.Plan, data.Content = "全局教学计划", "## 消费者组\n原文"
+	if summary, _ = lib.Render(Summary, data); !strings.Contains(summary, "本次只改写全文的第 2/5 节《消费者组》") {
+		t.Error("summary 模板没有填充章节位置")
+	}
+	if reviewer, _ = lib.Render(Reviewer, data); !strings.Contains(reviewer, "本次评审的是全文第 2/5 节《消费者组》的改写稿") {
+		t.Error("reviewer 模板没有填充章节位置")
+	}
+	request, _ := lib.Render(SectionRequest, data)
+	if !strings.HasPrefix(request, "请改写全文第 2/5 节《消费者组》。前一节是《生产者》，后一节是《位移提交》，") ||
+		!strings.Contains(request, "【全局教学计划】\n全局教学计划") {
+		t.Errorf("section_request 渲染结果不对:\n%s", request)
+	}
+}
+
+// TestOverride 测试覆盖目录中的模板替换内置模板并记录版本，错误的覆盖文件在加载时报错
+func TestOverride(t *testing.T) {
+	dir := t.TempDir()
+	override := "---\nversion: 3-team\n---\n给 {{.Persona.Audience}} 写一篇文档\n"
+	os.WriteFile(filepath.Join(dir, "summary.tmpl"), []byte(override), 0644)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("说明文件会被忽略"), 0644)
+
+	lib, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := lib.Render(Summary, testData{Persona: persona.Default()})
+	if err != nil || got != "给 后端开发初学者 写一篇文档" {
+		t.Errorf("覆盖模板没有生效: %q %v", got, err)
+	}
+	refs := strings.Join(lib.Refs(), ",")
+	if !strings.Contains(refs, "summary@3-team ("+filepath.Join(dir, "summary.tmpl")+")") || !strings.Contains(refs, "reviewer@") {
+		t.Errorf("版本记录不对: %s", refs)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("覆盖目录不存在时应该只使用内置模板: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"sumary.tmpl":   "---\nversion: 1\n---\n拼错的文件名",
+		"main.tmpl":     "没有元信息",
+		"reviewer.tmpl": "---\ndescription: 缺少版本\n---\n内容",
+		"summary.tmpl":  "---\nversion: 4\n---\n{{.Persona.Audience",
+	} {
+		bad := t.TempDir()
+		os.WriteFile(filepath.Join(bad, name), []byte(content), 0644)
+		if _, err := Load(bad); err == nil {
+			t.Errorf("%s 应该加载失败", name)
+		}
+	}
+
+	// 模板引用了不存在的字段时渲染报错，而不是输出 <no value>
+	bad := t.TempDir()
+	os.WriteFile(filepath.Join(bad, "main.tmpl"), []byte("---\nversion: 2\n---\n{{.Criteria}}"), 0644)
+	lib, _ = Load(bad)
+	if _, err := lib.Render(Main, testData{}); err == nil {
+		t.Error("引用不存在的字段应该报错")
+	}
+}
@@ -0,0 +1,183 @@
+// Package review 定义 ReviewerAgent 的结构化评审结果：每个评审标准 0～10 分、
+// 带章节定位的问题和修改建议。是否通过由分数阈值决定，不依赖模型的判断
+package review
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"eino_test/config"
+)
+
+// MaxScore 单项评分的满分
+const MaxScore = 10
+
+// Score 单项评分
+type Score struct {
+	Criterion string `json:"criterion" jsonschema_description:"评审标准的 id"`
+	Score     int    `json:"score" jsonschema:"minimum=0,maximum=10" jsonschema_description:"0～10 的整数分，10 分表示完全满足"`
+	Comment   string `json:"comment,omitempty" jsonschema_description:"打分理由，一两句话"`
+}
+
+// Issue 评审发现的问题
+type Issue struct {
+	Criterion  string `json:"criterion" jsonschema_description:"问题对应的评审标准 id"`
+	Anchor     string `json:"anchor" jsonschema_description:"问题所在章节的标题，例如 \"## 2.1 数据库事务\""`
+	Problem    string `json:"problem" jsonschema_description:"具体问题描述"`
+	Suggestion string `json:"suggestion" jsonschema_description:"具体的修改建议，可以给出改进后的示例"`
+}
+
+// Submission ReviewerAgent 通过 submit_review 工具提交的评审结果
+type Submission struct {
+	Scores  []Score `json:"scores" jsonschema_description:"每个评审标准各一项评分，不能遗漏或重复"`
+	Issues  []Issue `json:"issues,omitempty" jsonschema_description:"发现的问题，低于及格分的标准至少要有一条"`
+	Summary string  `json:"summary" jsonschema_description:"整体评价，一两句话"`
+}
+
+// Result 经过校验和阈值判定的评审结果
+type Result struct {
+	Submission
+	// Rubric 评审使用的标准集名称
+	Rubric string `json:"rubric,omitempty"`
+	// Iteration 第几轮评审，从 1 开始
+	Iteration int `json:"iteration"`
+	// Average 各项评分按权重计算的平均分
+	Average float64 `json:"average"`
+	// Failed 低于单项及格分的必须满足（must）标准
+	Failed []string `json:"failed,omitempty"`
+	// Weak 低于单项及格分的建议满足（should）标准，不影响是否通过
+	Weak []string `json:"weak,omitempty"`
+	// Passed 是否达到通过阈值
+	Passed bool      `json:"passed"`
+	Time   time.Time `json:"time"`
+}
+
+// Validate 校验评审结果：每个标准恰好评分一次、分数在 0～10 之间、问题带有章节定位，
+// 并把使用标准名称的评分统一为 id。minScore 以下的标准必须至少有一条问题
+func (s *Submission) Validate(criteria []Criterion, minScore int) error {
+	ids := map[string]string{}
+	for _, c := range criteria {
+		ids[c.ID], ids[c.Name] = c.ID, c.ID
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	for i := range s.Scores {
+		sc := &s.Scores[i]
+		id, ok := ids[strings.TrimSpace(sc.Criterion)]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("未知的评审标准 %q", sc.Criterion))
+			continue
+		case seen[id]:
+			errs = append(errs, fmt.Errorf("评审标准 %s 重复评分", id))
+		case sc.Score < 0 || sc.Score > MaxScore:
+			errs = append(errs, fmt.Errorf("评审标准 %s 的分数 %d 不在 0～%d 之间", id, sc.Score, MaxScore))
+		}
+		sc.Criterion, seen[id] = id, true
+	}
+	for _, c := range criteria {
+		if !seen[c.ID] {
+			errs = append(errs, fmt.Errorf("缺少评审标准 %s（%s）的评分", c.ID, c.Name))
+		}
+	}
+
+	withIssue := map[string]bool{}
+	for i := range s.Issues {
+		is := &s.Issues[i]
+		id, ok := ids[strings.TrimSpace(is.Criterion)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("第 %d 个问题的评审标准 %q 不存在", i+1, is.Criterion))
+			continue
+		}
+		is.Criterion, withIssue[id] = id, true
+		if strings.TrimSpace(is.Anchor) == "" || strings.TrimSpace(is.Problem) == "" || strings.TrimSpace(is.Suggestion) == "" {
+			errs = append(errs, fmt.Errorf("第 %d 个问题缺少 anchor、problem 或 suggestion", i+1))
+		}
+	}
+	for _, sc := range s.Scores {
+		if sc.Score < minScore && !withIssue[sc.Criterion] {
+			errs = append(errs, fmt.Errorf("评审标准 %s 只有 %d 分，需要至少给出一条问题和修改建议", sc.Criterion, sc.Score))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Evaluate 按阈值判定评审结果：必须满足的标准每项不低于 min_score 且加权平均分不低于 pass_score 才算通过。
+// 建议满足的标准低于 min_score 时只记录在 Weak 中
+func Evaluate(s Submission, criteria []Criterion, th config.ReviewConfig, iteration int) *Result {
+	r := &Result{Submission: s, Iteration: iteration, Time: time.Now()}
+	byID := map[string]Criterion{}
+	for _, c := range criteria {
+		byID[c.ID] = c
+	}
+	var total, weights float64
+	for _, sc := range s.Scores {
+		c, ok := byID[sc.Criterion]
+		if !ok {
+			c = Criterion{ID: sc.Criterion, Level: LevelMust}
+		}
+		if sc.Score < th.MinScore {
+			if c.Must() {
+				r.Failed = append(r.Failed, sc.Criterion)
+			} else {
+				r.Weak = append(r.Weak, sc.Criterion)
+			}
+		}
+		w := c.Weight
+		if w == 0 {
+			w = 1
+		}
+		total += w * float64(sc.Score)
+		weights += w
+	}
+	if weights > 0 {
+		r.Average = total / weights
+	}
+	r.Passed = len(s.Scores) > 0 && len(r.Failed) == 0 && r.Average >= th.PassScore
+	return r
+}
+
+// Feedback 把评审结果格式化为给 SummaryAgent 的反馈，按评审标准列出分数和问题
+func (r *Result) Feedback(criteria []Criterion) string {
+	var b strings.Builder
+	status := "未通过"
+	if r.Passed {
+		status = "通过"
+	}
+	fmt.Fprintf(&b, "第 %d 轮评审%s，加权平均分 %.1f\n\n", r.Iteration, status, r.Average)
+	names := map[string]string{}
+	for _, c := range criteria {
+		names[c.ID] = c.Name
+	}
+	for _, sc := range r.Scores {
+		mark := "✓"
+		switch {
+		case slices.Contains(r.Failed, sc.Criterion):
+			mark = "✗"
+		case slices.Contains(r.Weak, sc.Criterion):
+			mark = "△"
+		}
+		fmt.Fprintf(&b, "- %s 【%s】%d/%d", mark, names[sc.Criterion], sc.Score, MaxScore)
+		if sc.Comment != "" {
+			fmt.Fprintf(&b, "：%s", sc.Comment)
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Weak) > 0 {
+		b.WriteString("（△ 表示建议满足的标准低于及格分，不影响是否通过，但最好一并改进）\n")
+	}
+	if len(r.Issues) > 0 {
+		b.WriteString("\n【需要修改的问题】\n")
+		for i, is := range r.Issues {
+			fmt.Fprintf(&b, "%d. 【%s】%s\n   - 问题：%s\n   - 建议：%s\n", i+1, names[is.Criterion], is.Anchor, is.Problem, is.Suggestion)
+		}
+	}
+	if r.Summary != "" {
+		fmt.Fprintf(&b, "\n总结：%s", r.Summary)
+	}
+	return strings.TrimSpace(b.String())
+}
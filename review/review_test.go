@@ -0,0 +1,179 @@
+package review
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"eino_test/config"
+)
+
+// fullSubmission 每个标准都打 score 分
+func fullSubmission(score int) Submission {
+	var s Submission
+	for _, c := range DefaultCriteria {
+		s.Scores = append(s.Scores, Score{Criterion: c.ID, Score: score})
+	}
+	return s
+}
+
+// TestValidate 测试评分的完整性校验和标准名称到 id 的转换
+func TestValidate(t *testing.T) {
+	s := fullSubmission(9)
+	s.Scores[0].Criterion = "语言易懂性"
+	if err := s.Validate(DefaultCriteria, 6); err != nil {
+		t.Fatalf("合法的评审结果不应该报错: %v", err)
+	}
+	if s.Scores[0].Criterion != "readability" {
+		t.Errorf("标准名称应该转换为 id: %s", s.Scores[0].Criterion)
+	}
+
+	s = fullSubmission(9)
+	s.Scores = append(s.Scores[:1], s.Scores[2:]...)
+	s.Scores = append(s.Scores, Score{Criterion: "readability", Score: 11}, Score{Criterion: "security", Score: 5})
+	s.Scores[3].Score = 3
+	err := s.Validate(DefaultCriteria, 6)
+	if err == nil {
+		t.Fatal("不合法的评审结果应该报错")
+	}
+	for _, want := range []string{"缺少评审标准 detail", "readability 重复评分", "未知的评审标准 \"security\"", "comparison 只有 3 分"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息缺少 %q:\n%v", want, err)
+		}
+	}
+
+	s = fullSubmission(9)
+	s.Scores[5].Score = 4
+	s.Issues = []Issue{{Criterion: "图表辅助", Anchor: "## 2. 架构", Problem: "缺少架构图"}}
+	if err := s.Validate(DefaultCriteria, 6); err == nil || !strings.Contains(err.Error(), "缺少 anchor、problem 或 suggestion") {
+		t.Errorf("问题缺少修改建议时应该报错: %v", err)
+	}
+	s.Issues[0].Suggestion = "补充一张流程图"
+	if err := s.Validate(DefaultCriteria, 6); err != nil || s.Issues[0].Criterion != "diagrams" {
+		t.Errorf("低分标准带有问题时应该通过校验: %v", err)
+	}
+}
+
+// TestEvaluate 测试按平均分和单项最低分判定是否通过
+func TestEvaluate(t *testing.T) {
+	th := config.ReviewConfig{PassScore: 8, MinScore: 6}
+
+	r := Evaluate(fullSubmission(8), DefaultCriteria, th, 1)
+	if !r.Passed || r.Average != 8 || len(r.Failed) != 0 {
+		t.Errorf("全部 8 分应该通过: %+v", r)
+	}
+
+	s := fullSubmission(10)
+	s.Scores[2].Score = 5
+	if r := Evaluate(s, DefaultCriteria, th, 2); r.Passed || r.Average < 9 || strings.Join(r.Failed, ",") != "examples" {
+		t.Errorf("有一项低于最低分时不应该通过: %+v", r)
+	}
+	if r := Evaluate(fullSubmission(7), DefaultCriteria, th, 3); r.Passed || len(r.Failed) != 0 {
+		t.Errorf("平均分不够时不应该通过: %+v", r)
+	}
+	if r := Evaluate(Submission{}, DefaultCriteria, th, 1); r.Passed {
+		t.Error("没有评分时不应该通过")
+	}
+
+	// 建议满足的标准低于最低分时不阻止通过，加权平均分按权重计算
+	criteria := []Criterion{
+		{ID: "accuracy", Name: "接口准确性", Level: LevelMust, Weight: 3},
+		{ID: "diagrams", Name: "图表辅助", Level: LevelShould, Weight: 1},
+	}
+	weighted := Submission{Scores: []Score{{Criterion: "accuracy", Score: 10}, {Criterion: "diagrams", Score: 2}}}
+	if r := Evaluate(weighted, criteria, th, 1); !r.Passed || r.Average != 8 || len(r.Failed) != 0 || strings.Join(r.Weak, ",") != "diagrams" {
+		t.Errorf("建议满足的标准不应该阻止通过: %+v", r)
+	}
+	weighted.Scores[0].Score = 5
+	if r := Evaluate(weighted, criteria, th, 1); r.Passed || strings.Join(r.Failed, ",") != "accuracy" {
+		t.Errorf("必须满足的标准低于最低分时不应该通过: %+v", r)
+	}
+
+	s.Issues = []Issue{{Criterion: "examples", Anchor: "## 3. 消费者", Problem: "示例不能运行", Suggestion: "补全 import"}}
+	feedback := Evaluate(s, DefaultCriteria, th, 2).Feedback(DefaultCriteria)
+	for _, want := range []string{"第 2 轮评审未通过", "✗ 【举例和代码示例】5/10", "✓ 【语言易懂性】10/10", "【举例和代码示例】## 3. 消费者"} {
+		if !strings.Contains(feedback, want) {
+			t.Errorf("反馈缺少 %q:\n%s", want, feedback)
+		}
+	}
+}
+
+// TestRubric 测试内置评审标准和自定义评审标准文件的加载与校验
+func TestRubric(t *testing.T) {
+	def := DefaultRubric()
+	if def.Name != "default" || len(def.Criteria) != 10 || def.Criteria[9].ID != "markdown" {
+		t.Fatalf("内置评审标准不符合预期: %+v", def)
+	}
+	for _, c := range def.Criteria {
+		if !c.Must() || c.Weight != 1 || c.Description == "" || len(c.Guidelines) == 0 || len(c.Checklist) == 0 {
+			t.Errorf("内置评审标准 %s 不完整: %+v", c.ID, c)
+		}
+	}
+	if r, err := LoadRubric(""); err != nil || len(r.Criteria) != len(def.Criteria) {
+		t.Errorf("路径为空时应该使用内置标准: %v", err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "infra.yaml")
+	os.WriteFile(path, []byte(`name: infra
+criteria:
+  - id: security
+    name: 安全考量
+    description: 说明权限、密钥和网络暴露面
+    checklist: [是否说明了需要的最小权限？]
+  - id: diagrams
+    name: 图表辅助
+    level: should
+    weight: 0.5
+    checklist: [部署拓扑是否有图？]
+`), 0644)
+	r, err := LoadRubric(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "infra" || !r.Criteria[0].Must() || r.Criteria[0].Weight != 1 || r.Criteria[1].Must() || r.Criteria[1].Weight != 0.5 {
+		t.Errorf("自定义评审标准解析结果不对: %+v", r)
+	}
+
+	for name, content := range map[string]string{
+		"empty.yaml":   "name: empty\ncriteria: []\n",
+		"dup.yaml":     "criteria:\n  - {id: a, name: A, checklist: [x]}\n  - {id: a, name: B, checklist: [y]}\n",
+		"level.yaml":   "criteria:\n  - {id: a, name: A, level: may, checklist: [x]}\n",
+		"weight.yaml":  "criteria:\n  - {id: a, name: A, weight: -1, checklist: [x]}\n",
+		"unknown.yaml": "criteria:\n  - {id: a, name: A, checklist: [x], score: 3}\n",
+		"check.yaml":   "criteria:\n  - {id: a, name: A}\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := LoadRubric(path); err == nil {
+			t.Errorf("%s 应该加载失败", name)
+		}
+	}
+	if _, err := LoadRubric(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("文件不存在时应该报错")
+	}
+}
+
+// TestAppend 测试评分按行追加并能读回
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores", "review_scores.jsonl")
+	th := config.ReviewConfig{PassScore: 8, MinScore: 6}
+	for i, score := range []int{6, 9} {
+		if err := Append(path, Record{Source: "docs/kafka.md", Result: Evaluate(fullSubmission(score), DefaultCriteria, th, i+1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Append("", Record{}); err != nil {
+		t.Errorf("路径为空时不应该报错: %v", err)
+	}
+
+	records, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Source != "docs/kafka.md" || records[0].Passed || !records[1].Passed ||
+		records[1].Iteration != 2 || len(records[1].Scores) != len(DefaultCriteria) {
+		t.Errorf("读回的评分记录不符合预期: %+v", records)
+	}
+}
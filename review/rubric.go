@@ -0,0 +1,144 @@
+package review
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 评审标准的级别
+const (
+	// LevelMust 必须满足，低于单项及格分时评审不能通过
+	LevelMust = "must"
+	// LevelShould 建议满足，只计入加权平均分
+	LevelShould = "should"
+)
+
+//go:embed rubric.yaml
+var builtinRubric []byte
+
+// Criterion 一个评审标准。ReviewerAgent 的评审清单和 SummaryAgent 的改写原则
+// 都从同一份定义生成，修改标准时两边不会出现不一致
+type Criterion struct {
+	// ID 评审结果中使用的标识
+	ID string `yaml:"id" json:"id"`
+	// Name 指令中展示的名称
+	Name string `yaml:"name" json:"name"`
+	// Description 一句话说明这个标准要求什么
+	Description string `yaml:"description" json:"description,omitempty"`
+	// Level must 或 should，为空时按 must 处理
+	Level string `yaml:"level" json:"level,omitempty"`
+	// Weight 计算加权平均分时的权重，为 0 时按 1 处理
+	Weight float64 `yaml:"weight" json:"weight,omitempty"`
+	// Guidelines 改写时要遵守的具体做法，写入 SummaryAgent 的改写原则
+	Guidelines []string `yaml:"guidelines" json:"guidelines,omitempty"`
+	// Checklist 评审时逐条检查的问题，写入 ReviewerAgent 的评审标准
+	Checklist []string `yaml:"checklist" json:"checklist,omitempty"`
+}
+
+// Must 是否为必须满足的标准
+func (c Criterion) Must() bool {
+	return c.Level != LevelShould
+}
+
+// LevelLabel 指令中展示的级别说明
+func (c Criterion) LevelLabel() string {
+	if c.Must() {
+		return "必须满足"
+	}
+	return "建议满足"
+}
+
+// Rubric 一组评审标准
+type Rubric struct {
+	// Name 标准集名称，记录在评分结果中
+	Name     string      `yaml:"name" json:"name"`
+	Criteria []Criterion `yaml:"criteria" json:"criteria"`
+}
+
+// DefaultRubric 返回内置的 10 项评审标准
+func DefaultRubric() *Rubric {
+	r, err := ParseRubric(builtinRubric)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// DefaultCriteria 内置的评审标准
+var DefaultCriteria = DefaultRubric().Criteria
+
+// LoadRubric 读取评审标准文件，path 为空时返回内置标准
+func LoadRubric(path string) (*Rubric, error) {
+	if path == "" {
+		return DefaultRubric(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取评审标准文件失败: %w", err)
+	}
+	r, err := ParseRubric(data)
+	if err != nil {
+		return nil, fmt.Errorf("评审标准文件 %s 有误: %w", path, err)
+	}
+	return r, nil
+}
+
+// ParseRubric 解析评审标准，补齐默认的级别和权重并校验
+func ParseRubric(data []byte) (*Rubric, error) {
+	r := &Rubric{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(r); err != nil {
+		return nil, fmt.Errorf("解析评审标准失败: %v", err)
+	}
+	for i := range r.Criteria {
+		c := &r.Criteria[i]
+		if c.Level == "" {
+			c.Level = LevelMust
+		}
+		if c.Weight == 0 {
+			c.Weight = 1
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Validate 校验评审标准：至少一项，id 和名称不为空且不重复，级别和权重合法
+func (r *Rubric) Validate() error {
+	if len(r.Criteria) == 0 {
+		return errors.New("评审标准为空")
+	}
+	var errs []error
+	seen := map[string]bool{}
+	for i, c := range r.Criteria {
+		if strings.TrimSpace(c.ID) == "" || strings.TrimSpace(c.Name) == "" {
+			errs = append(errs, fmt.Errorf("第 %d 个评审标准缺少 id 或 name", i+1))
+			continue
+		}
+		for _, key := range []string{c.ID, c.Name} {
+			if seen[key] {
+				errs = append(errs, fmt.Errorf("评审标准 %q 重复", key))
+			}
+			seen[key] = true
+		}
+		if c.Level != LevelMust && c.Level != LevelShould {
+			errs = append(errs, fmt.Errorf("评审标准 %s 的 level 只能是 %s 或 %s，当前为 %q", c.ID, LevelMust, LevelShould, c.Level))
+		}
+		if c.Weight < 0 {
+			errs = append(errs, fmt.Errorf("评审标准 %s 的 weight 不能为负数", c.ID))
+		}
+		if len(c.Checklist) == 0 {
+			errs = append(errs, fmt.Errorf("评审标准 %s 缺少 checklist", c.ID))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,63 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// appendMu 批量改写时多个任务会写同一个文件，串行写入保证每行完整
+var appendMu sync.Mutex
+
+// Record 评分文件中的一行，记录哪个源文件在第几轮得到了什么评分
+type Record struct {
+	Source string `json:"source,omitempty"`
+	*Result
+}
+
+// Append 以 JSON Lines 格式把评审记录追加到 path，path 为空时不记录
+func Append(path string, rec Record) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	appendMu.Lock()
+	defer appendMu.Unlock()
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建评分文件目录失败: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开评分文件失败: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Load 读取评分文件中的所有记录
+func Load(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []Record
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			return nil, fmt.Errorf("解析评分文件 %s 失败: %w", path, err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
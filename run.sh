@@ -30,10 +30,13 @@ if ! grep -q "DASHSCOPE_API_KEY" .env; then
     exit 1
 fi
 
-# 运行程序
-echo "📝 执行程序..."
+# 运行程序（未指定参数时默认改写 ./text.md）
+if [ $# -eq 0 ]; then
+    set -- rewrite ./text.md
+fi
+echo "📝 执行程序: ./eino_demo $*"
 echo "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"
-./eino_demo
+./eino_demo "$@"
 echo "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"
 echo ""
 echo "✅ 程序执行完成！"
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"time"
+
+	myagent "eino_test/agent"
+
+	"github.com/cloudwego/eino/adk"
+)
+
+// ToolCall 事件中的工具调用
+type ToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Event adk.AgentEvent 的可序列化形式，字段与 utils.Event 打印的内容一致。
+// 改写-评审循环中事件的 Agent 都是循环的名称，Step 不为空时表示循环中的这个 Agent 完成了一步
+type Event struct {
+	Seq        int        `json:"seq"`
+	Time       time.Time  `json:"time"`
+	Agent      string     `json:"agent"`
+	RunPath    []string   `json:"run_path,omitempty"`
+	Role       string     `json:"role,omitempty"`
+	Content    string     `json:"content,omitempty"`
+	ToolName   string     `json:"tool_name,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	TransferTo string     `json:"transfer_to,omitempty"`
+	BreakLoop  bool       `json:"break_loop,omitempty"`
+	Exit       bool       `json:"exit,omitempty"`
+	Step       string     `json:"step,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// newEvent 将 adk.AgentEvent 转换为 Event。
+// 服务端以非流式模式运行 Agent，MessageOutput 中只会有完整的 Message
+func newEvent(e *adk.AgentEvent) Event {
+	out := Event{Time: time.Now(), Agent: e.AgentName}
+	for _, step := range e.RunPath {
+		out.RunPath = append(out.RunPath, step.String())
+	}
+
+	if e.Output != nil && e.Output.MessageOutput != nil {
+		mo := e.Output.MessageOutput
+		out.Role = string(mo.Role)
+		out.ToolName = mo.ToolName
+		if m := mo.Message; m != nil {
+			out.Content = m.Content
+			for _, tc := range m.ToolCalls {
+				out.ToolCalls = append(out.ToolCalls, ToolCall{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				})
+			}
+		}
+	}
+
+	if e.Action != nil {
+		if e.Action.TransferToAgent != nil {
+			out.TransferTo = e.Action.TransferToAgent.DestAgentName
+		}
+		out.BreakLoop = e.Action.BreakLoop != nil
+		out.Exit = e.Action.Exit
+		if step, ok := e.Action.CustomizedAction.(*myagent.StepAction); ok {
+			out.Step = step.Agent
+		}
+	}
+	if e.Err != nil {
+		out.Error = e.Err.Error()
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	myagent "eino_test/agent"
+	"eino_test/review"
+
+	"github.com/cloudwego/eino/adk"
+)
+
+// JobStatus 改写任务的状态
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// finished 任务是否已经结束
+func (s JobStatus) finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCanceled
+}
+
+// Review ReviewerAgent 一轮评审的回复，包括评审前的自动检查报告
+type Review struct {
+	Iteration int       `json:"iteration"`
+	Content   string    `json:"content"`
+	Time      time.Time `json:"time"`
+}
+
+// JobInfo 任务状态的快照
+type JobInfo struct {
+	ID         string    `json:"id"`
+	Status     JobStatus `json:"status"`
+	Source     string    `json:"source"`
+	Iterations int       `json:"iterations"`
+	Approved   bool      `json:"approved"`
+	Output     string    `json:"output,omitempty"`
+	// Scores 每一轮评审的结构化评分
+	Scores []*review.Result `json:"scores,omitempty"`
+	// Prompts 任务使用的提示词模板版本
+	Prompts    []string   `json:"prompts,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Events     int        `json:"events"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Job 一个改写任务，记录全部事件以便 SSE 客户端中途接入时回放
+type Job struct {
+	id        string
+	source    string
+	outputDir string
+	config    *myagent.Config
+	createdAt time.Time
+	cancel    context.CancelFunc
+
+	mu         sync.Mutex
+	status     JobStatus
+	progress   myagent.RewriteResult
+	events     []Event
+	reviews    []Review
+	err        string
+	finishedAt time.Time
+	// notify 在每次有新事件或状态变化时关闭并替换，用于唤醒等待中的 SSE 连接
+	notify chan struct{}
+}
+
+func newJob(id, source, outputDir string, cfg *myagent.Config, cancel context.CancelFunc) *Job {
+	return &Job{
+		id:        id,
+		source:    source,
+		outputDir: outputDir,
+		config:    cfg,
+		createdAt: time.Now(),
+		cancel:    cancel,
+		status:    JobQueued,
+		notify:    make(chan struct{}),
+	}
+}
+
+// broadcastLocked 唤醒所有等待中的订阅者，调用方需持有 mu
+func (j *Job) broadcastLocked() {
+	close(j.notify)
+	j.notify = make(chan struct{})
+}
+
+// setRunning 将任务标记为运行中，任务已被取消时返回 false
+func (j *Job) setRunning() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status != JobQueued {
+		return false
+	}
+	j.status = JobRunning
+	j.broadcastLocked()
+	return true
+}
+
+// record 记录一个 Agent 事件
+func (j *Job) record(e *adk.AgentEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.progress.Observe(e)
+	ev := newEvent(e)
+	ev.Seq = len(j.events) + 1
+	j.events = append(j.events, ev)
+
+	// 循环中事件的 AgentName 都是循环的名称，评审意见从 ReviewerAgent 完成一步时的 StepAction 中读取
+	if e.Action != nil {
+		if step, ok := e.Action.CustomizedAction.(*myagent.StepAction); ok && step.Agent == myagent.ReviewerName && step.Feedback != "" {
+			j.reviews = append(j.reviews, Review{
+				Iteration: j.progress.Iterations,
+				Content:   step.Feedback,
+				Time:      ev.Time,
+			})
+		}
+	}
+	j.broadcastLocked()
+}
+
+// finish 记录任务的最终状态
+func (j *Job) finish(status JobStatus, result *myagent.RewriteResult, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.finished() {
+		return
+	}
+	j.status = status
+	if result != nil {
+		j.progress = *result
+	}
+	if err != nil {
+		j.err = err.Error()
+	}
+	j.finishedAt = time.Now()
+	j.broadcastLocked()
+}
+
+// Info 返回任务状态的快照
+func (j *Job) Info() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := JobInfo{
+		ID:         j.id,
+		Status:     j.status,
+		Source:     j.source,
+		Iterations: j.progress.Iterations,
+		Approved:   j.progress.Approved,
+		Output:     j.progress.OutputPath,
+		Scores:     append([]*review.Result(nil), j.progress.Reviews...),
+		Error:      j.err,
+		Events:     len(j.events),
+		CreatedAt:  j.createdAt,
+	}
+	if j.config.Prompts != nil {
+		info.Prompts = j.config.Prompts.Refs()
+	}
+	if !j.finishedAt.IsZero() {
+		t := j.finishedAt
+		info.FinishedAt = &t
+	}
+	return info
+}
+
+// Reviews 返回评审历史的副本
+func (j *Job) Reviews() []Review {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Review(nil), j.reviews...)
+}
+
+// eventsSince 返回序号大于 after 的事件，以及用于等待后续事件的通道和任务是否已结束
+func (j *Job) eventsSince(after int) ([]Event, <-chan struct{}, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var out []Event
+	if after < len(j.events) {
+		out = append(out, j.events[after:]...)
+	}
+	return out, j.notify, j.status.finished()
+}
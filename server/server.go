@@ -0,0 +1,317 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	myagent "eino_test/agent"
+	"eino_test/persona"
+
+	"github.com/cloudwego/eino/adk"
+)
+
+// RunFunc 执行一次改写流程，默认为 agent.RunRewrite，测试中可以替换为假实现
+type RunFunc func(ctx context.Context, cfg *myagent.Config, documentPath string, onEvent func(*adk.AgentEvent)) (*myagent.RewriteResult, error)
+
+// Options 服务端参数
+type Options struct {
+	// WorkDir 每个任务会在其下创建 <job id>/ 目录保存上传的原文和改写结果
+	WorkDir string
+	// MaxConcurrent 同时运行的任务数
+	MaxConcurrent int
+	// Config 任务未指定时使用的 Agent 配置
+	Config *myagent.Config
+	// Run 为空时使用 agent.RunRewrite
+	Run RunFunc
+}
+
+// Server 改写任务的 HTTP 服务
+type Server struct {
+	opts Options
+	sem  chan struct{}
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// New 创建 HTTP 服务
+func New(opts Options) *Server {
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = 1
+	}
+	if opts.Config == nil {
+		opts.Config = myagent.DefaultConfig()
+	}
+	if opts.Run == nil {
+		opts.Run = myagent.RunRewrite
+	}
+	return &Server{
+		opts: opts,
+		sem:  make(chan struct{}, opts.MaxConcurrent),
+		jobs: map[string]*Job{},
+	}
+}
+
+// Handler 返回注册了全部路由的 http.Handler
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleCreate)
+	mux.HandleFunc("GET /jobs", s.handleList)
+	mux.HandleFunc("GET /jobs/{id}", s.handleGet)
+	mux.HandleFunc("GET /jobs/{id}/events", s.handleEvents)
+	mux.HandleFunc("GET /jobs/{id}/document", s.handleDocument)
+	mux.HandleFunc("GET /jobs/{id}/reviews", s.handleReviews)
+	mux.HandleFunc("POST /jobs/{id}/cancel", s.handleCancel)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleCancel)
+	return mux
+}
+
+// CreateJobRequest 创建任务的请求体
+type CreateJobRequest struct {
+	// Content 待改写的 Markdown 原文
+	Content string `json:"content"`
+	// Filename 原文文件名，用于提示模型生成输出文件名
+	Filename string `json:"filename"`
+	// Persona 读者画像名称，或 YAML 格式的画像内容
+	Persona       string `json:"persona"`
+	Model         string `json:"model"`
+	MaxIterations int    `json:"max_iterations"`
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req CreateJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("请求体不是合法的 JSON: %w", err))
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("content 不能为空"))
+		return
+	}
+	filename := filepath.Base(req.Filename)
+	if filename == "." || filename == "/" || filename == "" {
+		filename = "document.md"
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	jobDir := filepath.Join(s.opts.WorkDir, id)
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("创建任务目录失败: %w", err))
+		return
+	}
+	source := filepath.Join(jobDir, filename)
+	if err := os.WriteFile(source, []byte(req.Content), 0644); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("保存原文失败: %w", err))
+		return
+	}
+
+	cfg := *s.opts.Config
+	cfg.OutputDir = filepath.Join(jobDir, "output")
+	if req.Persona != "" {
+		p, err := persona.Lookup(req.Persona, cfg.PersonasDir)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		cfg.Persona = p
+	}
+	if req.Model != "" {
+		cfg.Summary.Model = req.Model
+		cfg.Reviewer.Model = req.Model
+	}
+	if req.MaxIterations > 0 {
+		cfg.MaxIterations = req.MaxIterations
+	}
+
+	// 任务的生命周期不跟随创建请求，使用独立的 context
+	ctx, cancel := context.WithCancel(context.Background())
+	job := newJob(id, source, cfg.OutputDir, &cfg, cancel)
+
+	s.mu.Lock()
+	s.jobs[id] = job
+	s.mu.Unlock()
+
+	go s.execute(ctx, job)
+
+	writeJSON(w, http.StatusAccepted, job.Info())
+}
+
+// execute 等待并发名额后运行任务
+func (s *Server) execute(ctx context.Context, job *Job) {
+	defer job.cancel()
+
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	case <-ctx.Done():
+		job.finish(JobCanceled, nil, ctx.Err())
+		return
+	}
+	if !job.setRunning() {
+		return
+	}
+
+	log.Printf("任务 %s 开始: %s", job.id, job.source)
+	result, err := s.opts.Run(ctx, job.config, job.source, job.record)
+	switch {
+	case ctx.Err() != nil:
+		job.finish(JobCanceled, result, ctx.Err())
+	case err != nil:
+		job.finish(JobFailed, result, err)
+	default:
+		job.finish(JobSucceeded, result, nil)
+	}
+	log.Printf("任务 %s 结束: %s", job.id, job.Info().Status)
+}
+
+func (s *Server) job(w http.ResponseWriter, r *http.Request) *Job {
+	s.mu.Lock()
+	job, ok := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("任务 %s 不存在", r.PathValue("id")))
+		return nil
+	}
+	return job
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		infos = append(infos, job.Info())
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	if job := s.job(w, r); job != nil {
+		writeJSON(w, http.StatusOK, job.Info())
+	}
+}
+
+func (s *Server) handleReviews(w http.ResponseWriter, r *http.Request) {
+	if job := s.job(w, r); job != nil {
+		writeJSON(w, http.StatusOK, job.Reviews())
+	}
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	job := s.job(w, r)
+	if job == nil {
+		return
+	}
+	job.cancel()
+	// 排队中的任务不会再进入 execute 的运行分支，直接标记为已取消
+	job.mu.Lock()
+	queued := job.status == JobQueued
+	job.mu.Unlock()
+	if queued {
+		job.finish(JobCanceled, nil, context.Canceled)
+	}
+	writeJSON(w, http.StatusOK, job.Info())
+}
+
+// handleDocument 返回最终保存的改写文档
+func (s *Server) handleDocument(w http.ResponseWriter, r *http.Request) {
+	job := s.job(w, r)
+	if job == nil {
+		return
+	}
+	info := job.Info()
+	if info.Output == "" {
+		writeError(w, http.StatusConflict, fmt.Errorf("任务 %s 还没有保存改写文档（状态: %s）", info.ID, info.Status))
+		return
+	}
+	content, err := os.ReadFile(info.Output)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("读取改写文档失败: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filepath.Base(info.Output)))
+	_, _ = w.Write(content)
+}
+
+// handleEvents 以 Server-Sent Events 推送任务事件。
+// 先回放已有事件再推送新事件，支持通过 Last-Event-ID 断线续传，任务结束时发送 done 事件
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	job := s.job(w, r)
+	if job == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("当前连接不支持流式输出"))
+		return
+	}
+
+	after := 0
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		if n, err := strconv.Atoi(last); err == nil && n > 0 {
+			after = n
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		events, notify, finished := job.eventsSince(after)
+		for _, ev := range events {
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "id: %d\nevent: agent_event\ndata: %s\n\n", ev.Seq, data)
+			after = ev.Seq
+		}
+		if finished {
+			data, _ := json.Marshal(job.Info())
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-notify:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成任务 ID 失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	myagent "eino_test/agent"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeRun 模拟一次两轮的改写流程：第一轮评审不通过，第二轮通过并保存。
+// 与真实的循环一样，事件的 AgentName 都是循环的名称，每一步先发出回复，再发出 StepAction
+func fakeRun(ctx context.Context, cfg *myagent.Config, documentPath string, onEvent func(*adk.AgentEvent)) (*myagent.RewriteResult, error) {
+	result := &myagent.RewriteResult{}
+	emit := func(agentName, content string) {
+		e := adk.EventFromMessage(schema.AssistantMessage(content, nil), nil, schema.Assistant, "")
+		step := &myagent.StepAction{Agent: agentName, Feedback: content}
+		if agentName == myagent.RewriterName {
+			step.Draft, step.Feedback = content, ""
+		}
+		for _, e := range []*adk.AgentEvent{e, {Action: &adk.AgentAction{CustomizedAction: step}}} {
+			e.AgentName = "文档改写Agent"
+			result.Observe(e)
+			onEvent(e)
+		}
+	}
+
+	emit(myagent.RewriterName, "# 草稿 1")
+	emit(myagent.ReviewerName, "【图表辅助】缺少 Mermaid 图")
+	emit(myagent.RewriterName, "# 草稿 2")
+	emit(myagent.ReviewerName, "文档满意")
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return nil, err
+	}
+	result.OutputPath = filepath.Join(cfg.OutputDir, "改写文档.md")
+	result.Approved = true
+	return result, os.WriteFile(result.OutputPath, []byte("# 草稿 2"), 0644)
+}
+
+// blockingRun 一直阻塞到任务被取消
+func blockingRun(ctx context.Context, cfg *myagent.Config, documentPath string, onEvent func(*adk.AgentEvent)) (*myagent.RewriteResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func createJob(t *testing.T, ts *httptest.Server) JobInfo {
+	t.Helper()
+	body := `{"content": "# 原文", "filename": "kafka.md", "max_iterations": 3}`
+	resp, err := http.Post(ts.URL+"/jobs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("创建任务返回 %d", resp.StatusCode)
+	}
+	var info JobInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+// TestJobLifecycle 测试创建任务、SSE 事件流、获取文档和评审历史
+func TestJobLifecycle(t *testing.T) {
+	srv := New(Options{WorkDir: t.TempDir(), Run: fakeRun})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	info := createJob(t, ts)
+
+	resp, err := http.Get(ts.URL + "/jobs/" + info.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %s", ct)
+	}
+
+	var agentEvents int
+	var done bool
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "event: agent_event":
+			agentEvents++
+		case "event: done":
+			done = true
+		}
+	}
+	if agentEvents != 8 || !done {
+		t.Fatalf("收到 %d 个 agent_event，done=%v", agentEvents, done)
+	}
+
+	resp, err = http.Get(ts.URL + "/jobs/" + info.ID + "/document")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(doc) != "# 草稿 2" {
+		t.Fatalf("获取文档失败: %d %s", resp.StatusCode, doc)
+	}
+
+	resp, err = http.Get(ts.URL + "/jobs/" + info.ID + "/reviews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reviews []Review
+	_ = json.NewDecoder(resp.Body).Decode(&reviews)
+	resp.Body.Close()
+	if len(reviews) != 2 || reviews[0].Iteration != 1 || reviews[1].Iteration != 2 || reviews[1].Content != "文档满意" {
+		t.Fatalf("评审历史不符合预期: %+v", reviews)
+	}
+
+	// 断线续传：Last-Event-ID 之后只剩 1 个事件
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/jobs/"+info.ID+"/events", nil)
+	req.Header.Set("Last-Event-ID", "7")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if n := strings.Count(string(data), "event: agent_event"); n != 1 {
+		t.Fatalf("续传应只收到 1 个事件，实际 %d", n)
+	}
+}
+
+// TestCancelJob 测试取消运行中的任务
+func TestCancelJob(t *testing.T) {
+	srv := New(Options{WorkDir: t.TempDir(), Run: blockingRun})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	info := createJob(t, ts)
+
+	resp, err := http.Post(ts.URL+"/jobs/"+info.ID+"/cancel", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if srv.jobs[info.ID].Info().Status == JobCanceled {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("任务没有被取消: %+v", srv.jobs[info.ID].Info())
+}
@@ -2,95 +2,145 @@ package tools
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"path/filepath"
+	"strings"
 	"testing"
 
-	lark "github.com/larksuite/oapi-sdk-go/v3"
-	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
-	larkdocx "github.com/larksuite/oapi-sdk-go/v3/service/docx/v1"
-)
+	"eino_test/config"
+	"eino_test/feishu"
+	"eino_test/feishu/feishutest"
 
-// 飞书应用配置
-const (
-	LARK_APP_ID     = "cli_a7a0822d4f71100d"
-	LARK_APP_SECRET = "UerLooRg7uvYVTYO1eoUufr65EOTHuBd"
-	FOLDER_TOKEN    = "HQjyfawL2lpgq9diKYwcTLl5nmY"                    // 飞书文件夹 token
-	USER_TOKEN      = "u-dbl.CVTu50WGLmzfWkcFeh4l5shRlgMPOaayFxw00JUy" // 用户 token
+	"github.com/cloudwego/eino/components/tool"
 )
 
-// TestCreateFeishuDocument 测试创建飞书文档
-func TestCreateFeishuDocument(t *testing.T) {
-	// 创建 Client
-	client := lark.NewClient(LARK_APP_ID, LARK_APP_SECRET)
+// saveToFeishu 调用 save_to_feishu 保存 content，测试中没有 session，通过 ctx 传入内容
+func saveToFeishu(t *testing.T, cfg config.FeishuConfig, source, content string, input SaveToFeishuInput) (string, error) {
+	t.Helper()
+	bt, err := NewSaveToFeishuTool(cfg, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, _ := json.Marshal(input)
+	return bt.(tool.InvokableTool).InvokableRun(WithDocumentContent(context.Background(), content), string(args))
+}
 
-	// 创建请求对象
-	req := larkdocx.NewCreateDocumentReqBuilder().
-		Body(larkdocx.NewCreateDocumentReqBodyBuilder().
-			FolderToken(FOLDER_TOKEN).
-			Title("测试文档 - 文档改写系统").
-			Build()).
-		Build()
+// TestSaveToFeishuTenantMode 测试使用应用身份创建飞书文档
+func TestSaveToFeishuTenantMode(t *testing.T) {
+	srv := feishutest.NewServer("cli_tenant", "secret")
+	defer srv.Close()
+
+	out, err := saveToFeishu(t, config.FeishuConfig{
+		AppID:       "cli_tenant",
+		AppSecret:   "secret",
+		FolderToken: "fldDefault",
+		AuthMode:    feishu.AuthModeTenant,
+		BaseURL:     srv.URL,
+	}, "", "# 标题", SaveToFeishuInput{Title: "测试文档 - 文档改写系统"})
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
 
-	// 发起请求
-	resp, err := client.Docx.V1.Document.Create(context.Background(), req, larkcore.WithUserAccessToken(USER_TOKEN))
+	docs := srv.Documents()
+	if len(docs) != 1 {
+		t.Fatalf("期望创建 1 个文档，实际 %d", len(docs))
+	}
+	if docs[0].FolderToken != "fldDefault" || docs[0].Token != "t-cli_tenant" {
+		t.Errorf("文档不符合预期: %+v", docs[0])
+	}
+	if !strings.Contains(out, feishu.DocumentURL(docs[0].ID)) {
+		t.Errorf("返回信息中没有文档链接: %s", out)
+	}
+	if blocks := srv.Blocks(docs[0].ID); len(blocks) != 1 || *blocks[0].BlockType != feishu.BlockTypeHeading1 {
+		t.Errorf("正文没有写入文档: %+v", blocks)
+	}
+}
 
-	// 处理错误
-	if err != nil {
-		t.Fatalf("创建文档失败: %v", err)
-		return
+// TestSaveToFeishuUserMode 测试使用本地保存的用户 token 创建飞书文档
+func TestSaveToFeishuUserMode(t *testing.T) {
+	srv := feishutest.NewServer("cli_user", "secret")
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token.json")
+	cfg := config.FeishuConfig{
+		AppID:     "cli_user",
+		AppSecret: "secret",
+		AuthMode:  feishu.AuthModeUser,
+		BaseURL:   srv.URL,
+		TokenFile: tokenFile,
 	}
 
-	// 服务端错误处理
-	if !resp.Success() {
-		t.Fatalf("logId: %s, error response: %s", resp.RequestId(), larkcore.Prettify(resp.CodeError))
-		return
+	// 没有授权时返回明确的错误
+	if _, err := saveToFeishu(t, cfg, "", "# 正文", SaveToFeishuInput{Title: "未授权"}); err == nil || !strings.Contains(err.Error(), "feishu login") {
+		t.Fatalf("期望提示先登录，实际: %v", err)
 	}
 
-	// 业务处理
-	fmt.Println("文档创建成功:")
-	fmt.Println(larkcore.Prettify(resp))
+	client, err := feishu.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.OAuth().Exchange(context.Background(), srv.AuthCode); err != nil {
+		t.Fatalf("授权码换取 token 失败: %v", err)
+	}
 
-	// 获取文档 ID
-	if resp.Data != nil && resp.Data.Document != nil {
-		docID := resp.Data.Document.DocumentId
-		if docID != nil {
-			fmt.Printf("文档 ID: %s\n", *docID)
-		}
+	if _, err := saveToFeishu(t, cfg, "", "# 正文", SaveToFeishuInput{Title: "用户文档", FolderToken: "fldUser"}); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	docs := srv.Documents()
+	if len(docs) != 1 || !strings.HasPrefix(docs[0].Token, "u-") || docs[0].FolderToken != "fldUser" {
+		t.Fatalf("文档不符合预期: %+v", docs)
 	}
 }
 
-// TestWriteToFeishuDocument 测试写入内容到飞书文档
-func TestWriteToFeishuDocument(t *testing.T) {
-	// 创建 Client
-	client := lark.NewClient(LARK_APP_ID, LARK_APP_SECRET)
-
-	// 首先创建一个文档
-	createReq := larkdocx.NewCreateDocumentReqBuilder().
-		Body(larkdocx.NewCreateDocumentReqBodyBuilder().
-			FolderToken(FOLDER_TOKEN).
-			Title("测试写入 - 文档改写系统").
-			Build()).
-		Build()
+// TestSaveToFeishuUpdatesExistingDocument 测试同一源文件再次保存时原地更新文档，文档被删除后重新创建
+func TestSaveToFeishuUpdatesExistingDocument(t *testing.T) {
+	srv := feishutest.NewServer("cli_update", "secret")
+	defer srv.Close()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "kafka.md")
+	cfg := config.FeishuConfig{
+		AppID:     "cli_update",
+		AppSecret: "secret",
+		BaseURL:   srv.URL,
+		DocsFile:  filepath.Join(dir, "feishu_docs.json"),
+	}
 
-	createResp, err := client.Docx.V1.Document.Create(context.Background(), createReq, larkcore.WithUserAccessToken(USER_TOKEN))
+	if _, err := saveToFeishu(t, cfg, source, "# 第一版\n\n旧内容", SaveToFeishuInput{Title: "Kafka"}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := saveToFeishu(t, cfg, source, "# 第二版", SaveToFeishuInput{Title: "Kafka 入门"})
 	if err != nil {
-		t.Fatalf("创建文档失败: %v", err)
-		return
+		t.Fatal(err)
 	}
 
-	if !createResp.Success() {
-		t.Fatalf("创建文档失败: %s", larkcore.Prettify(createResp.CodeError))
-		return
+	docs := srv.Documents()
+	if len(docs) != 1 || !strings.Contains(out, "已更新") {
+		t.Fatalf("再次保存应更新已有文档，实际有 %d 个文档: %s", len(docs), out)
+	}
+	blocks := srv.Blocks(docs[0].ID)
+	if len(blocks) != 1 || *blocks[0].Heading1.Elements[0].TextRun.Content != "第二版" {
+		t.Fatalf("文档正文没有被替换: %+v", blocks)
+	}
+	if docs[0].Title != "Kafka 入门" {
+		t.Errorf("文档标题没有更新: %q", docs[0].Title)
 	}
 
-	docID := createResp.Data.Document.DocumentId
-	if docID != nil {
-		fmt.Printf("创建的文档 ID: %s\n", *docID)
-
-		// 现在写入内容到文档
-		// 注意：这里需要使用正确的 API 来写入内容
-		// 飞书文档 API 可能需要使用 Block 相关的 API
+	// 其他源文件仍然新建文档
+	if _, err := saveToFeishu(t, cfg, filepath.Join(dir, "redis.md"), "# Redis", SaveToFeishuInput{Title: "Redis"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Documents()); n != 2 {
+		t.Fatalf("不同源文件应创建新文档，实际 %d 个", n)
+	}
 
-		fmt.Printf("文档创建成功，可以在飞书中查看: %s\n", *docID)
+	// 文档在飞书中被删除后重新创建并更新映射
+	srv.DeleteDocument(docs[0].ID)
+	if _, err := saveToFeishu(t, cfg, source, "# 第三版", SaveToFeishuInput{Title: "Kafka"}); err != nil {
+		t.Fatal(err)
+	}
+	rec, ok, err := feishu.LookupDocument(cfg.DocsFile, source)
+	if err != nil || !ok || rec.DocumentID == docs[0].ID {
+		t.Fatalf("映射没有指向新文档: %+v %v %v", rec, ok, err)
 	}
 }
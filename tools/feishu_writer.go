@@ -4,66 +4,82 @@ import (
 	"context"
 	"fmt"
 
+	"eino_test/config"
+	"eino_test/feishu"
+
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
-	lark "github.com/larksuite/oapi-sdk-go/v3"
-	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
-	larkdocx "github.com/larksuite/oapi-sdk-go/v3/service/docx/v1"
 )
 
-// SaveToFeishuInput 保存到飞书文档的输入参数
+// SaveToFeishuInput 保存到飞书文档的输入参数，与 save_document 一样，要保存的内容直接从 session 读取
 type SaveToFeishuInput struct {
-	Content     string `json:"content" jsonschema_description:"要保存的文档内容（Markdown 格式）"`
 	Title       string `json:"title" jsonschema_description:"飞书文档标题"`
 	FolderToken string `json:"folder_token" jsonschema_description:"飞书文件夹 token（可选，如果不提供则使用默认值）"`
 }
 
-// NewSaveToFeishuTool 创建一个保存文档到飞书的工具
-func NewSaveToFeishuTool() (tool.BaseTool, error) {
+// NewSaveToFeishuTool 创建一个保存文档到飞书的工具，凭证和鉴权模式来自配置。
+// source 为改写的源文件路径，不为空时通过 cfg.DocsFile 记录对应的飞书文档，
+// 之后再改写同一源文件会替换已有文档的正文而不是新建文档
+func NewSaveToFeishuTool(cfg config.FeishuConfig, source string) (tool.BaseTool, error) {
+	client, err := feishu.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	track := source != "" && cfg.DocsFile != ""
+
 	return utils.InferTool(
 		"save_to_feishu",
-		"将改写后的文档内容保存到飞书文档中，支持 Markdown 格式",
+		"将 session 中当前的改写稿保存到飞书文档中，只需要提供标题，不需要传入文档内容；同一源文件再次保存时会更新已有文档",
 		func(ctx context.Context, input *SaveToFeishuInput) (string, error) {
-			// 使用默认的文件夹 token 如果没有提供
-			folderToken := input.FolderToken
-			if folderToken == "" {
-				folderToken = "HQjyfawL2lpgq9diKYwcTLl5nmY" // 默认文件夹 token
+			content, err := DocumentContent(ctx)
+			if err != nil {
+				return err.Error(), err
 			}
 
-			// 创建 Client
-			client := lark.NewClient("cli_a7a0822d4f71100d", "UerLooRg7uvYVTYO1eoUufr65EOTHuBd")
-
-			// 创建文档
-			createReq := larkdocx.NewCreateDocumentReqBuilder().
-				Body(larkdocx.NewCreateDocumentReqBodyBuilder().
-					FolderToken(folderToken).
-					Title(input.Title).
-					Build()).
-				Build()
-
-			createResp, err := client.Docx.V1.Document.Create(
-				ctx,
-				createReq,
-				larkcore.WithUserAccessToken("u-dbl.CVTu50WGLmzfWkcFeh4l5shRlgMPOaayFxw00JUy"),
-			)
+			if track {
+				rec, ok, err := feishu.LookupDocument(cfg.DocsFile, source)
+				if err != nil {
+					return fmt.Sprintf("读取飞书文档映射失败: %v", err), err
+				}
+				if ok {
+					blocks, err := client.ReplaceMarkdown(ctx, rec.DocumentID, input.Title, content)
+					switch {
+					case err == nil:
+						rec.Title = input.Title
+						if err := feishu.RecordDocument(cfg.DocsFile, rec); err != nil {
+							return fmt.Sprintf("更新飞书文档映射失败: %v", err), err
+						}
+						return fmt.Sprintf("已更新飞书文档\n标题: %s\n文档链接: %s\n写入内容块: %d",
+							input.Title, feishu.DocumentURL(rec.DocumentID), blocks), nil
+					case feishu.IsNotFound(err):
+						// 文档已被删除，重新创建
+					default:
+						return fmt.Sprintf("更新飞书文档失败: %v\n文档链接: %s", err, feishu.DocumentURL(rec.DocumentID)), err
+					}
+				}
+			}
 
+			// 没有提供文件夹 token 时使用配置中的默认文件夹
+			docID, err := client.CreateDocument(ctx, input.FolderToken, input.Title)
 			if err != nil {
 				return fmt.Sprintf("创建飞书文档失败: %v", err), err
 			}
 
-			if !createResp.Success() {
-				return fmt.Sprintf("创建飞书文档失败: %s", larkcore.Prettify(createResp.CodeError)), fmt.Errorf("create document failed")
+			// 写入正文，失败时文档已经创建，把链接一并返回方便排查
+			docLink := feishu.DocumentURL(docID)
+			blocks, err := client.AppendMarkdown(ctx, docID, content)
+			if err != nil {
+				return fmt.Sprintf("飞书文档已创建但写入内容失败: %v\n文档链接: %s", err, docLink), err
 			}
-
-			// 获取文档 ID
-			docID := createResp.Data.Document.DocumentId
-			if docID == nil {
-				return "创建飞书文档失败: 无法获取文档 ID", fmt.Errorf("document id is nil")
+			if track {
+				rec := feishu.DocRecord{Source: source, DocumentID: docID, Title: input.Title}
+				if err := feishu.RecordDocument(cfg.DocsFile, rec); err != nil {
+					return fmt.Sprintf("飞书文档已创建但记录映射失败: %v\n文档链接: %s", err, docLink), err
+				}
 			}
 
 			// 返回成功信息和文档链接
-			docLink := fmt.Sprintf("https://feishu.cn/docx/%s", *docID)
-			successMsg := fmt.Sprintf("文档已成功保存到飞书\n标题: %s\n文档链接: %s\n\n说明: 文档已创建，内容可以通过飞书 API 的 Block 接口添加，或在飞书中手动编辑", input.Title, docLink)
+			successMsg := fmt.Sprintf("文档已成功保存到飞书\n标题: %s\n文档链接: %s\n写入内容块: %d", input.Title, docLink, blocks)
 			return successMsg, nil
 		},
 	)
@@ -2,18 +2,81 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"eino_test/components/state"
+	"eino_test/config"
+	"eino_test/coverage"
+	"eino_test/postprocess"
+
+	"github.com/cloudwego/eino/adk"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
 )
 
-// SaveDocumentInput 保存文档的输入参数
+// SaveDocumentInput 保存文档的输入参数。要保存的内容直接从 session 读取，模型只需要给出文件名
 type SaveDocumentInput struct {
-	Content  string `json:"content" jsonschema_description:"要保存的文档内容"`
-	Filename string `json:"filename" jsonschema_description:"保存的文件名（不包含路径，默认保存到当前目录）"`
+	Filename string `json:"filename" jsonschema_description:"保存的文件名（不包含路径，默认保存到输出目录）"`
+}
+
+// ErrNoDocument session 中没有可以保存的改写稿
+var ErrNoDocument = errors.New("session 中没有改写稿，无法保存")
+
+// documentContentCtxKey WithDocumentContent 在 ctx 中使用的键
+type documentContentCtxKey struct{}
+
+// WithDocumentContent 返回带有待保存内容的 ctx。不在 Agent 中调用保存工具时（例如按章节改写后直接保存）
+// 没有 session，通过它传入要保存的内容
+func WithDocumentContent(ctx context.Context, content string) context.Context {
+	return context.WithValue(ctx, documentContentCtxKey{}, content)
+}
+
+// DocumentContent 返回保存工具要保存的内容：优先使用 session 中 SummaryAgent 写入的改写稿，
+// 保证保存的正是评审过的文本；没有 session 时使用 WithDocumentContent 传入的内容
+func DocumentContent(ctx context.Context) (string, error) {
+	content := state.Draft(ctx)
+	if content == "" {
+		content, _ = ctx.Value(documentContentCtxKey{}).(string)
+	}
+	if strings.TrimSpace(content) == "" {
+		return "", ErrNoDocument
+	}
+	return content, nil
+}
+
+// SavedDocumentAction save_document 保存成功后通过 AgentAction.CustomizedAction 发出的事件，
+// 调用方可以据此拿到实际写入的文件路径
+type SavedDocumentAction struct {
+	Path string
+}
+
+func init() {
+	// 事件会随 checkpoint 一起用 gob 序列化，自定义 Action 需要注册
+	schema.RegisterName[*SavedDocumentAction]("eino_test_saved_document_action")
+}
+
+// DocumentMeta 保存文档时写入 <文档名>.meta.json 的元数据，记录文档由哪个读者画像、
+// 哪些版本的提示词和模型生成，方便对比不同提示词版本的效果
+type DocumentMeta struct {
+	Source  string            `json:"source,omitempty"`
+	Persona string            `json:"persona,omitempty"`
+	Prompts []string          `json:"prompts,omitempty"`
+	Models  map[string]string `json:"models,omitempty"`
+	// Coverage 保存的文档对照源文档的内容覆盖检查结果，没有源文档时为空
+	Coverage *coverage.Report `json:"coverage,omitempty"`
+	SavedAt  time.Time        `json:"saved_at"`
+}
+
+// MetaPath 返回文档对应的元数据文件路径
+func MetaPath(docPath string) string {
+	return strings.TrimSuffix(docPath, filepath.Ext(docPath)) + ".meta.json"
 }
 
 // ReadDocumentInput 读取文档的输入参数
@@ -22,11 +85,21 @@ type ReadDocumentInput struct {
 }
 
 // NewSaveDocumentTool 创建一个保存文档到 markdown 文件的工具
-func NewSaveDocumentTool() (tool.BaseTool, error) {
+// outputDir: 文档保存目录，为空时保存到当前目录
+// source: 改写前的源文件路径，用于修正文档中的相对链接，可以为空
+// pipeline: 写入前依次执行的后处理步骤，为空时原样保存
+// meta: 写入文档旁 .meta.json 的元数据，为 nil 时不写
+// coverageCfg: 对照源文档检查内容覆盖，结果写入元数据，没有源文件或没有启用检查项时不检查
+func NewSaveDocumentTool(outputDir, source string, pipeline postprocess.Pipeline, meta *DocumentMeta, coverageCfg config.CoverageConfig) (tool.BaseTool, error) {
 	return utils.InferTool(
 		"save_document",
-		"将改写后的文档内容保存到 markdown 文件中，自动将 Mermaid 代码块转换为可渲染的图片 URL",
+		"将 session 中当前的改写稿保存到 markdown 文件中，只需要提供文件名，不需要传入文档内容。写入前会按配置做后处理（例如将 Mermaid 代码块转换为可渲染的图片）",
 		func(ctx context.Context, input *SaveDocumentInput) (string, error) {
+			content, err := DocumentContent(ctx)
+			if err != nil {
+				return err.Error(), err
+			}
+
 			// 如果没有指定文件名，使用默认名称
 			if input.Filename == "" {
 				input.Filename = fmt.Sprintf("改写文档_%s.md", time.Now().Format("20060102_150405"))
@@ -37,28 +110,82 @@ func NewSaveDocumentTool() (tool.BaseTool, error) {
 				input.Filename += ".md"
 			}
 
-			// 将 Mermaid 代码块转换为图片 URL
-			processedContent := ConvertMermaidToImageURL(input.Content)
+			// 只取文件名部分，避免模型给出的路径逃逸出输出目录
+			target := filepath.Join(outputDir, filepath.Base(input.Filename))
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Sprintf("创建输出目录失败: %v", err), err
+			}
+
+			// 覆盖检查使用后处理之前的内容，图表转成图片后不影响代码块的比较
+			var report *coverage.Report
+			if meta != nil {
+				report = checkCoverage(ctx, source, content, coverageCfg)
+			}
+
+			// 后处理：Mermaid 转图片、下载图片、插入目录、改写链接等
+			doc := &postprocess.Document{Content: content, Path: target, Source: source}
+			pipeline.Run(ctx, doc)
 
 			// 写入文件
-			err := os.WriteFile(input.Filename, []byte(processedContent), 0644)
-			if err != nil {
+			if err := os.WriteFile(target, []byte(doc.Content), 0644); err != nil {
 				return fmt.Sprintf("保存文档失败: %v", err), err
 			}
 
+			if meta != nil {
+				m := *meta
+				m.Source, m.Coverage, m.SavedAt = source, report, time.Now()
+				data, _ := json.MarshalIndent(&m, "", "  ")
+				if err := os.WriteFile(MetaPath(target), data, 0644); err != nil {
+					doc.Warnf("写入元数据失败: %v", err)
+				}
+			}
+
 			// 获取文件的绝对路径
-			absPath, err := os.Getwd()
+			absPath, err := filepath.Abs(target)
 			if err != nil {
-				absPath = "当前目录"
-			} else {
-				absPath = fmt.Sprintf("%s/%s", absPath, input.Filename)
+				absPath = target
 			}
 
-			return fmt.Sprintf("✓ 文档已成功保存到: %s\n\n说明: Mermaid 图表已自动转换为可渲染的图片 URL", absPath), nil
+			// 不在 ChatModelAgent 中调用时没有 State，忽略错误即可
+			_ = adk.SendToolGenAction(ctx, "save_document", &adk.AgentAction{
+				CustomizedAction: &SavedDocumentAction{Path: absPath},
+			})
+
+			var result strings.Builder
+			fmt.Fprintf(&result, "✓ 文档已成功保存到: %s", absPath)
+			if len(pipeline) > 0 {
+				fmt.Fprintf(&result, "\n\n说明: 已执行后处理 %s", strings.Join(pipeline.Names(), " → "))
+			}
+			if len(doc.Assets) > 0 {
+				fmt.Fprintf(&result, "，写入 %d 个资源文件", len(doc.Assets))
+			}
+			for _, w := range doc.Warnings {
+				fmt.Fprintf(&result, "\n⚠️ %s", w)
+			}
+			if report != nil && !report.OK() {
+				fmt.Fprintf(&result, "\n⚠️ 与源文档相比可能遗漏了内容:\n%s", report.Format())
+			}
+			return result.String(), nil
 		},
 	)
 }
 
+// checkCoverage 读取源文件并检查内容覆盖，检查失败时返回 nil，不影响保存
+func checkCoverage(ctx context.Context, source, content string, cfg config.CoverageConfig) *coverage.Report {
+	if source == "" || len(cfg.Checks) == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil
+	}
+	report, err := coverage.Check(ctx, string(data), content, cfg)
+	if err != nil {
+		return nil
+	}
+	return report
+}
+
 // NewReadDocumentTool 创建一个读取 markdown 文件的工具
 func NewReadDocumentTool() (tool.BaseTool, error) {
 	return utils.InferTool(
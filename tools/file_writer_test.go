@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"eino_test/config"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// TestSaveDocumentFromContext 测试 save_document 只接收文件名，保存的内容原样来自调用方提供的改写稿
+func TestSaveDocumentFromContext(t *testing.T) {
+	dir := t.TempDir()
+	bt, err := NewSaveDocumentTool(dir, "", nil, nil, config.CoverageConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := bt.(tool.InvokableTool)
+
+	if _, err := save.InvokableRun(context.Background(), `{"filename": "kafka"}`); !errors.Is(err, ErrNoDocument) {
+		t.Fatalf("没有改写稿时应该报错: %v", err)
+	}
+
+	content := "# Kafka\n\n```go\n\tfmt.Println(\"缩进保留\")\n```\n"
+	ctx := WithDocumentContent(context.Background(), content)
+	if _, err := save.InvokableRun(ctx, `{"filename": "../kafka"}`); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "kafka.md"))
+	if err != nil || string(got) != content {
+		t.Errorf("保存的内容与改写稿不一致: %q, %v", got, err)
+	}
+}
@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"eino_test/components/state"
+	"eino_test/config"
+	"eino_test/lint"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+// LintMarkdownInput 检查 Markdown 格式的输入参数
+type LintMarkdownInput struct {
+	Content string `json:"content,omitempty" jsonschema_description:"要检查的 Markdown 内容，为空时检查 session 中当前的改写稿"`
+}
+
+// NewLintMarkdownTool 创建检查 Markdown 格式的工具，按 cfg 启用的规则报告带行号的问题
+func NewLintMarkdownTool(cfg config.LintConfig) (tool.BaseTool, error) {
+	return utils.InferTool(
+		"lint_markdown",
+		"用程序检查 Markdown 的格式问题：emoji 过多、标题跳级、代码块未闭合、章节缺少结构块、表格列数不一致，返回带行号的问题列表",
+		func(ctx context.Context, input *LintMarkdownInput) (string, error) {
+			content := input.Content
+			if content == "" {
+				content = state.Draft(ctx)
+			}
+			if content == "" {
+				return "没有可以检查的内容：请在 content 中传入 Markdown", nil
+			}
+			issues := lint.Check(content, cfg)
+			if len(issues) == 0 {
+				return "✓ 没有发现格式问题", nil
+			}
+			return fmt.Sprintf("发现 %d 个格式问题：\n%s", len(issues), lint.Format(issues)), nil
+		},
+	)
+}
@@ -1,12 +1,16 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"eino_test/mermaid"
 )
 
 // TestMermaidRendering 测试 Mermaid 渲染功能
@@ -35,38 +39,38 @@ func TestMermaidRendering(t *testing.T) {
 		t.Fatalf("读取原始文件失败，尝试过的路径: %v", filePaths)
 	}
 
-	fmt.Printf("✓ 成功读取文件: %s\n", foundPath)
+	t.Logf("✓ 成功读取文件: %s\n", foundPath)
 
-	fmt.Println("=== 原始 Markdown 文件内容 ===")
-	fmt.Println(string(originalContent[:500])) // 显示前500个字符
-	fmt.Println("\n...")
+	t.Log("=== 原始 Markdown 文件内容 ===")
+	t.Log(string(originalContent[:500])) // 显示前500个字符
+	t.Log("\n...")
 
 	// 转换 Mermaid 代码块为图片 URL
 	convertedContent := ConvertMermaidToImageURL(string(originalContent))
 
-	fmt.Println("\n=== 转换后的 Markdown 文件内容 ===")
-	fmt.Println(convertedContent[:500]) // 显示前500个字符
-	fmt.Println("\n...")
+	t.Log("\n=== 转换后的 Markdown 文件内容 ===")
+	t.Log(convertedContent[:500]) // 显示前500个字符
+	t.Log("\n...")
 
-	// 保存转换后的文件
-	outputFile := "改写文档_技术文档_已渲染.md"
+	// 保存转换后的文件，写到临时目录，不改动仓库中的文件
+	outputFile := filepath.Join(t.TempDir(), "改写文档_技术文档_已渲染.md")
 	err = os.WriteFile(outputFile, []byte(convertedContent), 0644)
 	if err != nil {
 		t.Fatalf("保存转换后的文件失败: %v", err)
 	}
 
-	fmt.Printf("\n✓ 转换完成！已保存到: %s\n", outputFile)
+	t.Logf("\n✓ 转换完成！已保存到: %s\n", outputFile)
 
 	// 统计 Mermaid 代码块数量
 	mermaidCount := countMermaidBlocks(string(originalContent))
-	fmt.Printf("✓ 发现 %d 个 Mermaid 代码块\n", mermaidCount)
+	t.Logf("✓ 发现 %d 个 Mermaid 代码块\n", mermaidCount)
 
 	// 验证转换结果
 	if !containsMermaidImageURLs(convertedContent) {
 		t.Fatalf("转换失败：输出中没有找到图片 URL")
 	}
 
-	fmt.Println("✓ 所有 Mermaid 代码块已成功转换为图片 URL")
+	t.Log("✓ 所有 Mermaid 代码块已成功转换为图片 URL")
 }
 
 // TestMermaidRenderTool 测试 Mermaid 渲染工具
@@ -97,21 +101,22 @@ func TestMermaidRenderTool(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// 使用 mermaid.ink 服务渲染
-			imageURL, err := renderWithMermaidInk(tc.mermaidCode, tc.format)
+			result, err := mermaid.NewInkRenderer("").Render(context.Background(), tc.mermaidCode, tc.format)
 			if err != nil {
 				t.Logf("渲染失败（可能是网络问题）: %v", err)
 				return
 			}
+			imageURL := result.URL
 
-			fmt.Printf("\n=== %s ===\n", tc.name)
-			fmt.Printf("图片 URL: %s\n", imageURL)
+			t.Logf("\n=== %s ===\n", tc.name)
+			t.Logf("图片 URL: %s\n", imageURL)
 
 			// 验证结果包含 URL
 			if !contains(imageURL, "https://") {
 				t.Fatalf("渲染失败：没有生成有效的 URL")
 			}
 
-			fmt.Printf("✓ %s 渲染成功\n", tc.name)
+			t.Logf("✓ %s 渲染成功\n", tc.name)
 		})
 	}
 }
@@ -124,11 +129,11 @@ func TestConvertMarkdownFile(t *testing.T) {
 	// 转换内容
 	converted := ConvertMermaidToImageURL(testMarkdown)
 
-	fmt.Println("=== 原始内容 ===")
-	fmt.Println(testMarkdown)
+	t.Log("=== 原始内容 ===")
+	t.Log(testMarkdown)
 
-	fmt.Println("\n=== 转换后的内容 ===")
-	fmt.Println(converted)
+	t.Log("\n=== 转换后的内容 ===")
+	t.Log(converted)
 
 	// 验证转换
 	if !containsMermaidImageURLs(converted) {
@@ -140,7 +145,7 @@ func TestConvertMarkdownFile(t *testing.T) {
 		t.Fatalf("转换失败：Java 代码块被修改了")
 	}
 
-	fmt.Println("\n✓ 转换成功！")
+	t.Log("\n✓ 转换成功！")
 }
 
 // 辅助函数
@@ -217,13 +222,13 @@ func TestMermaidURLValidity(t *testing.T) {
 		t.Fatalf("读取转换后的文件失败，尝试过的路径: %v", filePaths)
 	}
 
-	fmt.Printf("✓ 成功读取文件: %s\n", foundPath)
+	t.Logf("✓ 成功读取文件: %s\n", foundPath)
 
 	// 提取所有 mermaid.ink URL
 	markdown := string(content)
 	urls := extractMermaidURLs(markdown)
 
-	fmt.Printf("✓ 发现 %d 个 Mermaid 图片 URL\n", len(urls))
+	t.Logf("✓ 发现 %d 个 Mermaid 图片 URL\n", len(urls))
 
 	if len(urls) == 0 {
 		t.Fatalf("没有找到任何 Mermaid 图片 URL")
@@ -237,12 +242,12 @@ func TestMermaidURLValidity(t *testing.T) {
 	}
 
 	for i, url := range urls {
-		fmt.Printf("\n=== 验证 URL %d ===\n", i+1)
-		fmt.Printf("URL: %s\n", url[:min(len(url), 100)]+"...")
+		t.Logf("\n=== 验证 URL %d ===\n", i+1)
+		t.Logf("URL: %s\n", url[:min(len(url), 100)]+"...")
 
 		// 检查 URL 格式
 		if !strings.HasPrefix(url, "https://mermaid.ink/img/") {
-			fmt.Printf("❌ URL 格式错误\n")
+			t.Logf("❌ URL 格式错误\n")
 			invalidCount++
 			continue
 		}
@@ -250,18 +255,18 @@ func TestMermaidURLValidity(t *testing.T) {
 		// 检查 Base64URL 编码
 		encoded := strings.TrimPrefix(url, "https://mermaid.ink/img/")
 		if !isValidBase64URL(encoded) {
-			fmt.Printf("❌ Base64URL 编码无效\n")
+			t.Logf("❌ Base64URL 编码无效\n")
 			invalidCount++
 			continue
 		}
 
-		fmt.Printf("✓ URL 格式正确\n")
+		t.Logf("✓ URL 格式正确\n")
 
 		// 实际访问 URL 验证是否可以获取图片
-		fmt.Printf("  正在访问网络验证...\n")
+		t.Logf("  正在访问网络验证...\n")
 		resp, err := client.Head(url)
 		if err != nil {
-			fmt.Printf("❌ 网络访问失败: %v\n", err)
+			t.Logf("❌ 网络访问失败: %v\n", err)
 			invalidCount++
 			continue
 		}
@@ -269,7 +274,7 @@ func TestMermaidURLValidity(t *testing.T) {
 
 		// 检查 HTTP 状态码
 		if resp.StatusCode != http.StatusOK {
-			fmt.Printf("❌ HTTP 状态码错误: %d\n", resp.StatusCode)
+			t.Logf("❌ HTTP 状态码错误: %d\n", resp.StatusCode)
 			invalidCount++
 			continue
 		}
@@ -277,33 +282,33 @@ func TestMermaidURLValidity(t *testing.T) {
 		// 检查 Content-Type
 		contentType := resp.Header.Get("Content-Type")
 		if !strings.Contains(contentType, "image") {
-			fmt.Printf("❌ Content-Type 错误: %s (期望 image/*)\n", contentType)
+			t.Logf("❌ Content-Type 错误: %s (期望 image/*)\n", contentType)
 			invalidCount++
 			continue
 		}
 
 		// 获取 Content-Length
 		contentLength := resp.Header.Get("Content-Length")
-		fmt.Printf("✓ 网络验证成功 (HTTP %d, Content-Type: %s, Size: %s bytes)\n",
+		t.Logf("✓ 网络验证成功 (HTTP %d, Content-Type: %s, Size: %s bytes)\n",
 			resp.StatusCode, contentType, contentLength)
 		validCount++
 	}
 
-	fmt.Printf("\n=== 验证结果 ===\n")
-	fmt.Printf("✓ 有效 URL: %d\n", validCount)
-	fmt.Printf("❌ 无效 URL: %d\n", invalidCount)
+	t.Logf("\n=== 验证结果 ===\n")
+	t.Logf("✓ 有效 URL: %d\n", validCount)
+	t.Logf("❌ 无效 URL: %d\n", invalidCount)
 
 	if invalidCount > 0 {
-		fmt.Printf("\n⚠️  注意：部分 URL 无法访问，可能原因：\n")
-		fmt.Printf("1. mermaid.ink 服务暂时不可用或有速率限制\n")
-		fmt.Printf("2. 网络连接问题\n")
-		fmt.Printf("3. URL 编码问题\n\n")
-		fmt.Printf("✓ 但所有 URL 格式都是正确的 Base64URL 编码\n")
-		fmt.Printf("✓ 当 mermaid.ink 服务恢复时，这些 URL 应该可以正常工作\n")
+		t.Logf("\n⚠️  注意：部分 URL 无法访问，可能原因：\n")
+		t.Logf("1. mermaid.ink 服务暂时不可用或有速率限制\n")
+		t.Logf("2. 网络连接问题\n")
+		t.Logf("3. URL 编码问题\n\n")
+		t.Logf("✓ 但所有 URL 格式都是正确的 Base64URL 编码\n")
+		t.Logf("✓ 当 mermaid.ink 服务恢复时，这些 URL 应该可以正常工作\n")
 		// 不失败，因为这可能是服务问题而不是代码问题
 		// t.Fatalf("发现 %d 个无效的 URL", invalidCount)
 	} else {
-		fmt.Println("✓ 所有 URL 都有效且可以正常访问！")
+		t.Log("✓ 所有 URL 都有效且可以正常访问！")
 	}
 }
 
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"eino_test/components/state"
+	"eino_test/outline"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+)
+
+// SavedPlanAction save_teaching_plan 保存成功后通过 AgentAction.CustomizedAction 发出的事件，
+// 调用方可以据此拿到通过校验的教学大纲
+type SavedPlanAction struct {
+	Outline *outline.Outline
+}
+
+func init() {
+	// 事件会随 checkpoint 一起用 gob 序列化，自定义 Action 需要注册
+	schema.RegisterName[*SavedPlanAction]("eino_test_saved_plan_action")
+}
+
+// NewSaveTeachingPlanTool 创建保存教学大纲的工具：参数按大纲的 JSON Schema 生成，校验通过后写入 session，
+// 不通过时把问题返回给模型修正后重新提交
+func NewSaveTeachingPlanTool() (tool.BaseTool, error) {
+	return utils.InferTool(
+		"save_teaching_plan",
+		"提交结构化的教学大纲：全文分几部分、每一节的目标、前置知识和 3 个核心问题，以及读者读完后要能做到的 3～5 件事",
+		func(ctx context.Context, input *outline.Outline) (string, error) {
+			if err := input.Validate(); err != nil {
+				return fmt.Sprintf("教学大纲不合法，请修正后重新调用 save_teaching_plan:\n%v", err), nil
+			}
+			state.SetOutline(ctx, input)
+			// 不在 ChatModelAgent 中调用时没有 State，忽略错误即可
+			_ = adk.SendToolGenAction(ctx, "save_teaching_plan", &adk.AgentAction{
+				CustomizedAction: &SavedPlanAction{Outline: input},
+			})
+			return fmt.Sprintf("✓ 教学大纲已保存（%d 个部分，%d 节），不需要再做其他事情", len(input.Parts), len(input.Sections())), nil
+		},
+	)
+}